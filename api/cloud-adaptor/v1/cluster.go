@@ -20,9 +20,11 @@ package v1
 
 import (
 	"encoding/json"
+	"time"
 
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -70,12 +72,34 @@ type KubernetesClustersResponse struct {
 type CheckSSHReq struct {
 	Host string `json:"host"`
 	Port uint   `json:"port"`
+	// User the user logged in as, the default is docker
+	User string `json:"user,omitempty"`
+	// Bastion the host is dialed through the bastion, or the bastion of the cluster ClusterID if it is not set.
+	Bastion   *v1alpha1.SSHBastion `json:"bastion,omitempty"`
+	ClusterID string               `json:"clusterID,omitempty"`
 }
 
 type CheckSSHRes struct {
 	Status bool `json:"status"`
 }
 
+// CheckSSHBatchReq check the ssh of the nodes
+//
+//swagger:model CheckSSHBatchReq
+type CheckSSHBatchReq struct {
+	Nodes v1alpha1.NodeList `json:"nodes" binding:"required,min=1"`
+	// Bastion the nodes are dialed through the bastion, or the bastion of the cluster ClusterID if it is not set.
+	Bastion   *v1alpha1.SSHBastion `json:"bastion,omitempty"`
+	ClusterID string               `json:"clusterID,omitempty"`
+}
+
+// CheckSSHBatchRes the results of the nodes in the same order as the request
+//
+//swagger:model CheckSSHBatchRes
+type CheckSSHBatchRes struct {
+	Nodes []*ssh.CheckResult `json:"nodes"`
+}
+
 // AccessKeyResponse access key
 //
 //swagger:model AccessKeyResponse
@@ -95,6 +119,10 @@ type CreateKubernetesReq struct {
 	EIP                []string `json:"eip,omitempty"`
 	// rke
 	EncodedRKEConfig string `json:"encodedRKEConfig"`
+	// SkipPreflight installs the cluster without the preflight checks of the nodes.
+	SkipPreflight bool `json:"skipPreflight,omitempty"`
+	// Bastion the nodes are reached through the bastion, it is validated before installing.
+	Bastion *v1alpha1.SSHBastion `json:"bastion,omitempty"`
 	// custom
 	KubeConfig string `json:"kubeconfig,omitempty"`
 }
@@ -111,6 +139,10 @@ type UpdateKubernetesReq struct {
 	ETCDNodeNum        int    `json:"etcdNodeNum,omitempty"`
 	InstanceType       string `json:"instanceType,omitempty"`
 	EncodedRKEConfig   string `json:"encodedRKEConfig"`
+	// NodePoolID the node pool scaled to WorkerNodeNum workers, a new node pool of
+	// InstanceType is created if it is empty and InstanceType is specified.
+	NodePoolID     string                   `json:"nodePoolID,omitempty"`
+	WorkerDataDisk *v1alpha1.WorkerDataDisk `json:"workerDataDisk,omitempty"`
 }
 
 // CreateKubernetesRes create kubernetes res
@@ -143,6 +175,95 @@ type DeleteKubernetesClusterReq struct {
 	ProviderName string `form:"provider_name" binding:"required"`
 }
 
+// ReleaseResourcesReq release the cloud resources created for rainbond region
+//
+//swagger:model ReleaseResourcesReq
+type ReleaseResourcesReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+}
+
+// UpgradeKubernetesReq upgrade the kubernetes version of the cluster
+//
+//swagger:model UpgradeKubernetesReq
+type UpgradeKubernetesReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+	Version      string `json:"version" binding:"required"`
+}
+
+// RotateCertificatesReq rotate the certificates of the cluster
+//
+//swagger:model RotateCertificatesReq
+type RotateCertificatesReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+	// Service the certificates of the service are rotated, all services if it is empty.
+	Service string `json:"service" binding:"omitempty,oneof=etcd kubelet kube-apiserver kube-proxy kube-scheduler kube-controller-manager"`
+	// RotateCA rotates the CA certificate and all the certificates signed by it.
+	RotateCA bool `json:"rotateCA"`
+}
+
+// SecretsEncryptionReq enable the secrets encryption or rotate its key
+//
+//swagger:model SecretsEncryptionReq
+type SecretsEncryptionReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+}
+
+// SaveEtcdSnapshotReq save an etcd snapshot of the cluster
+//
+//swagger:model SaveEtcdSnapshotReq
+type SaveEtcdSnapshotReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+	// Name the name of the snapshot, it is generated with the current time if it is empty.
+	Name string `json:"name"`
+}
+
+// SetEtcdSnapshotPolicyReq configure the recurring etcd snapshots of the cluster
+//
+//swagger:model SetEtcdSnapshotPolicyReq
+type SetEtcdSnapshotPolicyReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+	Enabled      bool   `json:"enabled"`
+	// IntervalHours the interval between two snapshots, 12 hours if it is empty.
+	IntervalHours int `json:"intervalHours" binding:"omitempty,min=1"`
+	// Retention the number of the recurring snapshots kept on the etcd nodes, 6 if it is empty.
+	Retention int `json:"retention" binding:"omitempty,min=1"`
+}
+
+// RestoreEtcdSnapshotReq restore the cluster from an etcd snapshot
+//
+//swagger:model RestoreEtcdSnapshotReq
+type RestoreEtcdSnapshotReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+}
+
+// PreflightCheckReq check the nodes before installing the cluster
+//
+//swagger:model PreflightCheckReq
+type PreflightCheckReq struct {
+	ProviderName     string `json:"providerName" binding:"required"`
+	EncodedRKEConfig string `json:"encodedRKEConfig" binding:"required"`
+	// Bastion the nodes are checked through the bastion if it is set
+	Bastion *v1alpha1.SSHBastion `json:"bastion,omitempty"`
+}
+
+// RemoveKubernetesNodesReq drain the nodes and remove them from the cluster
+//
+//swagger:model RemoveKubernetesNodesReq
+type RemoveKubernetesNodesReq struct {
+	ProviderName string `json:"providerName" binding:"required"`
+	// Nodes the addresses of the nodes to remove
+	Nodes []string `json:"nodes" binding:"required,min=1,dive,required"`
+}
+
+// RotateSSHKeyReq rotate the ssh key of the enterprise
+//
+//swagger:model RotateSSHKeyReq
+type RotateSSHKeyReq struct {
+	// Type the type of the new key, rsa or ed25519, the default is ed25519
+	Type string `json:"type" binding:"omitempty,oneof=rsa ed25519"`
+}
+
 // GetCreateKubernetesClusterTaskRes create kubernetes res
 //
 //swagger:model GetCreateKubernetesClusterTaskRes
@@ -171,6 +292,8 @@ type InitRainbondRegionReq struct {
 	Provider  string `json:"providerName" binding:"required"`
 	ClusterID string `json:"clusterID" binding:"required"`
 	Retry     bool   `json:"retry"`
+	// Resume the last failed task from the step that failed
+	Resume bool `json:"resume"`
 }
 
 // InitRainbondTaskRes init rainbond region response
@@ -216,12 +339,22 @@ type UpdateInitRainbondTaskStatusReq struct {
 	Status string `json:"status" binding:"required"`
 }
 
+// InitNodeCmdReq the options of the node initialisation script
+//
+//swagger:model InitNodeCmdReq
+type InitNodeCmdReq struct {
+	// RegistryMirror the registry mirror docker is configured with, the builtin mirrors are used if it is empty.
+	RegistryMirror string `form:"registryMirror" binding:"omitempty,url"`
+}
+
 // InitNodeCmdRes init node cmd
 //
 //swagger:model InitNodeCmdRes
 type InitNodeCmdRes struct {
 	Cmd       string `json:"cmd"`
 	IsOffline bool   `json:"isOffline"`
+	// ExpiredAt the link in the command can be used once before it expires
+	ExpiredAt *time.Time `json:"expiredAt,omitempty"`
 }
 
 // GetLogContentRes create kubernetes cluster log
@@ -231,6 +364,46 @@ type GetLogContentRes struct {
 	Content string `json:"content"`
 }
 
+// ClusterLog the install log of the rke cluster, the rotated logs are
+// named create.log.<RFC3339> by the time of the next expansion.
+type ClusterLog struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Current is true for the log of the latest install or expansion
+	Current bool `json:"current"`
+}
+
+// ClusterLogListRes the install logs of the rke cluster
+//
+//swagger:model ClusterLogListRes
+type ClusterLogListRes struct {
+	Logs []*ClusterLog `json:"logs"`
+}
+
+// GetClusterLogReq read the install log by byte offset
+//
+//swagger:model GetClusterLogReq
+type GetClusterLogReq struct {
+	Offset int64 `form:"offset"`
+	// the max bytes to read, default 64KiB
+	Limit int64 `form:"limit"`
+	// Follow tails the log as server-sent events while the cluster is installing
+	Follow bool `form:"follow"`
+}
+
+// ClusterLogContentRes a chunk of the install log
+//
+//swagger:model ClusterLogContentRes
+type ClusterLogContentRes struct {
+	Name       string `json:"name"`
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"nextOffset"`
+	Size       int64  `json:"size"`
+	Content    string `json:"content"`
+	EOF        bool   `json:"eof"`
+}
+
 // GetKubeConfigRes get kubernetes cluster kubeconfig file
 //
 //swagger:model GetKubeConfigRes
@@ -256,6 +429,86 @@ type Message struct {
 	StepType string `json:"type"`
 	Message  string `json:"message"`
 	Status   string `json:"status"`
+	// Resource the cloud resource created or released, the message with resource is recorded but not saved as event.
+	Resource *v1alpha1.CloudResource `json:"resource,omitempty"`
+}
+
+// The step types of the task events.
+const (
+	StepInit                           = "Init"
+	StepCheckCluster                   = "CheckCluster"
+	StepAllocateResource               = "AllocateResource"
+	StepSelectZone                     = "SelectZone"
+	StepCreateVPC                      = "CreateVPC"
+	StepCreateVSwitch                  = "CreateVSWitch"
+	StepCreateCluster                  = "CreateCluster"
+	StepInitClusterConfig              = "InitClusterConfig"
+	StepInstallKubernetes              = "InstallKubernetes"
+	StepUpdateKubernetes               = "UpdateKubernetes"
+	StepCreateRDS                      = "CreateRDS"
+	StepCreateNAS                      = "CreateNAS"
+	StepCreateNASMount                 = "CreateNASMount"
+	StepCreateLoadBalancer             = "CreateLoadBalancer"
+	StepBoundLoadBalancer              = "BoundLoadBalancer"
+	StepSetSecurityGroup               = "SetSecurityGroup"
+	StepInitRainbondRegionOperator     = "InitRainbondRegionOperator"
+	StepInitRainbondRegionImageHub     = "InitRainbondRegionImageHub"
+	StepInitRainbondRegionPackage      = "InitRainbondRegionPackage"
+	StepInitRainbondRegionRegionConfig = "InitRainbondRegionRegionConfig"
+	StepInitRainbondRegion             = "InitRainbondRegion"
+	StepCreateTask                     = "CreateTask"
+	StepTaskInterrupted                = "TaskInterrupted"
+	StepCancelled                      = "Cancelled"
+	StepReleaseResources               = "ReleaseResources"
+	StepDeleteLoadBalancerListener     = "DeleteLoadBalancerListener"
+	StepDeleteVServerGroup             = "DeleteVServerGroup"
+	StepDeleteLoadBalancer             = "DeleteLoadBalancer"
+	StepDeleteNASMount                 = "DeleteNASMount"
+	StepDeleteNAS                      = "DeleteNAS"
+	StepDeleteRDS                      = "DeleteRDS"
+	StepUpgradeKubernetes              = "UpgradeKubernetes"
+	StepRotateCertificates             = "RotateCertificates"
+	StepEnableSecretsEncryption        = "EnableSecretsEncryption"
+	StepRotateEncryptionKey            = "RotateEncryptionKey"
+	StepDeployEncryptionKey            = "DeployEncryptionKey"
+	StepRewriteSecrets                 = "RewriteSecrets"
+	StepRemoveEncryptionKey            = "RemoveEncryptionKey"
+	StepSaveEtcdSnapshot               = "SaveEtcdSnapshot"
+	StepSetEtcdSnapshotPolicy          = "SetEtcdSnapshotPolicy"
+	StepRestoreEtcdSnapshot            = "RestoreEtcdSnapshot"
+	StepRemoveNodes                    = "RemoveNodes"
+	StepRotateSSHKey                   = "RotateSSHKey"
+	StepPreflightCheck                 = "PreflightCheck"
+	// StepDrainNode the events of the nodes are saved with the step type v1alpha1.NodeStepType(StepDrainNode, node)
+	StepDrainNode = "DrainNode"
+	// StepRemoveNode the events of the nodes are saved with the step type v1alpha1.NodeStepType(StepRemoveNode, node)
+	StepRemoveNode = "RemoveNode"
+	// StepUpgradeNode the events of the nodes are saved with the step type v1alpha1.NodeStepType(StepUpgradeNode, node)
+	StepUpgradeNode = "UpgradeNode"
+	// StepPushSSHKey the events of the nodes are saved with the step type v1alpha1.NodeStepType(StepPushSSHKey, node)
+	StepPushSSHKey = "PushSSHKey"
+	// StepRevokeSSHKey the events of the nodes are saved with the step type v1alpha1.NodeStepType(StepRevokeSSHKey, node)
+	StepRevokeSSHKey = "RevokeSSHKey"
+	// StepPreflightNode the events of the nodes are saved with the step type v1alpha1.NodeStepType(StepPreflightNode, node)
+	StepPreflightNode = "PreflightNode"
+	// StepRecordResource the message reports a cloud resource, it is not an event
+	StepRecordResource = "RecordResource"
+)
+
+// TaskStep the step of the task plan
+type TaskStep struct {
+	StepType string `json:"type"`
+	Message  string `json:"message"`
+	// pending, start, success, failure or cancelled
+	Status string `json:"status"`
+}
+
+// TaskPlanRes the steps of the task
+//
+//swagger:model TaskPlanRes
+type TaskPlanRes struct {
+	TaskID string      `json:"taskID"`
+	Steps  []*TaskStep `json:"steps"`
 }
 
 // SetRainbondClusterConfigReq -
@@ -0,0 +1,51 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package v1
+
+// Provider the metadata of a cloud adaptor
+//
+//swagger:model Provider
+type Provider struct {
+	Name string `json:"name"`
+	// NeedCredentials is true if the access key of the enterprise is required
+	NeedCredentials bool `json:"needCredentials"`
+	// Interfaces are the optional interfaces implemented by the adaptor, such as CloudAdaptor
+	Interfaces []string `json:"interfaces"`
+	// Capabilities are the actions supported by the adaptor, such as createCluster, expandNodes
+	Capabilities []string `json:"capabilities"`
+}
+
+// ProviderCapabilitiesRes the capabilities of the provider for the enterprise
+//
+//swagger:model ProviderCapabilitiesRes
+type ProviderCapabilitiesRes struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+	// NeedCredentials is true if the access key of the enterprise is required
+	NeedCredentials bool `json:"needCredentials"`
+	// AccessKeyConfigured is true if the enterprise has configured the access key of the provider
+	AccessKeyConfigured bool `json:"accessKeyConfigured"`
+}
+
+// ProviderListRes -
+//
+//swagger:model ProviderListRes
+type ProviderListRes struct {
+	Providers []*Provider `json:"providers"`
+}
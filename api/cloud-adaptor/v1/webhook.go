@@ -0,0 +1,88 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package v1
+
+import (
+	"time"
+
+	"goodrain.com/cloud-adaptor/internal/model"
+)
+
+// The events of the webhook payload
+const (
+	WebhookEventTaskSuccess    = "task.success"
+	WebhookEventTaskFailure    = "task.failure"
+	WebhookEventTaskCancelled  = "task.cancelled"
+	WebhookEventClusterDeleted = "cluster.deleted"
+)
+
+// CreateWebhookReq register a webhook
+//
+//swagger:model CreateWebhookReq
+type CreateWebhookReq struct {
+	URL string `json:"url" binding:"required"`
+	// Secret signs the payload, it is generated if empty
+	Secret string `json:"secret"`
+}
+
+// WebhookRes the webhook, the secret is only returned when it is created
+//
+//swagger:model WebhookRes
+type WebhookRes struct {
+	model.Webhook
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookListRes the webhooks of the enterprise
+//
+//swagger:model WebhookListRes
+type WebhookListRes struct {
+	Webhooks []*model.Webhook `json:"webhooks"`
+}
+
+// ListWebhookDeliveriesReq list the deliveries of the webhook
+//
+//swagger:model ListWebhookDeliveriesReq
+type ListWebhookDeliveriesReq struct {
+	Page     int `form:"page"`
+	PageSize int `form:"pageSize"`
+}
+
+// WebhookDeliveryListRes the deliveries of the webhook
+//
+//swagger:model WebhookDeliveryListRes
+type WebhookDeliveryListRes struct {
+	Deliveries []*model.WebhookDelivery `json:"deliveries"`
+	Total      int64                    `json:"total"`
+}
+
+// WebhookPayload the body posted to the webhook, it is signed by the
+// X-Cloud-Adaptor-Signature header: sha256=hex(HMAC-SHA256(secret, body)).
+type WebhookPayload struct {
+	DeliveryID   string    `json:"deliveryID"`
+	Event        string    `json:"event"`
+	EnterpriseID string    `json:"eid"`
+	ClusterID    string    `json:"clusterID,omitempty"`
+	ProviderName string    `json:"providerName,omitempty"`
+	TaskID       string    `json:"taskID,omitempty"`
+	TaskType     string    `json:"taskType,omitempty"`
+	StepType     string    `json:"stepType,omitempty"`
+	Message      string    `json:"message,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
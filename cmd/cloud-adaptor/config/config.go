@@ -37,6 +37,9 @@ type Config struct {
 	DB        *DB
 	NSQConfig *NSQConfig
 	Helm      *Helm
+
+	// AdvertiseURL the url the nodes reach cloud adaptor with, such as http://192.168.1.1:8080
+	AdvertiseURL string
 }
 
 //NSQConfig config
@@ -108,6 +111,7 @@ func GetDefaultConfig(ctx *cli.Context) *Config {
 			RepoFile:  parseByEnvAndCtx(ctx, "helm-repo-file", "HELM_REPO_FILE"),
 			RepoCache: parseByEnvAndCtx(ctx, "helm-cache", "HELM_CACHE"),
 		},
+		AdvertiseURL: parseByEnvAndCtx(ctx, "advertise-url", "ADVERTISE_URL"),
 	}
 }
 
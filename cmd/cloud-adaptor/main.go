@@ -31,8 +31,9 @@ import (
 	"goodrain.com/cloud-adaptor/internal/datastore"
 	"goodrain.com/cloud-adaptor/internal/handler"
 	"goodrain.com/cloud-adaptor/internal/nsqc"
+	"goodrain.com/cloud-adaptor/internal/repo"
 	"goodrain.com/cloud-adaptor/internal/task"
-	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/internal/usecase"
 
 	// Import all dependent packages in main.go for swag to generate doc.
 	// More detail: https://github.com/swaggo/swag/issues/817#issuecomment-730895033
@@ -102,6 +103,11 @@ func main() {
 				Usage:   "daemon server listen address",
 				EnvVars: []string{"LISTEN"},
 			},
+			&cli.StringFlag{
+				Name:    "advertise-url",
+				Usage:   "the url the nodes download the init script from, such as http://192.168.1.1:8080, it is required to initialise the nodes online",
+				EnvVars: []string{"ADVERTISE_URL"},
+			},
 		}, dbInfoFlag...),
 		Action: run,
 	}
@@ -125,11 +131,7 @@ func run(c *cli.Context) error {
 		return err
 	}
 
-	createChan := make(chan types.KubernetesConfigMessage, 10)
-	initChan := make(chan types.InitRainbondConfigMessage, 10)
-	updateChan := make(chan types.UpdateKubernetesConfigMessage, 10)
-
-	engine, err := initApp(ctx, db, config.C, createChan, initChan, updateChan)
+	engine, err := initApp(ctx, db, config.C)
 	if err != nil {
 		return err
 	}
@@ -149,17 +151,20 @@ func run(c *cli.Context) error {
 
 func newApp(ctx context.Context,
 	router *handler.Router,
-	createQueue chan types.KubernetesConfigMessage,
-	initQueue chan types.InitRainbondConfigMessage,
-	updateQueue chan types.UpdateKubernetesConfigMessage,
+	taskMessageRepo repo.TaskMessageRepository,
+	clusterUsecase *usecase.ClusterUsecase,
+	webhookUsecase *usecase.WebhookUsecase,
 	createHandler task.CreateKubernetesTaskHandler,
 	initHandler task.CloudInitTaskHandler,
-	cloudUpdateTaskHandler task.UpdateKubernetesTaskHandler) *gin.Engine {
+	cloudUpdateTaskHandler task.UpdateKubernetesTaskHandler,
+	operationTaskHandler task.OperationTaskHandler) *gin.Engine {
 	engine := router.NewRouter()
 	engine.Use(gin.Recovery())
 
-	msgConsumer := nsqc.NewTaskChannelConsumer(ctx, createQueue, initQueue, updateQueue, createHandler, initHandler, cloudUpdateTaskHandler)
+	msgConsumer := nsqc.NewTaskDBConsumer(ctx, taskMessageRepo, clusterUsecase, createHandler, initHandler, cloudUpdateTaskHandler, operationTaskHandler)
 	go msgConsumer.Start()
+	go webhookUsecase.Start(ctx)
+	go clusterUsecase.StartHealthReconciler(ctx)
 
 	return engine
 }
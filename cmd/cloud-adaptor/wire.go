@@ -34,17 +34,13 @@ import (
 	"goodrain.com/cloud-adaptor/internal/repo"
 	"goodrain.com/cloud-adaptor/internal/repo/dao"
 	"goodrain.com/cloud-adaptor/internal/task"
-	"goodrain.com/cloud-adaptor/internal/types"
 	"gorm.io/gorm"
 )
 
 // initApp init the application.
 func initApp(context.Context,
 	*gorm.DB,
-	*config.Config,
-	chan types.KubernetesConfigMessage,
-	chan types.InitRainbondConfigMessage,
-	chan types.UpdateKubernetesConfigMessage) (*gin.Engine, error) {
+	*config.Config) (*gin.Engine, error) {
 	panic(wire.Build(handler.ProviderSet, usecase.ProviderSet, repo.ProviderSet, task.ProviderSet,
 		nsqc.ProviderSet, dao.ProviderSet, middleware.ProviderSet, newApp))
 }
@@ -16,7 +16,6 @@ import (
 	"goodrain.com/cloud-adaptor/internal/repo/appstore"
 	"goodrain.com/cloud-adaptor/internal/repo/dao"
 	"goodrain.com/cloud-adaptor/internal/task"
-	"goodrain.com/cloud-adaptor/internal/types"
 	"goodrain.com/cloud-adaptor/internal/usecase"
 	"gorm.io/gorm"
 )
@@ -36,7 +35,7 @@ import (
 // Injectors from wire.go:
 
 // initApp init the application.
-func initApp(contextContext context.Context, db *gorm.DB, configConfig *config.Config, arg chan types.KubernetesConfigMessage, arg2 chan types.InitRainbondConfigMessage, arg3 chan types.UpdateKubernetesConfigMessage) (*gin.Engine, error) {
+func initApp(contextContext context.Context, db *gorm.DB, configConfig *config.Config) (*gin.Engine, error) {
 	appStoreDao := dao.NewAppStoreDao(db)
 	appTemplater := appstore.NewAppTemplater()
 	storer := appstore.NewStorer(appTemplater)
@@ -44,14 +43,51 @@ func initApp(contextContext context.Context, db *gorm.DB, configConfig *config.C
 	rkeClusterRepository := repo.NewRKEClusterRepo(db)
 	customClusterRepository := repo.NewCustomClusterRepository(db)
 	middlewareMiddleware := middleware.NewMiddleware(appStoreRepo, rkeClusterRepository, customClusterRepository)
-	taskProducer := producer.NewTaskChannelProducer(arg, arg2, arg3)
+	taskMessageRepository := repo.NewTaskMessageRepo(db)
+	taskPlanner := task.NewTaskPlanner()
+	taskProducer := producer.NewTaskDBProducer(taskMessageRepository, taskPlanner)
 	cloudAccesskeyRepository := repo.NewCloudAccessKeyRepo(db)
 	createKubernetesTaskRepository := repo.NewCreateKubernetesTaskRepo(db)
 	initRainbondTaskRepository := repo.NewInitRainbondRegionTaskRepo(db)
 	updateKubernetesTaskRepository := repo.NewUpdateKubernetesTaskRepo(db)
 	taskEventRepository := repo.NewTaskEventRepo(db)
 	rainbondClusterConfigRepository := repo.NewRainbondClusterConfigRepo(db)
-	clusterUsecase := usecase.NewClusterUsecase(db, taskProducer, cloudAccesskeyRepository, createKubernetesTaskRepository, initRainbondTaskRepository, updateKubernetesTaskRepository, taskEventRepository, rainbondClusterConfigRepository, rkeClusterRepository, customClusterRepository)
+	operationTaskRepository := repo.NewOperationTaskRepo(db)
+	cloudResourceRepository := repo.NewCloudResourceRepo(db)
+	store := repo.NewRKEStateStore(db)
+	sshKeyRepository := repo.NewSSHKeyRepo(db)
+	sshBastionRepository := repo.NewSSHBastionRepo(db)
+	initNodeTokenRepository := repo.NewInitNodeTokenRepo(db)
+	clusterHealthRepository := repo.NewClusterHealthRepo(db)
+	leaseRepository := repo.NewLeaseRepo(db)
+	taskResultRepository := repo.NewTaskResultRepo(db)
+	webhookRepository := repo.NewWebhookRepo(db)
+	webhookDeliveryRepository := repo.NewWebhookDeliveryRepo(db)
+	webhookUsecase := usecase.NewWebhookUsecase(webhookRepository, webhookDeliveryRepository)
+	clusterUsecaseDeps := usecase.ClusterUsecaseDeps{
+		DB:                        db,
+		TaskProducer:              taskProducer,
+		CloudAccessKeyRepo:        cloudAccesskeyRepository,
+		CreateKubernetesTaskRepo:  createKubernetesTaskRepository,
+		InitRainbondTaskRepo:      initRainbondTaskRepository,
+		UpdateKubernetesTaskRepo:  updateKubernetesTaskRepository,
+		TaskEventRepo:             taskEventRepository,
+		TaskResultRepo:            taskResultRepository,
+		TaskMessageRepo:           taskMessageRepository,
+		RainbondClusterConfigRepo: rainbondClusterConfigRepository,
+		RKEClusterRepo:            rkeClusterRepository,
+		CustomClusterRepo:         customClusterRepository,
+		OperationTaskRepo:         operationTaskRepository,
+		CloudResourceRepo:         cloudResourceRepository,
+		RKEStateStore:             store,
+		SSHKeyRepo:                sshKeyRepository,
+		SSHBastionRepo:            sshBastionRepository,
+		InitNodeTokenRepo:         initNodeTokenRepository,
+		ClusterHealthRepo:         clusterHealthRepository,
+		LeaseRepo:                 leaseRepository,
+		WebhookUsecase:            webhookUsecase,
+	}
+	clusterUsecase := usecase.NewClusterUsecase(clusterUsecaseDeps)
 	clusterHandler := handler.NewClusterHandler(clusterUsecase)
 	appStoreUsecase := usecase.NewAppStoreUsecase(appStoreRepo)
 	templateVersioner := appstore.NewTemplateVersioner(configConfig)
@@ -59,10 +95,12 @@ func initApp(contextContext context.Context, db *gorm.DB, configConfig *config.C
 	appTemplate := usecase.NewAppTemplate(templateVersionRepo)
 	appStoreHandler := handler.NewAppStoreHandler(appStoreUsecase, appTemplate)
 	systemHandler := handler.NewSystemHandler(db)
-	router := handler.NewRouter(middlewareMiddleware, clusterHandler, appStoreHandler, systemHandler)
+	webhookHandler := handler.NewWebhookHandler(webhookUsecase)
+	router := handler.NewRouter(middlewareMiddleware, clusterHandler, appStoreHandler, systemHandler, webhookHandler)
 	createKubernetesTaskHandler := task.NewCreateKubernetesTaskHandler(clusterUsecase)
 	cloudInitTaskHandler := task.NewCloudInitTaskHandler(clusterUsecase)
 	updateKubernetesTaskHandler := task.NewCloudUpdateTaskHandler(clusterUsecase)
-	engine := newApp(contextContext, router, arg, arg2, arg3, createKubernetesTaskHandler, cloudInitTaskHandler, updateKubernetesTaskHandler)
+	operationTaskHandler := task.NewOperationTaskHandler(clusterUsecase)
+	engine := newApp(contextContext, router, taskMessageRepository, clusterUsecase, webhookUsecase, createKubernetesTaskHandler, cloudInitTaskHandler, updateKubernetesTaskHandler, operationTaskHandler)
 	return engine, nil
 }
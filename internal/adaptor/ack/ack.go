@@ -35,6 +35,7 @@ import (
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
 	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
 	"goodrain.com/cloud-adaptor/internal/adaptor"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -47,6 +48,28 @@ type ackAdaptor struct {
 	accessKeyID     string
 	accessKeySecret string
 	client          *sdk.Client
+	recorder        func(resource *v1alpha1.CloudResource)
+}
+
+func init() {
+	adaptor.Register(&adaptor.Provider{
+		Name:            "ack",
+		NeedCredentials: true,
+		Interfaces:      []string{adaptor.InterfaceCloudAdaptor, adaptor.InterfaceResumableInitConfig, adaptor.InterfaceResourceReleaser, adaptor.InterfaceResourceRecordable},
+		Capabilities: []string{
+			adaptor.CapabilityCreateCluster,
+			adaptor.CapabilityExpandNodes,
+			adaptor.CapabilityDeleteCluster,
+			adaptor.CapabilityReleaseResources,
+			adaptor.CapabilityManagedDB,
+			adaptor.CapabilityManagedNAS,
+			adaptor.CapabilityLoadBalancer,
+			adaptor.CapabilityRefreshKubeConfig,
+		},
+		Create: func(accessKeyID, accessKeySecret string) (adaptor.RainbondClusterAdaptor, error) {
+			return Create(accessKeyID, accessKeySecret)
+		},
+	})
 }
 
 //Create create ack adaptor
@@ -62,6 +85,26 @@ func Create(accessKeyID, accessKeySecret string) (adaptor.CloudAdaptor, error) {
 	}, nil
 }
 
+//SetResourceRecorder set the recorder of the cloud resources created or released
+func (a *ackAdaptor) SetResourceRecorder(record func(resource *v1alpha1.CloudResource)) {
+	a.recorder = record
+}
+
+func (a *ackAdaptor) recordResource(regionID, clusterID, resourceType, resourceID, name, status string) {
+	if a.recorder == nil || resourceID == "" {
+		return
+	}
+	a.recorder(&v1alpha1.CloudResource{
+		Provider:     "ack",
+		RegionID:     regionID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Name:         name,
+		ClusterID:    clusterID,
+		Status:       status,
+	})
+}
+
 func (a *ackAdaptor) newRequest(method string) *requests.CommonRequest {
 	request := requests.NewCommonRequest()
 	request.Method = method
@@ -105,7 +148,7 @@ func getInstanceType(set string) []string {
 }
 
 func (a *ackAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, config *v1alpha1.KubernetesClusterConfig, rollback func(step, message, status string)) *v1alpha1.Cluster {
-	rollback("AllocateResource", "", "start")
+	rollback(v1.StepAllocateResource, "", "start")
 	// select instance resource type
 	//Resource type to be selected
 	var selectInstanceType string
@@ -128,15 +171,15 @@ func (a *ackAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 		}
 	}
 	if selectInstanceType == "" {
-		rollback("AllocateResource", "Unable to find a suitable instance type, it may be that the region is currently sold out.", "failure")
+		rollback(v1.StepAllocateResource, "Unable to find a suitable instance type, it may be that the region is currently sold out.", "failure")
 		return nil
 	}
-	rollback("AllocateResource", selectInstanceType, "success")
-	rollback("SelectZone", "", "start")
+	rollback(v1.StepAllocateResource, selectInstanceType, "success")
+	rollback(v1.StepSelectZone, "", "start")
 	// select zone
-	rollback("SelectZone", zoneID, "success")
+	rollback(v1.StepSelectZone, zoneID, "success")
 	if config.VpcID == "" {
-		rollback("CreateVPC", "", "start")
+		rollback(v1.StepCreateVPC, "", "start")
 		// create vpc
 		vpc := &v1alpha1.VPC{
 			RegionID:  config.Region,
@@ -144,12 +187,13 @@ func (a *ackAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 			CidrBlock: "10.0.0.0/8",
 		}
 		if err := a.CreateVPC(vpc); err != nil {
-			rollback("CreateVPC", err.Error(), "failure")
+			rollback(v1.StepCreateVPC, err.Error(), "failure")
 			return nil
 		}
-		rollback("CreateVPC", vpc.VpcID, "success")
+		a.recordResource(vpc.RegionID, "", v1alpha1.ResourceTypeVPC, vpc.VpcID, vpc.VpcName, v1alpha1.ResourceStatusCreated)
+		rollback(v1.StepCreateVPC, vpc.VpcID, "success")
 		config.VpcID = vpc.VpcID
-		rollback("CreateVSWitch", "", "start")
+		rollback(v1.StepCreateVSwitch, "", "start")
 		// create vswitch
 		vswitch := &v1alpha1.VSwitch{
 			RegionID:    vpc.RegionID,
@@ -159,21 +203,23 @@ func (a *ackAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 			ZoneID:      zoneID,
 		}
 		if err := a.CreateVSwitch(vswitch); err != nil {
-			rollback("CreateVSwitch", err.Error(), "failure")
+			rollback(v1.StepCreateVSwitch, err.Error(), "failure")
 			return nil
 		}
-		rollback("CreateVSWitch", vswitch.VSwitchID, "success")
+		a.recordResource(vswitch.RegionID, "", v1alpha1.ResourceTypeVSwitch, vswitch.VSwitchID, vswitch.VSwitchName, v1alpha1.ResourceStatusCreated)
+		rollback(v1.StepCreateVSwitch, vswitch.VSwitchID, "success")
 		config.VSwitchID = vswitch.VSwitchID
 	}
 	config.InstanceType = selectInstanceType
 	clusterConfig := v1alpha1.GetDefaultACKCreateClusterConfig(*config)
-	rollback("CreateCluster", "", "start")
+	rollback(v1.StepCreateCluster, "", "start")
 	cluster, err := a.CreateCluster(eid, clusterConfig)
 	if err != nil {
-		rollback("CreateCluster", err.Error(), "failure")
+		rollback(v1.StepCreateCluster, err.Error(), "failure")
 		return nil
 	}
-	rollback("CreateCluster", cluster.ClusterID, "success")
+	a.recordResource(config.Region, cluster.ClusterID, v1alpha1.ResourceTypeKubernetes, cluster.ClusterID, config.ClusterName, v1alpha1.ResourceStatusCreated)
+	rollback(v1.StepCreateCluster, cluster.ClusterID, "success")
 	return cluster
 }
 
@@ -750,8 +796,12 @@ func (a *ackAdaptor) DescribeAvailableResourceZones(regionID, InstanceType strin
 
 //GetRainbondInitConfig get rainbond init config
 func (a *ackAdaptor) GetRainbondInitConfig(eid string, cluster *v1alpha1.Cluster, gateway, chaos []*rainbondv1alpha1.K8sNode, rollback func(step, message, status string)) *v1alpha1.RainbondInitConfig {
+	return a.ResumeRainbondInitConfig(eid, cluster, gateway, chaos, nil, rollback)
+}
 
-	rollback("CreateRDS", "", "start")
+//ResumeRainbondInitConfig get rainbond init config, the cloud resources recorded in checkpoints are reused.
+func (a *ackAdaptor) ResumeRainbondInitConfig(eid string, cluster *v1alpha1.Cluster, gateway, chaos []*rainbondv1alpha1.K8sNode, checkpoints v1alpha1.Checkpoints, rollback func(step, message, status string)) *v1alpha1.RainbondInitConfig {
+	rollback(v1.StepCreateRDS, "", "start")
 	//指定pod cidr作为白名单
 	regionDB := &v1alpha1.Database{
 		Name:      "region",
@@ -764,63 +814,91 @@ func (a *ackAdaptor) GetRainbondInitConfig(eid string, cluster *v1alpha1.Cluster
 		Password:  cluster.ClusterID[0:16],
 		ClusterID: cluster.ClusterID,
 	}
-	if err := a.CreateDB(regionDB); err != nil {
-		rollback("CreateRDS", err.Error(), "failure")
+	if checkpoints.Done(v1.StepCreateRDS) {
+		regionDB.InstanceID = checkpoints[v1.StepCreateRDS]
+		if err := a.describeDBHost(regionDB); err != nil {
+			rollback(v1.StepCreateRDS, err.Error(), "failure")
+			return nil
+		}
+	} else if err := a.CreateDB(regionDB); err != nil {
+		rollback(v1.StepCreateRDS, err.Error(), "failure")
 		return nil
 	}
-	rollback("CreateRDS", regionDB.InstanceID, "success")
+	rollback(v1.StepCreateRDS, regionDB.InstanceID, "success")
 	// create nas
-	vs, err := a.DescribeVSwitch(cluster.RegionID, cluster.VSwitchID)
-	if err != nil {
-		vs, err = a.DescribeVSwitch(cluster.RegionID, cluster.VSwitchID)
+	nasID := checkpoints[v1.StepCreateNAS]
+	if !checkpoints.Done(v1.StepCreateNAS) {
+		vs, err := a.DescribeVSwitch(cluster.RegionID, cluster.VSwitchID)
 		if err != nil {
-			rollback("CreateNAS", fmt.Sprintf("found vswitch %s with cluster failure %s", cluster.VSwitchID, err.Error()), "failure")
+			vs, err = a.DescribeVSwitch(cluster.RegionID, cluster.VSwitchID)
+			if err != nil {
+				rollback(v1.StepCreateNAS, fmt.Sprintf("found vswitch %s with cluster failure %s", cluster.VSwitchID, err.Error()), "failure")
+				return nil
+			}
+		}
+		rollback(v1.StepCreateNAS, "", "start")
+		nasID, err = a.CreateNAS(cluster.ClusterID, cluster.RegionID, vs.ZoneID)
+		if err != nil {
+			rollback(v1.StepCreateNAS, err.Error(), "failure")
 			return nil
 		}
 	}
-	rollback("CreateNAS", "", "start")
-	nasID, err := a.CreateNAS(cluster.ClusterID, cluster.RegionID, vs.ZoneID)
-	if err != nil {
-		rollback("CreateNAS", err.Error(), "failure")
-		return nil
-	}
-	rollback("CreateNAS", nasID, "success")
-	rollback("CreateNASMount", "", "start")
-	nasMountDomain, err := a.CreateNASMountTarget(cluster.ClusterID, cluster.RegionID, nasID, cluster.VPCID, cluster.VSwitchID)
-	if err != nil {
-		rollback("CreateNASMount", err.Error(), "failure")
-		return nil
+	rollback(v1.StepCreateNAS, nasID, "success")
+	rollback(v1.StepCreateNASMount, "", "start")
+	nasMountDomain := checkpoints[v1.StepCreateNASMount]
+	if !checkpoints.Done(v1.StepCreateNASMount) {
+		var err error
+		nasMountDomain, err = a.CreateNASMountTarget(cluster.ClusterID, cluster.RegionID, nasID, cluster.VPCID, cluster.VSwitchID)
+		if err != nil {
+			rollback(v1.StepCreateNASMount, err.Error(), "failure")
+			return nil
+		}
 	}
-	rollback("CreateNASMount", nasMountDomain, "success")
+	rollback(v1.StepCreateNASMount, nasMountDomain, "success")
 
 	// create eip and bound
-	rollback("CreateLoadBalancer", "", "start")
-	slb, err := a.CreateLoadBalancer(cluster.ClusterID, cluster.RegionID)
-	if err != nil {
-		rollback("CreateLoadBalancer", err.Error(), "failure")
-		return nil
+	rollback(v1.StepCreateLoadBalancer, "", "start")
+	var slb *v1alpha1.LoadBalancer
+	if checkpoints.Done(v1.StepCreateLoadBalancer) {
+		// the message is id,address
+		info := strings.SplitN(checkpoints[v1.StepCreateLoadBalancer], ",", 2)
+		if len(info) == 2 {
+			slb = &v1alpha1.LoadBalancer{LoadBalancerID: info[0], Address: info[1]}
+		}
+	}
+	if slb == nil {
+		var err error
+		slb, err = a.CreateLoadBalancer(cluster.ClusterID, cluster.RegionID)
+		if err != nil {
+			rollback(v1.StepCreateLoadBalancer, err.Error(), "failure")
+			return nil
+		}
 	}
-	rollback("CreateLoadBalancer", slb.LoadBalancerID+","+slb.Address, "success")
+	rollback(v1.StepCreateLoadBalancer, slb.LoadBalancerID+","+slb.Address, "success")
 
 	// slb port 443 8443 80 6060 lb to cluster gateway node
-	var gatewayIPs []string
-	for _, g := range gateway {
-		gatewayIPs = append(gatewayIPs, g.InternalIP)
-	}
-	rollback("BoundLoadBalancer", "", "start")
-	logrus.Infof("gateway ips is %s", gatewayIPs)
-	if err := a.BoundLoadBalancerToCluster(cluster.ClusterID, cluster.RegionID, cluster.VPCID, slb.LoadBalancerID, gatewayIPs); err != nil {
-		rollback("BoundLoadBalancer", err.Error(), "failure")
-		return nil
+	if !checkpoints.Done(v1.StepBoundLoadBalancer) {
+		var gatewayIPs []string
+		for _, g := range gateway {
+			gatewayIPs = append(gatewayIPs, g.InternalIP)
+		}
+		rollback(v1.StepBoundLoadBalancer, "", "start")
+		logrus.Infof("gateway ips is %s", gatewayIPs)
+		if err := a.BoundLoadBalancerToCluster(cluster.ClusterID, cluster.RegionID, cluster.VPCID, slb.LoadBalancerID, gatewayIPs); err != nil {
+			rollback(v1.StepBoundLoadBalancer, err.Error(), "failure")
+			return nil
+		}
 	}
-	rollback("BoundLoadBalancer", "80,443,8443,6060", "success")
+	rollback(v1.StepBoundLoadBalancer, "80,443,8443,6060", "success")
 
 	// set security group
-	rollback("SetSecurityGroup", "", "start")
-	if err := a.SetSecurityGroup(cluster.ClusterID, cluster.RegionID, cluster.SecurityGroupID); err != nil {
-		rollback("SetSecurityGroup", err.Error(), "failure")
+	if !checkpoints.Done(v1.StepSetSecurityGroup) {
+		rollback(v1.StepSetSecurityGroup, "", "start")
+		if err := a.SetSecurityGroup(cluster.ClusterID, cluster.RegionID, cluster.SecurityGroupID); err != nil {
+			rollback(v1.StepSetSecurityGroup, err.Error(), "failure")
+		}
 	}
-	rollback("SetSecurityGroup", "80/80,443/443,8443/8443,6060/6060,10000/11000", "success")
+	rollback(v1.StepSetSecurityGroup, "80/80,443/443,8443/8443,6060/6060,10000/11000", "success")
 	return &v1alpha1.RainbondInitConfig{
 		ClusterID:      cluster.ClusterID,
 		RegionDatabase: regionDB,
@@ -831,11 +909,10 @@ func (a *ackAdaptor) GetRainbondInitConfig(eid string, cluster *v1alpha1.Cluster
 	}
 }
 
-//DeleteCluster delete cluster
+//DeleteCluster releases the cloud resources created for the rainbond region.
+//The kubernetes cluster itself is kept, it may not be created by cloud-adaptor.
 func (a *ackAdaptor) DeleteCluster(eid string, clusterID string) error {
-	return nil
-}
-
-func (a *ackAdaptor) ExpansionNode(ctx context.Context, eid string, en *v1alpha1.ExpansionNode, rollback func(step, message, status string)) *v1alpha1.Cluster {
-	return nil
+	return a.ReleaseRainbondResources(eid, clusterID, func(step, message, status string) {
+		logrus.Infof("release resources of cluster %s: %s %s %s", clusterID, step, status, message)
+	})
 }
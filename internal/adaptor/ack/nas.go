@@ -106,6 +106,7 @@ func (a *ackAdaptor) CreateNAS(clusterID, regionID, zoneID string) (string, erro
 	if !response.IsSuccess() {
 		return "", fmt.Errorf("create nas in region %s zone %s failure:%s", regionID, zoneID, response.String())
 	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeNAS, response.FileSystemId, "rainbond-region-nas_"+clusterID, v1alpha1.ResourceStatusCreated)
 	// nas status is empty, do not check nas status
 	return response.FileSystemId, nil
 	// ticker := time.NewTicker(time.Second * 3)
@@ -167,6 +168,7 @@ func (a *ackAdaptor) CreateNASMountTarget(clusterID, regionID, fileSystemID, Vpc
 	if !response.IsSuccess() {
 		return "", fmt.Errorf("create nas mount target failure:%s", response.String())
 	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeNASMountTarget, response.MountTargetDomain, fileSystemID, v1alpha1.ResourceStatusCreated)
 	ticker := time.NewTicker(time.Second * 3)
 	timer := time.NewTimer(time.Minute * 5)
 	defer timer.Stop()
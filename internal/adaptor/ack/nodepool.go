@@ -0,0 +1,339 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/pkg/util"
+)
+
+// nodeReadyTimeout the max time waiting for the nodes of the node pool ready
+var nodeReadyTimeout = time.Minute * 30
+
+type nodePool struct {
+	NodePoolInfo struct {
+		NodePoolID string `json:"nodepool_id"`
+		Name       string `json:"name"`
+		IsDefault  bool   `json:"is_default"`
+	} `json:"nodepool_info"`
+	ScalingGroup struct {
+		InstanceTypes []string `json:"instance_types"`
+	} `json:"scaling_group"`
+}
+
+type clusterNode struct {
+	InstanceID   string `json:"instance_id"`
+	NodeName     string `json:"node_name"`
+	NodeStatus   string `json:"node_status"`
+	State        string `json:"state"`
+	NodePoolID   string `json:"nodepool_id"`
+	CreationTime string `json:"creation_time"`
+}
+
+func (n clusterNode) ready() bool {
+	return n.NodeStatus == "Ready"
+}
+
+//ExpansionNode scales the workers of the node pool to en.WorkerNodeNum and waits for the nodes ready.
+//A new node pool is created if en.NodePoolID is empty and en.InstanceType is specified,
+//otherwise the node pool of en.NodePoolID, or the default node pool if it is empty, is scaled.
+func (a *ackAdaptor) ExpansionNode(ctx context.Context, eid string, en *v1alpha1.ExpansionNode, rollback func(step, message, status string)) *v1alpha1.Cluster {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	if en.WorkerNodeNum < 1 {
+		rollback(v1.StepInitClusterConfig, "the number of worker nodes must be greater than 0", "failure")
+		return nil
+	}
+	cluster, err := a.DescribeCluster(eid, en.ClusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return nil
+	}
+	pools, err := a.describeNodePools(en.ClusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return nil
+	}
+	pool, err := selectNodePool(pools, en.NodePoolID, en.InstanceType)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return nil
+	}
+	var nodes []clusterNode
+	if pool == nil {
+		if en.InstanceType == "" {
+			rollback(v1.StepInitClusterConfig, "no node pool to scale, the instance type of the new node pool is required", "failure")
+			return nil
+		}
+	} else {
+		nodes, err = a.describeClusterNodes(en.ClusterID, pool.NodePoolInfo.NodePoolID)
+		if err != nil {
+			rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+			return nil
+		}
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	rollback(v1.StepUpdateKubernetes, "", "start")
+	var poolID string
+	if pool == nil {
+		poolID, err = a.createNodePool(cluster, en)
+		if err != nil {
+			rollback(v1.StepUpdateKubernetes, err.Error(), "failure")
+			return nil
+		}
+	} else {
+		poolID = pool.NodePoolInfo.NodePoolID
+		if err := a.scaleNodePool(en.ClusterID, poolID, nodes, en.WorkerNodeNum); err != nil {
+			rollback(v1.StepUpdateKubernetes, err.Error(), "failure")
+			return nil
+		}
+	}
+	if err := a.waitNodePoolReady(ctx, en.ClusterID, poolID, en.WorkerNodeNum); err != nil {
+		rollback(v1.StepUpdateKubernetes, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepUpdateKubernetes, poolID, "success")
+	cluster, err = a.DescribeCluster(eid, en.ClusterID)
+	if err != nil {
+		logrus.Errorf("describe cluster %s failure %s", en.ClusterID, err.Error())
+		return &v1alpha1.Cluster{ClusterID: en.ClusterID}
+	}
+	return cluster
+}
+
+// selectNodePool returns the node pool of the id, or the default node pool if both the id and the instance type are empty.
+// It returns nil if a new node pool should be created, that is the id is empty and the instance type is specified,
+// or there is no default node pool.
+func selectNodePool(pools []*nodePool, poolID, instanceType string) (*nodePool, error) {
+	if poolID == "" && instanceType != "" {
+		return nil, nil
+	}
+	for _, pool := range pools {
+		if poolID != "" && pool.NodePoolInfo.NodePoolID == poolID {
+			return pool, nil
+		}
+		if poolID == "" && pool.NodePoolInfo.IsDefault {
+			return pool, nil
+		}
+	}
+	if poolID != "" {
+		return nil, fmt.Errorf("node pool %s not found", poolID)
+	}
+	return nil, nil
+}
+
+// selectRemovedNodes selects the nodes to remove, the not ready nodes are removed first, then the newest.
+func selectRemovedNodes(nodes []clusterNode, count int) []string {
+	sorted := make([]clusterNode, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ready() != sorted[j].ready() {
+			return !sorted[i].ready()
+		}
+		return sorted[i].CreationTime > sorted[j].CreationTime
+	})
+	var names []string
+	for i := 0; i < count && i < len(sorted); i++ {
+		names = append(names, sorted[i].NodeName)
+	}
+	return names
+}
+
+func (a *ackAdaptor) describeNodePools(clusterID string) ([]*nodePool, error) {
+	request := a.newRequest("GET")
+	request.PathPattern = "/clusters/" + clusterID + "/nodepools"
+	res, err := a.doRequest(request)
+	if err != nil {
+		return nil, fmt.Errorf("query node pools from alibaba api failure %s", err.Error())
+	}
+	if !res.IsSuccess() {
+		return nil, fmt.Errorf("query node pools from alibaba api failure:%s", res.String())
+	}
+	var body struct {
+		NodePools []*nodePool `json:"nodepools"`
+	}
+	if err := json.Unmarshal(res.GetHttpContentBytes(), &body); err != nil {
+		return nil, fmt.Errorf("unmarshal response failure:%s", err.Error())
+	}
+	return body.NodePools, nil
+}
+
+func (a *ackAdaptor) describeClusterNodes(clusterID, poolID string) ([]clusterNode, error) {
+	var nodes []clusterNode
+	for page := 1; ; page++ {
+		request := a.newRequest("GET")
+		request.PathPattern = "/clusters/" + clusterID + "/nodes"
+		request.QueryParams["nodepool_id"] = poolID
+		request.QueryParams["pageSize"] = strconv.Itoa(describePageSize)
+		request.QueryParams["pageNumber"] = strconv.Itoa(page)
+		res, err := a.doRequest(request)
+		if err != nil {
+			return nil, fmt.Errorf("query cluster nodes from alibaba api failure %s", err.Error())
+		}
+		if !res.IsSuccess() {
+			return nil, fmt.Errorf("query cluster nodes from alibaba api failure:%s", res.String())
+		}
+		var body struct {
+			Nodes []clusterNode `json:"nodes"`
+			Page  struct {
+				TotalCount int `json:"total_count"`
+			} `json:"page"`
+		}
+		if err := json.Unmarshal(res.GetHttpContentBytes(), &body); err != nil {
+			return nil, fmt.Errorf("unmarshal response failure:%s", err.Error())
+		}
+		// the released instances are still listed for a while
+		for _, node := range body.Nodes {
+			if node.State == "removing" || node.State == "deleting" {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+		if len(body.Nodes) == 0 || page*describePageSize >= body.Page.TotalCount {
+			return nodes, nil
+		}
+	}
+}
+
+// createNodePool creates the node pool of en.InstanceType in the vswitch of the cluster
+func (a *ackAdaptor) createNodePool(cluster *v1alpha1.Cluster, en *v1alpha1.ExpansionNode) (string, error) {
+	dataDisk := v1alpha1.WorkerDataDisk{Category: "cloud_efficiency", Size: "200", Encrypted: "false"}
+	if en.WorkerDataDisk != nil {
+		dataDisk = *en.WorkerDataDisk
+	}
+	name := "rainbond-" + strings.ReplaceAll(en.InstanceType, ".", "-")
+	body, err := json.Marshal(map[string]interface{}{
+		"nodepool_info": map[string]interface{}{
+			"name": name,
+		},
+		"scaling_group": map[string]interface{}{
+			"vswitch_ids":          strings.Split(cluster.VSwitchID, ","),
+			"instance_types":       []string{en.InstanceType},
+			"instance_charge_type": "PostPaid",
+			"system_disk_category": "cloud_efficiency",
+			"system_disk_size":     120,
+			"data_disks":           []v1alpha1.WorkerDataDisk{dataDisk},
+			"desired_size":         en.WorkerNodeNum,
+			"login_password":       util.RandPassword(16),
+		},
+		"kubernetes_config": map[string]interface{}{
+			"runtime":         "docker",
+			"runtime_version": cluster.DockerVersion,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	request := a.newRequest("POST")
+	request.PathPattern = "/clusters/" + cluster.ClusterID + "/nodepools"
+	request.Content = body
+	res, err := a.doRequest(request)
+	if err != nil {
+		return "", fmt.Errorf("create node pool from alibaba api failure %s", err.Error())
+	}
+	if !res.IsSuccess() {
+		return "", fmt.Errorf("create node pool from alibaba api failure:%s", res.String())
+	}
+	var info struct {
+		NodePoolID string `json:"nodepool_id"`
+	}
+	if err := json.Unmarshal(res.GetHttpContentBytes(), &info); err != nil {
+		return "", fmt.Errorf("unmarshal response failure:%s", err.Error())
+	}
+	a.recordResource(cluster.RegionID, cluster.ClusterID, v1alpha1.ResourceTypeNodePool, info.NodePoolID, name, v1alpha1.ResourceStatusCreated)
+	return info.NodePoolID, nil
+}
+
+// scaleNodePool adds or removes the nodes of the node pool, the removed nodes are drained and released.
+func (a *ackAdaptor) scaleNodePool(clusterID, poolID string, nodes []clusterNode, desired int) error {
+	switch {
+	case desired > len(nodes):
+		body, _ := json.Marshal(map[string]interface{}{"count": desired - len(nodes)})
+		request := a.newRequest("POST")
+		request.PathPattern = "/clusters/" + clusterID + "/nodepools/" + poolID
+		request.Content = body
+		res, err := a.doRequest(request)
+		if err != nil {
+			return fmt.Errorf("scale node pool from alibaba api failure %s", err.Error())
+		}
+		if !res.IsSuccess() {
+			return fmt.Errorf("scale node pool from alibaba api failure:%s", res.String())
+		}
+	case desired < len(nodes):
+		removed := selectRemovedNodes(nodes, len(nodes)-desired)
+		logrus.Infof("remove nodes %s from node pool %s", removed, poolID)
+		body, _ := json.Marshal(map[string]interface{}{
+			"nodes":        removed,
+			"release_node": true,
+			"drain_node":   true,
+		})
+		request := a.newRequest("POST")
+		request.PathPattern = "/clusters/" + clusterID + "/nodes"
+		request.Content = body
+		res, err := a.doRequest(request)
+		if err != nil {
+			return fmt.Errorf("remove nodes from alibaba api failure %s", err.Error())
+		}
+		if !res.IsSuccess() {
+			return fmt.Errorf("remove nodes from alibaba api failure:%s", res.String())
+		}
+	}
+	return nil
+}
+
+func (a *ackAdaptor) waitNodePoolReady(ctx context.Context, clusterID, poolID string, desired int) error {
+	ticker := time.NewTicker(time.Second * 10)
+	defer ticker.Stop()
+	timer := time.NewTimer(nodeReadyTimeout)
+	defer timer.Stop()
+	for {
+		nodes, err := a.describeClusterNodes(clusterID, poolID)
+		if err != nil {
+			logrus.Warningf("describe nodes of node pool %s failure %s", poolID, err.Error())
+		} else {
+			var ready int
+			for _, node := range nodes {
+				if node.ready() {
+					ready++
+				}
+			}
+			if len(nodes) == desired && ready == desired {
+				return nil
+			}
+			logrus.Infof("node pool %s has %d nodes, %d ready, desired %d", poolID, len(nodes), ready, desired)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return fmt.Errorf("waiting for the nodes of node pool %s ready timeout", poolID)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectNodePool(t *testing.T) {
+	newPool := func(id string, isDefault bool) *nodePool {
+		pool := &nodePool{}
+		pool.NodePoolInfo.NodePoolID = id
+		pool.NodePoolInfo.IsDefault = isDefault
+		return pool
+	}
+	pools := []*nodePool{newPool("np-1", false), newPool("np-default", true)}
+	pool, err := selectNodePool(pools, "np-1", "")
+	if err != nil || pool.NodePoolInfo.NodePoolID != "np-1" {
+		t.Errorf("expected np-1, got %+v %v", pool, err)
+	}
+	pool, err = selectNodePool(pools, "np-1", "ecs.g6.xlarge")
+	if err != nil || pool.NodePoolInfo.NodePoolID != "np-1" {
+		t.Errorf("expected np-1 with the instance type, got %+v %v", pool, err)
+	}
+	pool, err = selectNodePool(pools, "", "")
+	if err != nil || pool.NodePoolInfo.NodePoolID != "np-default" {
+		t.Errorf("expected the default node pool, got %+v %v", pool, err)
+	}
+	// a new node pool is created for the instance type even if the cluster has a default node pool
+	pool, err = selectNodePool(pools, "", "ecs.g6.xlarge")
+	if err != nil || pool != nil {
+		t.Errorf("expected a new node pool, got %+v %v", pool, err)
+	}
+	if _, err := selectNodePool(pools, "np-2", ""); err == nil {
+		t.Errorf("expected error for the node pool not found")
+	}
+	pool, err = selectNodePool(pools[:1], "", "")
+	if err != nil || pool != nil {
+		t.Errorf("expected no node pool, got %+v %v", pool, err)
+	}
+}
+
+func TestSelectRemovedNodes(t *testing.T) {
+	nodes := []clusterNode{
+		{NodeName: "old", NodeStatus: "Ready", CreationTime: "2021-01-01T00:00:00+08:00"},
+		{NodeName: "new", NodeStatus: "Ready", CreationTime: "2021-03-01T00:00:00+08:00"},
+		{NodeName: "unknown", NodeStatus: "Unknown", CreationTime: "2021-01-01T00:00:00+08:00"},
+		{NodeName: "middle", NodeStatus: "Ready", CreationTime: "2021-02-01T00:00:00+08:00"},
+	}
+	if removed := selectRemovedNodes(nodes, 2); !reflect.DeepEqual(removed, []string{"unknown", "new"}) {
+		t.Errorf("expected the not ready and the newest nodes removed, got %v", removed)
+	}
+	if removed := selectRemovedNodes(nodes, 5); len(removed) != 4 {
+		t.Errorf("expected all nodes removed, got %v", removed)
+	}
+}
@@ -42,16 +42,23 @@ func (a *ackAdaptor) DescribeDBInstance(clusterID, regionID, ZoneID string) (*rd
 	request := rds.CreateDescribeDBInstancesRequest()
 	request.RegionId = regionID
 	request.ZoneId = ZoneID
-	res, _ := ecsclient.DescribeDBInstances(request)
-	if res != nil && len(res.Items.DBInstance) > 0 {
+	request.PageSize = requests.NewInteger(describePageSize)
+	for page := 1; ; page++ {
+		request.PageNumber = requests.NewInteger(page)
+		res, err := ecsclient.DescribeDBInstances(request)
+		if err != nil {
+			return nil, err
+		}
 		for _, instance := range res.Items.DBInstance {
 			logrus.Infof("db instance %s", instance.DBInstanceDescription)
 			if instance.DBInstanceDescription == "rainbond-region-db_"+clusterID {
 				return &instance, nil
 			}
 		}
+		if len(res.Items.DBInstance) == 0 || page*describePageSize >= res.TotalRecordCount {
+			return nil, nil
+		}
 	}
-	return nil, nil
 }
 
 func (a *ackAdaptor) DescribeDBInstanceNetInfo(regionID, instanceID string) (response *rds.DescribeDBInstanceNetInfoResponse, err error) {
@@ -280,24 +287,8 @@ func (a *ackAdaptor) CreateDB(db *v1alpha1.Database) error {
 		}
 		if instance != nil {
 			db.InstanceID = instance.DBInstanceId
-			res, err := a.DescribeDBInstanceNetInfo(db.RegionID, instance.DBInstanceId)
-			if err != nil {
-				return fmt.Errorf("describe rds(mysql) net info from alibaba api failure:%s", err.Error())
-			}
-			for _, addr := range res.DBInstanceNetInfos.DBInstanceNetInfo {
-				if addr.IPType == "Private" {
-					db.Host = addr.ConnectionString
-					db.Port, _ = strconv.Atoi(addr.Port)
-				}
-			}
-			// if there is no private connection, use Public
-			if db.Host == "" {
-				for _, addr := range res.DBInstanceNetInfos.DBInstanceNetInfo {
-					if addr.IPType == "Public" {
-						db.Host = addr.ConnectionString
-						db.Port, _ = strconv.Atoi(addr.Port)
-					}
-				}
+			if err := a.describeDBHost(db); err != nil {
+				return err
 			}
 		} else {
 			response, err := a.CreateDBInstance(db.ClusterID, db.RegionID, db.ZoneID, db.VPCID, db.VSwitchID, db.PodCIDR)
@@ -305,6 +296,7 @@ func (a *ackAdaptor) CreateDB(db *v1alpha1.Database) error {
 				return fmt.Errorf("create rds(mysql) from alibaba api failure:%s", err.Error())
 			}
 			db.InstanceID = response.DBInstanceId
+			a.recordResource(db.RegionID, db.ClusterID, v1alpha1.ResourceTypeRDS, db.InstanceID, "rainbond-region-db_"+db.ClusterID, v1alpha1.ResourceStatusCreated)
 			db.Host = response.ConnectionString
 			db.Port, _ = strconv.Atoi(response.Port)
 		}
@@ -324,3 +316,27 @@ func (a *ackAdaptor) CreateDB(db *v1alpha1.Database) error {
 	}
 	return nil
 }
+
+// describeDBHost sets the connection address of the db instance
+func (a *ackAdaptor) describeDBHost(db *v1alpha1.Database) error {
+	res, err := a.DescribeDBInstanceNetInfo(db.RegionID, db.InstanceID)
+	if err != nil {
+		return fmt.Errorf("describe rds(mysql) net info from alibaba api failure:%s", err.Error())
+	}
+	for _, addr := range res.DBInstanceNetInfos.DBInstanceNetInfo {
+		if addr.IPType == "Private" {
+			db.Host = addr.ConnectionString
+			db.Port, _ = strconv.Atoi(addr.Port)
+		}
+	}
+	// if there is no private connection, use Public
+	if db.Host == "" {
+		for _, addr := range res.DBInstanceNetInfos.DBInstanceNetInfo {
+			if addr.IPType == "Public" {
+				db.Host = addr.ConnectionString
+				db.Port, _ = strconv.Atoi(addr.Port)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/nas"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/rds"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+// describePageSize the page size of the describe requests
+const describePageSize = 100
+
+// gatewayListenPorts the listener ports created by BoundLoadBalancerToCluster
+var gatewayListenPorts = []int{80, 443, 8443, 6060}
+
+func isGatewayListenPort(port int) bool {
+	for _, p := range gatewayListenPorts {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotExist(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "The specified resource does not exist")
+}
+
+//ReleaseRainbondResources deletes the slb, nas and rds created by GetRainbondInitConfig.
+//The resources are located by the names given by cloud-adaptor, the slb is not deleted if it
+//has listeners or vserver groups created by others.
+func (a *ackAdaptor) ReleaseRainbondResources(eid, clusterID string, rollback func(step, message, status string)) error {
+	cluster, err := a.DescribeCluster(eid, clusterID)
+	if err != nil {
+		return fmt.Errorf("describe cluster %s: %v", clusterID, err)
+	}
+	if err := a.releaseLoadBalancer(clusterID, cluster.RegionID, rollback); err != nil {
+		return err
+	}
+	if err := a.releaseNAS(clusterID, cluster.RegionID, rollback); err != nil {
+		return err
+	}
+	return a.releaseDB(clusterID, cluster.RegionID, rollback)
+}
+
+func (a *ackAdaptor) releaseLoadBalancer(clusterID, regionID string, rollback func(step, message, status string)) error {
+	client, err := slb.NewClientWithAccessKey(regionID, a.accessKeyID, a.accessKeySecret)
+	if err != nil {
+		return err
+	}
+	req := slb.CreateDescribeLoadBalancersRequest()
+	req.Scheme = "https"
+	req.RegionId = regionID
+	req.LoadBalancerName = "rainbond-region-lb_" + clusterID
+	req.PageSize = requests.NewInteger(describePageSize)
+	var loadBalancerIDs []string
+	for page := 1; ; page++ {
+		req.PageNumber = requests.NewInteger(page)
+		res, err := client.DescribeLoadBalancers(req)
+		if err != nil {
+			return fmt.Errorf("describe load balancer failure %s", err.Error())
+		}
+		for _, lb := range res.LoadBalancers.LoadBalancer {
+			if lb.LoadBalancerName == "rainbond-region-lb_"+clusterID {
+				loadBalancerIDs = append(loadBalancerIDs, lb.LoadBalancerId)
+			}
+		}
+		if len(res.LoadBalancers.LoadBalancer) == 0 || page*describePageSize >= res.TotalCount {
+			break
+		}
+	}
+	for _, loadBalancerID := range loadBalancerIDs {
+		if err := a.deleteLoadBalancer(client, clusterID, regionID, loadBalancerID, rollback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *ackAdaptor) deleteLoadBalancer(client *slb.Client, clusterID, regionID, loadBalancerID string, rollback func(step, message, status string)) error {
+	areq := slb.CreateDescribeLoadBalancerAttributeRequest()
+	areq.Scheme = "https"
+	areq.LoadBalancerId = loadBalancerID
+	attr, err := client.DescribeLoadBalancerAttribute(areq)
+	if err != nil {
+		return fmt.Errorf("describe load balancer %s failure %s", loadBalancerID, err.Error())
+	}
+	for _, listener := range attr.ListenerPortsAndProtocol.ListenerPortAndProtocol {
+		if !isGatewayListenPort(listener.ListenerPort) {
+			err := fmt.Errorf("load balancer %s has the listener %d not created by cloud-adaptor, refuse to delete it", loadBalancerID, listener.ListenerPort)
+			rollback(v1.StepDeleteLoadBalancer, err.Error(), "failure")
+			return err
+		}
+	}
+	greq := slb.CreateDescribeVServerGroupsRequest()
+	greq.Scheme = "https"
+	greq.LoadBalancerId = loadBalancerID
+	groups, err := client.DescribeVServerGroups(greq)
+	if err != nil && !isNotExist(err) {
+		return fmt.Errorf("describe vserver groups of load balancer %s failure %s", loadBalancerID, err.Error())
+	}
+	var groupIDs []string
+	if groups != nil {
+		for _, group := range groups.VServerGroups.VServerGroup {
+			if !strings.HasPrefix(group.VServerGroupName, "rainbond-gateway-nodes-") {
+				err := fmt.Errorf("load balancer %s has the vserver group %s not created by cloud-adaptor, refuse to delete it", loadBalancerID, group.VServerGroupName)
+				rollback(v1.StepDeleteLoadBalancer, err.Error(), "failure")
+				return err
+			}
+			groupIDs = append(groupIDs, group.VServerGroupId)
+		}
+	}
+
+	// the listeners use the vserver groups, delete them first
+	for _, listener := range attr.ListenerPortsAndProtocol.ListenerPortAndProtocol {
+		rollback(v1.StepDeleteLoadBalancerListener, "", "start")
+		req := slb.CreateDeleteLoadBalancerListenerRequest()
+		req.Scheme = "https"
+		req.LoadBalancerId = loadBalancerID
+		req.ListenerPort = requests.NewInteger(listener.ListenerPort)
+		req.ListenerProtocol = listener.ListenerProtocol
+		if _, err := client.DeleteLoadBalancerListener(req); err != nil && !isNotExist(err) {
+			rollback(v1.StepDeleteLoadBalancerListener, err.Error(), "failure")
+			return err
+		}
+		rollback(v1.StepDeleteLoadBalancerListener, fmt.Sprintf("%s,%d", loadBalancerID, listener.ListenerPort), "success")
+	}
+	for _, groupID := range groupIDs {
+		rollback(v1.StepDeleteVServerGroup, "", "start")
+		req := slb.CreateDeleteVServerGroupRequest()
+		req.Scheme = "https"
+		req.VServerGroupId = groupID
+		if _, err := client.DeleteVServerGroup(req); err != nil && !isNotExist(err) {
+			rollback(v1.StepDeleteVServerGroup, err.Error(), "failure")
+			return err
+		}
+		a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeVServerGroup, groupID, "", v1alpha1.ResourceStatusReleased)
+		rollback(v1.StepDeleteVServerGroup, groupID, "success")
+	}
+	rollback(v1.StepDeleteLoadBalancer, "", "start")
+	req := slb.CreateDeleteLoadBalancerRequest()
+	req.Scheme = "https"
+	req.LoadBalancerId = loadBalancerID
+	if _, err := client.DeleteLoadBalancer(req); err != nil && !isNotExist(err) {
+		rollback(v1.StepDeleteLoadBalancer, err.Error(), "failure")
+		return err
+	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeLoadBalancer, loadBalancerID, "", v1alpha1.ResourceStatusReleased)
+	rollback(v1.StepDeleteLoadBalancer, loadBalancerID, "success")
+	logrus.Infof("load balancer %s of cluster %s is deleted", loadBalancerID, clusterID)
+	return nil
+}
+
+func (a *ackAdaptor) releaseNAS(clusterID, regionID string, rollback func(step, message, status string)) error {
+	client, err := nas.NewClientWithAccessKey(regionID, a.accessKeyID, a.accessKeySecret)
+	if err != nil {
+		return err
+	}
+	req := nas.CreateDescribeFileSystemsRequest()
+	req.Scheme = "https"
+	req.RegionId = regionID
+	req.PageSize = requests.NewInteger(describePageSize)
+	// list all pages before deleting, the deletion shifts the pages
+	var fileSystemIDs []string
+	for page := 1; ; page++ {
+		req.PageNumber = requests.NewInteger(page)
+		res, err := client.DescribeFileSystems(req)
+		if err != nil {
+			if isNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("describe nas failure %s", err.Error())
+		}
+		for _, system := range res.FileSystems.FileSystem {
+			if system.Description == "rainbond-region-nas_"+clusterID {
+				fileSystemIDs = append(fileSystemIDs, system.FileSystemId)
+			}
+		}
+		if len(res.FileSystems.FileSystem) == 0 || page*describePageSize >= res.TotalCount {
+			break
+		}
+	}
+	for _, fileSystemID := range fileSystemIDs {
+		if err := a.deleteNAS(client, clusterID, regionID, fileSystemID, rollback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *ackAdaptor) deleteNAS(client *nas.Client, clusterID, regionID, fileSystemID string, rollback func(step, message, status string)) error {
+	mreq := nas.CreateDescribeMountTargetsRequest()
+	mreq.Scheme = "https"
+	mreq.FileSystemId = fileSystemID
+	targets, err := client.DescribeMountTargets(mreq)
+	if err != nil && !isNotExist(err) {
+		return fmt.Errorf("describe mount targets of nas %s failure %s", fileSystemID, err.Error())
+	}
+	if targets != nil {
+		for _, target := range targets.MountTargets.MountTarget {
+			rollback(v1.StepDeleteNASMount, "", "start")
+			req := nas.CreateDeleteMountTargetRequest()
+			req.Scheme = "https"
+			req.FileSystemId = fileSystemID
+			req.MountTargetDomain = target.MountTargetDomain
+			if _, err := client.DeleteMountTarget(req); err != nil && !isNotExist(err) {
+				rollback(v1.StepDeleteNASMount, err.Error(), "failure")
+				return err
+			}
+			a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeNASMountTarget, target.MountTargetDomain, "", v1alpha1.ResourceStatusReleased)
+			rollback(v1.StepDeleteNASMount, target.MountTargetDomain, "success")
+		}
+	}
+	rollback(v1.StepDeleteNAS, "", "start")
+	// the file system can be deleted after the mount targets are removed
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+	timer := time.NewTimer(time.Minute * 5)
+	defer timer.Stop()
+	for {
+		req := nas.CreateDeleteFileSystemRequest()
+		req.Scheme = "https"
+		req.FileSystemId = fileSystemID
+		_, err := client.DeleteFileSystem(req)
+		if err == nil || isNotExist(err) {
+			break
+		}
+		logrus.Infof("delete nas %s failure %s, retry", fileSystemID, err.Error())
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			rollback(v1.StepDeleteNAS, err.Error(), "failure")
+			return err
+		}
+	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeNAS, fileSystemID, "", v1alpha1.ResourceStatusReleased)
+	rollback(v1.StepDeleteNAS, fileSystemID, "success")
+	return nil
+}
+
+func (a *ackAdaptor) releaseDB(clusterID, regionID string, rollback func(step, message, status string)) error {
+	instance, err := a.DescribeDBInstance(clusterID, regionID, "")
+	if err != nil {
+		return fmt.Errorf("describe rds failure %s", err.Error())
+	}
+	if instance == nil {
+		return nil
+	}
+	rollback(v1.StepDeleteRDS, "", "start")
+	client, err := rds.NewClientWithAccessKey(regionID, a.accessKeyID, a.accessKeySecret)
+	if err != nil {
+		rollback(v1.StepDeleteRDS, err.Error(), "failure")
+		return err
+	}
+	req := rds.CreateDeleteDBInstanceRequest()
+	req.Scheme = "https"
+	req.DBInstanceId = instance.DBInstanceId
+	if _, err := client.DeleteDBInstance(req); err != nil && !isNotExist(err) {
+		rollback(v1.StepDeleteRDS, err.Error(), "failure")
+		return err
+	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeRDS, instance.DBInstanceId, "", v1alpha1.ResourceStatusReleased)
+	rollback(v1.StepDeleteRDS, instance.DBInstanceId, "success")
+	return nil
+}
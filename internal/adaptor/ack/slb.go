@@ -65,6 +65,7 @@ func (a *ackAdaptor) CreateLoadBalancer(clusterID, regionID string) (*v1alpha1.L
 	if !response.IsSuccess() {
 		return nil, fmt.Errorf("create load balance failure:%s", response.String())
 	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeLoadBalancer, response.LoadBalancerId, request.LoadBalancerName, v1alpha1.ResourceStatusCreated)
 	ticker := time.NewTicker(time.Second * 3)
 	timer := time.NewTimer(time.Minute * 5)
 	defer timer.Stop()
@@ -165,6 +166,7 @@ func (a *ackAdaptor) createVServerGroup(clusterID, regionID, vpcID, loadBalancer
 	if !response.IsSuccess() {
 		return "", fmt.Errorf("create load balance VServerGroup failure:%s", response.String())
 	}
+	a.recordResource(regionID, clusterID, v1alpha1.ResourceTypeVServerGroup, response.VServerGroupId, request.VServerGroupName, v1alpha1.ResourceStatusCreated)
 	return response.VServerGroupId, nil
 }
 
@@ -62,3 +62,62 @@ type RainbondClusterAdaptor interface {
 	CreateRainbondKubernetes(ctx context.Context, eid string, config *v1alpha1.KubernetesClusterConfig, rollback func(step, message, status string)) *v1alpha1.Cluster
 	GetRainbondInitConfig(eid string, cluster *v1alpha1.Cluster, gateway, chaos []*rainbondv1alpha1.K8sNode, rollback func(step, message, status string)) *v1alpha1.RainbondInitConfig
 }
+
+//ResumableInitConfigAdaptor the adaptor which can skip the succeeded steps when getting rainbond init config.
+type ResumableInitConfigAdaptor interface {
+	ResumeRainbondInitConfig(eid string, cluster *v1alpha1.Cluster, gateway, chaos []*rainbondv1alpha1.K8sNode, checkpoints v1alpha1.Checkpoints, rollback func(step, message, status string)) *v1alpha1.RainbondInitConfig
+}
+
+//ResourceReleaser the adaptor which can release the cloud resources created for the rainbond region.
+type ResourceReleaser interface {
+	// ReleaseRainbondResources deletes the resources created by cloud-adaptor in dependency order,
+	// every deletion is reported by rollback. The resources not created by cloud-adaptor are never deleted.
+	ReleaseRainbondResources(eid, clusterID string, rollback func(step, message, status string)) error
+}
+
+//KubernetesUpgrader the adaptor which can upgrade the kubernetes version of the cluster.
+type KubernetesUpgrader interface {
+	ListKubernetesVersions(eid, clusterID string) ([]*v1alpha1.KubernetesVersion, error)
+	// UpgradeKubernetes upgrades the cluster to the given version, the progress of every node is reported by rollback.
+	UpgradeKubernetes(ctx context.Context, eid, clusterID, version string, rollback func(step, message, status string)) error
+}
+
+//CertificateRotator the adaptor which can rotate the certificates of the cluster.
+type CertificateRotator interface {
+	ListCertificates(eid, clusterID string) ([]*v1alpha1.Certificate, error)
+	RotateCertificates(ctx context.Context, eid, clusterID string, options v1alpha1.RotateCertificatesOptions, rollback func(step, message, status string)) error
+}
+
+//SecretsEncryptor the adaptor which can encrypt the secrets of the cluster at rest.
+type SecretsEncryptor interface {
+	GetSecretsEncryptionStatus(eid, clusterID string) (*v1alpha1.SecretsEncryptionStatus, error)
+	EnableSecretsEncryption(ctx context.Context, eid, clusterID string, rollback func(step, message, status string)) error
+	// RotateSecretsEncryptionKey deploys a new key, rewrites the secrets and removes the old key, every phase is reported by rollback.
+	RotateSecretsEncryptionKey(ctx context.Context, eid, clusterID string, rollback func(step, message, status string)) error
+}
+
+//EtcdSnapshotter the adaptor which can save the etcd snapshots of the cluster and restore the cluster from them.
+type EtcdSnapshotter interface {
+	ListEtcdSnapshots(eid, clusterID string) ([]*v1alpha1.EtcdSnapshot, error)
+	SaveEtcdSnapshot(ctx context.Context, eid, clusterID, name string, rollback func(step, message, status string)) error
+	GetEtcdSnapshotPolicy(eid, clusterID string) (*v1alpha1.EtcdSnapshotPolicy, error)
+	SetEtcdSnapshotPolicy(ctx context.Context, eid, clusterID string, policy v1alpha1.EtcdSnapshotPolicy, rollback func(step, message, status string)) error
+	// RestoreEtcdSnapshot restores the etcd data of the cluster from the snapshot, the kubeconfig of the cluster is updated after restoring.
+	RestoreEtcdSnapshot(ctx context.Context, eid, clusterID, name string, rollback func(step, message, status string)) error
+}
+
+//NodeRemover the adaptor which can remove the nodes from the cluster safely.
+type NodeRemover interface {
+	// RemoveNodes drains the nodes and removes them from the cluster, the nodes are the addresses in the cluster config.
+	RemoveNodes(ctx context.Context, eid, clusterID string, nodes []string, rollback func(step, message, status string)) error
+}
+
+//PreflightChecker the adaptor which can check the nodes before installing the cluster.
+type PreflightChecker interface {
+	PreflightCheck(config *v1alpha1.KubernetesClusterConfig) (*v1alpha1.PreflightReport, error)
+}
+
+//ResourceRecordable the adaptor which reports the cloud resources it creates or releases.
+type ResourceRecordable interface {
+	SetResourceRecorder(record func(resource *v1alpha1.CloudResource))
+}
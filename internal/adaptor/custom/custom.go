@@ -28,6 +28,7 @@ import (
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
 	"github.com/sirupsen/logrus"
+	apiv1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
 	"goodrain.com/cloud-adaptor/internal/adaptor"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 	"goodrain.com/cloud-adaptor/internal/datastore"
@@ -43,6 +44,17 @@ type customAdaptor struct {
 	Repo *repo.CustomClusterRepo
 }
 
+func init() {
+	adaptor.Register(&adaptor.Provider{
+		Name: "custom",
+		// the custom cluster is imported by its kubeconfig
+		Capabilities: []string{adaptor.CapabilityCreateCluster, adaptor.CapabilityDeleteCluster},
+		Create: func(accessKeyID, accessKeySecret string) (adaptor.RainbondClusterAdaptor, error) {
+			return Create()
+		},
+	})
+}
+
 //Create create ack adaptor
 func Create() (adaptor.RainbondClusterAdaptor, error) {
 	return &customAdaptor{
@@ -189,7 +201,7 @@ func (c *customAdaptor) CreateCluster(string, v1alpha1.CreateClusterConfig) (*v1
 }
 
 func (c *customAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, config *v1alpha1.KubernetesClusterConfig, rollback func(step, message, status string)) *v1alpha1.Cluster {
-	rollback("CreateCluster", "", "success")
+	rollback(apiv1.StepCreateCluster, "", "success")
 	return nil
 }
 
@@ -22,9 +22,6 @@ import (
 	"fmt"
 
 	"goodrain.com/cloud-adaptor/internal/adaptor"
-	"goodrain.com/cloud-adaptor/internal/adaptor/ack"
-	"goodrain.com/cloud-adaptor/internal/adaptor/custom"
-	"goodrain.com/cloud-adaptor/internal/adaptor/rke"
 )
 
 //ErrorNotSupport not support adaptor
@@ -47,23 +44,21 @@ func GetCloudFactory() CloudFactory {
 }
 
 func (f *cloudFactory) GetAdaptor(adaptorType, accessKeyID, accessKeySecret string) (adaptor.CloudAdaptor, error) {
-	switch adaptorType {
-	case "ack":
-		return ack.Create(accessKeyID, accessKeySecret)
-	default:
+	ad, err := f.GetRainbondClusterAdaptor(adaptorType, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	cloudAdaptor, ok := ad.(adaptor.CloudAdaptor)
+	if !ok {
 		return nil, ErrorNotSupport
 	}
+	return cloudAdaptor, nil
 }
 
 func (f *cloudFactory) GetRainbondClusterAdaptor(adaptorType, accessKeyID, accessKeySecret string) (adaptor.RainbondClusterAdaptor, error) {
-	switch adaptorType {
-	case "ack":
-		return ack.Create(accessKeyID, accessKeySecret)
-	case "rke":
-		return rke.Create()
-	case "custom":
-		return custom.Create()
-	default:
+	provider, ok := adaptor.GetProvider(adaptorType)
+	if !ok {
 		return nil, ErrorNotSupport
 	}
+	return provider.Create(accessKeyID, accessKeySecret)
 }
@@ -0,0 +1,80 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package factory
+
+import (
+	"testing"
+
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+)
+
+func TestRegisteredProviders(t *testing.T) {
+	for _, name := range []string{"ack", "custom", "rke", "tke"} {
+		provider, ok := adaptor.GetProvider(name)
+		if !ok {
+			t.Fatalf("provider %s is not registered", name)
+		}
+		ad, err := GetCloudFactory().GetRainbondClusterAdaptor(name, "", "")
+		if err != nil {
+			t.Fatalf("create adaptor %s: %v", name, err)
+		}
+		if _, ok := ad.(adaptor.CloudAdaptor); ok != provider.Implements(adaptor.InterfaceCloudAdaptor) {
+			t.Errorf("provider %s: CloudAdaptor implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.ResumableInitConfigAdaptor); ok != provider.Implements(adaptor.InterfaceResumableInitConfig) {
+			t.Errorf("provider %s: ResumableInitConfigAdaptor implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.ResourceReleaser); ok != provider.Implements(adaptor.InterfaceResourceReleaser) {
+			t.Errorf("provider %s: ResourceReleaser implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.ResourceRecordable); ok != provider.Implements(adaptor.InterfaceResourceRecordable) {
+			t.Errorf("provider %s: ResourceRecordable implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.KubernetesUpgrader); ok != provider.Implements(adaptor.InterfaceKubernetesUpgrader) {
+			t.Errorf("provider %s: KubernetesUpgrader implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.CertificateRotator); ok != provider.Implements(adaptor.InterfaceCertificateRotator) {
+			t.Errorf("provider %s: CertificateRotator implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.SecretsEncryptor); ok != provider.Implements(adaptor.InterfaceSecretsEncryptor) {
+			t.Errorf("provider %s: SecretsEncryptor implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.EtcdSnapshotter); ok != provider.Implements(adaptor.InterfaceEtcdSnapshotter) {
+			t.Errorf("provider %s: EtcdSnapshotter implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.NodeRemover); ok != provider.Implements(adaptor.InterfaceNodeRemover) {
+			t.Errorf("provider %s: NodeRemover implemented: %v, declared: %v", name, ok, !ok)
+		}
+		if _, ok := ad.(adaptor.PreflightChecker); ok != provider.Implements(adaptor.InterfacePreflightChecker) {
+			t.Errorf("provider %s: PreflightChecker implemented: %v, declared: %v", name, ok, !ok)
+		}
+	}
+}
+
+func TestGetAdaptor(t *testing.T) {
+	if _, err := GetCloudFactory().GetAdaptor("tke", "", ""); err != nil {
+		t.Errorf("get tke adaptor: %v", err)
+	}
+	if _, err := GetCloudFactory().GetAdaptor("rke", "", ""); err != ErrorNotSupport {
+		t.Errorf("expected ErrorNotSupport for rke, got %v", err)
+	}
+	if _, err := GetCloudFactory().GetRainbondClusterAdaptor("unknown", "", ""); err != ErrorNotSupport {
+		t.Errorf("expected ErrorNotSupport for unknown provider, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package adaptor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The names of the optional interfaces an adaptor may implement.
+const (
+	//InterfaceCloudAdaptor the adaptor implements CloudAdaptor
+	InterfaceCloudAdaptor = "CloudAdaptor"
+	//InterfaceResumableInitConfig the adaptor implements ResumableInitConfigAdaptor
+	InterfaceResumableInitConfig = "ResumableInitConfigAdaptor"
+	//InterfaceResourceReleaser the adaptor implements ResourceReleaser
+	InterfaceResourceReleaser = "ResourceReleaser"
+	//InterfaceResourceRecordable the adaptor implements ResourceRecordable
+	InterfaceResourceRecordable = "ResourceRecordable"
+	//InterfaceKubernetesUpgrader the adaptor implements KubernetesUpgrader
+	InterfaceKubernetesUpgrader = "KubernetesUpgrader"
+	//InterfaceCertificateRotator the adaptor implements CertificateRotator
+	InterfaceCertificateRotator = "CertificateRotator"
+	//InterfaceSecretsEncryptor the adaptor implements SecretsEncryptor
+	InterfaceSecretsEncryptor = "SecretsEncryptor"
+	//InterfaceEtcdSnapshotter the adaptor implements EtcdSnapshotter
+	InterfaceEtcdSnapshotter = "EtcdSnapshotter"
+	//InterfaceNodeRemover the adaptor implements NodeRemover
+	InterfaceNodeRemover = "NodeRemover"
+	//InterfacePreflightChecker the adaptor implements PreflightChecker
+	InterfacePreflightChecker = "PreflightChecker"
+)
+
+// The capabilities an adaptor may support.
+const (
+	//CapabilityCreateCluster creates or imports the kubernetes cluster
+	CapabilityCreateCluster = "createCluster"
+	//CapabilityExpandNodes adds nodes to the kubernetes cluster
+	CapabilityExpandNodes = "expandNodes"
+	//CapabilityDeleteCluster deletes the kubernetes cluster
+	CapabilityDeleteCluster = "deleteCluster"
+	//CapabilityManagedDB creates the managed database for the rainbond region
+	CapabilityManagedDB = "managedDB"
+	//CapabilityManagedNAS creates the managed NAS for the rainbond region
+	CapabilityManagedNAS = "managedNAS"
+	//CapabilityLoadBalancer creates the load balancer for the rainbond gateway
+	CapabilityLoadBalancer = "loadBalancer"
+	//CapabilityRefreshKubeConfig fetches the latest kubeconfig from the cloud provider
+	CapabilityRefreshKubeConfig = "refreshKubeConfig"
+	//CapabilityReleaseResources deletes the cloud resources created for the rainbond region
+	CapabilityReleaseResources = "releaseResources"
+	//CapabilityUpgradeKubernetes upgrades the kubernetes version of the cluster
+	CapabilityUpgradeKubernetes = "upgradeKubernetes"
+	//CapabilityRotateCertificates rotates the certificates of the kubernetes components
+	CapabilityRotateCertificates = "rotateCertificates"
+	//CapabilitySecretsEncryption enables the secrets encryption at rest and rotates its key
+	CapabilitySecretsEncryption = "secretsEncryption"
+	//CapabilityEtcdSnapshot saves the etcd snapshots and restores the cluster from them
+	CapabilityEtcdSnapshot = "etcdSnapshot"
+	//CapabilityRemoveNodes drains the nodes and removes them from the kubernetes cluster
+	CapabilityRemoveNodes = "removeNodes"
+	//CapabilityPreflightCheck checks the nodes before installing the kubernetes cluster
+	CapabilityPreflightCheck = "preflightCheck"
+)
+
+//Provider the metadata of a registered adaptor
+type Provider struct {
+	Name string `json:"name"`
+	// NeedCredentials is true if the adaptor is created with the access key of the enterprise.
+	NeedCredentials bool `json:"needCredentials"`
+	// Interfaces are the optional interfaces implemented by the adaptor.
+	Interfaces []string `json:"interfaces"`
+	// Capabilities are the actions supported by the adaptor.
+	Capabilities []string `json:"capabilities"`
+	// Create creates the adaptor. The access key is empty if NeedCredentials is false.
+	Create func(accessKeyID, accessKeySecret string) (RainbondClusterAdaptor, error) `json:"-"`
+}
+
+//Implements returns true if the adaptor implements the given optional interface
+func (p *Provider) Implements(iface string) bool {
+	return contains(p.Interfaces, iface)
+}
+
+//Supports returns true if the adaptor supports the given capability
+func (p *Provider) Supports(capability string) bool {
+	return contains(p.Capabilities, capability)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]*Provider)
+)
+
+//Register makes an adaptor available by the provider name.
+//It is usually called in the init function of the adaptor package, and panics if the name is registered twice.
+func Register(p *Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if p == nil || p.Create == nil {
+		panic("adaptor: Register provider is nil")
+	}
+	if _, ok := providers[p.Name]; ok {
+		panic(fmt.Sprintf("adaptor: Register called twice for provider %s", p.Name))
+	}
+	providers[p.Name] = p
+}
+
+//Unregister removes the provider, it is used by the tests registering the fake adaptors.
+func Unregister(name string) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	delete(providers, name)
+}
+
+//GetProvider returns the registered provider
+func GetProvider(name string) (*Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+//ListProviders returns the registered providers sorted by name
+func ListProviders() []*Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	var list []*Provider
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
@@ -22,9 +22,12 @@ import (
 	"context"
 	"crypto/x509"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/cmd"
 	"github.com/rancher/rke/hosts"
 	"github.com/rancher/rke/log"
 	"github.com/rancher/rke/pki"
@@ -32,6 +35,8 @@ import (
 	"github.com/rancher/rke/services"
 	v3 "github.com/rancher/rke/types"
 	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 )
 
 func rebuildClusterWithRotatedCertificates(ctx context.Context,
@@ -189,3 +194,98 @@ func GenerateRKECSRs(ctx context.Context, rkeConfig *v3.RancherKubernetesEngineC
 	}
 	return pki.WriteCertificates(kubeCluster.CertificateDir, certBundle)
 }
+
+//ListCertificates lists the certificates in the current state of the cluster
+func (r *rkeAdaptor) ListCertificates(eid, clusterID string) ([]*v1alpha1.Certificate, error) {
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	clusterState, err := r.getClusterState(context.Background(), rkecluster)
+	if err != nil {
+		return nil, err
+	}
+	return listCertificates(clusterState.CurrentState.CertificatesBundle, time.Now()), nil
+}
+
+func listCertificates(bundle map[string]pki.CertificatePKI, now time.Time) []*v1alpha1.Certificate {
+	var certificates []*v1alpha1.Certificate
+	for name, certPKI := range bundle {
+		if certPKI.Certificate == nil {
+			continue
+		}
+		certificates = append(certificates, &v1alpha1.Certificate{
+			Name:       name,
+			CommonName: certPKI.Certificate.Subject.CommonName,
+			NotBefore:  certPKI.Certificate.NotBefore,
+			NotAfter:   certPKI.Certificate.NotAfter,
+			Expired:    now.After(certPKI.Certificate.NotAfter),
+		})
+	}
+	sort.Slice(certificates, func(i, j int) bool {
+		return certificates[i].Name < certificates[j].Name
+	})
+	return certificates
+}
+
+//RotateCertificates rotates the certificates of the services, the kubeconfig of the cluster is updated after rotating.
+func (r *rkeAdaptor) RotateCertificates(ctx context.Context, eid, clusterID string, options v1alpha1.RotateCertificatesOptions, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support rotating certificates", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	// the rotation is only set to the state, cluster.yml is not changed so that the later updates do not rotate again
+	rkeConfig.RotateCertificates = &v3.RotateCertificates{
+		CACertificates: options.RotateCA,
+		Services:       options.Services,
+	}
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	if err := cmd.ClusterInit(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	kubeConfig := configs[pki.KubeAdminCertName].Config
+	if kubeConfig == "" {
+		// the kubeconfig is not regenerated if the kube-admin certificate is not rotated
+		clusterState, err := cluster.ReadStateFile(ctx, cluster.GetStateFilePath(flags.ClusterFilePath, flags.ConfigDir))
+		if err != nil {
+			return errors.Wrap(err, "read cluster state file")
+		}
+		kubeConfig = clusterState.CurrentState.CertificatesBundle[pki.KubeAdminCertName].Config
+	}
+	if kubeConfig != "" {
+		rkecluster.KubeConfig = kubeConfig
+	}
+	if APIURL != "" {
+		rkecluster.APIURL = APIURL
+	}
+	return r.Repo.Update(rkecluster)
+}
@@ -0,0 +1,56 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/rancher/rke/pki"
+)
+
+func TestListCertificates(t *testing.T) {
+	now := time.Now()
+	bundle := map[string]pki.CertificatePKI{
+		pki.KubeAPICertName: {Certificate: &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "kube-apiserver"},
+			NotBefore: now.Add(-time.Hour),
+			NotAfter:  now.Add(time.Hour),
+		}},
+		pki.CACertName: {Certificate: &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "kube-ca"},
+			NotBefore: now.Add(-2 * time.Hour),
+			NotAfter:  now.Add(-time.Hour),
+		}},
+		// the csr without certificate is not listed
+		"kube-etcd-csr": {},
+	}
+	certificates := listCertificates(bundle, now)
+	if len(certificates) != 2 {
+		t.Fatalf("expected 2 certificates, got %d", len(certificates))
+	}
+	if certificates[0].Name != pki.KubeAPICertName || certificates[0].Expired {
+		t.Errorf("unexpected certificate %+v", certificates[0])
+	}
+	if certificates[1].Name != pki.CACertName || certificates[1].CommonName != "kube-ca" || !certificates[1].Expired {
+		t.Errorf("unexpected certificate %+v", certificates[1])
+	}
+}
@@ -21,15 +21,38 @@ package rke
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/pkg/errors"
 	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/cmd"
 	"github.com/rancher/rke/hosts"
+	"github.com/rancher/rke/k8s"
 	"github.com/rancher/rke/log"
 	"github.com/rancher/rke/pki"
 	"github.com/rancher/rke/pki/cert"
 	v3 "github.com/rancher/rke/types"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 )
 
+type encryptionKey struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+// encryptionProviderConfig the aescbc keys of the encryption provider file, the first key is active
+type encryptionProviderConfig struct {
+	Resources []struct {
+		Providers []struct {
+			AESCBC *struct {
+				Keys []encryptionKey `json:"keys"`
+			} `json:"aescbc"`
+		} `json:"providers"`
+	} `json:"resources"`
+}
+
 //RotateEncryptionKey -
 func RotateEncryptionKey(
 	ctx context.Context,
@@ -93,3 +116,207 @@ func RotateEncryptionKey(
 	log.Infof(ctx, "Cluster secrets encryption key rotated successfully")
 	return APIURL, caCrt, clientCert, clientKey, kubeCluster.Certificates, nil
 }
+
+// encryptionRotationProgress reports the phases of the key rotation by the log written by rke,
+// the new key is deployed before the secrets are rewritten and the old key is removed after them.
+type encryptionRotationProgress struct {
+	ctx      context.Context
+	rollback func(step, message, status string)
+
+	lock sync.Mutex
+	step string
+}
+
+func newEncryptionRotationProgress(ctx context.Context, rollback func(step, message, status string)) *encryptionRotationProgress {
+	rollback(v1.StepDeployEncryptionKey, "", "start")
+	return &encryptionRotationProgress{ctx: ctx, rollback: rollback, step: v1.StepDeployEncryptionKey}
+}
+
+func (p *encryptionRotationProgress) Debugf(msg string, args ...interface{}) {
+	log.Debugf(p.ctx, msg, args...)
+}
+
+func (p *encryptionRotationProgress) Warnf(msg string, args ...interface{}) {
+	log.Warnf(p.ctx, msg, args...)
+}
+
+func (p *encryptionRotationProgress) Infof(msg string, args ...interface{}) {
+	log.Infof(p.ctx, msg, args...)
+	message := fmt.Sprintf(msg, args...)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	switch {
+	case p.step == v1.StepDeployEncryptionKey && strings.HasPrefix(message, "Rewriting cluster secrets"):
+		p.next(v1.StepRewriteSecrets)
+	case p.step == v1.StepRewriteSecrets && strings.Contains(message, "Operation completed"):
+		p.next(v1.StepRemoveEncryptionKey)
+	}
+}
+
+func (p *encryptionRotationProgress) next(step string) {
+	p.rollback(p.step, "", "success")
+	p.step = step
+	p.rollback(step, "", "start")
+}
+
+// finish reports the result of the current phase
+func (p *encryptionRotationProgress) finish(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err != nil {
+		p.rollback(p.step, err.Error(), "failure")
+		return
+	}
+	p.rollback(p.step, "", "success")
+}
+
+// activeEncryptionKey returns the first aescbc key of the encryption provider file
+func activeEncryptionKey(providerFile string) (*encryptionKey, error) {
+	var config encryptionProviderConfig
+	if err := k8s.DecodeYamlResource(&config, providerFile); err != nil {
+		return nil, err
+	}
+	for _, resource := range config.Resources {
+		for _, provider := range resource.Providers {
+			if provider.AESCBC != nil && len(provider.AESCBC.Keys) > 0 {
+				return &provider.AESCBC.Keys[0], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no aescbc key found in the encryption provider file")
+}
+
+//GetSecretsEncryptionStatus returns whether the secrets encryption is enabled in cluster.yml and applied to the cluster
+func (r *rkeAdaptor) GetSecretsEncryptionStatus(eid, clusterID string) (*v1alpha1.SecretsEncryptionStatus, error) {
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	rkeConfig, err := r.getClusterConfig(rkecluster)
+	if err != nil {
+		return nil, err
+	}
+	clusterState, err := r.getClusterState(context.Background(), rkecluster)
+	if err != nil {
+		return nil, err
+	}
+	return secretsEncryptionStatus(rkeConfig, clusterState), nil
+}
+
+func secretsEncryptionStatus(rkeConfig *v3.RancherKubernetesEngineConfig, clusterState *cluster.FullState) *v1alpha1.SecretsEncryptionStatus {
+	status := &v1alpha1.SecretsEncryptionStatus{}
+	if config := rkeConfig.Services.KubeAPI.SecretsEncryptionConfig; config != nil && config.Enabled {
+		status.Enabled = true
+		status.CustomConfig = config.CustomConfig != nil
+	}
+	current := clusterState.CurrentState.RancherKubernetesEngineConfig
+	if current == nil || current.Services.KubeAPI.SecretsEncryptionConfig == nil || !current.Services.KubeAPI.SecretsEncryptionConfig.Enabled {
+		return status
+	}
+	if clusterState.CurrentState.EncryptionConfig != "" {
+		status.Active = true
+		if key, err := activeEncryptionKey(clusterState.CurrentState.EncryptionConfig); err == nil {
+			status.ActiveKey = key.Name
+		}
+	}
+	return status
+}
+
+//EnableSecretsEncryption enables the secrets encryption in cluster.yml and updates the cluster, the existing secrets are rewritten encrypted.
+func (r *rkeAdaptor) EnableSecretsEncryption(ctx context.Context, eid, clusterID string, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support secrets encryption", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	if rkeConfig.Services.KubeAPI.SecretsEncryptionConfig == nil {
+		rkeConfig.Services.KubeAPI.SecretsEncryptionConfig = &v3.SecretsEncryptionConfig{}
+	}
+	rkeConfig.Services.KubeAPI.SecretsEncryptionConfig.Enabled = true
+	restore, err := writeClusterConfig(filePath, rkeConfig)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	if err := cmd.ClusterInit(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		restore()
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	// the encryption provider is deployed and the secrets are rewritten when reconciling the cluster
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if config := configs[pki.KubeAdminCertName].Config; config != "" {
+		rkecluster.KubeConfig = config
+	}
+	rkecluster.APIURL = APIURL
+	return r.Repo.Update(rkecluster)
+}
+
+//RotateSecretsEncryptionKey rotates the key of the secrets encryption, the phases are reported by rollback.
+func (r *rkeAdaptor) RotateSecretsEncryptionKey(ctx context.Context, eid, clusterID string, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	flags := cluster.GetExternalFlags(false, false, false, false, "", workspace.path(clusterConfigFile))
+	clusterState, err := cluster.ReadStateFile(ctx, cluster.GetStateFilePath(flags.ClusterFilePath, flags.ConfigDir))
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support rotating encryption key", "failure")
+		return errors.Wrap(err, "read cluster state file")
+	}
+	if clusterState.CurrentState.RancherKubernetesEngineConfig == nil {
+		rollback(v1.StepInitClusterConfig, "the cluster is not running", "failure")
+		return fmt.Errorf("the current state of cluster %s not found", rkecluster.Name)
+	}
+
+	// the secrets are rewritten with the local kubeconfig which is not kept in the store
+	kubeConfig := clusterState.DesiredState.CertificatesBundle[pki.KubeAdminCertName].Config
+	if err := pki.DeployAdminConfig(ctx, kubeConfig, pki.GetLocalKubeConfig(flags.ClusterFilePath, flags.ConfigDir)); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+	// the key of the applied config is rotated, the same as the rotate-encryption-key of rke
+	progress := newEncryptionRotationProgress(ctx, rollback)
+	_, _, _, _, _, err = cmd.RotateEncryptionKey(log.SetLogger(ctx, progress), clusterState.CurrentState.RancherKubernetesEngineConfig.DeepCopy(), r.dialersOptions(eid, rkecluster.ClusterID), flags)
+	progress.finish(err)
+	return err
+}
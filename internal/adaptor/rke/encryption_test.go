@@ -0,0 +1,109 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/templates"
+	v3 "github.com/rancher/rke/types"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+)
+
+func testEncryptionProviderFile(t *testing.T, keys ...*encryptionKey) string {
+	providerFile, err := templates.CompileTemplateFromMap(templates.MultiKeyEncryptionProviderFile, map[string]interface{}{"KeyList": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return providerFile
+}
+
+func TestActiveEncryptionKey(t *testing.T) {
+	newKey := &encryptionKey{Name: "key-new", Secret: "bmV3LXNlY3JldC0xMjM0NTY3ODkwMTIzNDU2"}
+	oldKey := &encryptionKey{Name: "key-old", Secret: "b2xkLXNlY3JldC0xMjM0NTY3ODkwMTIzNDU2"}
+	key, err := activeEncryptionKey(testEncryptionProviderFile(t, newKey, oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Name != newKey.Name || key.Secret != newKey.Secret {
+		t.Errorf("expected the active key %s, got %s", newKey.Name, key.Name)
+	}
+}
+
+func TestEncryptionRotationProgress(t *testing.T) {
+	var events []string
+	rollback := func(step, message, status string) {
+		events = append(events, step+" "+status)
+	}
+	progress := newEncryptionRotationProgress(context.Background(), rollback)
+	progress.Infof("[%s] Restarting %s on %s nodes..", "controlPlane", "kube-apiserver", "controlPlane")
+	progress.Infof("Rewriting cluster secrets")
+	progress.Infof("[%s] Operation completed, %v secrets rewritten", "rewrite-secrets", 3)
+	progress.Infof("[%s] Restarting %s on %s nodes..", "controlPlane", "kube-apiserver", "controlPlane")
+	progress.finish(nil)
+	expected := []string{
+		v1.StepDeployEncryptionKey + " start", v1.StepDeployEncryptionKey + " success",
+		v1.StepRewriteSecrets + " start", v1.StepRewriteSecrets + " success",
+		v1.StepRemoveEncryptionKey + " start", v1.StepRemoveEncryptionKey + " success",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+
+	// the old key is redeployed if the secrets are not rewritten
+	events = nil
+	progress = newEncryptionRotationProgress(context.Background(), rollback)
+	progress.Infof("Rewriting cluster secrets")
+	progress.Infof("[%s] Operation encountered error: %v", "rewrite-secrets", "timeout")
+	progress.Infof("[%s] Restarting %s on %s nodes..", "controlPlane", "kube-apiserver", "controlPlane")
+	progress.finish(errors.New("timeout"))
+	if last := events[len(events)-1]; last != v1.StepRewriteSecrets+" failure" {
+		t.Errorf("expected the rewrite fails, got %v", events)
+	}
+}
+
+func TestSecretsEncryptionStatus(t *testing.T) {
+	key := &encryptionKey{Name: "key-active", Secret: "YWN0aXZlLXNlY3JldC0xMjM0NTY3ODkwMTIz"}
+	providerFile := testEncryptionProviderFile(t, key)
+	enabled := &v3.RancherKubernetesEngineConfig{}
+	enabled.Services.KubeAPI.SecretsEncryptionConfig = &v3.SecretsEncryptionConfig{Enabled: true}
+
+	// enabled in cluster.yml but not applied
+	state := &cluster.FullState{}
+	status := secretsEncryptionStatus(enabled, state)
+	if !status.Enabled || status.Active {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	state.CurrentState.RancherKubernetesEngineConfig = enabled
+	state.CurrentState.EncryptionConfig = providerFile
+	status = secretsEncryptionStatus(enabled, state)
+	if !status.Active || status.ActiveKey != key.Name {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	status = secretsEncryptionStatus(&v3.RancherKubernetesEngineConfig{}, &cluster.FullState{})
+	if status.Enabled || status.Active {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
@@ -0,0 +1,263 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/cmd"
+	"github.com/rancher/rke/log"
+	"github.com/rancher/rke/pki"
+	v3 "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+// nodeDrainTimeout the max time to evict the pods of a node
+var nodeDrainTimeout = 10 * time.Minute
+
+// splitClusterNodes splits the nodes of the cluster config into the ones to remove and the remaining ones.
+func splitClusterNodes(configNodes []v3.RKEConfigNode, addresses []string) (removed, remaining []v3.RKEConfigNode, err error) {
+	toRemove := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		toRemove[address] = true
+	}
+	for _, node := range configNodes {
+		if toRemove[node.Address] {
+			removed = append(removed, node)
+			delete(toRemove, node.Address)
+			continue
+		}
+		remaining = append(remaining, node)
+	}
+	for address := range toRemove {
+		return nil, nil, fmt.Errorf("node %s not found in the cluster config", address)
+	}
+	return removed, remaining, nil
+}
+
+// kubeNodeName returns the name of the kubernetes node registered by rke
+func kubeNodeName(node v3.RKEConfigNode) string {
+	if node.HostnameOverride != "" {
+		return strings.ToLower(node.HostnameOverride)
+	}
+	return strings.ToLower(node.Address)
+}
+
+// clusterLogWriter writes the output of the drain to the cluster log
+type clusterLogWriter struct {
+	ctx context.Context
+}
+
+func (w clusterLogWriter) Write(p []byte) (int, error) {
+	log.Infof(w.ctx, "%s", strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+func newDrainHelper(ctx context.Context, kubeClient kubernetes.Interface) *drain.Helper {
+	return &drain.Helper{
+		Ctx:                 ctx,
+		Client:              kubeClient,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		GracePeriodSeconds:  -1,
+		Timeout:             nodeDrainTimeout,
+		Out:                 clusterLogWriter{ctx: ctx},
+		ErrOut:              clusterLogWriter{ctx: ctx},
+	}
+}
+
+// drainNode cordons the node and evicts its pods, the node not registered is skipped.
+func drainNode(ctx context.Context, kubeClient kubernetes.Interface, name string) (bool, error) {
+	node, err := kubeClient.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	helper := newDrainHelper(ctx, kubeClient)
+	if err := drain.RunCordonOrUncordon(helper, node, true); err != nil {
+		return false, errors.Wrap(err, "cordon node")
+	}
+	if err := drain.RunNodeDrain(helper, name); err != nil {
+		return true, errors.Wrap(err, "drain node")
+	}
+	return true, nil
+}
+
+func uncordonNode(ctx context.Context, kubeClient kubernetes.Interface, name string) {
+	node, err := kubeClient.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		logrus.Warningf("get node %s to uncordon failure %s", name, err.Error())
+		return
+	}
+	if err := drain.RunCordonOrUncordon(newDrainHelper(ctx, kubeClient), node, false); err != nil {
+		logrus.Warningf("uncordon node %s failure %s", name, err.Error())
+	}
+}
+
+//RemoveNodes cordons and drains the nodes through the kube api, removes them from the cluster with rke and deletes the node objects.
+func (r *rkeAdaptor) RemoveNodes(ctx context.Context, eid, clusterID string, nodes []string, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support removing nodes", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	removed, remaining, err := splitClusterNodes(rkeConfig.Nodes, nodes)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	kubeClient, _, err := (&v1alpha1.KubeConfig{Config: rkecluster.KubeConfig}).GetKubeClient()
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return errors.Wrap(err, "create kube client")
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	// the cordoned nodes are made schedulable again if they are not removed
+	var cordoned []string
+	uncordon := func() {
+		for _, name := range cordoned {
+			uncordonNode(context.Background(), kubeClient, name)
+		}
+	}
+	for _, node := range removed {
+		step := v1alpha1.NodeStepType(v1.StepDrainNode, node.Address)
+		rollback(step, "", "start")
+		name := kubeNodeName(node)
+		registered, err := drainNode(ctx, kubeClient, name)
+		if registered {
+			cordoned = append(cordoned, name)
+		}
+		if err != nil {
+			rollback(step, err.Error(), "failure")
+			uncordon()
+			return errors.Wrapf(err, "drain node %s", node.Address)
+		}
+		if !registered {
+			rollback(step, "the node is not registered in the cluster", "success")
+			continue
+		}
+		rollback(step, "", "success")
+	}
+
+	rkeConfig.Nodes = remaining
+	restore, err := writeClusterConfig(filePath, rkeConfig)
+	if err != nil {
+		uncordon()
+		return err
+	}
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	if err := cmd.ClusterInit(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
+		restore()
+		uncordon()
+		return err
+	}
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
+	if err != nil {
+		// the removed nodes are still in the cluster with the old config
+		restore()
+		uncordon()
+		return err
+	}
+	rkecluster.KubeConfig = configs[pki.KubeAdminCertName].Config
+	rkecluster.APIURL = APIURL
+	rkecluster.NodeList = removeNodeList(rkecluster.NodeList, nodes)
+	if err := r.Repo.Update(rkecluster); err != nil {
+		logrus.Errorf("update rke cluster %s nodes failure %s", rkecluster.Name, err.Error())
+	}
+
+	// the api server in the old kubeconfig may be on a removed node
+	if kubeClient, _, err = (&v1alpha1.KubeConfig{Config: rkecluster.KubeConfig}).GetKubeClient(); err != nil {
+		return errors.Wrap(err, "create kube client")
+	}
+	var failed []string
+	for _, node := range removed {
+		step := v1alpha1.NodeStepType(v1.StepRemoveNode, node.Address)
+		rollback(step, "", "start")
+		err := kubeClient.CoreV1().Nodes().Delete(ctx, kubeNodeName(node), metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			rollback(step, err.Error(), "failure")
+			failed = append(failed, node.Address)
+			continue
+		}
+		rollback(step, "", "success")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("the node objects of %v are not deleted", failed)
+	}
+	return nil
+}
+
+// removeNodeList removes the nodes from the node list saved in json
+func removeNodeList(nodeList string, addresses []string) string {
+	var nodes v1alpha1.NodeList
+	if err := json.Unmarshal([]byte(nodeList), &nodes); err != nil {
+		return nodeList
+	}
+	var remaining v1alpha1.NodeList
+	for _, node := range nodes {
+		var removed bool
+		for _, address := range addresses {
+			if node.IP == address {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			remaining = append(remaining, node)
+		}
+	}
+	out, _ := json.Marshal(remaining)
+	return string(out)
+}
@@ -0,0 +1,73 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"context"
+	"testing"
+
+	v3 "github.com/rancher/rke/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSplitClusterNodes(t *testing.T) {
+	nodes := []v3.RKEConfigNode{{Address: "192.168.1.1"}, {Address: "192.168.1.2"}, {Address: "192.168.1.3"}}
+	removed, remaining, err := splitClusterNodes(nodes, []string{"192.168.1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Address != "192.168.1.2" || len(remaining) != 2 {
+		t.Fatalf("unexpected removed %v remaining %v", removed, remaining)
+	}
+	if _, _, err := splitClusterNodes(nodes, []string{"192.168.1.4"}); err == nil {
+		t.Fatal("expected the unknown node is rejected")
+	}
+}
+
+func TestRemoveNodeList(t *testing.T) {
+	nodeList := `[{"ip":"192.168.1.1","roles":["etcd"]},{"ip":"192.168.1.2","roles":["worker"]}]`
+	if got := removeNodeList(nodeList, []string{"192.168.1.2"}); got != `[{"ip":"192.168.1.1","roles":["etcd"]}]` {
+		t.Fatalf("unexpected node list %s", got)
+	}
+}
+
+func TestDrainNode(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	registered, err := drainNode(context.Background(), kubeClient, "node1")
+	if err != nil || !registered {
+		t.Fatalf("drain node: registered %v, err %v", registered, err)
+	}
+	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.Spec.Unschedulable {
+		t.Fatal("expected the node is cordoned")
+	}
+	uncordonNode(context.Background(), kubeClient, "node1")
+	if node, _ = kubeClient.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{}); node.Spec.Unschedulable {
+		t.Fatal("expected the node is uncordoned")
+	}
+
+	if registered, err := drainNode(context.Background(), kubeClient, "node2"); err != nil || registered {
+		t.Fatalf("expected the unregistered node is skipped: registered %v, err %v", registered, err)
+	}
+}
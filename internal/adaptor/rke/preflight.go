@@ -0,0 +1,369 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rke/services"
+	v3 "github.com/rancher/rke/types"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	sshutil "goodrain.com/cloud-adaptor/pkg/util/ssh"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// The checks of the preflight.
+const (
+	preflightCheckSSH           = "ssh"
+	preflightCheckDocker        = "docker"
+	preflightCheckPorts         = "ports"
+	preflightCheckKernelModules = "kernelModules"
+	preflightCheckSwap          = "swap"
+	preflightCheckDisk          = "disk"
+	preflightCheckTime          = "time"
+	preflightCheckHostname      = "hostname"
+)
+
+const (
+	// the commands may hang if the docker daemon or the disk does not respond
+	preflightDockerCommand   = "timeout 10 docker version --format '{{.Server.Version}}'"
+	preflightPortsCommand    = "ss -Htln 2>/dev/null || netstat -tln"
+	preflightModulesCommand  = "cat /proc/modules; cat /lib/modules/$(uname -r)/modules.builtin 2>/dev/null; true"
+	preflightSwapCommand     = "cat /proc/swaps"
+	preflightDiskCommand     = "timeout 10 df -Pk /var/lib/docker 2>/dev/null || timeout 10 df -Pk /"
+	preflightTimeCommand     = "date +%s"
+	preflightHostnameCommand = "hostname"
+)
+
+var (
+	// preflightMinDockerVersion the docker of the lower version is not tested with rke
+	preflightMinDockerVersion = utilversion.MustParseGeneric("19.03.0")
+	// preflightMinDiskKB and preflightRecommendedDiskKB the free space of the docker root dir
+	preflightMinDiskKB         int64 = 10 * 1024 * 1024
+	preflightRecommendedDiskKB int64 = 30 * 1024 * 1024
+	// preflightWarnTimeSkew and preflightMaxTimeSkew the time difference between the node and cloud adaptor
+	preflightWarnTimeSkew = 2 * time.Second
+	preflightMaxTimeSkew  = 30 * time.Second
+	// preflightKernelModules the kernel modules required by docker, kube-proxy and the network plugins
+	preflightKernelModules = []string{"br_netfilter", "overlay", "ip_tables", "iptable_filter", "iptable_nat",
+		"nf_conntrack", "nf_nat", "veth", "vxlan", "xt_conntrack"}
+)
+
+// preflightPorts returns the tcp ports the node listens on after the install
+func preflightPorts(node v3.RKEConfigNode) []int {
+	ports := []int{10250, 10256}
+	for _, role := range node.Role {
+		switch role {
+		case services.ETCDRole:
+			ports = append(ports, 2379, 2380)
+		case services.ControlRole:
+			ports = append(ports, 6443)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// commandRunner runs the command on the node
+type commandRunner interface {
+	Run(command string) (string, error)
+}
+
+//PreflightCheck checks the nodes of the rke config through ssh with the enterprise key, or the cloud adaptor key if the enterprise has none, before installing.
+//The nodes are reached through the bastion of the rke config, or the bastion of the config if it is set.
+func (r *rkeAdaptor) PreflightCheck(config *v1alpha1.KubernetesClusterConfig) (*v1alpha1.PreflightReport, error) {
+	if config.RKEConfig == nil {
+		return nil, errors.New("rke config is required")
+	}
+	return r.preflightCheck(config.EnterpriseID, "", config.RKEConfig, config.Bastion)
+}
+
+// preflightCheck checks the nodes of the rke config, the nodes are reached through the bastion of the rke config,
+// the bastion if it is not nil, or the bastion saved for the cluster.
+func (r *rkeAdaptor) preflightCheck(eid, clusterID string, rkeConfig *v3.RancherKubernetesEngineConfig, bastion *v1alpha1.SSHBastion) (*v1alpha1.PreflightReport, error) {
+	key, err := r.enterpriseSSHKey(eid)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		if _, err := sshutil.GetOrMakeSSHRSA(); err != nil {
+			return nil, err
+		}
+		if key, err = sshutil.ReadPrivateKey(defaultSSHKeyPath); err != nil {
+			return nil, err
+		}
+	}
+	dialer := bastion.Dialer(key)
+	if dialer == nil {
+		host, err := r.clusterBastion(eid, clusterID, key)
+		if err != nil {
+			return nil, err
+		}
+		if host != nil {
+			if dialer, err = bastionDialer(host, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if rkeConfig.BastionHost.Address != "" {
+		if dialer, err = bastionDialer(&rkeConfig.BastionHost, key); err != nil {
+			return nil, err
+		}
+	}
+	nodes := rkeConfig.Nodes
+	reports := make([]*v1alpha1.NodePreflightReport, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i] = preflightNode(dialer, nodes[i], key)
+		}(i)
+	}
+	wg.Wait()
+	checkHostnames(nodes, reports)
+	return v1alpha1.NewPreflightReport(reports), nil
+}
+
+// preflightNode connects to the node through the bastion if it is not nil with its own key in the rke config or the cloud adaptor key, and checks the node.
+func preflightNode(bastion *sshutil.Bastion, node v3.RKEConfigNode, key []byte) *v1alpha1.NodePreflightReport {
+	report := &v1alpha1.NodePreflightReport{Address: node.Address}
+	if node.SSHKey != "" {
+		key = []byte(node.SSHKey)
+	}
+	port, _ := strconv.Atoi(node.Port)
+	if port == 0 {
+		port = 22
+	}
+	user := node.User
+	if user == "" {
+		user = "docker"
+	}
+	client, err := sshutil.Dial(bastion, node.Address, uint(port), user, key)
+	if err != nil {
+		report.AddCheck(preflightCheckSSH, v1alpha1.PreflightFail, err.Error())
+		return report
+	}
+	defer client.Close()
+	report.AddCheck(preflightCheckSSH, v1alpha1.PreflightPass, fmt.Sprintf("logged in as %s", user))
+	checkNode(client, node, report)
+	return report
+}
+
+// checkNode runs the checks on the node, the hostname of the node is recorded for checkHostnames.
+func checkNode(runner commandRunner, node v3.RKEConfigNode, report *v1alpha1.NodePreflightReport) {
+	output, err := runner.Run(preflightDockerCommand)
+	status, message := checkDockerVersion(output, err)
+	report.AddCheck(preflightCheckDocker, status, message)
+
+	checks := []struct {
+		name    string
+		command string
+		check   func(output string) (string, string)
+	}{
+		{name: preflightCheckPorts, command: preflightPortsCommand, check: func(output string) (string, string) {
+			return checkPorts(output, preflightPorts(node))
+		}},
+		{name: preflightCheckKernelModules, command: preflightModulesCommand, check: checkKernelModules},
+		{name: preflightCheckSwap, command: preflightSwapCommand, check: checkSwap},
+		{name: preflightCheckDisk, command: preflightDiskCommand, check: checkDisk},
+	}
+	for _, c := range checks {
+		output, err := runner.Run(c.command)
+		if err != nil {
+			report.AddCheck(c.name, v1alpha1.PreflightWarn, err.Error())
+			continue
+		}
+		status, message := c.check(output)
+		report.AddCheck(c.name, status, message)
+	}
+
+	before := time.Now()
+	output, err = runner.Run(preflightTimeCommand)
+	after := time.Now()
+	if err != nil {
+		report.AddCheck(preflightCheckTime, v1alpha1.PreflightWarn, err.Error())
+	} else {
+		status, message := checkTimeSkew(output, before, after)
+		report.AddCheck(preflightCheckTime, status, message)
+	}
+
+	if output, err = runner.Run(preflightHostnameCommand); err == nil {
+		report.Hostname = strings.TrimSpace(output)
+	}
+}
+
+func checkDockerVersion(output string, err error) (string, string) {
+	if err != nil {
+		return v1alpha1.PreflightFail, fmt.Sprintf("the docker daemon is not accessible: %s", err.Error())
+	}
+	version := strings.TrimSpace(output)
+	parsed, err := utilversion.ParseGeneric(version)
+	if err != nil {
+		return v1alpha1.PreflightWarn, fmt.Sprintf("unknown docker version %s", version)
+	}
+	if parsed.LessThan(preflightMinDockerVersion) {
+		return v1alpha1.PreflightWarn, fmt.Sprintf("docker %s is older than %s", version, preflightMinDockerVersion)
+	}
+	return v1alpha1.PreflightPass, fmt.Sprintf("docker %s", version)
+}
+
+// checkPorts checks the output of ss -Htln or netstat -tln, the local address is the fourth field of both.
+func checkPorts(output string, ports []int) (string, string) {
+	listening := make(map[int]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		address := fields[3]
+		port, err := strconv.Atoi(address[strings.LastIndex(address, ":")+1:])
+		if err != nil {
+			continue
+		}
+		listening[port] = true
+	}
+	var used []string
+	for _, port := range ports {
+		if listening[port] {
+			used = append(used, strconv.Itoa(port))
+		}
+	}
+	if len(used) > 0 {
+		return v1alpha1.PreflightFail, fmt.Sprintf("the ports %s are in use", strings.Join(used, ","))
+	}
+	return v1alpha1.PreflightPass, ""
+}
+
+// checkKernelModules checks the loaded modules in /proc/modules and the builtin ones in modules.builtin
+func checkKernelModules(output string) (string, string) {
+	modules := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(path.Base(fields[0]), ".ko")
+		modules[strings.ReplaceAll(name, "-", "_")] = true
+	}
+	var missing []string
+	for _, module := range preflightKernelModules {
+		if !modules[module] {
+			missing = append(missing, module)
+		}
+	}
+	if len(missing) > 0 {
+		return v1alpha1.PreflightWarn, fmt.Sprintf("the kernel modules %s are not loaded", strings.Join(missing, ","))
+	}
+	return v1alpha1.PreflightPass, ""
+}
+
+// checkSwap checks /proc/swaps, the first line is the header
+func checkSwap(output string) (string, string) {
+	var swaps []string
+	for i, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if fields := strings.Fields(line); i > 0 && len(fields) > 0 {
+			swaps = append(swaps, fields[0])
+		}
+	}
+	if len(swaps) > 0 {
+		return v1alpha1.PreflightWarn, fmt.Sprintf("swap is enabled on %s", strings.Join(swaps, ","))
+	}
+	return v1alpha1.PreflightPass, ""
+}
+
+// checkDisk checks the available space in the output of df -Pk
+func checkDisk(output string) (string, string) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 6 {
+		return v1alpha1.PreflightWarn, "unknown disk space"
+	}
+	available, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return v1alpha1.PreflightWarn, "unknown disk space"
+	}
+	message := fmt.Sprintf("%dGB available on %s", available/1024/1024, fields[5])
+	if available < preflightMinDiskKB {
+		return v1alpha1.PreflightFail, message
+	}
+	if available < preflightRecommendedDiskKB {
+		return v1alpha1.PreflightWarn, message
+	}
+	return v1alpha1.PreflightPass, message
+}
+
+// checkTimeSkew compares the unix time of the node with the middle of the time the command runs
+func checkTimeSkew(output string, before, after time.Time) (string, string) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return v1alpha1.PreflightWarn, fmt.Sprintf("unknown time %s", strings.TrimSpace(output))
+	}
+	local := before.Add(after.Sub(before) / 2)
+	skew := time.Duration(math.Abs(float64(time.Unix(seconds, 0).Sub(local.Truncate(time.Second)))))
+	message := fmt.Sprintf("the time differs by %s", skew)
+	if skew > preflightMaxTimeSkew {
+		return v1alpha1.PreflightFail, message
+	}
+	if skew > preflightWarnTimeSkew {
+		return v1alpha1.PreflightWarn, message
+	}
+	return v1alpha1.PreflightPass, message
+}
+
+// checkHostnames checks the nodes have unique names, the hostname_override in the rke config is used as the name if it is set.
+func checkHostnames(nodes []v3.RKEConfigNode, reports []*v1alpha1.NodePreflightReport) {
+	names := make(map[string][]string)
+	for i, node := range nodes {
+		name := strings.ToLower(node.HostnameOverride)
+		if name == "" {
+			name = strings.ToLower(reports[i].Hostname)
+		}
+		if name != "" {
+			names[name] = append(names[name], node.Address)
+		}
+	}
+	for i, node := range nodes {
+		report := reports[i]
+		name := strings.ToLower(node.HostnameOverride)
+		if name == "" {
+			name = strings.ToLower(report.Hostname)
+		}
+		if name == "" {
+			// the node is not reachable or the hostname is unknown
+			if len(report.Checks) > 1 {
+				report.AddCheck(preflightCheckHostname, v1alpha1.PreflightWarn, "unknown hostname")
+			}
+			continue
+		}
+		if others := names[name]; len(others) > 1 {
+			report.AddCheck(preflightCheckHostname, v1alpha1.PreflightFail, fmt.Sprintf("the name %s is used by the nodes %s", name, strings.Join(others, ",")))
+			continue
+		}
+		report.AddCheck(preflightCheckHostname, v1alpha1.PreflightPass, name)
+	}
+}
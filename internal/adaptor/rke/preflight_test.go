@@ -0,0 +1,131 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rke/types"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+// fakeRunner returns the output of the commands
+type fakeRunner map[string]string
+
+func (f fakeRunner) Run(command string) (string, error) {
+	output, ok := f[command]
+	if !ok {
+		return "", fmt.Errorf("command %s failed", command)
+	}
+	return output, nil
+}
+
+func TestCheckNode(t *testing.T) {
+	runner := fakeRunner{
+		preflightDockerCommand: "20.10.7\n",
+		preflightPortsCommand: "LISTEN 0 128 0.0.0.0:22 0.0.0.0:*\n" +
+			"LISTEN 0 128 [::]:2379 [::]:*\n",
+		preflightModulesCommand: "br_netfilter 28672 0 - Live 0x0\noverlay 126976 0 - Live 0x0\nkernel/net/ipv4/netfilter/ip_tables.ko\n" +
+			"kernel/net/ipv4/netfilter/iptable_filter.ko\nkernel/net/ipv4/netfilter/iptable_nat.ko\nnf_conntrack 1 0\nnf_nat 1 0\nveth 1 0\nvxlan 1 0\nxt_conntrack 1 0\n",
+		preflightSwapCommand:     "Filename\tType\tSize\tUsed\tPriority\n",
+		preflightDiskCommand:     "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/vda1 104857600 10485760 94371840 10% /\n",
+		preflightTimeCommand:     fmt.Sprintf("%d\n", time.Now().Unix()),
+		preflightHostnameCommand: "node1\n",
+	}
+	report := &v1alpha1.NodePreflightReport{Address: "192.168.1.1"}
+	checkNode(runner, v3.RKEConfigNode{Address: "192.168.1.1", Role: []string{"etcd", "worker"}}, report)
+	if report.Hostname != "node1" {
+		t.Errorf("unexpected hostname %s", report.Hostname)
+	}
+	results := make(map[string]string)
+	for _, check := range report.Checks {
+		results[check.Name] = check.Status
+	}
+	expected := map[string]string{
+		preflightCheckDocker:        v1alpha1.PreflightPass,
+		preflightCheckPorts:         v1alpha1.PreflightFail,
+		preflightCheckKernelModules: v1alpha1.PreflightPass,
+		preflightCheckSwap:          v1alpha1.PreflightPass,
+		preflightCheckDisk:          v1alpha1.PreflightPass,
+		preflightCheckTime:          v1alpha1.PreflightPass,
+	}
+	for name, status := range expected {
+		if results[name] != status {
+			t.Errorf("check %s: expected %s, got %s", name, status, results[name])
+		}
+	}
+	if report.Status != v1alpha1.PreflightFail {
+		t.Errorf("expected the node fails, got %s", report.Status)
+	}
+}
+
+func TestPreflightChecks(t *testing.T) {
+	if status, _ := checkDockerVersion("", fmt.Errorf("permission denied")); status != v1alpha1.PreflightFail {
+		t.Errorf("expected the inaccessible docker fails, got %s", status)
+	}
+	if status, _ := checkDockerVersion("18.09.9", nil); status != v1alpha1.PreflightWarn {
+		t.Errorf("expected the old docker warns, got %s", status)
+	}
+	if status, message := checkPorts("tcp 0 0 0.0.0.0:6443 0.0.0.0:* LISTEN\n", []int{6443, 10250}); status != v1alpha1.PreflightFail || !strings.Contains(message, "6443") {
+		t.Errorf("expected the used port fails, got %s %s", status, message)
+	}
+	if status, message := checkKernelModules("br_netfilter 1 0\n"); status != v1alpha1.PreflightWarn || !strings.Contains(message, "overlay") {
+		t.Errorf("expected the missing modules warn, got %s %s", status, message)
+	}
+	if status, _ := checkSwap("Filename Type Size Used Priority\n/swapfile file 1048572 0 -2\n"); status != v1alpha1.PreflightWarn {
+		t.Errorf("expected the swap warns, got %s", status)
+	}
+	if status, _ := checkDisk("Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/vda1 10485760 8388608 2097152 80% /\n"); status != v1alpha1.PreflightFail {
+		t.Errorf("expected the small disk fails, got %s", status)
+	}
+	now := time.Now()
+	if status, _ := checkTimeSkew(fmt.Sprintf("%d", now.Add(time.Minute).Unix()), now, now); status != v1alpha1.PreflightFail {
+		t.Errorf("expected the time skew fails, got %s", status)
+	}
+	if status, _ := checkTimeSkew(fmt.Sprintf("%d", now.Add(5*time.Second).Unix()), now, now); status != v1alpha1.PreflightWarn {
+		t.Errorf("expected the time skew warns, got %s", status)
+	}
+}
+
+func TestCheckHostnames(t *testing.T) {
+	nodes := []v3.RKEConfigNode{{Address: "192.168.1.1"}, {Address: "192.168.1.2"}, {Address: "192.168.1.3", HostnameOverride: "node3"}, {Address: "192.168.1.4"}}
+	reports := []*v1alpha1.NodePreflightReport{
+		{Address: "192.168.1.1", Hostname: "localhost"},
+		{Address: "192.168.1.2", Hostname: "LOCALHOST"},
+		{Address: "192.168.1.3", Hostname: "localhost"},
+		{Address: "192.168.1.4"},
+	}
+	reports[3].AddCheck(preflightCheckSSH, v1alpha1.PreflightFail, "connect failure")
+	checkHostnames(nodes, reports)
+	for i, status := range []string{v1alpha1.PreflightFail, v1alpha1.PreflightFail, v1alpha1.PreflightPass} {
+		if last := reports[i].Checks[len(reports[i].Checks)-1]; last.Name != preflightCheckHostname || last.Status != status {
+			t.Errorf("node %s: expected hostname %s, got %+v", nodes[i].Address, status, last)
+		}
+	}
+	if len(reports[3].Checks) != 1 {
+		t.Errorf("expected the unreachable node is not checked, got %+v", reports[3].Checks)
+	}
+	report := v1alpha1.NewPreflightReport(reports)
+	if report.Status != v1alpha1.PreflightFail || len(report.Failures()) != 3 {
+		t.Errorf("unexpected report %s %v", report.Status, report.Failures())
+	}
+}
@@ -40,11 +40,13 @@ import (
 	"github.com/rancher/rke/pki/cert"
 	v3 "github.com/rancher/rke/types"
 	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
 	"goodrain.com/cloud-adaptor/internal/adaptor"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 	"goodrain.com/cloud-adaptor/internal/datastore"
 	"goodrain.com/cloud-adaptor/internal/model"
 	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
 	yaml "gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/version"
@@ -52,12 +54,52 @@ import (
 
 type rkeAdaptor struct {
 	Repo repo.RKEClusterRepository
+	// Store saves cluster.yml, cluster.rkestate and create.log of the clusters
+	Store blobstore.Store
+	// Keys the ssh keys of the enterprises which the nodes are logged in with
+	Keys repo.SSHKeyRepository
+	// Bastions the bastions which the nodes of the clusters are reached through
+	Bastions repo.SSHBastionRepository
+	// Leases keeps the operations of one cluster from materialising its state at the same time
+	Leases repo.LeaseRepository
+}
+
+func init() {
+	adaptor.Register(&adaptor.Provider{
+		Name: "rke",
+		Interfaces: []string{
+			adaptor.InterfaceKubernetesUpgrader,
+			adaptor.InterfaceCertificateRotator,
+			adaptor.InterfaceSecretsEncryptor,
+			adaptor.InterfaceEtcdSnapshotter,
+			adaptor.InterfaceNodeRemover,
+			adaptor.InterfacePreflightChecker,
+		},
+		Capabilities: []string{
+			adaptor.CapabilityCreateCluster,
+			adaptor.CapabilityExpandNodes,
+			adaptor.CapabilityDeleteCluster,
+			adaptor.CapabilityUpgradeKubernetes,
+			adaptor.CapabilityRotateCertificates,
+			adaptor.CapabilitySecretsEncryption,
+			adaptor.CapabilityEtcdSnapshot,
+			adaptor.CapabilityRemoveNodes,
+			adaptor.CapabilityPreflightCheck,
+		},
+		Create: func(accessKeyID, accessKeySecret string) (adaptor.RainbondClusterAdaptor, error) {
+			return Create()
+		},
+	})
 }
 
 //Create create ack adaptor
 func Create() (adaptor.RainbondClusterAdaptor, error) {
 	return &rkeAdaptor{
-		Repo: repo.NewRKEClusterRepo(datastore.GetGDB()),
+		Repo:     repo.NewRKEClusterRepo(datastore.GetGDB()),
+		Store:    repo.NewRKEStateStore(datastore.GetGDB()),
+		Keys:     repo.NewSSHKeyRepo(datastore.GetGDB()),
+		Bastions: repo.NewSSHBastionRepo(datastore.GetGDB()),
+		Leases:   repo.NewLeaseRepo(datastore.GetGDB()),
 	}, nil
 }
 
@@ -141,21 +183,21 @@ func (r *rkeAdaptor) GetRainbondInitConfig(
 }
 
 func (r *rkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, config *v1alpha1.KubernetesClusterConfig, rollback func(step, message, status string)) *v1alpha1.Cluster {
-	rollback("InitClusterConfig", "", "start")
+	rollback(v1.StepInitClusterConfig, "", "start")
 	rkecluster, err := r.Repo.GetCluster(eid, config.ClusterName)
 	if err != nil {
 		logrus.Errorf("get cluster meta info failure %s", err.Error())
-		rollback("InitClusterConfig", "Get cluster meta info failure", "failure")
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
 		return nil
 	}
 
 	rkeConfig := config.RKEConfig
 	if rkeConfig == nil {
-		rollback("InitClusterConfig", "RKE config not found", "failure")
+		rollback(v1.StepInitClusterConfig, "RKE config not found", "failure")
 		return nil
 	}
 	if len(rkeConfig.Nodes) < 0 {
-		rollback("InitClusterConfig", "Provide at least one node", "failure")
+		rollback(v1.StepInitClusterConfig, "Provide at least one node", "failure")
 		return nil
 	}
 	var masterNode, etcdNode, workerNode int
@@ -171,47 +213,51 @@ func (r *rkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 		}
 	}
 	if workerNode == 0 {
-		rollback("InitClusterConfig", "Provide at least one compute node", "failure")
+		rollback(v1.StepInitClusterConfig, "Provide at least one compute node", "failure")
 		return nil
 	}
 	if masterNode == 0 {
-		rollback("InitClusterConfig", "Provide at least one master node", "failure")
+		rollback(v1.StepInitClusterConfig, "Provide at least one master node", "failure")
 		return nil
 	}
 	if etcdNode == 0 {
-		rollback("InitClusterConfig", "Provide at least one etcd node", "failure")
+		rollback(v1.StepInitClusterConfig, "Provide at least one etcd node", "failure")
 		return nil
 	}
 
 	// create rke cluster config
-	configDir := "/tmp"
-	if os.Getenv("CONFIG_DIR") != "" {
-		configDir = os.Getenv("CONFIG_DIR")
-	}
-	clusterStatPath := fmt.Sprintf("%s/enterprise/%s/rke/%s", configDir, config.EnterpriseID, config.ClusterName)
-
 	if rkecluster.Stats == v1alpha1.InstallFailed {
 		//TODO: This action will result in an inconsistency with the configuration of the node
 		// if the configuration such as the SSL certificate has been passed to the node.
 
-		// clear local state data
-		os.RemoveAll(clusterStatPath)
+		// clear state data
+		if err := repo.DeleteRKEState(r.Store, rkecluster.EnterpriseID, rkecluster.Name); err != nil {
+			logrus.Errorf("clear rke cluster %s state failure %s", rkecluster.Name, err.Error())
+		}
 		rkecluster.Stats = v1alpha1.InitState
 		if err := r.Repo.Update(rkecluster); err != nil {
 			logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
 		}
 	}
 	if rkecluster.Stats == v1alpha1.InitState {
-		// clear local state data
-		os.RemoveAll(clusterStatPath)
+		// clear state data
+		if err := repo.DeleteRKEState(r.Store, rkecluster.EnterpriseID, rkecluster.Name); err != nil {
+			logrus.Errorf("clear rke cluster %s state failure %s", rkecluster.Name, err.Error())
+		}
 	}
 
-	os.MkdirAll(clusterStatPath, 0755)
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		logrus.Errorf("open rke cluster workspace failure %s", err.Error())
+		return nil
+	}
+	defer workspace.close()
 
-	filePath := fmt.Sprintf("%s/cluster.yml", clusterStatPath)
+	filePath := workspace.path(clusterConfigFile)
 	out, _ := yaml.Marshal(rkeConfig)
 	if err := ioutil.WriteFile(filePath, out, 0755); err != nil {
-		rollback("InitClusterConfig", err.Error(), "failure")
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
 		logrus.Errorf("write rke cluster config file failure %s", err.Error())
 		return nil
 	}
@@ -222,20 +268,11 @@ func (r *rkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 	defer cancel()
 
 	// set install log out
-	logPath := fmt.Sprintf("%s/create.log", clusterStatPath)
-	writer, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE, 0755)
-	if err != nil {
-		logrus.Errorf("open create cluster log file %s failure %s", logPath, err.Error())
-	}
-	logger := logrus.New()
-	if writer != nil {
-		defer writer.Close()
-		logger.Out = writer
-	}
-	ctx = log.SetLogger(ctx, logger)
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
 
 	// update cluster meta info
-	rkecluster.CreateLogPath = logPath
+	rkecluster.CreateLogPath = workspace.prefix + clusterLogFile
 	rkecluster.PodCIDR = rkeConfig.Services.KubeController.ClusterCIDR
 	rkecluster.ServiceCIDR = rkeConfig.Services.KubeController.ServiceClusterIPRange
 	var kubernetesVersion = "v1.23.10-rancher1"
@@ -251,25 +288,46 @@ func (r *rkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 	}
 
 	// cluster init
-	if err := cmd.ClusterInit(ctx, rkeConfig, hosts.DialersOptions{}, flags); err != nil {
-		rollback("InitClusterConfig", err.Error(), "failure")
+	if err := cmd.ClusterInit(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
 		rkecluster.Stats = v1alpha1.InstallFailed
 		if err := r.Repo.Update(rkecluster); err != nil {
 			logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
 		}
 		return nil
 	}
-	rollback("InitClusterConfig", "init cluster config success", "success")
+	rollback(v1.StepInitClusterConfig, "init cluster config success", "success")
+
+	// the install does not start if any node fails the preflight checks
+	if !config.SkipPreflight {
+		rollback(v1.StepPreflightCheck, "", "start")
+		var failure string
+		report, err := r.preflightCheck(eid, rkecluster.ClusterID, rkeConfig, nil)
+		if err != nil {
+			failure = err.Error()
+		} else if report.Status == v1alpha1.PreflightFail {
+			failure = strings.Join(report.Failures(), "; ")
+		}
+		if failure != "" {
+			rollback(v1.StepPreflightCheck, failure, "failure")
+			rkecluster.Stats = v1alpha1.InstallFailed
+			if err := r.Repo.Update(rkecluster); err != nil {
+				logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
+			}
+			return nil
+		}
+		rollback(v1.StepPreflightCheck, report.Status, "success")
+	}
 
 	// cluster install and up
-	rollback("InstallKubernetes", "", "start")
-	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, hosts.DialersOptions{}, flags, map[string]interface{}{})
+	rollback(v1.StepInstallKubernetes, "", "start")
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
 	if err != nil {
 		rkecluster.Stats = v1alpha1.InstallFailed
 		if err := r.Repo.Update(rkecluster); err != nil {
 			logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
 		}
-		rollback("InstallKubernetes", err.Error(), "failure")
+		rollback(v1.StepInstallKubernetes, err.Error(), "failure")
 		return nil
 	}
 	rkecluster.KubeConfig = configs[pki.KubeAdminCertName].Config
@@ -279,7 +337,7 @@ func (r *rkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, c
 	if err := r.Repo.Update(rkecluster); err != nil {
 		logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
 	}
-	rollback("InstallKubernetes", rkecluster.ClusterID, "success")
+	rollback(v1.StepInstallKubernetes, rkecluster.ClusterID, "success")
 	return converClusterMeta(rkecluster)
 }
 
@@ -359,10 +417,10 @@ func (r *rkeAdaptor) CreateCluster(eid string, config v1alpha1.CreateClusterConf
 	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
 	// cluster init
 
-	if err := cmd.ClusterInit(context.Background(), rkeConfig, hosts.DialersOptions{}, flags); err != nil {
+	if err := cmd.ClusterInit(context.Background(), rkeConfig, r.dialersOptions(eid, ""), flags); err != nil {
 		return nil, err
 	}
-	_, _, _, _, _, err := r.ClusterUp(context.Background(), hosts.DialersOptions{}, flags, map[string]interface{}{})
+	_, _, _, _, _, err := r.ClusterUp(context.Background(), r.dialersOptions(eid, ""), flags, map[string]interface{}{})
 	return nil, err
 }
 
@@ -396,6 +454,10 @@ func (r *rkeAdaptor) ClusterUp(ctx context.Context, dialersOptions hosts.Dialers
 		return RotateEncryptionKey(ctx, clusterState.CurrentState.RancherKubernetesEngineConfig.DeepCopy(), dialersOptions, flags)
 	}
 
+	// the task may be cancelled between phases, stop before changing the hosts.
+	if err := ctx.Err(); err != nil {
+		return APIURL, caCrt, clientCert, clientKey, nil, err
+	}
 	log.Infof(ctx, "Building Kubernetes cluster")
 	err = kubeCluster.SetupDialers(ctx, dialersOptions)
 	if err != nil {
@@ -426,6 +488,9 @@ func (r *rkeAdaptor) ClusterUp(ctx context.Context, dialersOptions hosts.Dialers
 	clientKey = string(cert.EncodePrivateKeyPEM(kubeCluster.Certificates[pki.KubeAdminCertName].Key))
 	caCrt = string(cert.EncodeCertPEM(kubeCluster.Certificates[pki.CACertName].Certificate))
 
+	if err := ctx.Err(); err != nil {
+		return APIURL, caCrt, clientCert, clientKey, nil, err
+	}
 	// moved deploying certs before reconcile to remove all unneeded certs generation from reconcile
 	err = kubeCluster.SetUpHosts(ctx, flags)
 	if err != nil {
@@ -480,10 +545,16 @@ func (r *rkeAdaptor) ClusterUp(ctx context.Context, dialersOptions hosts.Dialers
 		return APIURL, caCrt, clientCert, clientKey, nil, err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return APIURL, caCrt, clientCert, clientKey, nil, err
+	}
 	if err := kubeCluster.PrePullK8sImages(ctx); err != nil {
 		return APIURL, caCrt, clientCert, clientKey, nil, err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return APIURL, caCrt, clientCert, clientKey, nil, err
+	}
 	errMsgMaxUnavailableNotFailedCtrl, err := kubeCluster.DeployControlPlane(ctx, svcOptionsData, reconcileCluster)
 	if err != nil {
 		return APIURL, caCrt, clientCert, clientKey, nil, err
@@ -504,6 +575,9 @@ func (r *rkeAdaptor) ClusterUp(ctx context.Context, dialersOptions hosts.Dialers
 		return APIURL, caCrt, clientCert, clientKey, nil, err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return APIURL, caCrt, clientCert, clientKey, nil, err
+	}
 	errMsgMaxUnavailableNotFailedWrkr, err := kubeCluster.DeployWorkerPlane(ctx, svcOptionsData, reconcileCluster)
 	if err != nil {
 		return APIURL, caCrt, clientCert, clientKey, nil, err
@@ -518,6 +592,9 @@ func (r *rkeAdaptor) ClusterUp(ctx context.Context, dialersOptions hosts.Dialers
 		return APIURL, caCrt, clientCert, clientKey, nil, err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return APIURL, caCrt, clientCert, clientKey, nil, err
+	}
 	err = cluster.ConfigureCluster(ctx, kubeCluster.RancherKubernetesEngineConfig, kubeCluster.Certificates, flags, dialersOptions, data, false)
 	if err != nil {
 		return APIURL, caCrt, clientCert, clientKey, nil, err
@@ -569,11 +646,11 @@ func (r *rkeAdaptor) GetKubeConfig(eid, clusterID string) (*v1alpha1.KubeConfig,
 
 func (r *rkeAdaptor) ExpansionNode(ctx context.Context, eid string, en *v1alpha1.ExpansionNode, rollback func(step, message, status string)) *v1alpha1.Cluster {
 	//Check cluster local state file, if not exist, not support expansion node
-	rollback("InitClusterConfig", "", "start")
+	rollback(v1.StepInitClusterConfig, "", "start")
 	rkecluster, err := r.Repo.GetCluster(eid, en.ClusterID)
 	if err != nil {
 		logrus.Errorf("get cluster meta info failure %s", err.Error())
-		rollback("InitClusterConfig", "Get cluster meta info failure", "failure")
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
 		return nil
 	}
 	rkecluster.Stats = v1alpha1.InitState
@@ -581,72 +658,56 @@ func (r *rkeAdaptor) ExpansionNode(ctx context.Context, eid string, en *v1alpha1
 		logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
 	}
 	rkecluster.Stats = v1alpha1.InstallFailed
-	configDir := "/tmp"
-	if os.Getenv("CONFIG_DIR") != "" {
-		configDir = os.Getenv("CONFIG_DIR")
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		logrus.Errorf("open rke cluster workspace failure %s", err.Error())
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		r.Repo.Update(rkecluster)
+		return nil
 	}
-	clusterStatPath := fmt.Sprintf("%s/enterprise/%s/rke/%s", configDir, rkecluster.EnterpriseID, rkecluster.Name)
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
 
-	os.MkdirAll(clusterStatPath, 0755)
-	filePath := fmt.Sprintf("%s/cluster.yml", clusterStatPath)
-
-	clusterStatFile := fmt.Sprintf("%s/enterprise/%s/rke/%s/cluster.rkestate", configDir, rkecluster.EnterpriseID, rkecluster.Name)
-	oldClusterStatFile := fmt.Sprintf("%s/rke/%s/cluster.rkestate", configDir, rkecluster.Name)
-	_, err = os.Stat(clusterStatFile)
-	if err != nil {
-		_, err = os.Stat(oldClusterStatFile)
-		if err != nil {
-			logrus.Errorf("read cluster %s state file failure %s ", en.ClusterID, err.Error())
-			rollback("InitClusterConfig", "state file not exist, can not support expansion node", "failure")
-			r.Repo.Update(rkecluster)
-			return nil
-		}
+	if !workspace.stateExists() {
+		logrus.Errorf("read cluster %s state file failure %s ", en.ClusterID, errClusterStateNotFound.Error())
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support expansion node", "failure")
+		r.Repo.Update(rkecluster)
+		return nil
 	}
 
 	if err := os.Rename(filePath, filePath+".bak"); err != nil {
-		rollback("InitClusterConfig", err.Error(), "failure")
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
 		logrus.Errorf("move old cluster config file failure %s", err.Error())
 		r.Repo.Update(rkecluster)
 		return nil
 	}
 	out, _ := yaml.Marshal(en.RKEConfig)
 	if err := ioutil.WriteFile(filePath, out, 0755); err != nil {
-		rollback("InitClusterConfig", err.Error(), "failure")
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
 		logrus.Errorf("write rke cluster config file failure %s", err.Error())
 		os.Rename(filePath+".bak", filePath)
 		r.Repo.Update(rkecluster)
 		return nil
 	}
-	// set install log out
-	logPath := fmt.Sprintf("%s/create.log", clusterStatPath)
-	// svae old log
-	os.Rename(logPath, logPath+"."+time.Now().Format(time.RFC3339))
-	writer, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE, 0755)
-	if err != nil {
-		logrus.Errorf("open create cluster log file %s failure %s", logPath, err.Error())
-	}
-	logger := logrus.New()
-	if writer != nil {
-		defer writer.Close()
-		logger.Out = writer
-	}
-	ctx = log.SetLogger(ctx, logger)
+	// set install log out, the old log is saved with the time suffix
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
 
 	//up cluster
 	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
-	if err := cmd.ClusterInit(ctx, en.RKEConfig, hosts.DialersOptions{}, flags); err != nil {
+	if err := cmd.ClusterInit(ctx, en.RKEConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
 		r.Repo.Update(rkecluster)
-		rollback("InitClusterConfig", err.Error(), "failure")
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
 		return nil
 	}
-	rollback("InitClusterConfig", "", "success")
+	rollback(v1.StepInitClusterConfig, "", "success")
 
 	// cluster install and up
-	rollback("UpdateKubernetes", filePath, "start")
-	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, hosts.DialersOptions{}, flags, map[string]interface{}{})
+	rollback(v1.StepUpdateKubernetes, filePath, "start")
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
 	if err != nil {
 		r.Repo.Update(rkecluster)
-		rollback("UpdateKubernetes", err.Error(), "failure")
+		rollback(v1.StepUpdateKubernetes, err.Error(), "failure")
 		return nil
 	}
 	rkecluster.KubeConfig = configs[pki.KubeAdminCertName].Config
@@ -655,7 +716,7 @@ func (r *rkeAdaptor) ExpansionNode(ctx context.Context, eid string, en *v1alpha1
 	if err := r.Repo.Update(rkecluster); err != nil {
 		logrus.Errorf("update rke cluster %s state failure %s", rkecluster.Name, err.Error())
 	}
-	rollback("UpdateKubernetes", "", "success")
+	rollback(v1.StepUpdateKubernetes, "", "success")
 	clu, _ := r.DescribeCluster(eid, rkecluster.ClusterID)
 	return clu
 }
@@ -0,0 +1,353 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/cmd"
+	"github.com/rancher/rke/pki"
+	"github.com/rancher/rke/services"
+	v3 "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	sshutil "goodrain.com/cloud-adaptor/pkg/util/ssh"
+)
+
+// etcdSnapshotExtension rke-tools compresses the snapshot file as <name>.zip
+const etcdSnapshotExtension = ".zip"
+
+// etcdBackupBundleFile the certificates bundle saved with the recurring snapshots, it is not a snapshot.
+const etcdBackupBundleFile = "pki.bundle.tar.gz"
+
+// listEtcdSnapshotsCommand prints the name, size and modification time of the files in the snapshot dir
+var listEtcdSnapshotsCommand = fmt.Sprintf("if [ -d %[1]s ]; then find %[1]s -maxdepth 1 -type f -printf '%%f\\t%%s\\t%%T@\\n'; fi", services.EtcdSnapshotPath)
+
+// runNodeCommand runs the command on the node with the ssh config of the node in cluster.yml,
+// the node using the default key is logged in with the enterprise key.
+// The node is reached through the bastion in cluster.yml, or the bastion of the cluster if it is not nil.
+func runNodeCommand(rkeConfig *v3.RancherKubernetesEngineConfig, node v3.RKEConfigNode, enterpriseKey []byte, bastion *v3.BastionHost, command string) (string, error) {
+	key, err := hostSSHKey(node.SSHKey, nodeSSHKeyPath(rkeConfig, node), enterpriseKey)
+	if err != nil {
+		return "", errors.WithMessagef(err, "node %s", node.Address)
+	}
+	if node.User == "" {
+		return "", fmt.Errorf("the ssh user of node %s is not set", node.Address)
+	}
+	port, _ := strconv.Atoi(node.Port)
+	if port == 0 {
+		port = 22
+	}
+	if rkeConfig != nil && rkeConfig.BastionHost.Address != "" {
+		bastion = &rkeConfig.BastionHost
+	}
+	var dialer *sshutil.Bastion
+	if bastion != nil {
+		if dialer, err = bastionDialer(bastion, enterpriseKey); err != nil {
+			return "", err
+		}
+	}
+	return sshutil.RunCommand(dialer, node.Address, uint(port), node.User, key, command)
+}
+
+// parseEtcdSnapshots adds the snapshots listed by listEtcdSnapshotsCommand on the node to snapshots.
+func parseEtcdSnapshots(snapshots map[string]*v1alpha1.EtcdSnapshot, node, output string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 || fields[0] == etcdBackupBundleFile {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		modified, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			continue
+		}
+		sec, frac := math.Modf(modified)
+		createdAt := time.Unix(int64(sec), int64(frac*1e9))
+		name := strings.TrimSuffix(fields[0], etcdSnapshotExtension)
+		snapshot, ok := snapshots[name]
+		if !ok {
+			snapshot = &v1alpha1.EtcdSnapshot{Name: name, CreatedAt: createdAt}
+			snapshots[name] = snapshot
+		}
+		if size > snapshot.Size {
+			snapshot.Size = size
+		}
+		if createdAt.Before(snapshot.CreatedAt) {
+			snapshot.CreatedAt = createdAt
+		}
+		snapshot.Nodes = append(snapshot.Nodes, node)
+	}
+}
+
+//ListEtcdSnapshots lists the snapshots saved on the etcd nodes, the latest first.
+func (r *rkeAdaptor) ListEtcdSnapshots(eid, clusterID string) ([]*v1alpha1.EtcdSnapshot, error) {
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	rkeConfig, err := r.getClusterConfig(rkecluster)
+	if err != nil {
+		return nil, err
+	}
+	enterpriseKey, err := r.enterpriseSSHKey(eid)
+	if err != nil {
+		return nil, err
+	}
+	bastion, err := r.clusterBastion(eid, clusterID, enterpriseKey)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string]*v1alpha1.EtcdSnapshot)
+	var listed int
+	var lastErr error
+	for _, node := range rkeConfig.Nodes {
+		if !isEtcdNode(node) {
+			continue
+		}
+		output, err := runNodeCommand(rkeConfig, node, enterpriseKey, bastion, listEtcdSnapshotsCommand)
+		if err != nil {
+			logrus.Warningf("list etcd snapshots on node %s failure %s", node.Address, err.Error())
+			lastErr = err
+			continue
+		}
+		listed++
+		parseEtcdSnapshots(snapshots, node.Address, output)
+	}
+	// the snapshots are listed if any etcd node is reachable
+	if listed == 0 && lastErr != nil {
+		return nil, errors.Wrap(lastErr, "list etcd snapshots")
+	}
+	var result []*v1alpha1.EtcdSnapshot
+	for _, snapshot := range snapshots {
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func isEtcdNode(node v3.RKEConfigNode) bool {
+	for _, role := range node.Role {
+		if role == services.ETCDRole {
+			return true
+		}
+	}
+	return false
+}
+
+//SaveEtcdSnapshot saves a snapshot with the name on all etcd nodes.
+func (r *rkeAdaptor) SaveEtcdSnapshot(ctx context.Context, eid, clusterID, name string, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support etcd snapshot", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	return cmd.SnapshotSaveEtcdHosts(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags, name)
+}
+
+//GetEtcdSnapshotPolicy returns the policy of the recurring snapshots in cluster.yml
+func (r *rkeAdaptor) GetEtcdSnapshotPolicy(eid, clusterID string) (*v1alpha1.EtcdSnapshotPolicy, error) {
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	rkeConfig, err := r.getClusterConfig(rkecluster)
+	if err != nil {
+		return nil, err
+	}
+	return etcdSnapshotPolicy(rkeConfig.Services.Etcd), nil
+}
+
+// etcdSnapshotPolicy returns the policy of the etcd service, the unset fields are the defaults of rke.
+// The legacy creation and retention periods are used if the backup config is not set.
+func etcdSnapshotPolicy(etcd v3.ETCDService) *v1alpha1.EtcdSnapshotPolicy {
+	policy := &v1alpha1.EtcdSnapshotPolicy{
+		Enabled:       etcd.Snapshot == nil || *etcd.Snapshot,
+		IntervalHours: cluster.DefaultEtcdBackupConfigIntervalHours,
+		Retention:     cluster.DefaultEtcdBackupConfigRetention,
+	}
+	if etcd.BackupConfig != nil {
+		if etcd.BackupConfig.IntervalHours > 0 {
+			policy.IntervalHours = etcd.BackupConfig.IntervalHours
+		}
+		if etcd.BackupConfig.Retention > 0 {
+			policy.Retention = etcd.BackupConfig.Retention
+		}
+		return policy
+	}
+	creation, err := time.ParseDuration(etcd.Creation)
+	if err != nil || creation < time.Hour {
+		return policy
+	}
+	policy.IntervalHours = int(creation / time.Hour)
+	if retention, err := time.ParseDuration(etcd.Retention); err == nil && retention >= creation {
+		policy.Retention = int(retention / creation)
+	}
+	return policy
+}
+
+// setEtcdSnapshotPolicy sets the policy to the etcd service, the other backup configs such as s3 are kept.
+func setEtcdSnapshotPolicy(etcd *v3.ETCDService, policy v1alpha1.EtcdSnapshotPolicy) {
+	enabled := policy.Enabled
+	etcd.Snapshot = &enabled
+	if !enabled {
+		return
+	}
+	if etcd.BackupConfig == nil {
+		etcd.BackupConfig = &v3.BackupConfig{}
+	}
+	etcd.BackupConfig.Enabled = &enabled
+	etcd.BackupConfig.IntervalHours = policy.IntervalHours
+	etcd.BackupConfig.Retention = policy.Retention
+}
+
+//SetEtcdSnapshotPolicy sets the policy of the recurring snapshots in cluster.yml and updates the snapshot service of the etcd nodes.
+func (r *rkeAdaptor) SetEtcdSnapshotPolicy(ctx context.Context, eid, clusterID string, policy v1alpha1.EtcdSnapshotPolicy, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support etcd snapshot", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	setEtcdSnapshotPolicy(&rkeConfig.Services.Etcd, policy)
+	restore, err := writeClusterConfig(filePath, rkeConfig)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	if err := cmd.ClusterInit(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		restore()
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	// the snapshot container of the etcd nodes is recreated when reconciling the etcd plane
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if config := configs[pki.KubeAdminCertName].Config; config != "" {
+		rkecluster.KubeConfig = config
+	}
+	rkecluster.APIURL = APIURL
+	return r.Repo.Update(rkecluster)
+}
+
+//RestoreEtcdSnapshot restores the etcd data of the cluster from the snapshot saved on the etcd nodes.
+func (r *rkeAdaptor) RestoreEtcdSnapshot(ctx context.Context, eid, clusterID, name string, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support etcd snapshot", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	// the state file saved in the snapshot is preferred, the local one is used if the snapshot does not include it
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	APIURL, _, _, _, certs, err := cmd.RestoreEtcdSnapshot(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{}, name)
+	if err != nil {
+		return err
+	}
+	if config := certs[pki.KubeAdminCertName].Config; config != "" {
+		rkecluster.KubeConfig = config
+	}
+	if APIURL != "" {
+		rkecluster.APIURL = APIURL
+	}
+	return r.Repo.Update(rkecluster)
+}
@@ -0,0 +1,84 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rke/types"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+func TestParseEtcdSnapshots(t *testing.T) {
+	snapshots := make(map[string]*v1alpha1.EtcdSnapshot)
+	parseEtcdSnapshots(snapshots, "192.168.1.1", "snap1.zip\t1024\t1697000000.5000000000\npki.bundle.tar.gz\t100\t1697000000.0\nbroken line\n")
+	parseEtcdSnapshots(snapshots, "192.168.1.2", "snap1.zip\t2048\t1696999999.0000000000\nsnap2\t512\t1697000100.0000000000\n")
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	snap1 := snapshots["snap1"]
+	if snap1 == nil {
+		t.Fatal("snapshot snap1 not found")
+	}
+	if snap1.Size != 2048 || len(snap1.Nodes) != 2 || snap1.CreatedAt.Unix() != 1696999999 {
+		t.Errorf("unexpected snapshot %+v", snap1)
+	}
+	if snap2 := snapshots["snap2"]; snap2 == nil || snap2.Size != 512 || snap2.Nodes[0] != "192.168.1.2" {
+		t.Errorf("unexpected snapshot %+v", snap2)
+	}
+}
+
+func TestRunNodeCommandSSHConfig(t *testing.T) {
+	node := v3.RKEConfigNode{Address: "192.168.1.1", User: "docker", SSHKeyPath: defaultSSHKeyPath}
+	if _, err := runNodeCommand(nil, node, nil, nil, "true"); errors.Cause(err) != errNoEnterpriseSSHKey {
+		t.Errorf("expected the node using the default key is not logged in without the enterprise key, got %v", err)
+	}
+	node.User = ""
+	if _, err := runNodeCommand(nil, node, []byte("enterprise key"), nil, "true"); err == nil {
+		t.Error("expected the error of the missing ssh user")
+	}
+}
+
+func TestEtcdSnapshotPolicy(t *testing.T) {
+	// rke takes the recurring snapshots by default
+	policy := etcdSnapshotPolicy(v3.ETCDService{})
+	if !policy.Enabled || policy.IntervalHours != 12 || policy.Retention != 6 {
+		t.Errorf("unexpected default policy %+v", policy)
+	}
+	policy = etcdSnapshotPolicy(v3.ETCDService{Creation: "6h", Retention: "24h"})
+	if policy.IntervalHours != 6 || policy.Retention != 4 {
+		t.Errorf("unexpected legacy policy %+v", policy)
+	}
+
+	etcd := v3.ETCDService{Creation: "6h", Retention: "24h", BackupConfig: &v3.BackupConfig{S3BackupConfig: &v3.S3BackupConfig{BucketName: "backup"}}}
+	setEtcdSnapshotPolicy(&etcd, v1alpha1.EtcdSnapshotPolicy{Enabled: true, IntervalHours: 2, Retention: 10})
+	if etcd.BackupConfig.S3BackupConfig == nil {
+		t.Errorf("the s3 backup config should be kept")
+	}
+	policy = etcdSnapshotPolicy(etcd)
+	if !policy.Enabled || policy.IntervalHours != 2 || policy.Retention != 10 {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+
+	setEtcdSnapshotPolicy(&etcd, v1alpha1.EtcdSnapshotPolicy{})
+	if policy := etcdSnapshotPolicy(etcd); policy.Enabled {
+		t.Errorf("the recurring snapshots should be disabled")
+	}
+}
@@ -0,0 +1,183 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/hosts"
+	v3 "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+	sshutil "goodrain.com/cloud-adaptor/pkg/util/ssh"
+	"gorm.io/gorm"
+)
+
+// defaultSSHKeyPath the key file of cloud adaptor, the nodes using it are logged in with the key of the enterprise if there is one.
+const defaultSSHKeyPath = cluster.DefaultClusterSSHKeyPath
+
+// enterpriseSSHKey returns the private key of the enterprise, or nil if the enterprise has no key yet.
+func (r *rkeAdaptor) enterpriseSSHKey(eid string) ([]byte, error) {
+	key, err := r.Keys.GetActive(eid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.Keys.GetPrivateKey(key)
+}
+
+// errNoEnterpriseSSHKey the host using the default key can not be logged in because the enterprise has no key
+var errNoEnterpriseSSHKey = errors.New("the enterprise has no ssh key to log in to the host using the default key")
+
+// hostSSHKey returns the key the host is logged in with, the host using the default key file is logged in with the enterprise key.
+func hostSSHKey(sshKey, keyPath string, enterpriseKey []byte) ([]byte, error) {
+	if sshKey != "" {
+		return []byte(sshKey), nil
+	}
+	if keyPath == "" || keyPath == defaultSSHKeyPath {
+		if enterpriseKey == nil {
+			return nil, errNoEnterpriseSSHKey
+		}
+		return enterpriseKey, nil
+	}
+	return sshutil.ReadPrivateKey(keyPath)
+}
+
+// nodeSSHKeyPath returns the key file of the node in the rke config
+func nodeSSHKeyPath(rkeConfig *v3.RancherKubernetesEngineConfig, node v3.RKEConfigNode) string {
+	if node.SSHKeyPath != "" {
+		return node.SSHKeyPath
+	}
+	if rkeConfig != nil && rkeConfig.SSHKeyPath != "" {
+		return rkeConfig.SSHKeyPath
+	}
+	return defaultSSHKeyPath
+}
+
+// clusterBastion returns the bastion of the cluster with the key it is logged in with, or nil if the cluster has no bastion.
+// The bastion without a key of its own is logged in with the enterpriseKey.
+func (r *rkeAdaptor) clusterBastion(eid, clusterID string, enterpriseKey []byte) (*v3.BastionHost, error) {
+	if clusterID == "" || r.Bastions == nil {
+		return nil, nil
+	}
+	bastion, err := r.Bastions.Get(eid, clusterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	key, err := r.Bastions.GetPrivateKey(bastion)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get the ssh key of the bastion")
+	}
+	if key == nil {
+		key = enterpriseKey
+	}
+	port := bastion.Port
+	if port == 0 {
+		port = 22
+	}
+	host := &v3.BastionHost{
+		Address: bastion.Address,
+		Port:    strconv.Itoa(int(port)),
+		User:    bastion.User,
+		SSHKey:  string(key),
+	}
+	if key == nil {
+		host.SSHKeyPath = defaultSSHKeyPath
+	}
+	return host, nil
+}
+
+// bastionDialer converts the bastion of rke to the one of sshutil, the key of the bastion is chosen the same way as the one of a node.
+func bastionDialer(bastion *v3.BastionHost, enterpriseKey []byte) (*sshutil.Bastion, error) {
+	key, err := hostSSHKey(bastion.SSHKey, bastion.SSHKeyPath, enterpriseKey)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "bastion %s", bastion.Address)
+	}
+	port, _ := strconv.Atoi(bastion.Port)
+	if port == 0 {
+		port = 22
+	}
+	return &sshutil.Bastion{Host: bastion.Address, Port: uint(port), User: bastion.User, PrivateKey: key}, nil
+}
+
+// dialersOptions returns the dialers of rke which log in to the hosts using the default key with the enterprise key,
+// and tunnel through the bastion of the cluster if it has one and the rke config does not define another.
+// The keys and the bastion are read when the first host is dialed.
+func (r *rkeAdaptor) dialersOptions(eid, clusterID string) hosts.DialersOptions {
+	var once sync.Once
+	var key []byte
+	var bastion *v3.BastionHost
+	var loadErr error
+	load := func() error {
+		once.Do(func() {
+			if key, loadErr = r.enterpriseSSHKey(eid); loadErr != nil {
+				loadErr = errors.WithMessage(loadErr, "get the ssh key of the enterprise")
+				return
+			}
+			bastion, loadErr = r.clusterBastion(eid, clusterID, key)
+		})
+		return loadErr
+	}
+	return hosts.DialersOptions{
+		DockerDialerFactory: func(h *hosts.Host) (func(network, address string) (net.Conn, error), error) {
+			if err := load(); err != nil {
+				return nil, err
+			}
+			useKey := key != nil && h.SSHKey == "" && !h.SSHAgentAuth && h.SSHKeyPath == defaultSSHKeyPath
+			useBastion := bastion != nil && h.BastionHost.Address == ""
+			if !useKey && !useBastion {
+				return hosts.SSHFactory(h)
+			}
+			// the key and the bastion are not saved to the host, so they are not written to the cluster state
+			host := *h
+			if useKey {
+				host.SSHKey = string(key)
+			}
+			if useBastion {
+				host.BastionHost = *bastion
+			}
+			return hosts.SSHFactory(&host)
+		},
+		// the kubernetes api is reached through the bastion as well, unless the rke config defines its own bastion
+		K8sWrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			if err := load(); err != nil {
+				logrus.Warningf("load the bastion of cluster %s: %v", clusterID, err)
+				return rt
+			}
+			if bastion == nil {
+				return rt
+			}
+			wrap, err := hosts.BastionHostWrapTransport(*bastion)
+			if err != nil {
+				logrus.Warningf("wrap the transport with the bastion of cluster %s: %v", clusterID, err)
+				return rt
+			}
+			return wrap(rt)
+		},
+	}
+}
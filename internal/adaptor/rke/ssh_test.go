@@ -0,0 +1,106 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rke/types"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"gorm.io/gorm"
+)
+
+// fakeBastions the bastions of the clusters, the private keys are not encrypted.
+type fakeBastions map[string]*model.SSHBastion
+
+func (f fakeBastions) Save(bastion *model.SSHBastion, privateKey []byte) error {
+	bastion.PrivateKey = string(privateKey)
+	f[bastion.EnterpriseID+"/"+bastion.ClusterID] = bastion
+	return nil
+}
+
+func (f fakeBastions) Get(eid, clusterID string) (*model.SSHBastion, error) {
+	bastion, ok := f[eid+"/"+clusterID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return bastion, nil
+}
+
+func (f fakeBastions) GetPrivateKey(bastion *model.SSHBastion) ([]byte, error) {
+	if bastion.PrivateKey == "" {
+		return nil, nil
+	}
+	return []byte(bastion.PrivateKey), nil
+}
+
+func (f fakeBastions) Delete(eid, clusterID string) error {
+	delete(f, eid+"/"+clusterID)
+	return nil
+}
+
+func TestClusterBastion(t *testing.T) {
+	r := &rkeAdaptor{Bastions: fakeBastions{
+		"eid/c1": {EnterpriseID: "eid", ClusterID: "c1", Address: "10.0.0.1", User: "jump"},
+		"eid/c2": {EnterpriseID: "eid", ClusterID: "c2", Address: "10.0.0.2", Port: 2222, User: "jump", PrivateKey: "own key"},
+	}}
+	tests := []struct {
+		clusterID string
+		key       []byte
+		want      *v3.BastionHost
+	}{
+		{clusterID: "c0", key: []byte("enterprise key")},
+		{clusterID: "c1", key: []byte("enterprise key"), want: &v3.BastionHost{Address: "10.0.0.1", Port: "22", User: "jump", SSHKey: "enterprise key"}},
+		{clusterID: "c1", want: &v3.BastionHost{Address: "10.0.0.1", Port: "22", User: "jump", SSHKeyPath: defaultSSHKeyPath}},
+		{clusterID: "c2", key: []byte("enterprise key"), want: &v3.BastionHost{Address: "10.0.0.2", Port: "2222", User: "jump", SSHKey: "own key"}},
+	}
+	for _, tc := range tests {
+		got, err := r.clusterBastion("eid", tc.clusterID, tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Errorf("cluster %s: expected %+v, got %+v", tc.clusterID, tc.want, got)
+		}
+	}
+}
+
+func TestBastionDialer(t *testing.T) {
+	dialer, err := bastionDialer(&v3.BastionHost{Address: "10.0.0.1", User: "jump"}, []byte("enterprise key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialer.Host != "10.0.0.1" || dialer.Port != 22 || dialer.User != "jump" || string(dialer.PrivateKey) != "enterprise key" {
+		t.Fatalf("expected the bastion is logged in with the enterprise key on port 22, got %+v", dialer)
+	}
+	dialer, err = bastionDialer(&v3.BastionHost{Address: "10.0.0.1", Port: "2222", User: "jump", SSHKey: "own key"}, []byte("enterprise key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialer.Port != 2222 || string(dialer.PrivateKey) != "own key" {
+		t.Fatalf("expected the bastion is logged in with its own key, got %+v", dialer)
+	}
+	if _, err := bastionDialer(&v3.BastionHost{Address: "10.0.0.1", User: "jump", SSHKeyPath: "/not/exist"}, []byte("enterprise key")); err == nil {
+		t.Fatal("expected the error of reading the key file")
+	}
+	if _, err := bastionDialer(&v3.BastionHost{Address: "10.0.0.1", User: "jump", SSHKeyPath: defaultSSHKeyPath}, nil); errors.Cause(err) != errNoEnterpriseSSHKey {
+		t.Fatalf("expected the bastion using the default key is not logged in without the enterprise key, got %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/log"
+	v3 "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	clusterConfigFile = "cluster.yml"
+	clusterStateFile  = "cluster.rkestate"
+	clusterLogFile    = "create.log"
+)
+
+// clusterLogSyncInterval the interval to save the log written by rke to the store, so the log can be followed while rke is running.
+var clusterLogSyncInterval = 5 * time.Second
+
+var (
+	// clusterLeaseTTL the lease of the cluster expires if the instance running the operation is gone
+	clusterLeaseTTL = time.Minute
+	// clusterLeaseRenewInterval the lease of the cluster is renewed while the workspace is open
+	clusterLeaseRenewInterval = 20 * time.Second
+)
+
+// errClusterStateNotFound the cluster.rkestate of the cluster does not exist, the cluster is not created by rke successfully.
+var errClusterStateNotFound = errors.New("cluster state file not found")
+
+// clusterWorkspace the temp dir which the state files of the cluster are materialised to while rke is running
+type clusterWorkspace struct {
+	store  blobstore.Store
+	prefix string
+	dir    string
+	lease  *clusterLease
+}
+
+// clusterLease the lease of the cluster held while its workspace is open
+type clusterLease struct {
+	leases      repo.LeaseRepository
+	name, owner string
+	stop, done  chan struct{}
+}
+
+// acquireClusterLease acquires the lease of the cluster and renews it until release,
+// returns bcode.ErrClusterOperationRunning if another operation of the cluster holds it.
+func acquireClusterLease(leases repo.LeaseRepository, clusterID string) (*clusterLease, error) {
+	hostname, _ := os.Hostname()
+	l := &clusterLease{
+		leases: leases,
+		name:   "rke-cluster-" + clusterID,
+		owner:  fmt.Sprintf("%s-%s", hostname, uuidutil.NewUUID()),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	acquired, err := leases.Acquire(l.name, l.owner, clusterLeaseTTL)
+	if err != nil {
+		return nil, errors.Wrap(err, "acquire the lease of the cluster")
+	}
+	if !acquired {
+		return nil, errors.WithStack(bcode.ErrClusterOperationRunning)
+	}
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(clusterLeaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				acquired, err := leases.Acquire(l.name, l.owner, clusterLeaseTTL)
+				if err != nil {
+					logrus.Errorf("renew the lease %s failure %s", l.name, err.Error())
+				} else if !acquired {
+					logrus.Errorf("the lease %s is lost", l.name)
+				}
+			}
+		}
+	}()
+	return l, nil
+}
+
+func (l *clusterLease) release() {
+	close(l.stop)
+	<-l.done
+	if err := l.leases.Release(l.name, l.owner); err != nil {
+		logrus.Errorf("release the lease %s failure %s", l.name, err.Error())
+	}
+}
+
+// openClusterWorkspace materialises cluster.yml and cluster.rkestate of the cluster to a temp dir.
+// The lease of the cluster is held until close, so the operations of one cluster do not overwrite the state of each other.
+// The files are saved back to the store and the dir is removed by close.
+func (r *rkeAdaptor) openClusterWorkspace(rkecluster *model.RKECluster) (*clusterWorkspace, error) {
+	lease, err := acquireClusterLease(r.Leases, rkecluster.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.ImportLegacyRKEState(r.Store, rkecluster.EnterpriseID, rkecluster.Name); err != nil {
+		lease.release()
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "rke-state-")
+	if err != nil {
+		lease.release()
+		return nil, errors.Wrap(err, "create the workspace of the cluster")
+	}
+	w := &clusterWorkspace{store: r.Store, prefix: repo.RKEStatePrefix(rkecluster.EnterpriseID, rkecluster.Name), dir: dir, lease: lease}
+	for _, name := range []string{clusterConfigFile, clusterStateFile} {
+		data, err := r.Store.Get(w.prefix + name)
+		if err == blobstore.ErrNotFound {
+			continue
+		}
+		if err == nil {
+			err = ioutil.WriteFile(w.path(name), data, 0600)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			lease.release()
+			return nil, errors.Wrapf(err, "materialise %s", name)
+		}
+	}
+	return w, nil
+}
+
+func (w *clusterWorkspace) path(name string) string {
+	return filepath.Join(w.dir, name)
+}
+
+func (w *clusterWorkspace) stateExists() bool {
+	_, err := os.Stat(w.path(clusterStateFile))
+	return err == nil
+}
+
+// close saves cluster.yml and cluster.rkestate written by rke back to the store, removes the temp dir and releases the lease of the cluster.
+func (w *clusterWorkspace) close() {
+	defer w.lease.release()
+	defer os.RemoveAll(w.dir)
+	for _, name := range []string{clusterConfigFile, clusterStateFile} {
+		data, err := ioutil.ReadFile(w.path(name))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logrus.Errorf("read %s of the workspace %s failure %s", name, w.dir, err.Error())
+			}
+			continue
+		}
+		if err := w.store.Put(w.prefix+name, data); err != nil {
+			logrus.Errorf("save %s%s failure %s", w.prefix, name, err.Error())
+		}
+	}
+}
+
+// withClusterLogger writes the rke log to the create.log of the workspace and saves it to the store periodically,
+// the old log in the store is saved with the time suffix.
+func withClusterLogger(ctx context.Context, w *clusterWorkspace) (context.Context, func()) {
+	logKey := w.prefix + clusterLogFile
+	if old, err := w.store.Get(logKey); err == nil {
+		if err := w.store.Put(logKey+"."+time.Now().Format(time.RFC3339), old); err != nil {
+			logrus.Errorf("rotate the log %s failure %s", logKey, err.Error())
+		}
+	}
+	logPath := w.path(clusterLogFile)
+	writer, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		logrus.Errorf("open create cluster log file %s failure %s", logPath, err.Error())
+	}
+	logger := logrus.New()
+	if writer == nil {
+		return log.SetLogger(ctx, logger), func() {}
+	}
+	logger.Out = writer
+
+	var saved []byte
+	sync := func() {
+		data, err := ioutil.ReadFile(logPath)
+		if err != nil || (saved != nil && bytes.Equal(data, saved)) {
+			return
+		}
+		if err := w.store.Put(logKey, data); err != nil {
+			logrus.Errorf("save the log %s failure %s", logKey, err.Error())
+			return
+		}
+		saved = data
+	}
+	// the empty log replaces the old one at once
+	sync()
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(clusterLogSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				sync()
+			}
+		}
+	}()
+	return log.SetLogger(ctx, logger), func() {
+		close(stop)
+		<-done
+		writer.Close()
+		sync()
+	}
+}
+
+// getClusterConfig returns the cluster.yml of the cluster in the store
+func (r *rkeAdaptor) getClusterConfig(rkecluster *model.RKECluster) (*v3.RancherKubernetesEngineConfig, error) {
+	out, err := repo.GetRKEStateFile(r.Store, rkecluster.EnterpriseID, rkecluster.Name, clusterConfigFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read cluster config file")
+	}
+	return parseClusterConfig(out)
+}
+
+// getClusterState returns the cluster.rkestate of the cluster in the store
+func (r *rkeAdaptor) getClusterState(ctx context.Context, rkecluster *model.RKECluster) (*cluster.FullState, error) {
+	out, err := repo.GetRKEStateFile(r.Store, rkecluster.EnterpriseID, rkecluster.Name, clusterStateFile)
+	if err != nil {
+		if err == blobstore.ErrNotFound {
+			return nil, errClusterStateNotFound
+		}
+		return nil, errors.Wrap(err, "read cluster state file")
+	}
+	return cluster.StringToFullState(ctx, string(out))
+}
+
+// readClusterConfig reads the cluster.yml of the workspace
+func readClusterConfig(filePath string) (*v3.RancherKubernetesEngineConfig, error) {
+	out, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "read cluster config file")
+	}
+	return parseClusterConfig(out)
+}
+
+func parseClusterConfig(out []byte) (*v3.RancherKubernetesEngineConfig, error) {
+	var rkeConfig v3.RancherKubernetesEngineConfig
+	if err := yaml.Unmarshal(out, &rkeConfig); err != nil {
+		return nil, errors.Wrap(err, "parse cluster config file")
+	}
+	return &rkeConfig, nil
+}
+
+// writeClusterConfig writes the cluster.yml, the old one is moved to cluster.yml.bak and can be restored by the returned func.
+func writeClusterConfig(filePath string, rkeConfig *v3.RancherKubernetesEngineConfig) (func(), error) {
+	if err := os.Rename(filePath, filePath+".bak"); err != nil {
+		return nil, errors.Wrap(err, "move old cluster config file")
+	}
+	restore := func() {
+		if err := os.Rename(filePath+".bak", filePath); err != nil {
+			logrus.Errorf("restore cluster config file %s failure %s", filePath, err.Error())
+		}
+	}
+	out, _ := yaml.Marshal(rkeConfig)
+	if err := ioutil.WriteFile(filePath, out, 0755); err != nil {
+		restore()
+		return nil, errors.Wrap(err, "write rke cluster config file")
+	}
+	return restore, nil
+}
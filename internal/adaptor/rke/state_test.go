@@ -0,0 +1,86 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+)
+
+// fakeLeases the leases in memory
+type fakeLeases struct {
+	lock   sync.Mutex
+	owners map[string]string
+	expire map[string]time.Time
+}
+
+func newFakeLeases() *fakeLeases {
+	return &fakeLeases{owners: map[string]string{}, expire: map[string]time.Time{}}
+}
+
+func (f *fakeLeases) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.owners[name] != "" && f.owners[name] != owner && f.expire[name].After(time.Now()) {
+		return false, nil
+	}
+	f.owners[name], f.expire[name] = owner, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (f *fakeLeases) Release(name, owner string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.owners[name] == owner {
+		f.expire[name] = time.Now().Add(-time.Second)
+	}
+	return nil
+}
+
+func TestOpenClusterWorkspaceLease(t *testing.T) {
+	r := &rkeAdaptor{Store: blobstore.NewLocalStore(t.TempDir()), Leases: newFakeLeases()}
+	c1 := &model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "id1"}
+	c2 := &model.RKECluster{EnterpriseID: "eid", Name: "c2", ClusterID: "id2"}
+
+	w1, err := r.openClusterWorkspace(c1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.openClusterWorkspace(c1); errors.Cause(err) != bcode.ErrClusterOperationRunning {
+		t.Fatalf("open the workspace of a cluster in operation, want %v, got %v", bcode.ErrClusterOperationRunning, err)
+	}
+	w2, err := r.openClusterWorkspace(c2)
+	if err != nil {
+		t.Fatalf("open the workspace of another cluster: %v", err)
+	}
+	w2.close()
+
+	w1.close()
+	w1, err = r.openClusterWorkspace(c1)
+	if err != nil {
+		t.Fatalf("open the workspace of a cluster after close: %v", err)
+	}
+	w1.close()
+}
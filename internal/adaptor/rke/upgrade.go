@@ -0,0 +1,325 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rke/cluster"
+	"github.com/rancher/rke/cmd"
+	"github.com/rancher/rke/metadata"
+	"github.com/rancher/rke/pki"
+	v3 "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/pkg/util/versionutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// nodeUpgradeCheckInterval the interval to check the kubelet version of the nodes during upgrading
+var nodeUpgradeCheckInterval = 10 * time.Second
+
+func initMetadata(ctx context.Context) error {
+	if metadata.K8sVersionToRKESystemImages != nil {
+		return nil
+	}
+	return metadata.InitMetadata(ctx)
+}
+
+// checkUpgradeVersion checks whether the cluster of the current version can be upgraded to the target version.
+// Only one minor version can be upgraded at a time, and downgrading is only allowed to roll back to the previous version.
+func checkUpgradeVersion(current, previous, target string) error {
+	if _, ok := metadata.K8sVersionToRKESystemImages[target]; !ok || metadata.K8sBadVersions[target] {
+		return fmt.Errorf("version %s is not supported by rke", target)
+	}
+	if target == current {
+		return fmt.Errorf("the cluster is running version %s", target)
+	}
+	targetVersion, err := utilversion.ParseGeneric(target)
+	if err != nil {
+		return fmt.Errorf("invalid version %s", target)
+	}
+	currentVersion, err := utilversion.ParseGeneric(current)
+	if err != nil {
+		return fmt.Errorf("invalid current version %s", current)
+	}
+	if target != previous {
+		if targetVersion.LessThan(currentVersion) {
+			return fmt.Errorf("downgrading to version %s is not supported", target)
+		}
+		if targetVersion.Major() != currentVersion.Major() || targetVersion.Minor() > currentVersion.Minor()+1 {
+			return fmt.Errorf("only one minor version can be upgraded at a time, from %s to %s", current, target)
+		}
+	}
+	if !versionutil.CheckVersion(target) {
+		return fmt.Errorf("version %s is not supported by rainbond", target)
+	}
+	return nil
+}
+
+func listKubernetesVersions(current, previous string) []*v1alpha1.KubernetesVersion {
+	var versions []*v1alpha1.KubernetesVersion
+	for version := range metadata.K8sVersionToRKESystemImages {
+		if metadata.K8sBadVersions[version] {
+			continue
+		}
+		kv := &v1alpha1.KubernetesVersion{
+			Version:  version,
+			Current:  version == current,
+			Previous: previous != "" && version == previous,
+		}
+		if err := checkUpgradeVersion(current, previous, version); err != nil {
+			kv.Reason = err.Error()
+		} else {
+			kv.Upgradable = true
+		}
+		versions = append(versions, kv)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		vi, erri := utilversion.ParseGeneric(versions[i].Version)
+		vj, errj := utilversion.ParseGeneric(versions[j].Version)
+		if erri != nil || errj != nil || vi.String() == vj.String() {
+			return versions[i].Version < versions[j].Version
+		}
+		return vi.LessThan(vj)
+	})
+	return versions
+}
+
+//ListKubernetesVersions lists the kubernetes versions of the embedded rke metadata
+func (r *rkeAdaptor) ListKubernetesVersions(eid, clusterID string) ([]*v1alpha1.KubernetesVersion, error) {
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := initMetadata(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "init rke metadata")
+	}
+	return listKubernetesVersions(rkecluster.KubernetesVersion, rkecluster.PreviousKubernetesVersion), nil
+}
+
+//UpgradeKubernetes upgrades the kubernetes version of the cluster with the rke state
+func (r *rkeAdaptor) UpgradeKubernetes(ctx context.Context, eid, clusterID, version string, rollback func(step, message, status string)) error {
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rkecluster, err := r.Repo.GetCluster(eid, clusterID)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, "Get cluster meta info failure", "failure")
+		return err
+	}
+	if err := initMetadata(ctx); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return errors.Wrap(err, "init rke metadata")
+	}
+	if err := checkUpgradeVersion(rkecluster.KubernetesVersion, rkecluster.PreviousKubernetesVersion, version); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+
+	workspace, err := r.openClusterWorkspace(rkecluster)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	defer workspace.close()
+	filePath := workspace.path(clusterConfigFile)
+	if !workspace.stateExists() {
+		rollback(v1.StepInitClusterConfig, "state file not exist, can not support upgrade", "failure")
+		return errClusterStateNotFound
+	}
+	rkeConfig, err := readClusterConfig(filePath)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+	rkeConfig.Version = version
+	// the system images of the target version are used
+	rkeConfig.SystemImages = v3.RKESystemImages{}
+	restore, err := writeClusterConfig(filePath, rkeConfig)
+	if err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		return err
+	}
+
+	ctx, closeLog := withClusterLogger(ctx, workspace)
+	defer closeLog()
+
+	flags := cluster.GetExternalFlags(false, false, false, false, "", filePath)
+	if err := cmd.ClusterInit(ctx, rkeConfig, r.dialersOptions(eid, rkecluster.ClusterID), flags); err != nil {
+		rollback(v1.StepInitClusterConfig, err.Error(), "failure")
+		restore()
+		return err
+	}
+	rollback(v1.StepInitClusterConfig, "", "success")
+
+	progress := newNodeUpgradeProgress(version, rollback)
+	progress.start(&v1alpha1.KubeConfig{Config: rkecluster.KubeConfig})
+	watchCtx, cancel := context.WithCancel(ctx)
+	go progress.watch(watchCtx, &v1alpha1.KubeConfig{Config: rkecluster.KubeConfig})
+	APIURL, _, _, _, configs, err := r.ClusterUp(ctx, r.dialersOptions(eid, rkecluster.ClusterID), flags, map[string]interface{}{})
+	cancel()
+	if err != nil {
+		// the nodes not upgraded keep running the old version with the old config
+		restore()
+		progress.finish(nil)
+		return err
+	}
+	rkecluster.KubeConfig = configs[pki.KubeAdminCertName].Config
+	rkecluster.APIURL = APIURL
+	// record the version before upgrading, the cluster can be rolled back to it
+	rkecluster.PreviousKubernetesVersion = rkecluster.KubernetesVersion
+	rkecluster.KubernetesVersion = version
+	rkecluster.Stats = v1alpha1.RunningState
+	if err := r.Repo.Update(rkecluster); err != nil {
+		logrus.Errorf("update rke cluster %s version failure %s", rkecluster.Name, err.Error())
+	}
+	return progress.finish(&v1alpha1.KubeConfig{Config: rkecluster.KubeConfig})
+}
+
+// nodeUpgradeProgress reports the upgrade event of every node, the node is upgraded when its kubelet runs the target version.
+type nodeUpgradeProgress struct {
+	target   string
+	rollback func(step, message, status string)
+
+	lock     sync.Mutex
+	pending  map[string]bool
+	upgraded map[string]bool
+}
+
+func newNodeUpgradeProgress(target string, rollback func(step, message, status string)) *nodeUpgradeProgress {
+	return &nodeUpgradeProgress{
+		target:   target,
+		rollback: rollback,
+		pending:  make(map[string]bool),
+		upgraded: make(map[string]bool),
+	}
+}
+
+func (p *nodeUpgradeProgress) start(kubeConfig *v1alpha1.KubeConfig) {
+	versions, err := listKubeletVersions(kubeConfig)
+	if err != nil {
+		logrus.Warningf("list the nodes to upgrade failure %s", err.Error())
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for node := range versions {
+		p.pending[node] = true
+		p.rollback(v1alpha1.NodeStepType(v1.StepUpgradeNode, node), "", "start")
+	}
+}
+
+func (p *nodeUpgradeProgress) watch(ctx context.Context, kubeConfig *v1alpha1.KubeConfig) {
+	ticker := time.NewTicker(nodeUpgradeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			versions, err := listKubeletVersions(kubeConfig)
+			if err != nil {
+				logrus.Debugf("list the kubelet versions failure %s", err.Error())
+				continue
+			}
+			p.update(versions, false)
+		}
+	}
+}
+
+// finish reports the nodes not upgraded as failure. The kubeConfig is nil if the upgrade failed.
+func (p *nodeUpgradeProgress) finish(kubeConfig *v1alpha1.KubeConfig) error {
+	versions := map[string]string{}
+	if kubeConfig != nil {
+		var err error
+		if versions, err = listKubeletVersions(kubeConfig); err != nil {
+			logrus.Warningf("list the kubelet versions failure %s", err.Error())
+		}
+	}
+	if failed := p.update(versions, true); len(failed) > 0 && kubeConfig != nil {
+		return fmt.Errorf("nodes %v are not upgraded to %s", failed, p.target)
+	}
+	return nil
+}
+
+func (p *nodeUpgradeProgress) update(versions map[string]string, final bool) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for node, version := range versions {
+		if !sameKubernetesVersion(version, p.target) {
+			continue
+		}
+		if p.upgraded[node] {
+			continue
+		}
+		p.rollback(v1alpha1.NodeStepType(v1.StepUpgradeNode, node), version, "success")
+		p.upgraded[node] = true
+		delete(p.pending, node)
+	}
+	var failed []string
+	if final {
+		for node := range p.pending {
+			message := "the node is not upgraded"
+			if version, ok := versions[node]; ok {
+				message = fmt.Sprintf("the kubelet is running version %s", version)
+			}
+			p.rollback(v1alpha1.NodeStepType(v1.StepUpgradeNode, node), message, "failure")
+			failed = append(failed, node)
+		}
+		sort.Strings(failed)
+	}
+	return failed
+}
+
+func listKubeletVersions(kubeConfig *v1alpha1.KubeConfig) (map[string]string, error) {
+	coreClient, _, err := kubeConfig.GetKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	nodes, err := coreClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		versions[node.Name] = node.Status.NodeInfo.KubeletVersion
+	}
+	return versions, nil
+}
+
+// sameKubernetesVersion compares the kubelet version with the rke version, such as v1.23.10 and v1.23.10-rancher1-1
+func sameKubernetesVersion(kubeletVersion, rkeVersion string) bool {
+	v1, err := utilversion.ParseGeneric(kubeletVersion)
+	if err != nil {
+		return false
+	}
+	v2, err := utilversion.ParseGeneric(rkeVersion)
+	if err != nil {
+		return false
+	}
+	return v1.String() == v2.String()
+}
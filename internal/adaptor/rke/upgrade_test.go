@@ -0,0 +1,78 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rke
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckUpgradeVersion(t *testing.T) {
+	if err := initMetadata(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name              string
+		current, previous string
+		target            string
+		upgradable        bool
+	}{
+		{name: "patch", current: "v1.23.4-rancher1-1", target: "v1.23.10-rancher1-1", upgradable: true},
+		{name: "next minor", current: "v1.23.10-rancher1-1", target: "v1.24.4-rancher1-1", upgradable: true},
+		{name: "skip minor", current: "v1.22.13-rancher1-1", target: "v1.24.4-rancher1-1"},
+		{name: "same", current: "v1.23.10-rancher1-1", target: "v1.23.10-rancher1-1"},
+		{name: "downgrade", current: "v1.24.4-rancher1-1", target: "v1.23.10-rancher1-1"},
+		{name: "rollback", current: "v1.24.4-rancher1-1", previous: "v1.23.10-rancher1-1", target: "v1.23.10-rancher1-1", upgradable: true},
+		{name: "not in metadata", current: "v1.23.10-rancher1-1", target: "v1.24.99-rancher1-1"},
+		{name: "not supported by rainbond", current: "v1.17.17-rancher2-4", target: "v1.18.20-rancher1-3"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkUpgradeVersion(tc.current, tc.previous, tc.target)
+			if (err == nil) != tc.upgradable {
+				t.Errorf("expected upgradable %v, got %v", tc.upgradable, err)
+			}
+		})
+	}
+}
+
+func TestListKubernetesVersions(t *testing.T) {
+	if err := initMetadata(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	versions := listKubernetesVersions("v1.23.10-rancher1-1", "")
+	var current int
+	for i, version := range versions {
+		if version.Current {
+			current++
+			if version.Upgradable {
+				t.Errorf("the current version should not be upgradable")
+			}
+		}
+		if i > 0 && versions[i-1].Version == version.Version {
+			t.Errorf("duplicate version %s", version.Version)
+		}
+	}
+	if current != 1 {
+		t.Errorf("expected one current version, got %d", current)
+	}
+	if !sameKubernetesVersion("v1.23.10", "v1.23.10-rancher1-1") || sameKubernetesVersion("v1.22.13", "v1.23.10-rancher1-1") {
+		t.Errorf("unexpected result of sameKubernetesVersion")
+	}
+}
@@ -0,0 +1,152 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tke
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	cdb "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cdb/v20170320"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+func (t *tkeAdaptor) cdbClient(regionID string) (*cdb.Client, error) {
+	return cdb.NewClient(t.credential(), regionID, t.clientProfile())
+}
+
+func dbInstanceName(clusterID string) string {
+	return "rainbond-region-db_" + clusterID
+}
+
+//CreateDB create the cdb(mysql) instance, account and privileges for rainbond region
+func (t *tkeAdaptor) CreateDB(db *v1alpha1.Database) error {
+	if db.RegionID == "" {
+		return fmt.Errorf("not privide region id")
+	}
+	client, err := t.cdbClient(db.RegionID)
+	if err != nil {
+		return err
+	}
+	//create instance
+	if db.InstanceID == "" {
+		instance, err := t.describeDBInstance(client, dbInstanceName(db.ClusterID), "")
+		if err != nil {
+			return fmt.Errorf("describe cdb(mysql) from tencent api failure:%s", err.Error())
+		}
+		if instance != nil {
+			db.InstanceID = toString(instance.InstanceId)
+		} else {
+			req := cdb.NewCreateDBInstanceHourRequest()
+			req.GoodsNum = common.Int64Ptr(1)
+			req.Memory = common.Int64Ptr(1000)
+			req.Volume = common.Int64Ptr(25)
+			req.EngineVersion = common.StringPtr("8.0")
+			req.UniqVpcId = common.StringPtr(db.VPCID)
+			req.UniqSubnetId = common.StringPtr(db.VSwitchID)
+			req.Zone = common.StringPtr(db.ZoneID)
+			req.InstanceName = common.StringPtr(dbInstanceName(db.ClusterID))
+			req.Password = common.StringPtr(db.Password)
+			res, err := client.CreateDBInstanceHour(req)
+			if err != nil {
+				return fmt.Errorf("create cdb(mysql) from tencent api failure:%s", err.Error())
+			}
+			if len(res.Response.InstanceIds) == 0 {
+				return fmt.Errorf("create cdb(mysql) from tencent api failure: no instance is created")
+			}
+			db.InstanceID = toString(res.Response.InstanceIds[0])
+		}
+	}
+	if err := t.waitingDBInstanceReady(client, db); err != nil {
+		return err
+	}
+	//create acount
+	if err := t.createDBAccount(client, db.InstanceID, db.UserName, db.Password); err != nil {
+		return fmt.Errorf("create cdb(mysql) account failure:%s", err.Error())
+	}
+	//grant account privilege, the database is created by the region with the privilege
+	req := cdb.NewModifyAccountPrivilegesRequest()
+	req.InstanceId = common.StringPtr(db.InstanceID)
+	req.Accounts = []*cdb.Account{{User: common.StringPtr(db.UserName), Host: common.StringPtr("%")}}
+	req.GlobalPrivileges = common.StringPtrs([]string{"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER", "CREATE TEMPORARY TABLES", "LOCK TABLES", "EXECUTE", "CREATE VIEW", "SHOW VIEW", "CREATE ROUTINE", "ALTER ROUTINE", "EVENT", "TRIGGER"})
+	if _, err := client.ModifyAccountPrivileges(req); err != nil {
+		return fmt.Errorf("create cdb(mysql) user privilege from tencent api failure:%s", err.Error())
+	}
+	return nil
+}
+
+func (t *tkeAdaptor) describeDBInstance(client *cdb.Client, name, instanceID string) (*cdb.InstanceInfo, error) {
+	req := cdb.NewDescribeDBInstancesRequest()
+	if instanceID != "" {
+		req.InstanceIds = common.StringPtrs([]string{instanceID})
+	} else {
+		req.InstanceNames = common.StringPtrs([]string{name})
+	}
+	res, err := client.DescribeDBInstances(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range res.Response.Items {
+		if (instanceID != "" && toString(item.InstanceId) == instanceID) || (instanceID == "" && toString(item.InstanceName) == name) {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// waitingDBInstanceReady waits the instance is running, and sets the host and port of the database.
+func (t *tkeAdaptor) waitingDBInstanceReady(client *cdb.Client, db *v1alpha1.Database) error {
+	return waitFor(time.Minute*10, pollInterval, func() (bool, error) {
+		instance, err := t.describeDBInstance(client, "", db.InstanceID)
+		if err != nil {
+			return false, fmt.Errorf("describe cdb(mysql) from tencent api failure:%s", err.Error())
+		}
+		// status 1 means the instance is running
+		if instance == nil || instance.Status == nil || *instance.Status != 1 {
+			logrus.Infof("db %s is not ready", db.InstanceID)
+			return false, nil
+		}
+		db.Host = toString(instance.Vip)
+		if instance.Vport != nil {
+			db.Port = int(*instance.Vport)
+		}
+		return true, nil
+	})
+}
+
+func (t *tkeAdaptor) createDBAccount(client *cdb.Client, instanceID, name, password string) error {
+	req := cdb.NewDescribeAccountsRequest()
+	req.InstanceId = common.StringPtr(instanceID)
+	res, err := client.DescribeAccounts(req)
+	if err != nil {
+		return err
+	}
+	for _, account := range res.Response.Items {
+		if toString(account.User) == name {
+			return nil
+		}
+	}
+	create := cdb.NewCreateAccountsRequest()
+	create.InstanceId = common.StringPtr(instanceID)
+	create.Accounts = []*cdb.Account{{User: common.StringPtr(name), Host: common.StringPtr("%")}}
+	create.Password = common.StringPtr(password)
+	_, err = client.CreateAccounts(create)
+	return err
+}
@@ -0,0 +1,105 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tke
+
+import (
+	"fmt"
+	"time"
+
+	cfs "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cfs/v20190719"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+func (t *tkeAdaptor) cfsClient(regionID string) (*cfs.Client, error) {
+	return cfs.NewClient(t.credential(), regionID, t.clientProfile())
+}
+
+func nasName(clusterID string) string {
+	return "rainbond-region-nas_" + clusterID
+}
+
+//CreateNAS create the cfs file system with a vpc mount target, the existing one is reused.
+func (t *tkeAdaptor) CreateNAS(clusterID, regionID, zoneID, vpcID, subnetID string) (string, error) {
+	client, err := t.cfsClient(regionID)
+	if err != nil {
+		return "", err
+	}
+	describe := cfs.NewDescribeCfsFileSystemsRequest()
+	describe.VpcId = common.StringPtr(vpcID)
+	res, err := client.DescribeCfsFileSystems(describe)
+	if err != nil {
+		return "", fmt.Errorf("describe cfs from tencent api failure:%s", err.Error())
+	}
+	for _, fs := range res.Response.FileSystems {
+		if toString(fs.FsName) == nasName(clusterID) {
+			return toString(fs.FileSystemId), nil
+		}
+	}
+	req := cfs.NewCreateCfsFileSystemRequest()
+	req.Zone = common.StringPtr(zoneID)
+	req.NetInterface = common.StringPtr("VPC")
+	req.PGroupId = common.StringPtr("pgroupbasic")
+	req.Protocol = common.StringPtr("NFS")
+	req.StorageType = common.StringPtr("SD")
+	req.VpcId = common.StringPtr(vpcID)
+	req.SubnetId = common.StringPtr(subnetID)
+	req.FsName = common.StringPtr(nasName(clusterID))
+	created, err := client.CreateCfsFileSystem(req)
+	if err != nil {
+		return "", fmt.Errorf("create cfs from tencent api failure:%s", err.Error())
+	}
+	return toString(created.Response.FileSystemId), nil
+}
+
+//GetNASMountTarget waits the file system is available and returns the ip address of its mount target
+func (t *tkeAdaptor) GetNASMountTarget(regionID, fileSystemID string) (string, error) {
+	client, err := t.cfsClient(regionID)
+	if err != nil {
+		return "", err
+	}
+	err = waitFor(time.Minute*5, pollInterval, func() (bool, error) {
+		req := cfs.NewDescribeCfsFileSystemsRequest()
+		req.FileSystemId = common.StringPtr(fileSystemID)
+		res, err := client.DescribeCfsFileSystems(req)
+		if err != nil {
+			return false, fmt.Errorf("describe cfs from tencent api failure:%s", err.Error())
+		}
+		for _, fs := range res.Response.FileSystems {
+			if toString(fs.FileSystemId) == fileSystemID && toString(fs.LifeCycleState) == "available" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	req := cfs.NewDescribeMountTargetsRequest()
+	req.FileSystemId = common.StringPtr(fileSystemID)
+	res, err := client.DescribeMountTargets(req)
+	if err != nil {
+		return "", fmt.Errorf("describe cfs mount targets from tencent api failure:%s", err.Error())
+	}
+	for _, mount := range res.Response.MountTargets {
+		if ip := toString(mount.IpAddress); ip != "" {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("not found the mount target of cfs %s", fileSystemID)
+}
@@ -0,0 +1,201 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tke
+
+import (
+	"fmt"
+	"time"
+
+	clb "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/clb/v20180317"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+// gatewayPorts the ports of rainbond gateway which are forwarded by the load balancer
+var gatewayPorts = []int64{80, 443, 8443, 6060}
+
+func (t *tkeAdaptor) clbClient(regionID string) (*clb.Client, error) {
+	return clb.NewClient(t.credential(), regionID, t.clientProfile())
+}
+
+func loadBalancerName(clusterID string) string {
+	return "rainbond-region-lb_" + clusterID
+}
+
+//CreateLoadBalancer create the public clb for the rainbond gateway, the existing one is reused.
+func (t *tkeAdaptor) CreateLoadBalancer(clusterID, regionID, vpcID string) (*v1alpha1.LoadBalancer, error) {
+	client, err := t.clbClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	lb, err := t.describeLoadBalancer(client, loadBalancerName(clusterID), "")
+	if err != nil {
+		return nil, err
+	}
+	loadBalancerID := ""
+	if lb != nil {
+		loadBalancerID = toString(lb.LoadBalancerId)
+	} else {
+		req := clb.NewCreateLoadBalancerRequest()
+		req.LoadBalancerType = common.StringPtr("OPEN")
+		req.LoadBalancerName = common.StringPtr(loadBalancerName(clusterID))
+		req.VpcId = common.StringPtr(vpcID)
+		res, err := client.CreateLoadBalancer(req)
+		if err != nil {
+			return nil, fmt.Errorf("create clb from tencent api failure:%s", err.Error())
+		}
+		if len(res.Response.LoadBalancerIds) == 0 {
+			return nil, fmt.Errorf("create clb from tencent api failure: no load balancer is created")
+		}
+		loadBalancerID = toString(res.Response.LoadBalancerIds[0])
+	}
+	re := &v1alpha1.LoadBalancer{
+		LoadBalancerID:   loadBalancerID,
+		LoadBalancerName: loadBalancerName(clusterID),
+		RegionID:         regionID,
+		VpcID:            vpcID,
+		AddressType:      "internet",
+	}
+	// status 1 means the load balancer is running
+	err = waitFor(time.Minute*5, pollInterval, func() (bool, error) {
+		lb, err := t.describeLoadBalancer(client, "", loadBalancerID)
+		if err != nil {
+			return false, err
+		}
+		if lb == nil || lb.Status == nil || *lb.Status != 1 || len(lb.LoadBalancerVips) == 0 {
+			return false, nil
+		}
+		re.Address = toString(lb.LoadBalancerVips[0])
+		re.LoadBalancerStatus = "active"
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+func (t *tkeAdaptor) describeLoadBalancer(client *clb.Client, name, loadBalancerID string) (*clb.LoadBalancer, error) {
+	req := clb.NewDescribeLoadBalancersRequest()
+	if loadBalancerID != "" {
+		req.LoadBalancerIds = common.StringPtrs([]string{loadBalancerID})
+	} else {
+		req.LoadBalancerName = common.StringPtr(name)
+	}
+	res, err := client.DescribeLoadBalancers(req)
+	if err != nil {
+		return nil, fmt.Errorf("describe clb from tencent api failure:%s", err.Error())
+	}
+	for _, lb := range res.Response.LoadBalancerSet {
+		if (loadBalancerID != "" && toString(lb.LoadBalancerId) == loadBalancerID) || (loadBalancerID == "" && toString(lb.LoadBalancerName) == name) {
+			return lb, nil
+		}
+	}
+	return nil, nil
+}
+
+//BoundLoadBalancerToCluster create the tcp listeners of gateway ports and register the gateway nodes to them.
+func (t *tkeAdaptor) BoundLoadBalancerToCluster(regionID, loadBalancerID string, gatewayIPs []string) error {
+	if len(gatewayIPs) == 0 {
+		return fmt.Errorf("gateway nodes can not be empty")
+	}
+	instances, err := t.describeInstances(regionID, nil, gatewayIPs)
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("not found the instances of gateway nodes %v", gatewayIPs)
+	}
+	client, err := t.clbClient(regionID)
+	if err != nil {
+		return err
+	}
+	describe := clb.NewDescribeListenersRequest()
+	describe.LoadBalancerId = common.StringPtr(loadBalancerID)
+	res, err := client.DescribeListeners(describe)
+	if err != nil {
+		return fmt.Errorf("describe clb listeners from tencent api failure:%s", err.Error())
+	}
+	listeners := make(map[int64]string)
+	for _, listener := range res.Response.Listeners {
+		if listener.Port != nil {
+			listeners[*listener.Port] = toString(listener.ListenerId)
+		}
+	}
+	for _, port := range gatewayPorts {
+		listenerID, ok := listeners[port]
+		if !ok {
+			req := clb.NewCreateListenerRequest()
+			req.LoadBalancerId = common.StringPtr(loadBalancerID)
+			req.Ports = []*int64{common.Int64Ptr(port)}
+			req.Protocol = common.StringPtr("TCP")
+			req.ListenerNames = common.StringPtrs([]string{fmt.Sprintf("rainbond-gateway-%d", port)})
+			created, err := client.CreateListener(req)
+			if err != nil {
+				return fmt.Errorf("create clb listener %d from tencent api failure:%s", port, err.Error())
+			}
+			if err := t.waitingTaskSuccess(client, toString(created.Response.RequestId)); err != nil {
+				return err
+			}
+			if len(created.Response.ListenerIds) == 0 {
+				return fmt.Errorf("create clb listener %d from tencent api failure: no listener is created", port)
+			}
+			listenerID = toString(created.Response.ListenerIds[0])
+		}
+		req := clb.NewRegisterTargetsRequest()
+		req.LoadBalancerId = common.StringPtr(loadBalancerID)
+		req.ListenerId = common.StringPtr(listenerID)
+		for _, instance := range instances {
+			req.Targets = append(req.Targets, &clb.Target{
+				InstanceId: instance.InstanceId,
+				Port:       common.Int64Ptr(port),
+			})
+		}
+		registered, err := client.RegisterTargets(req)
+		if err != nil {
+			return fmt.Errorf("register clb targets of listener %d from tencent api failure:%s", port, err.Error())
+		}
+		if err := t.waitingTaskSuccess(client, toString(registered.Response.RequestId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitingTaskSuccess waits the asynchronous clb task, the task id is the request id of the operation.
+func (t *tkeAdaptor) waitingTaskSuccess(client *clb.Client, taskID string) error {
+	return waitFor(time.Minute*2, pollInterval, func() (bool, error) {
+		req := clb.NewDescribeTaskStatusRequest()
+		req.TaskId = common.StringPtr(taskID)
+		res, err := client.DescribeTaskStatus(req)
+		if err != nil {
+			return false, fmt.Errorf("describe clb task status from tencent api failure:%s", err.Error())
+		}
+		if res.Response.Status == nil {
+			return false, nil
+		}
+		switch *res.Response.Status {
+		case 0:
+			return true, nil
+		case 1:
+			return false, fmt.Errorf("clb task %s failure", taskID)
+		}
+		return false, nil
+	})
+}
@@ -20,171 +20,651 @@ package tke
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
+	"github.com/sirupsen/logrus"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	cvm "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cvm/v20170312"
 	tke "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/tke/v20180525"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
 	"goodrain.com/cloud-adaptor/internal/adaptor"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/datastore"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+	"goodrain.com/cloud-adaptor/pkg/util"
+	"goodrain.com/cloud-adaptor/pkg/util/versionutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+const defaultRegion = "ap-guangzhou"
+
+// pollInterval the interval to query the status of the asynchronous resources
+var pollInterval = time.Second * 5
+
 type tkeAdaptor struct {
 	accessKeyID     string
 	accessKeySecret string
-	tkeclient       *tke.Client
+	// endpoint and scheme override the address of the tencent cloud api, they are used by the tests.
+	endpoint string
+	scheme   string
+	// store saves the passwords of the region databases
+	store blobstore.Store
 }
 
-//Create create ack adaptor
+func init() {
+	adaptor.Register(&adaptor.Provider{
+		Name:            "tke",
+		NeedCredentials: true,
+		Interfaces:      []string{adaptor.InterfaceCloudAdaptor},
+		Capabilities: []string{
+			adaptor.CapabilityCreateCluster,
+			adaptor.CapabilityManagedDB,
+			adaptor.CapabilityManagedNAS,
+			adaptor.CapabilityLoadBalancer,
+			adaptor.CapabilityRefreshKubeConfig,
+		},
+		Create: func(accessKeyID, accessKeySecret string) (adaptor.RainbondClusterAdaptor, error) {
+			return Create(accessKeyID, accessKeySecret)
+		},
+	})
+}
+
+//Create create tke adaptor
 func Create(accessKeyID, accessKeySecret string) (adaptor.CloudAdaptor, error) {
-	credential := common.NewCredential(accessKeyID, accessKeySecret)
-	client, err := tke.NewClient(credential, "ap-guangzhou", profile.NewClientProfile())
-	if err != nil {
-		return nil, err
-	}
 	return &tkeAdaptor{
 		accessKeyID:     accessKeyID,
 		accessKeySecret: accessKeySecret,
-		tkeclient:       client,
+		store:           repo.NewBlobRepo(datastore.GetGDB()),
 	}, nil
 }
 
-func toString(s *string) string {
-	if s == nil {
-		return ""
-	}
-	return *s
+func (t *tkeAdaptor) credential() *common.Credential {
+	return common.NewCredential(t.accessKeyID, t.accessKeySecret)
 }
 
-func (t *tkeAdaptor) ClusterList(eid string) ([]*v1alpha1.Cluster, error) {
-	req := tke.NewDescribeClustersRequest()
-	res, err := t.tkeclient.DescribeClusters(req)
-	if err != nil {
-		return nil, fmt.Errorf("Query cluster list from tencent api failure %s", err.Error())
+func (t *tkeAdaptor) clientProfile() *profile.ClientProfile {
+	cpf := profile.NewClientProfile()
+	if t.endpoint != "" {
+		cpf.HttpProfile.Endpoint = t.endpoint
 	}
-	var clusters []*v1alpha1.Cluster
-	fmt.Printf("%+v", *res.Response.Clusters[0])
-	for _, c := range res.Response.Clusters {
-		createTime, _ := time.Parse(time.RFC3339, toString(c.CreatedTime))
-		clusters = append(clusters, &v1alpha1.Cluster{
-			Name:           toString(c.ClusterName),
-			ClusterID:      toString(c.ClusterId),
-			Created:        v1alpha1.NewTime(createTime),
-			State:          toString(c.ClusterStatus),
-			ClusterType:    toString(c.ClusterType),
-			CurrentVersion: toString(c.ClusterVersion),
-			VPCID:          toString(c.ClusterNetworkSettings.VpcId),
-			RegionID:       "",
-			NetworkMode:    "",
-			SubnetCIDR:     "",
-			PodCIDR:        toString(c.ClusterNetworkSettings.ClusterCIDR),
-		})
+	if t.scheme != "" {
+		cpf.HttpProfile.Scheme = t.scheme
 	}
-	return clusters, nil
+	return cpf
 }
 
-func (t *tkeAdaptor) DescribeCluster(eid, clusterID string) (*v1alpha1.Cluster, error) {
-	return nil, nil
+func (t *tkeAdaptor) tkeClient(regionID string) (*tke.Client, error) {
+	return tke.NewClient(t.credential(), regionID, t.clientProfile())
 }
 
-func (t *tkeAdaptor) CreateCluster(string, v1alpha1.CreateClusterConfig) (*v1alpha1.Cluster, error) {
-	return nil, nil
+func (t *tkeAdaptor) cvmClient(regionID string) (*cvm.Client, error) {
+	return cvm.NewClient(t.credential(), regionID, t.clientProfile())
 }
 
-//DeleteCluster delete cluster
-func (t *tkeAdaptor) DeleteCluster(eid, clusterID string) error {
-	return nil
+// regions returns the regions where the clusters are queried, they are specified by the env TKE_REGIONS.
+func regions() []string {
+	var list []string
+	for _, region := range strings.Split(os.Getenv("TKE_REGIONS"), ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			list = append(list, region)
+		}
+	}
+	if len(list) == 0 {
+		return []string{defaultRegion}
+	}
+	return list
 }
 
-func (t *tkeAdaptor) GetKubeConfig(eid, clusterID string) (*v1alpha1.KubeConfig, error) {
-	return nil, nil
+func toString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
-func (t *tkeAdaptor) VPCList(regionID string) ([]*v1alpha1.VPC, error) {
-	return nil, nil
+func clusterState(status string) string {
+	switch status {
+	case "Running":
+		return v1alpha1.RunningState
+	case "Creating":
+		return v1alpha1.InitState
+	case "Abnormal":
+		return v1alpha1.InstallFailed
+	}
+	return strings.ToLower(status)
 }
 
-func (t *tkeAdaptor) CreateVPC(v *v1alpha1.VPC) error {
-	return nil
+func (t *tkeAdaptor) clusterConver(regionID string, c *tke.Cluster) *v1alpha1.Cluster {
+	createTime, _ := time.Parse(time.RFC3339, toString(c.CreatedTime))
+	cluster := &v1alpha1.Cluster{
+		Name:              toString(c.ClusterName),
+		ClusterID:         toString(c.ClusterId),
+		Created:           v1alpha1.NewTime(createTime),
+		State:             clusterState(toString(c.ClusterStatus)),
+		ClusterType:       toString(c.ClusterType),
+		CurrentVersion:    toString(c.ClusterVersion),
+		KubernetesVersion: toString(c.ClusterVersion),
+		RegionID:          regionID,
+		Parameters:        make(map[string]interface{}),
+	}
+	if c.ClusterNodeNum != nil {
+		cluster.Size = int(*c.ClusterNodeNum)
+	}
+	if c.ClusterNetworkSettings != nil {
+		cluster.VPCID = toString(c.ClusterNetworkSettings.VpcId)
+		cluster.PodCIDR = toString(c.ClusterNetworkSettings.ClusterCIDR)
+	}
+	return cluster
 }
 
-func (t *tkeAdaptor) DeleteVPC(regionID, vpcID string) error {
-	return nil
+func (t *tkeAdaptor) describeClusters(regionID string, clusterIDs ...string) ([]*v1alpha1.Cluster, error) {
+	client, err := t.tkeClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	req := tke.NewDescribeClustersRequest()
+	req.ClusterIds = common.StringPtrs(clusterIDs)
+	req.Limit = common.Int64Ptr(100)
+	res, err := client.DescribeClusters(req)
+	if err != nil {
+		return nil, fmt.Errorf("query cluster list from tencent api failure %s", err.Error())
+	}
+	var clusters []*v1alpha1.Cluster
+	for _, c := range res.Response.Clusters {
+		clusters = append(clusters, t.clusterConver(regionID, c))
+	}
+	return clusters, nil
 }
 
-func (t *tkeAdaptor) DescribeVPC(regionID, vpcID string) (*v1alpha1.VPC, error) {
-	return nil, nil
+func (t *tkeAdaptor) ClusterList(eid string) ([]*v1alpha1.Cluster, error) {
+	var clusters []*v1alpha1.Cluster
+	for _, region := range regions() {
+		list, err := t.describeClusters(region)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, list...)
+	}
+	var wait sync.WaitGroup
+	for i := range clusters {
+		wait.Add(1)
+		go func(cluster *v1alpha1.Cluster) {
+			defer wait.Done()
+			t.checkRainbondInit(cluster)
+		}(clusters[i])
+	}
+	wait.Wait()
+	return clusters, nil
 }
 
-func (t *tkeAdaptor) CreateVSwitch(v *v1alpha1.VSwitch) error {
-	return nil
+// checkRainbondInit checks whether rainbond can be installed in the cluster, or has been installed.
+func (t *tkeAdaptor) checkRainbondInit(cluster *v1alpha1.Cluster) {
+	if cluster.State != v1alpha1.RunningState {
+		cluster.Parameters["DisableRainbondInit"] = true
+		return
+	}
+	if !versionutil.CheckVersion(cluster.CurrentVersion) {
+		cluster.Parameters["DisableRainbondInit"] = true
+		cluster.Parameters["Message"] = fmt.Sprintf("当前集群版本为 %s ，无法继续初始化，初始化Rainbond支持的版本为1.19.x-1.25.x", cluster.CurrentVersion)
+		return
+	}
+	kube, err := t.getKubeConfig(cluster.RegionID, cluster.ClusterID)
+	if err != nil {
+		cluster.Parameters["Message"] = "无法创建集群通信客户端"
+		cluster.Parameters["DisableRainbondInit"] = true
+		return
+	}
+	coreclient, _, err := kube.GetKubeClient()
+	if err != nil {
+		cluster.Parameters["Message"] = "无法创建集群通信客户端"
+		cluster.Parameters["DisableRainbondInit"] = true
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	if _, err := coreclient.CoreV1().ConfigMaps("rbd-system").Get(ctx, "region-config", metav1.GetOptions{}); err == nil {
+		cluster.RainbondInit = true
+	}
 }
 
-func (t *tkeAdaptor) DescribeVSwitch(regionID, vswitchID string) (*v1alpha1.VSwitch, error) {
-	return nil, nil
+// DescribeCluster describes the cluster, the regions are searched in turn.
+func (t *tkeAdaptor) DescribeCluster(eid, clusterID string) (*v1alpha1.Cluster, error) {
+	if clusterID == "" {
+		return nil, fmt.Errorf("cluster id can not be empty")
+	}
+	for _, region := range regions() {
+		clusters, err := t.describeClusters(region, clusterID)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters {
+			if cluster.ClusterID != clusterID {
+				continue
+			}
+			if err := t.describeClusterNetwork(cluster); err != nil {
+				logrus.Warningf("describe the network of cluster %s: %v", clusterID, err)
+			}
+			return cluster, nil
+		}
+	}
+	return nil, fmt.Errorf("not found cluster %s", clusterID)
 }
 
-func (t *tkeAdaptor) DeleteVSwitch(regionID, vswitchID string) error {
+// describeClusterNetwork sets the zone and subnet of the cluster by its worker nodes.
+func (t *tkeAdaptor) describeClusterNetwork(cluster *v1alpha1.Cluster) error {
+	client, err := t.tkeClient(cluster.RegionID)
+	if err != nil {
+		return err
+	}
+	req := tke.NewDescribeClusterInstancesRequest()
+	req.ClusterId = common.StringPtr(cluster.ClusterID)
+	req.InstanceRole = common.StringPtr("WORKER")
+	res, err := client.DescribeClusterInstances(req)
+	if err != nil {
+		return err
+	}
+	var instanceIDs []string
+	for _, instance := range res.Response.InstanceSet {
+		instanceIDs = append(instanceIDs, toString(instance.InstanceId))
+	}
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	instances, err := t.describeInstances(cluster.RegionID, instanceIDs, nil)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		if instance.Placement != nil {
+			cluster.ZoneID = toString(instance.Placement.Zone)
+		}
+		if instance.VirtualPrivateCloud != nil {
+			cluster.VSwitchID = toString(instance.VirtualPrivateCloud.SubnetId)
+		}
+		if len(instance.SecurityGroupIds) > 0 {
+			cluster.SecurityGroupID = toString(instance.SecurityGroupIds[0])
+		}
+		return nil
+	}
 	return nil
 }
 
-func (t *tkeAdaptor) ListZones(regionID string) ([]*v1alpha1.Zone, error) {
-	return nil, nil
+// describeInstances describes the cvm instances by ids or private ips.
+func (t *tkeAdaptor) describeInstances(regionID string, instanceIDs, privateIPs []string) ([]*cvm.Instance, error) {
+	client, err := t.cvmClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	req := cvm.NewDescribeInstancesRequest()
+	req.Limit = common.Int64Ptr(100)
+	if len(instanceIDs) > 0 {
+		req.InstanceIds = common.StringPtrs(instanceIDs)
+	}
+	if len(privateIPs) > 0 {
+		req.Filters = []*cvm.Filter{{
+			Name:   common.StringPtr("private-ip-address"),
+			Values: common.StringPtrs(privateIPs),
+		}}
+	}
+	res, err := client.DescribeInstances(req)
+	if err != nil {
+		return nil, fmt.Errorf("query instances from tencent api failure %s", err.Error())
+	}
+	return res.Response.InstanceSet, nil
 }
 
-func (t *tkeAdaptor) ListInstanceType(regionID string) ([]*v1alpha1.InstanceType, error) {
-	return nil, nil
+func (t *tkeAdaptor) CreateCluster(eid string, config v1alpha1.CreateClusterConfig) (*v1alpha1.Cluster, error) {
+	tkeConfig, ok := config.(*v1alpha1.TKEClusterConfig)
+	if !ok {
+		return nil, fmt.Errorf("cluster config is not TKEClusterConfig")
+	}
+	runInstancesPara, err := json.Marshal(map[string]interface{}{
+		"InstanceChargeType": "POSTPAID_BY_HOUR",
+		"Placement":          map[string]interface{}{"Zone": tkeConfig.ZoneID},
+		"InstanceType":       tkeConfig.InstanceType,
+		"InstanceCount":      tkeConfig.WorkerNodeNum,
+		"SystemDisk":         map[string]interface{}{"DiskType": "CLOUD_PREMIUM", "DiskSize": tkeConfig.SystemDiskSize},
+		"DataDisks":          []map[string]interface{}{{"DiskType": "CLOUD_PREMIUM", "DiskSize": tkeConfig.DataDiskSize}},
+		"VirtualPrivateCloud": map[string]interface{}{
+			"VpcId":    tkeConfig.VpcID,
+			"SubnetId": tkeConfig.SubnetID,
+		},
+		"InternetAccessible": map[string]interface{}{
+			"InternetChargeType":      "TRAFFIC_POSTPAID_BY_HOUR",
+			"InternetMaxBandwidthOut": 10,
+			"PublicIpAssigned":        true,
+		},
+		"LoginSettings": map[string]interface{}{"Password": tkeConfig.LoginPassword},
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := t.tkeClient(tkeConfig.RegionID)
+	if err != nil {
+		return nil, err
+	}
+	req := tke.NewCreateClusterRequest()
+	req.ClusterType = common.StringPtr("MANAGED_CLUSTER")
+	req.ClusterCIDRSettings = &tke.ClusterCIDRSettings{
+		ClusterCIDR: common.StringPtr(tkeConfig.ClusterCIDR),
+		ServiceCIDR: common.StringPtr(tkeConfig.ServiceCIDR),
+	}
+	req.ClusterBasicSettings = &tke.ClusterBasicSettings{
+		ClusterOs:      common.StringPtr(tkeConfig.ClusterOS),
+		ClusterVersion: common.StringPtr(tkeConfig.ClusterVersion),
+		ClusterName:    common.StringPtr(tkeConfig.ClusterName),
+		VpcId:          common.StringPtr(tkeConfig.VpcID),
+	}
+	req.RunInstancesForNode = []*tke.RunInstancesForNode{{
+		NodeRole:         common.StringPtr("WORKER"),
+		RunInstancesPara: []*string{common.StringPtr(string(runInstancesPara))},
+	}}
+	res, err := client.CreateCluster(req)
+	if err != nil {
+		return nil, fmt.Errorf("create tke cluster from tencent api failure %s", err.Error())
+	}
+	return &v1alpha1.Cluster{
+		Name:              tkeConfig.ClusterName,
+		ClusterID:         toString(res.Response.ClusterId),
+		State:             v1alpha1.InitState,
+		RegionID:          tkeConfig.RegionID,
+		ZoneID:            tkeConfig.ZoneID,
+		VPCID:             tkeConfig.VpcID,
+		VSwitchID:         tkeConfig.SubnetID,
+		PodCIDR:           tkeConfig.ClusterCIDR,
+		KubernetesVersion: tkeConfig.ClusterVersion,
+	}, nil
 }
 
-func (t *tkeAdaptor) CreateDB(*v1alpha1.Database) error {
-	return nil
-}
-func (t *tkeAdaptor) CreateNAS(regionID, zoneID string) (string, error) {
-	return "", nil
+func (t *tkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, config *v1alpha1.KubernetesClusterConfig, rollback func(step, message, status string)) *v1alpha1.Cluster {
+	rollback(v1.StepAllocateResource, "", "start")
+	instanceType, zoneID, err := t.selectInstanceType(config.Region, config.WorkerResourceType)
+	if err != nil {
+		rollback(v1.StepAllocateResource, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepAllocateResource, instanceType, "success")
+	rollback(v1.StepSelectZone, "", "start")
+	rollback(v1.StepSelectZone, zoneID, "success")
+	if config.VpcID == "" {
+		rollback(v1.StepCreateVPC, "", "start")
+		vpc := &v1alpha1.VPC{
+			RegionID:  config.Region,
+			VpcName:   "rainbond-default-vpc",
+			CidrBlock: "10.0.0.0/16",
+		}
+		if err := t.CreateVPC(vpc); err != nil {
+			rollback(v1.StepCreateVPC, err.Error(), "failure")
+			return nil
+		}
+		rollback(v1.StepCreateVPC, vpc.VpcID, "success")
+		config.VpcID = vpc.VpcID
+		rollback(v1.StepCreateVSwitch, "", "start")
+		subnet := &v1alpha1.VSwitch{
+			RegionID:    config.Region,
+			VpcID:       vpc.VpcID,
+			CidrBlock:   "10.0.22.0/24",
+			VSwitchName: "rainbond-default-subnet",
+			ZoneID:      zoneID,
+		}
+		if err := t.CreateVSwitch(subnet); err != nil {
+			rollback(v1.StepCreateVSwitch, err.Error(), "failure")
+			return nil
+		}
+		rollback(v1.StepCreateVSwitch, subnet.VSwitchID, "success")
+		config.VSwitchID = subnet.VSwitchID
+	}
+	config.InstanceType = instanceType
+	rollback(v1.StepCreateCluster, "", "start")
+	cluster, err := t.CreateCluster(eid, v1alpha1.GetDefaultTKECreateClusterConfig(*config, zoneID))
+	if err != nil {
+		rollback(v1.StepCreateCluster, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepCreateCluster, cluster.ClusterID, "success")
+	return cluster
 }
 
-func (t *tkeAdaptor) GetNasZone(regionID string) (string, error) {
-	return "", nil
+// selectInstanceType selects the instance type on sale, which matches the cpu and memory of the resource type.
+func (t *tkeAdaptor) selectInstanceType(regionID, resourceType string) (string, string, error) {
+	client, err := t.cvmClient(regionID)
+	if err != nil {
+		return "", "", err
+	}
+	req := cvm.NewDescribeZoneInstanceConfigInfosRequest()
+	req.Filters = []*cvm.Filter{{
+		Name:   common.StringPtr("instance-charge-type"),
+		Values: common.StringPtrs([]string{"POSTPAID_BY_HOUR"}),
+	}}
+	res, err := client.DescribeZoneInstanceConfigInfos(req)
+	if err != nil {
+		return "", "", fmt.Errorf("query instance types from tencent api failure %s", err.Error())
+	}
+	cpu, memory := instanceResource(resourceType)
+	for _, item := range res.Response.InstanceTypeQuotaSet {
+		if toString(item.Status) != "SELL" || item.Cpu == nil || item.Memory == nil {
+			continue
+		}
+		if *item.Cpu == cpu && *item.Memory == memory {
+			return toString(item.InstanceType), toString(item.Zone), nil
+		}
+	}
+	return "", "", fmt.Errorf("Unable to find a suitable instance type, it may be that the region is currently sold out.")
 }
 
-func (t *tkeAdaptor) GetNASInfo(regionID, fileSystemID string) (*v1alpha1.NasStorageInfo, error) {
-	return nil, nil
+// instanceResource returns the cpu and memory(GB) of the worker resource type, such as ecs.g5.xlarge.
+func instanceResource(resourceType string) (int64, int64) {
+	switch {
+	case strings.Contains(resourceType, ".3xlarge"):
+		return 12, 48
+	case strings.Contains(resourceType, ".2xlarge"):
+		return 8, 32
+	case strings.Contains(resourceType, ".xlarge"):
+		return 4, 16
+	}
+	return 2, 8
 }
 
-func (t *tkeAdaptor) CreateNASMountTarget(regionID, fileSystemID, VpcID, VSwitchID string) (string, error) {
-	return "", nil
+//DeleteCluster delete cluster
+func (t *tkeAdaptor) DeleteCluster(eid, clusterID string) error {
+	return nil
 }
 
-func (t *tkeAdaptor) CreateLoadBalancer(regionID string) (*v1alpha1.LoadBalancer, error) {
-	return nil, nil
+// GetKubeConfig returns the kubeconfig with the extranet endpoint of the cluster.
+func (t *tkeAdaptor) GetKubeConfig(eid, clusterID string) (*v1alpha1.KubeConfig, error) {
+	cluster, err := t.DescribeCluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return t.getKubeConfig(cluster.RegionID, clusterID)
 }
 
-func (t *tkeAdaptor) BoundLoadBalancerToCluster(regionID, VpcID, loadBalancerID string, endpoints []string) error {
-	return nil
+func (t *tkeAdaptor) getKubeConfig(regionID, clusterID string) (*v1alpha1.KubeConfig, error) {
+	client, err := t.tkeClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	req := tke.NewDescribeClusterSecurityRequest()
+	req.ClusterId = common.StringPtr(clusterID)
+	res, err := client.DescribeClusterSecurity(req)
+	if err != nil {
+		return nil, fmt.Errorf("query kube config from tencent api failure %s", err.Error())
+	}
+	endpoint := toString(res.Response.ClusterExternalEndpoint)
+	if endpoint == "" {
+		// the extranet endpoint is required to connect the cluster
+		if err := t.createExtranetEndpoint(client, clusterID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("the extranet endpoint of cluster %s is not ready", clusterID)
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	var config *clientcmdapi.Config
+	if kubeconfig := toString(res.Response.Kubeconfig); kubeconfig != "" {
+		config, err = clientcmd.Load([]byte(kubeconfig))
+		if err != nil {
+			return nil, fmt.Errorf("load kube config: %v", err)
+		}
+	} else {
+		config = clientcmdapi.NewConfig()
+		config.Clusters[clusterID] = &clientcmdapi.Cluster{
+			CertificateAuthorityData: []byte(toString(res.Response.CertificationAuthority)),
+		}
+		config.AuthInfos[clusterID] = &clientcmdapi.AuthInfo{
+			Username: toString(res.Response.UserName),
+			Password: toString(res.Response.Password),
+		}
+		config.Contexts[clusterID] = &clientcmdapi.Context{Cluster: clusterID, AuthInfo: clusterID}
+		config.CurrentContext = clusterID
+	}
+	for _, c := range config.Clusters {
+		c.Server = endpoint
+	}
+	out, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, err
+	}
+	return &v1alpha1.KubeConfig{Config: string(out)}, nil
 }
 
-// init rainbond region
-func (t *tkeAdaptor) InitRainbondRegion(initConfig *v1alpha1.RainbondInitConfig) error {
+func (t *tkeAdaptor) createExtranetEndpoint(client *tke.Client, clusterID string) error {
+	statusReq := tke.NewDescribeClusterEndpointStatusRequest()
+	statusReq.ClusterId = common.StringPtr(clusterID)
+	statusReq.IsExtranet = common.BoolPtr(true)
+	status, err := client.DescribeClusterEndpointStatus(statusReq)
+	if err != nil {
+		return fmt.Errorf("query cluster endpoint status from tencent api failure %s", err.Error())
+	}
+	if toString(status.Response.Status) != "NotFound" {
+		return nil
+	}
+	req := tke.NewCreateClusterEndpointRequest()
+	req.ClusterId = common.StringPtr(clusterID)
+	req.IsExtranet = common.BoolPtr(true)
+	if _, err := client.CreateClusterEndpoint(req); err != nil {
+		return fmt.Errorf("create cluster extranet endpoint from tencent api failure %s", err.Error())
+	}
 	return nil
 }
 
-func (t *tkeAdaptor) SetSecurityGroup(regionID, securityGroupID string) error {
-	return nil
-}
+//GetRainbondInitConfig get rainbond init config
+func (t *tkeAdaptor) GetRainbondInitConfig(eid string, cluster *v1alpha1.Cluster, gateway, chaos []*rainbondv1alpha1.K8sNode, rollback func(step, message, status string)) *v1alpha1.RainbondInitConfig {
+	rollback(v1.StepCreateRDS, "", "start")
+	password, err := t.regionDBPassword(eid, cluster.ClusterID)
+	if err != nil {
+		rollback(v1.StepCreateRDS, err.Error(), "failure")
+		return nil
+	}
+	regionDB := &v1alpha1.Database{
+		Name:      "region",
+		RegionID:  cluster.RegionID,
+		UserName:  "rainbond_region",
+		VPCID:     cluster.VPCID,
+		ZoneID:    cluster.ZoneID,
+		PodCIDR:   cluster.PodCIDR,
+		VSwitchID: cluster.VSwitchID,
+		Password:  password,
+		ClusterID: cluster.ClusterID,
+	}
+	if err := t.CreateDB(regionDB); err != nil {
+		rollback(v1.StepCreateRDS, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepCreateRDS, regionDB.InstanceID, "success")
 
-func (t *tkeAdaptor) DescribeAvailableResourceZones(regionID, InstanceType string) ([]*v1alpha1.AvailableResourceZone, error) {
-	return nil, nil
-}
+	rollback(v1.StepCreateNAS, "", "start")
+	fileSystemID, err := t.CreateNAS(cluster.ClusterID, cluster.RegionID, cluster.ZoneID, cluster.VPCID, cluster.VSwitchID)
+	if err != nil {
+		rollback(v1.StepCreateNAS, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepCreateNAS, fileSystemID, "success")
+	rollback(v1.StepCreateNASMount, "", "start")
+	nfsServer, err := t.GetNASMountTarget(cluster.RegionID, fileSystemID)
+	if err != nil {
+		rollback(v1.StepCreateNASMount, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepCreateNASMount, nfsServer, "success")
 
-func (t *tkeAdaptor) CreateRainbondKubernetes(ctx context.Context, eid string, config *v1alpha1.KubernetesClusterConfig, rollback func(step, message, status string)) *v1alpha1.Cluster {
-	return nil
+	rollback(v1.StepCreateLoadBalancer, "", "start")
+	clb, err := t.CreateLoadBalancer(cluster.ClusterID, cluster.RegionID, cluster.VPCID)
+	if err != nil {
+		rollback(v1.StepCreateLoadBalancer, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepCreateLoadBalancer, clb.LoadBalancerID+","+clb.Address, "success")
+
+	rollback(v1.StepBoundLoadBalancer, "", "start")
+	var gatewayIPs []string
+	for _, g := range gateway {
+		gatewayIPs = append(gatewayIPs, g.InternalIP)
+	}
+	if err := t.BoundLoadBalancerToCluster(cluster.RegionID, clb.LoadBalancerID, gatewayIPs); err != nil {
+		rollback(v1.StepBoundLoadBalancer, err.Error(), "failure")
+		return nil
+	}
+	rollback(v1.StepBoundLoadBalancer, "80,443,8443,6060", "success")
+	return &v1alpha1.RainbondInitConfig{
+		ClusterID:      cluster.ClusterID,
+		RegionDatabase: regionDB,
+		NFSServer:      nfsServer,
+		GatewayNodes:   gateway,
+		ChaosNodes:     chaos,
+		EIPs:           []string{clb.Address},
+	}
 }
-func (t *tkeAdaptor) GetRainbondInitConfig(eid string, cluster *v1alpha1.Cluster, gateway, chaos []*rainbondv1alpha1.K8sNode, rollback func(step, message, status string)) *v1alpha1.RainbondInitConfig {
-	return nil
+
+// regionDBPassword returns the password of the region database of the cluster. It is generated and saved at the first time,
+// the account created before is reused with the same password if the init is retried.
+func (t *tkeAdaptor) regionDBPassword(eid, clusterID string) (string, error) {
+	key := fmt.Sprintf("enterprise/%s/tke/%s/region-db-password", eid, clusterID)
+	password, err := t.store.Get(key)
+	if err == nil {
+		return string(password), nil
+	}
+	if err != blobstore.ErrNotFound {
+		return "", fmt.Errorf("get the password of the region database failure %s", err.Error())
+	}
+	password = []byte(util.RandPassword(16))
+	if err := t.store.Put(key, password); err != nil {
+		return "", fmt.Errorf("save the password of the region database failure %s", err.Error())
+	}
+	return string(password), nil
 }
 
 func (t *tkeAdaptor) ExpansionNode(ctx context.Context, eid string, en *v1alpha1.ExpansionNode, rollback func(step, message, status string)) *v1alpha1.Cluster {
 	return nil
 }
+
+// waitFor calls the check every interval until it returns true or the timeout.
+func waitFor(timeout, interval time.Duration, check func() (bool, error)) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-timer.C:
+			return fmt.Errorf("wait timeout")
+		case <-time.After(interval):
+		}
+	}
+}
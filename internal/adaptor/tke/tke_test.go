@@ -19,20 +19,311 @@
 package tke
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
-var testAccess = ""
-var testSecret = ""
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://10.0.0.2:443
+  name: cls-test
+contexts:
+- context:
+    cluster: cls-test
+    user: admin
+  name: cls-test
+current-context: cls-test
+users:
+- name: admin
+  user:
+    token: test
+`
+
+// fakeTencentCloud is a fake of the tencent cloud api endpoint, it responds by the action of the request.
+type fakeTencentCloud struct {
+	lock      sync.Mutex
+	responses map[string]interface{}
+	requests  map[string][]map[string]interface{}
+}
+
+func newFakeTencentCloud(t *testing.T, responses map[string]interface{}) (*fakeTencentCloud, *tkeAdaptor) {
+	fake := &fakeTencentCloud{responses: responses, requests: make(map[string][]map[string]interface{})}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	pollInterval = 0
+	return fake, &tkeAdaptor{
+		accessKeyID:     "test",
+		accessKeySecret: "test",
+		endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		scheme:          "http",
+		store:           blobstore.NewLocalStore(t.TempDir()),
+	}
+}
+
+func (f *fakeTencentCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action := r.Header.Get("X-TC-Action")
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.lock.Lock()
+	f.requests[action] = append(f.requests[action], body)
+	res, ok := f.responses[action]
+	f.lock.Unlock()
+	if !ok {
+		res = map[string]interface{}{"Error": map[string]string{"Code": "InvalidAction", "Message": "unknown action " + action}}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"Response": res})
+}
+
+func (f *fakeTencentCloud) requested(action string) []map[string]interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.requests[action]
+}
 
 func TestDescribeCluster(t *testing.T) {
-	adaptor, err := Create(testAccess, testSecret)
+	_, adaptor := newFakeTencentCloud(t, map[string]interface{}{
+		"DescribeClusters": map[string]interface{}{
+			"TotalCount": 1,
+			"Clusters": []map[string]interface{}{{
+				"ClusterId":              "cls-test",
+				"ClusterName":            "test",
+				"ClusterVersion":         "1.20.6",
+				"ClusterType":            "MANAGED_CLUSTER",
+				"ClusterStatus":          "Running",
+				"ClusterNodeNum":         3,
+				"CreatedTime":            "2021-06-01T10:00:00Z",
+				"ClusterNetworkSettings": map[string]interface{}{"VpcId": "vpc-test", "ClusterCIDR": "172.20.0.0/16"},
+			}},
+		},
+		"DescribeClusterInstances": map[string]interface{}{
+			"TotalCount":  1,
+			"InstanceSet": []map[string]interface{}{{"InstanceId": "ins-test", "InstanceRole": "WORKER"}},
+		},
+		"DescribeInstances": map[string]interface{}{
+			"TotalCount": 1,
+			"InstanceSet": []map[string]interface{}{{
+				"InstanceId":          "ins-test",
+				"Placement":           map[string]interface{}{"Zone": "ap-guangzhou-3"},
+				"VirtualPrivateCloud": map[string]interface{}{"VpcId": "vpc-test", "SubnetId": "subnet-test"},
+			}},
+		},
+	})
+	cluster, err := adaptor.DescribeCluster("test", "cls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.State != v1alpha1.RunningState || cluster.Size != 3 || cluster.VPCID != "vpc-test" {
+		t.Fatalf("unexpected cluster %+v", cluster)
+	}
+	if cluster.ZoneID != "ap-guangzhou-3" || cluster.VSwitchID != "subnet-test" {
+		t.Fatalf("unexpected cluster network %s %s", cluster.ZoneID, cluster.VSwitchID)
+	}
+	if _, err := adaptor.DescribeCluster("test", "cls-notfound"); err == nil {
+		t.Fatal("expect error for not found cluster")
+	}
+}
+
+func TestGetKubeConfig(t *testing.T) {
+	_, adaptor := newFakeTencentCloud(t, map[string]interface{}{
+		"DescribeClusters": map[string]interface{}{
+			"Clusters": []map[string]interface{}{{"ClusterId": "cls-test", "ClusterStatus": "Running"}},
+		},
+		"DescribeClusterInstances": map[string]interface{}{},
+		"DescribeClusterSecurity": map[string]interface{}{
+			"Kubeconfig":              testKubeConfig,
+			"ClusterExternalEndpoint": "cls-test.ccs.tencent-cloud.com",
+		},
+	})
+	kubeConfig, err := adaptor.GetKubeConfig("test", "cls-test")
 	if err != nil {
 		t.Fatal(err)
 	}
-	clusters, err := adaptor.ClusterList("test")
+	config, err := clientcmd.Load([]byte(kubeConfig.Config))
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Log(clusters)
+	if server := config.Clusters["cls-test"].Server; server != "https://cls-test.ccs.tencent-cloud.com" {
+		t.Fatalf("the server of kubeconfig is %s, expect the extranet endpoint", server)
+	}
+}
+
+func TestGetKubeConfigWithoutExtranetEndpoint(t *testing.T) {
+	fake, adaptor := newFakeTencentCloud(t, map[string]interface{}{
+		"DescribeClusterSecurity":       map[string]interface{}{"Kubeconfig": testKubeConfig},
+		"DescribeClusterEndpointStatus": map[string]interface{}{"Status": "NotFound"},
+		"CreateClusterEndpoint":         map[string]interface{}{},
+	})
+	if _, err := adaptor.getKubeConfig(defaultRegion, "cls-test"); err == nil {
+		t.Fatal("expect error when the extranet endpoint is not ready")
+	}
+	if len(fake.requested("CreateClusterEndpoint")) != 1 {
+		t.Fatal("expect the extranet endpoint is created")
+	}
+}
+
+func TestNetworkResources(t *testing.T) {
+	_, adaptor := newFakeTencentCloud(t, map[string]interface{}{
+		"DescribeVpcs": map[string]interface{}{
+			"VpcSet": []map[string]interface{}{{"VpcId": "vpc-test", "VpcName": "test", "CidrBlock": "10.0.0.0/16"}},
+		},
+		"CreateVpc":    map[string]interface{}{"Vpc": map[string]interface{}{"VpcId": "vpc-new"}},
+		"CreateSubnet": map[string]interface{}{"Subnet": map[string]interface{}{"SubnetId": "subnet-new"}},
+		"DescribeZones": map[string]interface{}{
+			"ZoneSet": []map[string]interface{}{
+				{"Zone": "ap-guangzhou-3", "ZoneName": "广州三区", "ZoneState": "AVAILABLE"},
+				{"Zone": "ap-guangzhou-1", "ZoneName": "广州一区", "ZoneState": "UNAVAILABLE"},
+			},
+		},
+		"DescribeInstanceTypeConfigs": map[string]interface{}{
+			"InstanceTypeConfigSet": []map[string]interface{}{
+				{"Zone": "ap-guangzhou-3", "InstanceType": "S5.LARGE8", "InstanceFamily": "S5", "CPU": 4, "Memory": 8},
+				{"Zone": "ap-guangzhou-4", "InstanceType": "S5.LARGE8", "InstanceFamily": "S5", "CPU": 4, "Memory": 8},
+			},
+		},
+	})
+	vpcs, err := adaptor.VPCList(defaultRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vpcs) != 1 || vpcs[0].VpcID != "vpc-test" {
+		t.Fatalf("unexpected vpcs %+v", vpcs)
+	}
+	vpc := &v1alpha1.VPC{RegionID: defaultRegion, VpcName: "test", CidrBlock: "10.0.0.0/16"}
+	if err := adaptor.CreateVPC(vpc); err != nil || vpc.VpcID != "vpc-new" {
+		t.Fatalf("create vpc %s: %v", vpc.VpcID, err)
+	}
+	subnet := &v1alpha1.VSwitch{RegionID: defaultRegion, VpcID: vpc.VpcID, ZoneID: "ap-guangzhou-3", CidrBlock: "10.0.22.0/24"}
+	if err := adaptor.CreateVSwitch(subnet); err != nil || subnet.VSwitchID != "subnet-new" {
+		t.Fatalf("create subnet %s: %v", subnet.VSwitchID, err)
+	}
+	zones, err := adaptor.ListZones(defaultRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 || zones[0].ZoneID != "ap-guangzhou-3" {
+		t.Fatalf("unexpected zones %+v", zones)
+	}
+	types, err := adaptor.ListInstanceType(defaultRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 1 || types[0].CPUCoreCount != 4 || types[0].MemorySize != 8 {
+		t.Fatalf("unexpected instance types %+v", types)
+	}
+}
+
+func TestCreateRainbondKubernetes(t *testing.T) {
+	fake, adaptor := newFakeTencentCloud(t, map[string]interface{}{
+		"DescribeZoneInstanceConfigInfos": map[string]interface{}{
+			"InstanceTypeQuotaSet": []map[string]interface{}{
+				{"Zone": "ap-guangzhou-3", "InstanceType": "S5.2XLARGE16", "Cpu": 8, "Memory": 16, "Status": "SELL"},
+				{"Zone": "ap-guangzhou-3", "InstanceType": "S5.LARGE16", "Cpu": 4, "Memory": 16, "Status": "SOLD_OUT"},
+				{"Zone": "ap-guangzhou-4", "InstanceType": "S5.LARGE16", "Cpu": 4, "Memory": 16, "Status": "SELL"},
+			},
+		},
+		"CreateCluster": map[string]interface{}{"ClusterId": "cls-new"},
+	})
+	var failures []string
+	cluster := adaptor.CreateRainbondKubernetes(nil, "test", &v1alpha1.KubernetesClusterConfig{
+		ClusterName:        "test",
+		Region:             defaultRegion,
+		VpcID:              "vpc-test",
+		VSwitchID:          "subnet-test",
+		WorkerResourceType: "ecs.g5.xlarge",
+		WorkerNodeNum:      3,
+	}, func(step, message, status string) {
+		if status == "failure" {
+			failures = append(failures, step+": "+message)
+		}
+	})
+	if cluster == nil {
+		t.Fatalf("create cluster failure %v", failures)
+	}
+	if cluster.ClusterID != "cls-new" || cluster.ZoneID != "ap-guangzhou-4" {
+		t.Fatalf("unexpected cluster %+v", cluster)
+	}
+	req := fake.requested("CreateCluster")[0]
+	if req["ClusterType"] != "MANAGED_CLUSTER" {
+		t.Fatalf("unexpected cluster type %v", req["ClusterType"])
+	}
+	para := req["RunInstancesForNode"].([]interface{})[0].(map[string]interface{})["RunInstancesPara"].([]interface{})[0].(string)
+	if !strings.Contains(para, `"InstanceType":"S5.LARGE16"`) || !strings.Contains(para, `"SubnetId":"subnet-test"`) {
+		t.Fatalf("unexpected run instances para %s", para)
+	}
+}
+
+func TestGetRainbondInitConfig(t *testing.T) {
+	fake, adaptor := newFakeTencentCloud(t, map[string]interface{}{
+		"DescribeDBInstances":     map[string]interface{}{"Items": []map[string]interface{}{{"InstanceId": "cdb-test", "InstanceName": "other", "Status": 1, "Vip": "10.0.0.10", "Vport": 3306}}},
+		"CreateDBInstanceHour":    map[string]interface{}{"InstanceIds": []string{"cdb-test"}},
+		"DescribeAccounts":        map[string]interface{}{},
+		"CreateAccounts":          map[string]interface{}{},
+		"ModifyAccountPrivileges": map[string]interface{}{},
+		"DescribeCfsFileSystems":  map[string]interface{}{"FileSystems": []map[string]interface{}{{"FileSystemId": "cfs-test", "FsName": "other", "LifeCycleState": "available"}}},
+		"CreateCfsFileSystem":     map[string]interface{}{"FileSystemId": "cfs-test"},
+		"DescribeMountTargets":    map[string]interface{}{"MountTargets": []map[string]interface{}{{"FileSystemId": "cfs-test", "IpAddress": "10.0.0.20"}}},
+		"DescribeLoadBalancers":   map[string]interface{}{"LoadBalancerSet": []map[string]interface{}{{"LoadBalancerId": "lb-test", "LoadBalancerName": "other", "Status": 1, "LoadBalancerVips": []string{"1.1.1.1"}}}},
+		"CreateLoadBalancer":      map[string]interface{}{"LoadBalancerIds": []string{"lb-test"}},
+		"DescribeInstances":       map[string]interface{}{"InstanceSet": []map[string]interface{}{{"InstanceId": "ins-gateway"}}},
+		"DescribeListeners":       map[string]interface{}{"Listeners": []map[string]interface{}{{"ListenerId": "lbl-80", "Port": 80}}},
+		"CreateListener":          map[string]interface{}{"ListenerIds": []string{"lbl-new"}, "RequestId": "task"},
+		"RegisterTargets":         map[string]interface{}{"RequestId": "task"},
+		"DescribeTaskStatus":      map[string]interface{}{"Status": 0},
+	})
+	var failures []string
+	cluster := &v1alpha1.Cluster{
+		ClusterID: "cls-test",
+		RegionID:  defaultRegion,
+		ZoneID:    "ap-guangzhou-3",
+		VPCID:     "vpc-test",
+		VSwitchID: "subnet-test",
+	}
+	rollback := func(step, message, status string) {
+		if status == "failure" {
+			failures = append(failures, step+": "+message)
+		}
+	}
+	initConfig := adaptor.GetRainbondInitConfig("test", cluster, []*rainbondv1alpha1.K8sNode{{InternalIP: "10.0.0.5"}}, nil, rollback)
+	if initConfig == nil {
+		t.Fatalf("get rainbond init config failure %v", failures)
+	}
+	if initConfig.RegionDatabase.Host != "10.0.0.10" || initConfig.RegionDatabase.Port != 3306 {
+		t.Fatalf("unexpected region database %+v", initConfig.RegionDatabase)
+	}
+	if initConfig.NFSServer != "10.0.0.20" {
+		t.Fatalf("unexpected nfs server %s", initConfig.NFSServer)
+	}
+	if len(initConfig.EIPs) != 1 || initConfig.EIPs[0] != "1.1.1.1" {
+		t.Fatalf("unexpected eips %v", initConfig.EIPs)
+	}
+	// the listener of port 80 exists, the others are created
+	if n := len(fake.requested("CreateListener")); n != len(gatewayPorts)-1 {
+		t.Fatalf("expect %d listeners are created, got %d", len(gatewayPorts)-1, n)
+	}
+	if n := len(fake.requested("RegisterTargets")); n != len(gatewayPorts) {
+		t.Fatalf("expect targets are registered to %d listeners, got %d", len(gatewayPorts), n)
+	}
+	if len(fake.requested("CreateDBInstanceHour")) != 1 || len(fake.requested("CreateAccounts")) != 1 {
+		t.Fatal("expect the cdb instance and account are created")
+	}
+	password := initConfig.RegionDatabase.Password
+	if strings.Contains(password, "cls-test") || fake.requested("CreateAccounts")[0]["Password"] != password {
+		t.Fatalf("unexpected password %s of the region database", password)
+	}
+	// the init is retried with the saved password
+	initConfig = adaptor.GetRainbondInitConfig("test", cluster, []*rainbondv1alpha1.K8sNode{{InternalIP: "10.0.0.5"}}, nil, rollback)
+	if initConfig == nil || initConfig.RegionDatabase.Password != password {
+		t.Fatalf("expect the password of the region database is reused, failures %v", failures)
+	}
 }
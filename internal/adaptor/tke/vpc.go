@@ -0,0 +1,240 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tke
+
+import (
+	"fmt"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	cvm "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cvm/v20170312"
+	vpc "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/vpc/v20170312"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+func (t *tkeAdaptor) vpcClient(regionID string) (*vpc.Client, error) {
+	return vpc.NewClient(t.credential(), regionID, t.clientProfile())
+}
+
+func vpcConver(regionID string, v *vpc.Vpc) *v1alpha1.VPC {
+	re := &v1alpha1.VPC{
+		VpcID:        toString(v.VpcId),
+		RegionID:     regionID,
+		Status:       "Available",
+		VpcName:      toString(v.VpcName),
+		CreationTime: toString(v.CreatedTime),
+		CidrBlock:    toString(v.CidrBlock),
+	}
+	if v.IsDefault != nil {
+		re.IsDefault = *v.IsDefault
+	}
+	return re
+}
+
+func subnetConver(regionID string, s *vpc.Subnet) *v1alpha1.VSwitch {
+	re := &v1alpha1.VSwitch{
+		VpcID:        toString(s.VpcId),
+		RegionID:     regionID,
+		VSwitchID:    toString(s.SubnetId),
+		Status:       "Available",
+		CidrBlock:    toString(s.CidrBlock),
+		ZoneID:       toString(s.Zone),
+		VSwitchName:  toString(s.SubnetName),
+		CreationTime: toString(s.CreatedTime),
+		NetworkACLID: toString(s.NetworkAclId),
+	}
+	if s.AvailableIpAddressCount != nil {
+		re.AvailableIPAddressCount = int64(*s.AvailableIpAddressCount)
+	}
+	if s.IsDefault != nil {
+		re.IsDefault = *s.IsDefault
+	}
+	return re
+}
+
+func (t *tkeAdaptor) VPCList(regionID string) ([]*v1alpha1.VPC, error) {
+	client, err := t.vpcClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	req := vpc.NewDescribeVpcsRequest()
+	req.Limit = common.StringPtr("100")
+	res, err := client.DescribeVpcs(req)
+	if err != nil {
+		return nil, fmt.Errorf("query vpc list from tencent api failure %s", err.Error())
+	}
+	var list []*v1alpha1.VPC
+	for _, v := range res.Response.VpcSet {
+		list = append(list, vpcConver(regionID, v))
+	}
+	return list, nil
+}
+
+func (t *tkeAdaptor) CreateVPC(v *v1alpha1.VPC) error {
+	client, err := t.vpcClient(v.RegionID)
+	if err != nil {
+		return err
+	}
+	req := vpc.NewCreateVpcRequest()
+	req.VpcName = common.StringPtr(v.VpcName)
+	req.CidrBlock = common.StringPtr(v.CidrBlock)
+	res, err := client.CreateVpc(req)
+	if err != nil {
+		return fmt.Errorf("create vpc from tencent api failure %s", err.Error())
+	}
+	v.VpcID = toString(res.Response.Vpc.VpcId)
+	v.Status = "Available"
+	return nil
+}
+
+func (t *tkeAdaptor) DeleteVPC(regionID, vpcID string) error {
+	client, err := t.vpcClient(regionID)
+	if err != nil {
+		return err
+	}
+	req := vpc.NewDeleteVpcRequest()
+	req.VpcId = common.StringPtr(vpcID)
+	if _, err := client.DeleteVpc(req); err != nil {
+		return fmt.Errorf("delete vpc from tencent api failure %s", err.Error())
+	}
+	return nil
+}
+
+func (t *tkeAdaptor) DescribeVPC(regionID, vpcID string) (*v1alpha1.VPC, error) {
+	client, err := t.vpcClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	req := vpc.NewDescribeVpcsRequest()
+	req.VpcIds = common.StringPtrs([]string{vpcID})
+	res, err := client.DescribeVpcs(req)
+	if err != nil {
+		return nil, fmt.Errorf("query vpc from tencent api failure %s", err.Error())
+	}
+	for _, v := range res.Response.VpcSet {
+		if toString(v.VpcId) == vpcID {
+			return vpcConver(regionID, v), nil
+		}
+	}
+	return nil, fmt.Errorf("not found vpc %s", vpcID)
+}
+
+func (t *tkeAdaptor) CreateVSwitch(v *v1alpha1.VSwitch) error {
+	client, err := t.vpcClient(v.RegionID)
+	if err != nil {
+		return err
+	}
+	req := vpc.NewCreateSubnetRequest()
+	req.VpcId = common.StringPtr(v.VpcID)
+	req.SubnetName = common.StringPtr(v.VSwitchName)
+	req.CidrBlock = common.StringPtr(v.CidrBlock)
+	req.Zone = common.StringPtr(v.ZoneID)
+	res, err := client.CreateSubnet(req)
+	if err != nil {
+		return fmt.Errorf("create subnet from tencent api failure %s", err.Error())
+	}
+	v.VSwitchID = toString(res.Response.Subnet.SubnetId)
+	v.Status = "Available"
+	return nil
+}
+
+func (t *tkeAdaptor) DescribeVSwitch(regionID, vswitchID string) (*v1alpha1.VSwitch, error) {
+	client, err := t.vpcClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	req := vpc.NewDescribeSubnetsRequest()
+	req.SubnetIds = common.StringPtrs([]string{vswitchID})
+	res, err := client.DescribeSubnets(req)
+	if err != nil {
+		return nil, fmt.Errorf("query subnet from tencent api failure %s", err.Error())
+	}
+	for _, s := range res.Response.SubnetSet {
+		if toString(s.SubnetId) == vswitchID {
+			return subnetConver(regionID, s), nil
+		}
+	}
+	return nil, fmt.Errorf("not found subnet %s", vswitchID)
+}
+
+func (t *tkeAdaptor) DeleteVSwitch(regionID, vswitchID string) error {
+	client, err := t.vpcClient(regionID)
+	if err != nil {
+		return err
+	}
+	req := vpc.NewDeleteSubnetRequest()
+	req.SubnetId = common.StringPtr(vswitchID)
+	if _, err := client.DeleteSubnet(req); err != nil {
+		return fmt.Errorf("delete subnet from tencent api failure %s", err.Error())
+	}
+	return nil
+}
+
+func (t *tkeAdaptor) ListZones(regionID string) ([]*v1alpha1.Zone, error) {
+	client, err := t.cvmClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.DescribeZones(cvm.NewDescribeZonesRequest())
+	if err != nil {
+		return nil, fmt.Errorf("query zone list from tencent api failure %s", err.Error())
+	}
+	var zones []*v1alpha1.Zone
+	for _, z := range res.Response.ZoneSet {
+		if toString(z.ZoneState) != "AVAILABLE" {
+			continue
+		}
+		zones = append(zones, &v1alpha1.Zone{
+			ZoneID:    toString(z.Zone),
+			LocalName: toString(z.ZoneName),
+		})
+	}
+	return zones, nil
+}
+
+func (t *tkeAdaptor) ListInstanceType(regionID string) ([]*v1alpha1.InstanceType, error) {
+	client, err := t.cvmClient(regionID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.DescribeInstanceTypeConfigs(cvm.NewDescribeInstanceTypeConfigsRequest())
+	if err != nil {
+		return nil, fmt.Errorf("query instance type list from tencent api failure %s", err.Error())
+	}
+	// the same instance type is returned by every zone
+	exists := make(map[string]struct{})
+	var types []*v1alpha1.InstanceType
+	for _, c := range res.Response.InstanceTypeConfigSet {
+		if _, ok := exists[toString(c.InstanceType)]; ok {
+			continue
+		}
+		exists[toString(c.InstanceType)] = struct{}{}
+		it := &v1alpha1.InstanceType{
+			InstanceTypeID:     toString(c.InstanceType),
+			InstanceTypeFamily: toString(c.InstanceFamily),
+		}
+		if c.CPU != nil {
+			it.CPUCoreCount = int(*c.CPU)
+		}
+		if c.Memory != nil {
+			it.MemorySize = float64(*c.Memory)
+		}
+		types = append(types, it)
+	}
+	return types, nil
+}
@@ -22,6 +22,8 @@ import (
 	"net"
 	"os"
 	"strings"
+
+	"goodrain.com/cloud-adaptor/pkg/util"
 )
 
 //GetDefaultACKCreateClusterConfig get create ack cluster default config
@@ -107,6 +109,6 @@ func GetDefaultACKCreateClusterConfig(config KubernetesClusterConfig) CreateClus
 		CPUPolicy:                "none",
 		VPCID:                    config.VpcID,
 		VSwitchIDs:               []string{config.VSwitchID},
-		LoginPassword:            "RootPassword123!",
+		LoginPassword:            util.RandPassword(16),
 	}
 }
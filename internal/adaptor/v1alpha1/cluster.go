@@ -31,6 +31,7 @@ import (
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
 	v3 "github.com/rancher/rke/types"
 	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -76,8 +77,39 @@ type Cluster struct {
 	RainbondInit      bool                   `json:"rainbond_init,omitempty"`
 	CreateLogPath     string                 `json:"create_log_path,omitempty"`
 	EIP               []string               `json:"eip,omitempty"`
+
+	// Health the health of the cluster checked periodically, nil if the cluster has not been checked yet.
+	Health *ClusterHealth `json:"health,omitempty"`
+}
+
+//ClusterHealth the health of the cluster
+type ClusterHealth struct {
+	Status       string     `json:"status"`
+	APIReachable bool       `json:"api_reachable"`
+	ReadyNodes   int        `json:"ready_nodes"`
+	TotalNodes   int        `json:"total_nodes"`
+	RegionStatus string     `json:"region_status"`
+	Reason       string     `json:"reason,omitempty"`
+	LastSeen     *time.Time `json:"last_seen,omitempty"`
+	CheckedAt    time.Time  `json:"checked_at"`
 }
 
+// The health states of the cluster
+var (
+	HealthHealthy     = "healthy"
+	HealthDegraded    = "degraded"
+	HealthUnreachable = "unreachable"
+	HealthUnknown     = "unknown"
+)
+
+// The states of the rainbond region in the cluster
+var (
+	RegionReady        = "ready"
+	RegionNotReady     = "notReady"
+	RegionNotInstalled = "notInstalled"
+	RegionUnknown      = "unknown"
+)
+
 //RunningState running
 var RunningState = "running"
 
@@ -218,6 +250,38 @@ type KubernetesClusterConfig struct {
 	InstanceType       string                            `json:"instanceType,omitempty"`
 	DockerVersion      string                            `json:"dockerVersion,omitempty"`
 	KubernetesVersion  string                            `json:"kubernetesVersion,omitempty"`
+	Bastion            *SSHBastion                       `json:"bastion,omitempty"`
+	// SkipPreflight installs the cluster without checking the nodes
+	SkipPreflight bool `json:"skipPreflight,omitempty"`
+}
+
+//SSHBastion ssh bastion host that nodes are reached through
+type SSHBastion struct {
+	Address    string `json:"address" binding:"required"`
+	Port       uint   `json:"port"`
+	User       string `json:"user" binding:"required"`
+	PrivateKey string `json:"privateKey,omitempty"`
+}
+
+//Dialer returns the bastion used to dial ssh, falling back to the defaultKey
+//when the bastion has no key of its own.
+func (b *SSHBastion) Dialer(defaultKey []byte) *ssh.Bastion {
+	if b == nil || b.Address == "" {
+		return nil
+	}
+	bastion := &ssh.Bastion{
+		Host:       b.Address,
+		Port:       b.Port,
+		User:       b.User,
+		PrivateKey: []byte(b.PrivateKey),
+	}
+	if bastion.Port == 0 {
+		bastion.Port = 22
+	}
+	if len(bastion.PrivateKey) == 0 {
+		bastion.PrivateKey = defaultKey
+	}
+	return bastion
 }
 
 //NodeList node list
@@ -468,12 +532,185 @@ type RainbondInitConfig struct {
 	RegionDatabase    *Database
 	ETCDConfig        *rainbondv1alpha1.EtcdConfig
 	NasServer         string
+	NFSServer         string
 	SuffixHTTPHost    string
 	GatewayNodes      []*rainbondv1alpha1.K8sNode
 	ChaosNodes        []*rainbondv1alpha1.K8sNode
 	EIPs              []string
 }
 
+//Checkpoints the messages of the succeeded steps, keyed by step type.
+type Checkpoints map[string]string
+
+//Done returns whether the step has succeeded
+func (c Checkpoints) Done(step string) bool {
+	_, ok := c[step]
+	return ok
+}
+
+//The types and status of the cloud resources created by the adaptor
+const (
+	ResourceTypeVPC            = "vpc"
+	ResourceTypeVSwitch        = "vswitch"
+	ResourceTypeKubernetes     = "kubernetes"
+	ResourceTypeRDS            = "rds"
+	ResourceTypeNAS            = "nas"
+	ResourceTypeNASMountTarget = "nasMountTarget"
+	ResourceTypeLoadBalancer   = "loadBalancer"
+	ResourceTypeVServerGroup   = "vserverGroup"
+	ResourceTypeNodePool       = "nodePool"
+
+	ResourceStatusCreated  = "created"
+	ResourceStatusReleased = "released"
+)
+
+//CloudResource the cloud resource created or released by the adaptor
+type CloudResource struct {
+	Provider     string `json:"provider"`
+	RegionID     string `json:"regionID"`
+	ResourceType string `json:"resourceType"`
+	ResourceID   string `json:"resourceID"`
+	Name         string `json:"name,omitempty"`
+	// ClusterID is empty if the resource is created before the cluster
+	ClusterID string `json:"clusterID,omitempty"`
+	Status    string `json:"status"`
+}
+
+//KubernetesVersion the kubernetes version which the cluster can be upgraded to
+type KubernetesVersion struct {
+	Version string `json:"version"`
+	// Current is true if the cluster is running the version
+	Current bool `json:"current"`
+	// Previous is true if the cluster is upgraded from the version, it can be rolled back to.
+	Previous   bool `json:"previous"`
+	Upgradable bool `json:"upgradable"`
+	// Reason why the version is not upgradable
+	Reason string `json:"reason,omitempty"`
+}
+
+//Certificate the certificate of the kubernetes component
+type Certificate struct {
+	Name       string    `json:"name"`
+	CommonName string    `json:"commonName"`
+	NotBefore  time.Time `json:"notBefore"`
+	NotAfter   time.Time `json:"notAfter"`
+	Expired    bool      `json:"expired"`
+}
+
+//RotateCertificatesOptions the options of rotating certificates
+type RotateCertificatesOptions struct {
+	// Services the certificates of the services are rotated, all services if it is empty.
+	Services []string
+	RotateCA bool
+}
+
+//SecretsEncryptionStatus the status of the secrets encryption at rest
+type SecretsEncryptionStatus struct {
+	// Enabled is true if the encryption is enabled in the cluster config
+	Enabled bool `json:"enabled"`
+	// Active is true if the encryption is applied to the cluster
+	Active bool `json:"active"`
+	// CustomConfig is true if the encryption provider is configured by the user, its key can not be rotated.
+	CustomConfig bool `json:"customConfig"`
+	// ActiveKey the name of the key encrypting the secrets
+	ActiveKey string `json:"activeKey,omitempty"`
+}
+
+//EtcdSnapshot the etcd snapshot saved on the etcd nodes
+type EtcdSnapshot struct {
+	Name string `json:"name"`
+	// Size the size of the snapshot file in bytes, the largest one if the sizes differ between the nodes
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Nodes the etcd nodes which have the snapshot file
+	Nodes []string `json:"nodes"`
+}
+
+//EtcdSnapshotPolicy the policy of the recurring etcd snapshots
+type EtcdSnapshotPolicy struct {
+	Enabled bool `json:"enabled"`
+	// IntervalHours the interval between two snapshots
+	IntervalHours int `json:"intervalHours"`
+	// Retention the number of the recurring snapshots kept on the etcd nodes
+	Retention int `json:"retention"`
+}
+
+// The results of the preflight checks, from the best to the worst.
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+var preflightSeverity = map[string]int{PreflightPass: 0, PreflightWarn: 1, PreflightFail: 2}
+
+// worsePreflightStatus returns the worse one of the two results
+func worsePreflightStatus(a, b string) string {
+	if a == "" || preflightSeverity[b] > preflightSeverity[a] {
+		return b
+	}
+	return a
+}
+
+//PreflightCheck the result of a check on the node
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+//NodePreflightReport the results of the checks on the node, the status is the worst result of the checks.
+type NodePreflightReport struct {
+	Address  string            `json:"address"`
+	Hostname string            `json:"hostname,omitempty"`
+	Status   string            `json:"status"`
+	Checks   []*PreflightCheck `json:"checks"`
+}
+
+//AddCheck adds the result of a check
+func (n *NodePreflightReport) AddCheck(name, status, message string) {
+	n.Checks = append(n.Checks, &PreflightCheck{Name: name, Status: status, Message: message})
+	n.Status = worsePreflightStatus(n.Status, status)
+}
+
+//PreflightReport the preflight results of the nodes, the install is not allowed if the status is fail.
+type PreflightReport struct {
+	Status string                 `json:"status"`
+	Nodes  []*NodePreflightReport `json:"nodes"`
+}
+
+//NewPreflightReport creates the report of the nodes
+func NewPreflightReport(nodes []*NodePreflightReport) *PreflightReport {
+	report := &PreflightReport{Status: PreflightPass, Nodes: nodes}
+	for _, node := range nodes {
+		report.Status = worsePreflightStatus(report.Status, node.Status)
+	}
+	return report
+}
+
+//Failures returns the failed checks in the form of <address>: <check>: <message>
+func (p *PreflightReport) Failures() []string {
+	var failures []string
+	for _, node := range p.Nodes {
+		for _, check := range node.Checks {
+			if check.Status == PreflightFail {
+				failures = append(failures, fmt.Sprintf("%s: %s: %s", node.Address, check.Name, check.Message))
+			}
+		}
+	}
+	return failures
+}
+
+//NodeStepType returns the step type of the event of the node, every node of the step has its own event.
+func NodeStepType(step, node string) string {
+	return step + ":" + node
+}
+
+//IsNodeStepType returns true if the step type is the step of a node
+func IsNodeStepType(stepType string) bool {
+	return strings.Contains(stepType, ":")
+}
+
 //NasStorageInfo nas storage info
 type NasStorageInfo struct {
 	FileSystemID string `json:"FileSystemId" xml:"FileSystemId"`
@@ -544,4 +781,7 @@ type ExpansionNode struct {
 	InstanceType       string                            `json:"instanceType,omitempty"`
 	DockerVersion      string                            `json:"dockerVersion,omitempty"`
 	RKEConfig          *v3.RancherKubernetesEngineConfig `json:"rkeConfig"`
+	// NodePoolID the node pool to scale, a new node pool is created if it is empty and InstanceType is specified.
+	NodePoolID     string          `json:"nodePoolID,omitempty"`
+	WorkerDataDisk *WorkerDataDisk `json:"workerDataDisk,omitempty"`
 }
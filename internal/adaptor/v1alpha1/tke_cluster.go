@@ -0,0 +1,87 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package v1alpha1
+
+import (
+	"net"
+	"os"
+
+	"goodrain.com/cloud-adaptor/pkg/util"
+)
+
+//TKEClusterConfig tke cluster config
+type TKEClusterConfig struct {
+	ClusterName    string `json:"cluster_name,omitempty"`
+	ClusterVersion string `json:"cluster_version,omitempty"`
+	ClusterOS      string `json:"cluster_os,omitempty"`
+	RegionID       string `json:"region_id,omitempty"`
+	ZoneID         string `json:"zone_id,omitempty"`
+	VpcID          string `json:"vpc_id,omitempty"`
+	SubnetID       string `json:"subnet_id,omitempty"`
+	ClusterCIDR    string `json:"cluster_cidr,omitempty"`
+	ServiceCIDR    string `json:"service_cidr,omitempty"`
+	InstanceType   string `json:"instance_type,omitempty"`
+	WorkerNodeNum  int    `json:"worker_node_num,omitempty"`
+	SystemDiskSize int    `json:"system_disk_size,omitempty"`
+	DataDiskSize   int    `json:"data_disk_size,omitempty"`
+	LoginPassword  string `json:"login_password,omitempty"`
+}
+
+//GetDefaultTKECreateClusterConfig get create tke cluster default config
+func GetDefaultTKECreateClusterConfig(config KubernetesClusterConfig, zoneID string) CreateClusterConfig {
+	kubernetesVersion := os.Getenv("DEFAULT_TKE_VERSION")
+	if kubernetesVersion == "" {
+		kubernetesVersion = "1.20.6"
+	}
+	if config.KubernetesVersion != "" {
+		kubernetesVersion = config.KubernetesVersion
+	}
+	serviceClusterIPRange := "172.21.0.0/20"
+	podIPRange := "172.20.0.0/16"
+	if config.ServiceCIDR != "" {
+		if _, _, err := net.ParseCIDR(config.ServiceCIDR); err == nil {
+			serviceClusterIPRange = config.ServiceCIDR
+		}
+	}
+	if config.ClusterCIDR != "" {
+		if _, _, err := net.ParseCIDR(config.ClusterCIDR); err == nil {
+			podIPRange = config.ClusterCIDR
+		}
+	}
+	workerNum := config.WorkerNodeNum
+	if workerNum < 2 {
+		workerNum = 2
+	}
+	return &TKEClusterConfig{
+		ClusterName:    config.ClusterName,
+		ClusterVersion: kubernetesVersion,
+		ClusterOS:      "centos7.6.0_x64",
+		RegionID:       config.Region,
+		ZoneID:         zoneID,
+		VpcID:          config.VpcID,
+		SubnetID:       config.VSwitchID,
+		ClusterCIDR:    podIPRange,
+		ServiceCIDR:    serviceClusterIPRange,
+		InstanceType:   config.InstanceType,
+		WorkerNodeNum:  workerNum,
+		SystemDiskSize: 100,
+		DataDiskSize:   200,
+		LoginPassword:  util.RandPassword(16),
+	}
+}
@@ -117,6 +117,18 @@ func AutoMigrate(db *gorm.DB) error {
 		"RainbondClusterConfig": model.RainbondClusterConfig{},
 		"AppStore": model.AppStore{},
 		"TaskEvent": model.TaskEvent{},
+		"TaskResult": model.TaskResult{},
+		"TaskMessage": model.TaskMessage{},
+		"Webhook": model.Webhook{},
+		"WebhookDelivery": model.WebhookDelivery{},
+		"OperationTask": model.OperationTask{},
+		"CloudResource": model.CloudResource{},
+		"Blob": model.Blob{},
+		"SSHKey": model.SSHKey{},
+		"SSHBastion": model.SSHBastion{},
+		"InitNodeToken": model.InitNodeToken{},
+		"ClusterHealth": model.ClusterHealth{},
+		"Lease": model.Lease{},
 	}
 
 	for name, mod := range models {
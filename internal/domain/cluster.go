@@ -23,9 +23,20 @@ type ClusterTaskType string
 
 // ClusterTaskType -
 var (
-	ClusterTaskTypeInitRainbond     ClusterTaskType = "init-rainbond"
-	ClusterTaskTypeCreateKubernetes ClusterTaskType = "create-kubernetes"
-	ClusterTaskTypeUpdateKubernetes ClusterTaskType = "update-kubernetes"
+	ClusterTaskTypeInitRainbond            ClusterTaskType = "init-rainbond"
+	ClusterTaskTypeCreateKubernetes        ClusterTaskType = "create-kubernetes"
+	ClusterTaskTypeUpdateKubernetes        ClusterTaskType = "update-kubernetes"
+	ClusterTaskTypeReleaseResources        ClusterTaskType = "release-resources"
+	ClusterTaskTypeUpgradeKubernetes       ClusterTaskType = "upgrade-kubernetes"
+	ClusterTaskTypeRotateCertificates      ClusterTaskType = "rotate-certificates"
+	ClusterTaskTypeEnableSecretsEncryption ClusterTaskType = "enable-secrets-encryption"
+	ClusterTaskTypeRotateEncryptionKey     ClusterTaskType = "rotate-encryption-key"
+	ClusterTaskTypeSaveEtcdSnapshot        ClusterTaskType = "save-etcd-snapshot"
+	ClusterTaskTypeSetEtcdSnapshotPolicy   ClusterTaskType = "set-etcd-snapshot-policy"
+	ClusterTaskTypeRestoreEtcdSnapshot     ClusterTaskType = "restore-etcd-snapshot"
+	ClusterTaskTypeRemoveNodes             ClusterTaskType = "remove-nodes"
+	ClusterTaskTypeRotateSSHKey            ClusterTaskType = "rotate-ssh-key"
+	ClusterTaskTypePreflightCheck          ClusterTaskType = "preflight-check"
 )
 
 // Cluster -
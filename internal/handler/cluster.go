@@ -21,11 +21,14 @@ package handler
 import (
 	"encoding/json"
 	"goodrain.com/cloud-adaptor/internal/model"
-	"goodrain.com/cloud-adaptor/pkg/util/ssh"
-	"io/ioutil"
+	"io"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -207,11 +210,15 @@ func (e *ClusterHandler) DeleteKubernetesCluster(ctx *gin.Context) {
 	}
 	eid := ctx.Param("eid")
 	clusterID := ctx.Param("clusterID")
-	err := e.cluster.DeleteKubernetesCluster(eid, clusterID, req.ProviderName)
+	task, err := e.cluster.DeleteKubernetesCluster(eid, clusterID, req.ProviderName)
 	if err != nil {
 		ginutil.JSON(ctx, nil, err)
 		return
 	}
+	if task != nil {
+		ginutil.JSON(ctx, task, nil)
+		return
+	}
 	ginutil.JSON(ctx, nil, nil)
 }
 
@@ -301,6 +308,105 @@ func (e *ClusterHandler) GetTaskEventList(ctx *gin.Context) {
 	ginutil.JSON(ctx, v1.TaskEventListRes{Events: events}, nil)
 }
 
+// StreamTaskEvents pushes the events of the task as server-sent events.
+//
+// The id of the message is the seq of the event, the client resumes from the Last-Event-ID header
+// or the lastEventID query. A complete event is sent after the terminal event of the task.
+//
+// @Summary pushes the events of the task as server-sent events.
+// @Tags cluster
+// @ID streamTaskEvents
+// @Produce  text/event-stream
+// @Param eid path string true "the enterprise id"
+// @Param taskID path string true "the task id"
+// @Param Last-Event-ID header string false "the id of the last received event"
+// @Success 200 {object} model.TaskEvent
+// @Failure 404 {object} ginutil.Result
+// @Router /api/v1/enterprises/:eid/tasks/:taskID/events/stream [get]
+func (e *ClusterHandler) StreamTaskEvents(ctx *gin.Context) {
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("lastEventID")
+	}
+	seq := int64(-1)
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			ginutil.JSONv2(ctx, nil, bcode.BadRequest)
+			return
+		}
+		seq = id
+	}
+	events, err := e.cluster.WatchTaskEvents(ctx.Request.Context(), ctx.Param("eid"), ctx.Param("taskID"), seq)
+	if err != nil {
+		ginutil.JSONv2(ctx, nil, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	keepalive := time.NewTicker(time.Second * 15)
+	defer keepalive.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// the terminal event has been received before resuming
+				if ctx.Request.Context().Err() == nil {
+					ctx.Render(-1, sse.Event{Event: "complete", Data: map[string]string{"status": ""}})
+				}
+				return false
+			}
+			ctx.Render(-1, sse.Event{Id: strconv.FormatInt(event.Seq, 10), Event: "message", Data: event})
+			if usecase.IsTerminalEvent(event.StepType, event.Status) {
+				ctx.Render(-1, sse.Event{Event: "complete", Data: map[string]string{"status": event.Status}})
+				return false
+			}
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// CancelTask cancels the cluster task.
+//
+// @Summary cancels the cluster task.
+// @Tags cluster
+// @ID cancelTask
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param taskID path string true "the task id"
+// @Success 200
+// @Failure 404 {object} ginutil.Result
+// @Failure 409 {object} ginutil.Result
+// @Router /api/v1/enterprises/:eid/tasks/:taskID/cancel [post]
+func (e *ClusterHandler) CancelTask(ctx *gin.Context) {
+	err := e.cluster.CancelTask(ctx.Param("eid"), ctx.Param("taskID"))
+	ginutil.JSONv2(ctx, nil, err)
+}
+
+// GetTaskPlan returns the step plan of the task.
+//
+// @Summary returns the step plan of the task.
+// @Tags cluster
+// @ID getTaskPlan
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param taskID path string true "the task id"
+// @Success 200 {object} v1.TaskPlanRes
+// @Failure 404 {object} ginutil.Result
+// @Router /api/v1/enterprises/:eid/tasks/:taskID/plan [get]
+func (e *ClusterHandler) GetTaskPlan(ctx *gin.Context) {
+	plan, err := e.cluster.GetTaskPlan(ctx.Param("eid"), ctx.Param("taskID"))
+	ginutil.JSONv2(ctx, plan, err)
+}
+
 // AddAccessKey add access keys
 func (e *ClusterHandler) AddAccessKey(ctx *gin.Context) {
 	var req v1.AddAccessKey
@@ -488,9 +594,11 @@ func (e *ClusterHandler) UpdateInitRainbondTaskStatus(ctx *gin.Context) {
 	ginutil.JSON(ctx, task, nil)
 }
 
-// GetInitNodeCmd get node init cmd shell
+// GetInitNodeCmd get node init cmd shell, the node is initialised with the ssh key of the enterprise,
+// or the legacy key of cloud adaptor without the enterprise id. The command downloads the init script
+// from cloud adaptor at the advertise url with a one-time token.
 //
-// swagger:route GET /enterprise-server/api/v1/init_node_cmd cloud init
+// swagger:route GET /enterprise-server/api/v1/enterprises/{eid}/init_node_cmd cloud init
 //
 // Produces:
 // - application/json
@@ -501,10 +609,33 @@ func (e *ClusterHandler) UpdateInitRainbondTaskStatus(ctx *gin.Context) {
 // Responses:
 // 200: body:InitNodeCmdRes
 func (e *ClusterHandler) GetInitNodeCmd(c *gin.Context) {
-	res, err := e.cluster.GetInitNodeCmd(c.Request.Context())
+	var req v1.InitNodeCmdReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginutil.Error(c, bcode.NewBadRequest(err.Error()))
+		return
+	}
+	res, err := e.cluster.GetInitNodeCmd(c.Request.Context(), c.Param("eid"), &req)
 	ginutil.JSONv2(c, res, err)
 }
 
+// GetInitNodeScript returns the node initialisation script.
+// @Summary returns the script authorizing the ssh key, setting up the kernel modules and sysctl, and installing docker, the token can be used once before it expires.
+// @Tags clusters
+// @ID getInitNodeScript
+// @Produce  plain
+// @Param token path string true "the one-time token in the init node command"
+// @Success 200 {string} string
+// @Failure 403 {object} ginutil.Result "7046, the token of the init node script is invalid or expired"
+// @Router /api/v1/init_node_script/:token [get]
+func (e *ClusterHandler) GetInitNodeScript(c *gin.Context) {
+	script, err := e.cluster.GetInitNodeScript(c.Param("token"))
+	if err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/x-shellscript; charset=utf-8", []byte(script))
+}
+
 // check ssh connect
 //
 // swagger:route GET /enterprise-server/api/v1/check_ssh
@@ -524,7 +655,7 @@ func (e *ClusterHandler) CheckSSH(ctx *gin.Context) {
 		ginutil.JSON(ctx, nil, bcode.BadRequest)
 		return
 	}
-	r, err := ssh.CheckSSHConnect(req.Host, req.Port)
+	r, err := e.cluster.CheckSSH(ctx.Param("eid"), &req)
 	if err != nil {
 		ginutil.JSON(ctx, r, err)
 		return
@@ -548,16 +679,96 @@ func (e *ClusterHandler) CheckSSH(ctx *gin.Context) {
 // Responses:
 // 200: body:GetLogContentRes
 func (e *ClusterHandler) GetLogContent(ctx *gin.Context) {
-	cluster, err := e.cluster.GetCluster("rke", ctx.Param("eid"), ctx.Param("clusterID"))
+	content, err := e.cluster.GetCreateLogContent(ctx.Param("eid"), ctx.Param("clusterID"))
 	if err != nil {
 		ginutil.JSON(ctx, nil, err)
 		return
 	}
-	var content []byte
-	if cluster.CreateLogPath != "" {
-		content, _ = ioutil.ReadFile(cluster.CreateLogPath)
+	ginutil.JSON(ctx, v1.GetLogContentRes{Content: content}, nil)
+}
+
+// ListClusterLogs lists the install logs of the rke cluster.
+//
+// @Summary lists the install log and the rotated logs of the expansions.
+// @Tags cluster
+// @ID listClusterLogs
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Success 200 {object} v1.ClusterLogListRes
+// @Failure 404 {object} ginutil.Result
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/logs [get]
+func (e *ClusterHandler) ListClusterLogs(ctx *gin.Context) {
+	logs, err := e.cluster.ListClusterLogs(ctx.Param("eid"), ctx.Param("clusterID"))
+	ginutil.JSONv2(ctx, &v1.ClusterLogListRes{Logs: logs}, err)
+}
+
+// GetClusterLog reads the install log of the rke cluster by byte offset.
+//
+// With follow=true, the log is pushed as server-sent events while the cluster is installing,
+// the id of the message is the next offset and the client resumes from the Last-Event-ID header.
+//
+// @Summary reads the install log by byte offset.
+// @Tags cluster
+// @ID getClusterLog
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param name path string true "the log name"
+// @Param getClusterLogReq query v1.GetClusterLogReq false "."
+// @Success 200 {object} v1.ClusterLogContentRes
+// @Failure 404 {object} ginutil.Result
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/logs/:name [get]
+func (e *ClusterHandler) GetClusterLog(ctx *gin.Context) {
+	var req v1.GetClusterLogReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ginutil.JSONv2(ctx, nil, bcode.BadRequest)
+		return
+	}
+	eid, clusterID, name := ctx.Param("eid"), ctx.Param("clusterID"), ctx.Param("name")
+	if !req.Follow {
+		content, err := e.cluster.ReadClusterLog(eid, clusterID, name, req.Offset, req.Limit)
+		ginutil.JSONv2(ctx, content, err)
+		return
+	}
+
+	if lastEventID := ctx.GetHeader("Last-Event-ID"); lastEventID != "" {
+		offset, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			ginutil.JSONv2(ctx, nil, bcode.BadRequest)
+			return
+		}
+		req.Offset = offset
 	}
-	ginutil.JSON(ctx, v1.GetLogContentRes{Content: string(content)}, nil)
+	chunks, err := e.cluster.FollowClusterLog(ctx.Request.Context(), eid, clusterID, name, req.Offset)
+	if err != nil {
+		ginutil.JSONv2(ctx, nil, err)
+		return
+	}
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	keepalive := time.NewTicker(time.Second * 15)
+	defer keepalive.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if ctx.Request.Context().Err() == nil {
+					ctx.Render(-1, sse.Event{Event: "complete", Data: ""})
+				}
+				return false
+			}
+			ctx.Render(-1, sse.Event{Id: strconv.FormatInt(chunk.NextOffset, 10), Event: "message", Data: chunk})
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return false
+			}
+		}
+		return true
+	})
 }
 
 // ReInstallKubernetesCluster retry install rke cluster .
@@ -851,3 +1062,477 @@ func (e *ClusterHandler) DeleteInstallHelmRegionEvent(ctx *gin.Context) {
 	err := e.cluster.TaskEventRepo.DeleteEvent(eid, "helm_install_region")
 	ginutil.JSON(ctx, nil, err)
 }
+
+// ListProviders lists the cloud adaptors.
+// @Summary lists the registered cloud adaptors.
+// @Tags providers
+// @ID listProviders
+// @Produce  json
+// @Success 200 {object} v1.ProviderListRes
+// @Router /api/v1/providers [get]
+func (e *ClusterHandler) ListProviders(c *gin.Context) {
+	ginutil.JSONv2(c, &v1.ProviderListRes{Providers: e.cluster.ListProviders()}, nil)
+}
+
+// GetProviderCapabilities returns the capabilities of the provider.
+// @Summary returns the capabilities of the provider, the console hides the unsupported actions.
+// @Tags providers
+// @ID getProviderCapabilities
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param name path string true "the provider name"
+// @Success 200 {object} v1.ProviderCapabilitiesRes
+// @Router /api/v1/enterprises/:eid/providers/:name/capabilities [get]
+func (e *ClusterHandler) GetProviderCapabilities(c *gin.Context) {
+	res, err := e.cluster.GetProviderCapabilities(c.Param("eid"), c.Param("name"))
+	ginutil.JSONv2(c, res, err)
+}
+
+// ReleaseRainbondResources deletes the cloud resources created for the rainbond region.
+// @Summary deletes the database, NAS and load balancer created for the rainbond region, the deletions are reported as the task events.
+// @Tags clusters
+// @ID releaseRainbondResources
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param releaseResourcesReq body v1.ReleaseResourcesReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/release-resources [post]
+func (e *ClusterHandler) ReleaseRainbondResources(c *gin.Context) {
+	var req v1.ReleaseResourcesReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.ReleaseRainbondResources(c.Param("eid"), c.Param("clusterID"), req.ProviderName)
+	ginutil.JSONv2(c, task, err)
+}
+
+// ListCloudResources returns the cloud resources created by cloud-adaptor for the cluster.
+// @Summary returns the cloud resources created by cloud-adaptor for the cluster, including the released ones.
+// @Tags clusters
+// @ID listCloudResources
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Success 200 {array} model.CloudResource
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/resources [get]
+func (e *ClusterHandler) ListCloudResources(c *gin.Context) {
+	resources, err := e.cluster.ListCloudResources(c.Param("eid"), c.Param("clusterID"))
+	ginutil.JSONv2(c, resources, err)
+}
+
+// ListKubernetesVersions returns the kubernetes versions which the cluster can be upgraded to.
+// @Summary returns the kubernetes versions supported by the provider, the versions can not be upgraded to have the reason.
+// @Tags clusters
+// @ID listKubernetesVersions
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param providerName query string true "the provider of the cluster"
+// @Success 200 {array} v1alpha1.KubernetesVersion
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/kubernetes-versions [get]
+func (e *ClusterHandler) ListKubernetesVersions(c *gin.Context) {
+	versions, err := e.cluster.ListKubernetesVersions(c.Param("eid"), c.Param("clusterID"), c.Query("providerName"))
+	ginutil.JSONv2(c, versions, err)
+}
+
+// UpgradeKubernetes upgrades the kubernetes version of the cluster.
+// @Summary upgrades the kubernetes version of the cluster one minor version at a time, the progress of the nodes are reported as the task events.
+// @Tags clusters
+// @ID upgradeKubernetes
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param upgradeKubernetesReq body v1.UpgradeKubernetesReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider; 7036, the cluster can not be upgraded to the kubernetes version"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/upgrade [post]
+func (e *ClusterHandler) UpgradeKubernetes(c *gin.Context) {
+	var req v1.UpgradeKubernetesReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.UpgradeKubernetes(c.Param("eid"), c.Param("clusterID"), req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// ListCertificates returns the certificates of the cluster with their expiry.
+// @Summary returns the certificates of the kubernetes components read from the cluster state.
+// @Tags clusters
+// @ID listCertificates
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param providerName query string true "the provider of the cluster"
+// @Success 200 {array} v1alpha1.Certificate
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/certificates [get]
+func (e *ClusterHandler) ListCertificates(c *gin.Context) {
+	certificates, err := e.cluster.ListCertificates(c.Param("eid"), c.Param("clusterID"), c.Query("providerName"))
+	ginutil.JSONv2(c, certificates, err)
+}
+
+// RotateCertificates rotates the certificates of the cluster.
+// @Summary rotates the certificates of all services or the given service, the CA certificate is rotated if rotateCA is true.
+// @Tags clusters
+// @ID rotateCertificates
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param rotateCertificatesReq body v1.RotateCertificatesReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/rotate-certificates [post]
+func (e *ClusterHandler) RotateCertificates(c *gin.Context) {
+	var req v1.RotateCertificatesReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.RotateCertificates(c.Param("eid"), c.Param("clusterID"), req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// GetSecretsEncryptionStatus returns whether the secrets encryption at rest is active.
+// @Summary returns whether the secrets encryption is enabled in the cluster config and applied to the cluster.
+// @Tags clusters
+// @ID getSecretsEncryptionStatus
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param providerName query string true "the provider of the cluster"
+// @Success 200 {object} v1alpha1.SecretsEncryptionStatus
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/secrets-encryption [get]
+func (e *ClusterHandler) GetSecretsEncryptionStatus(c *gin.Context) {
+	status, err := e.cluster.GetSecretsEncryptionStatus(c.Param("eid"), c.Param("clusterID"), c.Query("providerName"))
+	ginutil.JSONv2(c, status, err)
+}
+
+// EnableSecretsEncryption enables the secrets encryption at rest.
+// @Summary enables the secrets encryption of the cluster, the existing secrets are rewritten encrypted.
+// @Tags clusters
+// @ID enableSecretsEncryption
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param secretsEncryptionReq body v1.SecretsEncryptionReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete; 7038, the secrets encryption is already enabled"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/secrets-encryption [post]
+func (e *ClusterHandler) EnableSecretsEncryption(c *gin.Context) {
+	var req v1.SecretsEncryptionReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.EnableSecretsEncryption(c.Param("eid"), c.Param("clusterID"), req.ProviderName)
+	ginutil.JSONv2(c, task, err)
+}
+
+// RotateSecretsEncryptionKey rotates the key of the secrets encryption.
+// @Summary rotates the key of the secrets encryption, deploying the new key, rewriting the secrets and removing the old key are reported as the task events.
+// @Tags clusters
+// @ID rotateSecretsEncryptionKey
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param secretsEncryptionReq body v1.SecretsEncryptionReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider; 7037, the secrets encryption is not enabled; 7039, the key of the custom encryption config can not be rotated"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/secrets-encryption/rotate [post]
+func (e *ClusterHandler) RotateSecretsEncryptionKey(c *gin.Context) {
+	var req v1.SecretsEncryptionReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.RotateSecretsEncryptionKey(c.Param("eid"), c.Param("clusterID"), req.ProviderName)
+	ginutil.JSONv2(c, task, err)
+}
+
+// ListEtcdSnapshots lists the etcd snapshots.
+// @Summary lists the etcd snapshots saved on the etcd nodes with the size and the creation time, the latest first.
+// @Tags clusters
+// @ID listEtcdSnapshots
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param providerName query string true "the provider of the cluster"
+// @Success 200 {array} v1alpha1.EtcdSnapshot
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/etcd-snapshots [get]
+func (e *ClusterHandler) ListEtcdSnapshots(c *gin.Context) {
+	snapshots, err := e.cluster.ListEtcdSnapshots(c.Param("eid"), c.Param("clusterID"), c.Query("providerName"))
+	ginutil.JSONv2(c, snapshots, err)
+}
+
+// SaveEtcdSnapshot saves an etcd snapshot.
+// @Summary saves an etcd snapshot on all etcd nodes, the name is generated with the current time if it is empty.
+// @Tags clusters
+// @ID saveEtcdSnapshot
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param saveEtcdSnapshotReq body v1.SaveEtcdSnapshotReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider; 7041, the name of the etcd snapshot is invalid"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/etcd-snapshots [post]
+func (e *ClusterHandler) SaveEtcdSnapshot(c *gin.Context) {
+	var req v1.SaveEtcdSnapshotReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.SaveEtcdSnapshot(c.Param("eid"), c.Param("clusterID"), &req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// GetEtcdSnapshotPolicy returns the policy of the recurring etcd snapshots.
+// @Summary returns whether the recurring etcd snapshots are enabled, their interval and retention.
+// @Tags clusters
+// @ID getEtcdSnapshotPolicy
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param providerName query string true "the provider of the cluster"
+// @Success 200 {object} v1alpha1.EtcdSnapshotPolicy
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/etcd-snapshot-policy [get]
+func (e *ClusterHandler) GetEtcdSnapshotPolicy(c *gin.Context) {
+	policy, err := e.cluster.GetEtcdSnapshotPolicy(c.Param("eid"), c.Param("clusterID"), c.Query("providerName"))
+	ginutil.JSONv2(c, policy, err)
+}
+
+// SetEtcdSnapshotPolicy configures the recurring etcd snapshots.
+// @Summary configures the interval and the retention of the recurring etcd snapshots, the etcd nodes are updated in the task.
+// @Tags clusters
+// @ID setEtcdSnapshotPolicy
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param setEtcdSnapshotPolicyReq body v1.SetEtcdSnapshotPolicyReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/etcd-snapshot-policy [put]
+func (e *ClusterHandler) SetEtcdSnapshotPolicy(c *gin.Context) {
+	var req v1.SetEtcdSnapshotPolicyReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.SetEtcdSnapshotPolicy(c.Param("eid"), c.Param("clusterID"), &req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// RestoreEtcdSnapshot restores the cluster from an etcd snapshot.
+// @Summary restores the etcd data of the cluster from the snapshot, the kubeconfig of the cluster is updated after restoring.
+// @Tags clusters
+// @ID restoreEtcdSnapshot
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param restoreEtcdSnapshotReq body v1.RestoreEtcdSnapshotReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Failure 404 {object} ginutil.Result "7040, the etcd snapshot not found"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/etcd-snapshots/restore [post]
+func (e *ClusterHandler) RestoreEtcdSnapshot(c *gin.Context) {
+	var req v1.RestoreEtcdSnapshotReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.RestoreEtcdSnapshot(c.Param("eid"), c.Param("clusterID"), &req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// RemoveKubernetesNodes drains the nodes and removes them from the cluster.
+// @Summary cordons and drains the nodes, removes them from the cluster and deletes the node objects, every node reports its own events.
+// @Tags clusters
+// @ID removeKubernetesNodes
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param removeKubernetesNodesReq body v1.RemoveKubernetesNodesReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7011, RKE etcd node must odd number"
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Failure 404 {object} ginutil.Result "7042, the node not found in the cluster"
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/remove-nodes [post]
+func (e *ClusterHandler) RemoveKubernetesNodes(c *gin.Context) {
+	var req v1.RemoveKubernetesNodesReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.RemoveKubernetesNodes(c.Param("eid"), c.Param("clusterID"), &req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// PreflightCheck checks the nodes before installing the cluster.
+// @Summary checks ssh, docker, ports, kernel modules, swap, disk space, time skew and hostname of the nodes in background, every node is reported as pass, warn or fail.
+// @Tags clusters
+// @ID preflightCheck
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param preflightCheckReq body v1.PreflightCheckReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 400 {object} ginutil.Result "7034, the action is not supported by the provider"
+// @Router /api/v1/enterprises/:eid/kclusters/preflight [post]
+func (e *ClusterHandler) PreflightCheck(c *gin.Context) {
+	var req v1.PreflightCheckReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.PreflightCheck(c.Param("eid"), &req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// GetPreflightReport returns the report of the preflight task.
+// @Summary returns the report of the nodes checked by the preflight task.
+// @Tags clusters
+// @ID getPreflightReport
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param taskID path string true "the id of the preflight task"
+// @Success 200 {object} v1alpha1.PreflightReport
+// @Failure 404 {object} ginutil.Result "7049, the preflight report is not found"
+// @Router /api/v1/enterprises/:eid/kclusters/preflight/:taskID [get]
+func (e *ClusterHandler) GetPreflightReport(c *gin.Context) {
+	report, err := e.cluster.GetPreflightReport(c.Param("eid"), c.Param("taskID"))
+	ginutil.JSONv2(c, report, err)
+}
+
+// CheckSSHBatch checks the ssh of the nodes.
+// @Summary logs in to the nodes with their users and the key of the enterprise concurrently and checks the users can run docker, the failures are categorized as unreachable, authFailed, noDockerGroup, hostKeyChanged or probeFailed.
+// @Tags clusters
+// @ID checkSSHBatch
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param checkSSHBatchReq body v1.CheckSSHBatchReq true "."
+// @Success 200 {object} v1.CheckSSHBatchRes
+// @Router /api/v1/enterprises/:eid/check_ssh/batch [post]
+func (e *ClusterHandler) CheckSSHBatch(c *gin.Context) {
+	var req v1.CheckSSHBatchReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	res, err := e.cluster.CheckSSHBatch(c.Param("eid"), &req)
+	ginutil.JSONv2(c, res, err)
+}
+
+// GetSSHKey returns the ssh key of the enterprise.
+// @Summary returns the type, public key and fingerprint of the ssh key which cloud adaptor logs in to the nodes of the enterprise with.
+// @Tags clusters
+// @ID getSSHKey
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Success 200 {object} model.SSHKey
+// @Router /api/v1/enterprises/:eid/ssh-key [get]
+func (e *ClusterHandler) GetSSHKey(c *gin.Context) {
+	key, err := e.cluster.GetSSHKey(c.Param("eid"))
+	ginutil.JSONv2(c, key, err)
+}
+
+// RotateSSHKey rotates the ssh key of the enterprise.
+// @Summary makes a new ssh key, pushes its public key to all nodes of the rke clusters of the enterprise, then activates it and revokes the old one, every node reports its own events.
+// @Tags clusters
+// @ID rotateSSHKey
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param rotateSSHKeyReq body v1.RotateSSHKeyReq true "."
+// @Success 200 {object} model.OperationTask
+// @Failure 409 {object} ginutil.Result "7024, the last task not complete"
+// @Router /api/v1/enterprises/:eid/ssh-key/rotate [post]
+func (e *ClusterHandler) RotateSSHKey(c *gin.Context) {
+	var req v1.RotateSSHKeyReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	task, err := e.cluster.RotateSSHKey(c.Param("eid"), &req)
+	ginutil.JSONv2(c, task, err)
+}
+
+// GetClusterBastion returns the bastion of the cluster.
+// @Summary returns the bastion host the nodes of the cluster are reached through, without the private key.
+// @Tags clusters
+// @ID getClusterBastion
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Success 200 {object} model.SSHBastion
+// @Failure 404 {object} ginutil.Result "7045, the cluster has no bastion host"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/bastion [get]
+func (e *ClusterHandler) GetClusterBastion(c *gin.Context) {
+	bastion, err := e.cluster.GetClusterBastion(c.Param("eid"), c.Param("clusterID"))
+	ginutil.JSONv2(c, bastion, err)
+}
+
+// SetClusterBastion sets the bastion of the cluster.
+// @Summary validates the bastion host and sets it as the one every ssh connection to the nodes of the cluster tunnels through, the key of the enterprise is used if the private key is empty.
+// @Tags clusters
+// @ID setClusterBastion
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Param sshBastion body v1alpha1.SSHBastion true "."
+// @Success 200 {object} model.SSHBastion
+// @Failure 400 {object} ginutil.Result "7044, the bastion host is unreachable"
+// @Failure 404 {object} ginutil.Result "7027, cluster not found"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/bastion [put]
+func (e *ClusterHandler) SetClusterBastion(c *gin.Context) {
+	var req v1alpha1.SSHBastion
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	bastion, err := e.cluster.SetClusterBastion(c.Param("eid"), c.Param("clusterID"), &req)
+	ginutil.JSONv2(c, bastion, err)
+}
+
+// DeleteClusterBastion deletes the bastion of the cluster.
+// @Summary deletes the bastion host of the cluster, the nodes are dialed directly afterwards.
+// @Tags clusters
+// @ID deleteClusterBastion
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param clusterID path string true "the cluster id"
+// @Success 200
+// @Failure 404 {object} ginutil.Result "7045, the cluster has no bastion host"
+// @Router /api/v1/enterprises/:eid/kclusters/:clusterID/bastion [delete]
+func (e *ClusterHandler) DeleteClusterBastion(c *gin.Context) {
+	err := e.cluster.DeleteClusterBastion(c.Param("eid"), c.Param("clusterID"))
+	ginutil.JSONv2(c, nil, err)
+}
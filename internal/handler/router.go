@@ -36,6 +36,7 @@ type Router struct {
 	system     *SystemHandler
 	appStore   *AppStoreHandler
 	helm       *HelmHandler
+	webhook    *WebhookHandler
 }
 
 // NewRouter creates a new router.
@@ -44,12 +45,14 @@ func NewRouter(
 	cluster *ClusterHandler,
 	appStore *AppStoreHandler,
 	system *SystemHandler,
+	webhook *WebhookHandler,
 ) *Router {
 	return &Router{
 		middleware: middleware,
 		cluster:    cluster,
 		appStore:   appStore,
 		system:     system,
+		webhook:    webhook,
 	}
 }
 
@@ -82,7 +85,10 @@ func (r *Router) NewRouter() *gin.Engine {
 	apiv1.GET("/backup", r.system.Backup)
 	apiv1.POST("/recover", r.system.Recover)
 	apiv1.GET("/init_node_cmd", r.cluster.GetInitNodeCmd)
+	apiv1.GET("/init_node_script/:token", r.cluster.GetInitNodeScript)
 	apiv1.POST("/check_ssh", r.cluster.CheckSSH)
+	apiv1.POST("/check_ssh/batch", r.cluster.CheckSSHBatch)
+	apiv1.GET("/providers", r.cluster.ListProviders)
 
 	apiv1.POST("/helm/chart", CORSMidle(r.helm.GetHelmCommand))
 	entv1 := apiv1.Group("/enterprises/:eid")
@@ -93,20 +99,53 @@ func (r *Router) NewRouter() *gin.Engine {
 	entv1.DELETE("/kclusters/:clusterID", r.cluster.DeleteKubernetesCluster)
 	entv1.POST("/kclusters/:clusterID/reinstall", r.cluster.ReInstallKubernetesCluster)
 	entv1.GET("/kclusters/:clusterID/createlog", r.cluster.GetLogContent)
+	entv1.GET("/kclusters/:clusterID/logs", r.cluster.ListClusterLogs)
+	entv1.GET("/kclusters/:clusterID/logs/:name", r.cluster.GetClusterLog)
 	entv1.GET("/kclusters/:clusterID/kubeconfig", r.cluster.GetKubeConfig)
 	entv1.GET("/kclusters/:clusterID/rainbondcluster", r.cluster.GetRainbondClusterConfig)
 	entv1.PUT("/kclusters/:clusterID/rainbondcluster", r.cluster.SetRainbondClusterConfig)
 	entv1.POST("/kclusters/:clusterID/uninstall", r.cluster.UninstallRegion)
 	entv1.POST("/kclusters/prune-update-rkeconfig", r.cluster.pruneUpdateRKEConfig)
+	entv1.POST("/kclusters/preflight", r.cluster.PreflightCheck)
+	entv1.GET("/kclusters/preflight/:taskID", r.cluster.GetPreflightReport)
+	entv1.GET("/init_node_cmd", r.cluster.GetInitNodeCmd)
+	entv1.POST("/check_ssh", r.cluster.CheckSSH)
+	entv1.POST("/check_ssh/batch", r.cluster.CheckSSHBatch)
+	entv1.GET("/ssh-key", r.cluster.GetSSHKey)
+	entv1.POST("/ssh-key/rotate", r.cluster.RotateSSHKey)
 
 	clusterv1 := entv1.Group("/kclusters/:clusterID")
 	{
 		clusterv1.GET("/rainbond-components", r.cluster.listRainbondComponents)
 		clusterv1.GET("/rainbond-components/:podName/events", r.cluster.listPodEvents)
+		clusterv1.POST("/release-resources", r.cluster.ReleaseRainbondResources)
+		clusterv1.GET("/resources", r.cluster.ListCloudResources)
+		clusterv1.GET("/kubernetes-versions", r.cluster.ListKubernetesVersions)
+		clusterv1.POST("/upgrade", r.cluster.UpgradeKubernetes)
+		clusterv1.GET("/certificates", r.cluster.ListCertificates)
+		clusterv1.POST("/rotate-certificates", r.cluster.RotateCertificates)
+		clusterv1.GET("/secrets-encryption", r.cluster.GetSecretsEncryptionStatus)
+		clusterv1.POST("/secrets-encryption", r.cluster.EnableSecretsEncryption)
+		clusterv1.POST("/secrets-encryption/rotate", r.cluster.RotateSecretsEncryptionKey)
+		clusterv1.GET("/etcd-snapshots", r.cluster.ListEtcdSnapshots)
+		clusterv1.POST("/etcd-snapshots", r.cluster.SaveEtcdSnapshot)
+		clusterv1.GET("/etcd-snapshot-policy", r.cluster.GetEtcdSnapshotPolicy)
+		clusterv1.PUT("/etcd-snapshot-policy", r.cluster.SetEtcdSnapshotPolicy)
+		clusterv1.POST("/etcd-snapshots/restore", r.cluster.RestoreEtcdSnapshot)
+		clusterv1.POST("/remove-nodes", r.cluster.RemoveKubernetesNodes)
+		clusterv1.GET("/bastion", r.cluster.GetClusterBastion)
+		clusterv1.PUT("/bastion", r.cluster.SetClusterBastion)
+		clusterv1.DELETE("/bastion", r.cluster.DeleteClusterBastion)
 	}
 
 	entv1.POST("/accesskey", r.cluster.AddAccessKey)
 	entv1.GET("/accesskey", r.cluster.GetAccessKey)
+	entv1.GET("/providers/:name/capabilities", r.cluster.GetProviderCapabilities)
+	// webhook
+	entv1.POST("/webhooks", r.webhook.Create)
+	entv1.GET("/webhooks", r.webhook.List)
+	entv1.DELETE("/webhooks/:webhookID", r.webhook.Delete)
+	entv1.GET("/webhooks/:webhookID/deliveries", r.webhook.ListDeliveries)
 	entv1.GET("/last-ck-task", r.cluster.GetLastAddKubernetesClusterTask)
 	entv1.GET("/ck-task/:taskID", r.cluster.GetAddKubernetesClusterTask)
 
@@ -115,6 +154,9 @@ func (r *Router) NewRouter() *gin.Engine {
 	entv1.DELETE("/tasks/helm_region_install", r.cluster.DeleteInstallHelmRegionEvent)
 
 	entv1.GET("/tasks/:taskID/events", r.cluster.GetTaskEventList)
+	entv1.GET("/tasks/:taskID/events/stream", r.cluster.StreamTaskEvents)
+	entv1.POST("/tasks/:taskID/cancel", r.cluster.CancelTask)
+	entv1.GET("/tasks/:taskID/plan", r.cluster.GetTaskPlan)
 	entv1.GET("/init-task/:clusterID", r.cluster.GetInitRainbondTask)
 	entv1.GET("/init-tasks", r.cluster.GetRunningInitRainbondTask)
 	entv1.POST("/init-cluster", r.cluster.CreateInitRainbondTask)
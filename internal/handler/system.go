@@ -47,7 +47,8 @@ func NewSystemHandler(db *gorm.DB) *SystemHandler {
 	}
 }
 
-//Backup backup all data
+//Backup backup all data. The private keys of the ssh keys and the bastions are kept encrypted with SSH_KEY_SECRET,
+//the host recovering the backup must be started with the same secret to use them.
 func (s SystemHandler) Backup(ctx *gin.Context) {
 	//backup dir
 	backupTmpPath := "/tmp/backup/"
@@ -66,6 +67,10 @@ func (s SystemHandler) Backup(ctx *gin.Context) {
 	s.db.Model(&model.RKECluster{}).Scan(&result.RKEClusters)
 	s.db.Model(&model.RainbondClusterConfig{}).Scan(&result.RainbondClusterConfigs)
 	s.db.Model(&model.AppStore{}).Scan(&result.AppStores)
+	s.db.Model(&model.Blob{}).Scan(&result.Blobs)
+	// the private keys are encrypted with SSH_KEY_SECRET which is not in the backup
+	s.db.Model(&model.SSHKey{}).Scan(&result.SSHKeys)
+	s.db.Model(&model.SSHBastion{}).Scan(&result.SSHBastions)
 	data, err := json.Marshal(result)
 	if err != nil {
 		ginutil.JSON(ctx, nil, err)
@@ -242,6 +247,15 @@ func (s SystemHandler) Recover(c *gin.Context) {
 				if err := tx.Where("1 = 1").Delete(&model.AppStore{}).Error; err != nil {
 					return err
 				}
+				if err := tx.Where("1 = 1").Delete(&model.Blob{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("1 = 1").Delete(&model.SSHKey{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("1 = 1").Delete(&model.SSHBastion{}).Error; err != nil {
+					return err
+				}
 
 				for _, accessKey := range data.CloudAccessKeys {
 					if err := tx.Create(&accessKey).Error; err != nil {
@@ -259,7 +273,12 @@ func (s SystemHandler) Recover(c *gin.Context) {
 					}
 				}
 				for _, taskEvent := range data.TaskEvents {
-					if err := tx.Create(&taskEvent).Error; err != nil {
+					db := tx
+					if taskEvent.Seq == 0 {
+						// the events backed up before seq was added have no seq, as the seq is unique in the events of the task
+						db = tx.Omit("seq")
+					}
+					if err := db.Create(&taskEvent).Error; err != nil {
 						return fmt.Errorf("recover taskEvent failure %s", err.Error())
 					}
 				}
@@ -289,6 +308,21 @@ func (s SystemHandler) Recover(c *gin.Context) {
 						return fmt.Errorf("recover appStores failure %s", err.Error())
 					}
 				}
+				for _, blob := range data.Blobs {
+					if err := tx.Create(&blob).Error; err != nil {
+						return fmt.Errorf("recover blobs failure %s", err.Error())
+					}
+				}
+				for _, sshKey := range data.SSHKeys {
+					if err := tx.Create(&sshKey).Error; err != nil {
+						return fmt.Errorf("recover ssh keys failure %s", err.Error())
+					}
+				}
+				for _, bastion := range data.SSHBastions {
+					if err := tx.Create(&bastion).Error; err != nil {
+						return fmt.Errorf("recover ssh bastions failure %s", err.Error())
+					}
+				}
 				logrus.Infof("recover db backup data success")
 				return nil
 			}(); err != nil {
@@ -0,0 +1,109 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/usecase"
+	"goodrain.com/cloud-adaptor/pkg/util/ginutil"
+)
+
+// WebhookHandler -
+type WebhookHandler struct {
+	webhook *usecase.WebhookUsecase
+}
+
+// NewWebhookHandler new webhook handler
+func NewWebhookHandler(webhook *usecase.WebhookUsecase) *WebhookHandler {
+	return &WebhookHandler{webhook: webhook}
+}
+
+// Create registers a webhook.
+// @Summary registers a webhook notified of the cluster lifecycle events.
+// @Tags webhooks
+// @ID createWebhook
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param createWebhookReq body v1.CreateWebhookReq true "."
+// @Success 200 {object} v1.WebhookRes
+// @Failure 400 {object} ginutil.Result "7033, webhook url invalid"
+// @Router /api/v1/enterprises/:eid/webhooks [post]
+func (w *WebhookHandler) Create(c *gin.Context) {
+	var req v1.CreateWebhookReq
+	if err := ginutil.ShouldBindJSON(c, &req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	webhook, err := w.webhook.CreateWebhook(c.Param("eid"), &req)
+	ginutil.JSONv2(c, webhook, err)
+}
+
+// List lists the webhooks.
+// @Summary lists the webhooks of the enterprise.
+// @Tags webhooks
+// @ID listWebhooks
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Success 200 {object} v1.WebhookListRes
+// @Router /api/v1/enterprises/:eid/webhooks [get]
+func (w *WebhookHandler) List(c *gin.Context) {
+	webhooks, err := w.webhook.ListWebhooks(c.Param("eid"))
+	ginutil.JSONv2(c, &v1.WebhookListRes{Webhooks: webhooks}, err)
+}
+
+// Delete deletes the webhook.
+// @Summary deletes the webhook.
+// @Tags webhooks
+// @ID deleteWebhook
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param webhookID path string true "the webhook id"
+// @Success 200
+// @Failure 404 {object} ginutil.Result "7032, webhook not found"
+// @Router /api/v1/enterprises/:eid/webhooks/:webhookID [delete]
+func (w *WebhookHandler) Delete(c *gin.Context) {
+	err := w.webhook.DeleteWebhook(c.Param("eid"), c.Param("webhookID"))
+	ginutil.JSONv2(c, nil, err)
+}
+
+// ListDeliveries lists the delivery history of the webhook.
+// @Summary lists the delivery history of the webhook, the latest first.
+// @Tags webhooks
+// @ID listWebhookDeliveries
+// @Accept  json
+// @Produce  json
+// @Param eid path string true "the enterprise id"
+// @Param webhookID path string true "the webhook id"
+// @Param listWebhookDeliveriesReq query v1.ListWebhookDeliveriesReq false "."
+// @Success 200 {object} v1.WebhookDeliveryListRes
+// @Failure 404 {object} ginutil.Result "7032, webhook not found"
+// @Router /api/v1/enterprises/:eid/webhooks/:webhookID/deliveries [get]
+func (w *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var req v1.ListWebhookDeliveriesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginutil.Error(c, err)
+		return
+	}
+	deliveries, err := w.webhook.ListDeliveries(c.Param("eid"), c.Param("webhookID"), req.Page, req.PageSize)
+	ginutil.JSONv2(c, deliveries, err)
+}
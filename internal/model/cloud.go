@@ -18,6 +18,8 @@
 
 package model
 
+import "time"
+
 //CloudAccessKey cloud access key
 type CloudAccessKey struct {
 	Model
@@ -27,6 +29,107 @@ type CloudAccessKey struct {
 	SecretKey    string `gorm:"column:secret_key" json:"secret_key"`
 }
 
+// the status of the ssh keys
+const (
+	SSHKeyStatusActive  = "active"
+	SSHKeyStatusPending = "pending"
+	SSHKeyStatusRevoked = "revoked"
+)
+
+//SSHKey the ssh key pair of the enterprise which cloud adaptor logs in to the nodes with, the private key is encrypted.
+type SSHKey struct {
+	Model
+	KeyID        string `gorm:"column:key_id;uniqueIndex;type:varchar(64)" json:"keyID"`
+	EnterpriseID string `gorm:"column:eid;index;type:varchar(64)" json:"eid"`
+	Type         string `gorm:"column:type;type:varchar(16)" json:"type"`
+	PublicKey    string `gorm:"column:public_key;type:text" json:"publicKey"`
+	PrivateKey   string `gorm:"column:private_key;type:text" json:"privateKey,omitempty"`
+	Fingerprint  string `gorm:"column:fingerprint" json:"fingerprint"`
+	// Status the pending key is being pushed to the nodes, the private key of the revoked one is removed.
+	Status string `gorm:"column:status;type:varchar(16)" json:"status"`
+}
+
+//SSHBastion the bastion host which cloud adaptor reaches the nodes of the cluster through, the private key is encrypted.
+type SSHBastion struct {
+	Model
+	EnterpriseID string `gorm:"column:eid;uniqueIndex:eid_cluster;type:varchar(64)" json:"eid"`
+	ClusterID    string `gorm:"column:cluster_id;uniqueIndex:eid_cluster;type:varchar(64)" json:"clusterID"`
+	Address      string `gorm:"column:address" json:"address"`
+	Port         uint   `gorm:"column:port" json:"port"`
+	User         string `gorm:"column:user" json:"user"`
+	// PrivateKey the key of the bastion, the key of the enterprise is used if it is empty.
+	PrivateKey string `gorm:"column:private_key;type:text" json:"privateKey,omitempty"`
+}
+
+//InitNodeToken the one-time token of downloading the node initialisation script
+type InitNodeToken struct {
+	Model
+	Token        string `gorm:"column:token;uniqueIndex;type:varchar(64)" json:"-"`
+	EnterpriseID string `gorm:"column:eid;type:varchar(64)" json:"eid"`
+	// RegistryMirror the registry mirror docker is configured with, the builtin mirrors are used if it is empty.
+	RegistryMirror string    `gorm:"column:registry_mirror" json:"registryMirror"`
+	ExpiredAt      time.Time `gorm:"column:expired_at" json:"expiredAt"`
+	Used           bool      `gorm:"column:used" json:"used"`
+}
+
+//ClusterHealth the health of the stored cluster checked periodically, the status is one of the health states of v1alpha1.
+type ClusterHealth struct {
+	Model
+	EnterpriseID string `gorm:"column:eid;uniqueIndex:eid_cluster_health;type:varchar(64)" json:"eid"`
+	ClusterID    string `gorm:"column:cluster_id;uniqueIndex:eid_cluster_health;type:varchar(64)" json:"clusterID"`
+	Provider     string `gorm:"column:provider;type:varchar(32)" json:"provider"`
+	Status       string `gorm:"column:status;type:varchar(16)" json:"status"`
+	APIReachable bool   `gorm:"column:api_reachable" json:"apiReachable"`
+	ReadyNodes   int    `gorm:"column:ready_nodes" json:"readyNodes"`
+	TotalNodes   int    `gorm:"column:total_nodes" json:"totalNodes"`
+	RegionStatus string `gorm:"column:region_status;type:varchar(16)" json:"regionStatus"`
+	// Reason why the cluster is not healthy
+	Reason string `gorm:"column:reason;type:text" json:"reason"`
+	// LastSeen the last time the kubernetes api of the cluster responded
+	LastSeen  *time.Time `gorm:"column:last_seen" json:"lastSeen"`
+	CheckedAt time.Time  `gorm:"column:checked_at" json:"checkedAt"`
+}
+
+//Lease the lease of a periodic job which must run on only one instance at the same time
+type Lease struct {
+	Model
+	Name     string    `gorm:"column:name;uniqueIndex;type:varchar(64)" json:"name"`
+	Owner    string    `gorm:"column:owner;type:varchar(128)" json:"owner"`
+	ExpireAt time.Time `gorm:"column:expire_at" json:"expireAt"`
+}
+
+//Webhook the endpoint of the enterprise notified of the cluster lifecycle events
+type Webhook struct {
+	Model
+	WebhookID    string `gorm:"column:webhook_id;uniqueIndex;type:varchar(64)" json:"webhookID"`
+	EnterpriseID string `gorm:"column:eid;index;type:varchar(64)" json:"eid"`
+	URL          string `gorm:"column:url;type:varchar(1024)" json:"url"`
+	// Secret signs the payload with HMAC-SHA256
+	Secret string `gorm:"column:secret" json:"-"`
+}
+
+//The status of the webhook delivery
+const (
+	WebhookDeliveryStatusPending = "pending"
+	WebhookDeliveryStatusSuccess = "success"
+	WebhookDeliveryStatusFailure = "failure"
+)
+
+//WebhookDelivery a delivery of the webhook, it is retried with exponential backoff until success.
+type WebhookDelivery struct {
+	Model
+	DeliveryID   string    `gorm:"column:delivery_id;uniqueIndex;type:varchar(64)" json:"deliveryID"`
+	WebhookID    string    `gorm:"column:webhook_id;index;type:varchar(64)" json:"webhookID"`
+	EnterpriseID string    `gorm:"column:eid" json:"eid"`
+	Event        string    `gorm:"column:event" json:"event"`
+	Payload      string    `gorm:"column:payload;type:text" json:"payload"`
+	Status       string    `gorm:"column:status;index;type:varchar(32)" json:"status"`
+	Attempts     int       `gorm:"column:attempts" json:"attempts"`
+	NextAttempt  time.Time `gorm:"column:next_attempt;index" json:"nextAttempt"`
+	ResponseCode int       `gorm:"column:response_code" json:"responseCode"`
+	Error        string    `gorm:"column:error;size:512" json:"error"`
+}
+
 //CreateKubernetesTask create kubernetes task model
 type CreateKubernetesTask struct {
 	Model
@@ -49,6 +152,8 @@ type InitRainbondTask struct {
 	Provider     string `gorm:"column:provider_name" json:"providerName"`
 	EnterpriseID string `gorm:"column:eid" json:"eid"`
 	Status       string `gorm:"column:status" json:"status"`
+	// ResumeFrom the task resumed by this task
+	ResumeFrom string `gorm:"column:resume_from" json:"resumeFrom,omitempty"`
 }
 
 //UpdateKubernetesTask -
@@ -64,16 +169,64 @@ type UpdateKubernetesTask struct {
 	Status       string `gorm:"column:status" json:"status"`
 }
 
+//OperationTask the task of the operation on the cluster, it runs in the process which creates it.
+type OperationTask struct {
+	Model
+	TaskID       string `gorm:"column:task_id;uniqueIndex;type:varchar(64)" json:"taskID"`
+	EnterpriseID string `gorm:"column:eid;index;type:varchar(64)" json:"eid"`
+	ClusterID    string `gorm:"column:cluster_id;index;type:varchar(64)" json:"clusterID"`
+	Provider     string `gorm:"column:provider_name" json:"providerName"`
+	Type         string `gorm:"column:type;type:varchar(64)" json:"type"`
+	Status       string `gorm:"column:status" json:"status"`
+	// Result the json encoded result of the task, such as the preflight report
+	Result string `gorm:"column:result;type:text" json:"-"`
+}
+
+//CloudResource the cloud resource created by cloud-adaptor
+type CloudResource struct {
+	Model
+	Provider     string `gorm:"column:provider_name;uniqueIndex:resource;type:varchar(32)" json:"providerName"`
+	RegionID     string `gorm:"column:region_id" json:"regionID"`
+	ResourceType string `gorm:"column:resource_type;uniqueIndex:resource;type:varchar(32)" json:"resourceType"`
+	ResourceID   string `gorm:"column:resource_id;uniqueIndex:resource;type:varchar(128)" json:"resourceID"`
+	Name         string `gorm:"column:name" json:"name"`
+	ClusterID    string `gorm:"column:cluster_id;index;type:varchar(64)" json:"clusterID"`
+	EnterpriseID string `gorm:"column:eid;index;type:varchar(64)" json:"eid"`
+	// TaskID the task which creates the resource
+	TaskID string `gorm:"column:task_id" json:"taskID"`
+	Status string `gorm:"column:status" json:"status"`
+}
+
+//Blob the blob saved in the database, such as the state files of the rke clusters
+type Blob struct {
+	Model
+	Key     string `gorm:"column:blob_key;uniqueIndex;type:varchar(255)" json:"key"`
+	Size    int64  `gorm:"column:size" json:"size"`
+	Content []byte `gorm:"column:content;type:longblob" json:"content"`
+}
+
 //TaskEvent task event
 type TaskEvent struct {
 	Model
-	TaskID       string `gorm:"column:task_id" json:"taskID"`
-	EnterpriseID string `gorm:"column:eid" json:"eid"`
+	TaskID       string `gorm:"column:task_id;uniqueIndex:task_seq;type:varchar(64)" json:"taskID"`
+	EnterpriseID string `gorm:"column:eid;uniqueIndex:task_seq;type:varchar(64)" json:"eid"`
 	StepType     string `gorm:"column:step_type" json:"type"`
 	Message      string `gorm:"column:message;size:512" json:"message"`
 	Status       string `gorm:"column:status" json:"status"`
 	EventID      string `gorm:"column:event_id" json:"eventID"`
 	Reason       string `gorm:"column:reason" json:"reason"`
+	// Seq increases every time an event of the task is saved, it is unique in the events of the task.
+	// The events saved before seq was added have no seq.
+	Seq int64 `gorm:"column:seq;uniqueIndex:task_seq" json:"seq"`
+}
+
+//TaskResult the terminal state of the task, only the first terminal event of the task is recorded.
+type TaskResult struct {
+	Model
+	TaskID       string `gorm:"column:task_id;uniqueIndex;type:varchar(64)" json:"taskID"`
+	EnterpriseID string `gorm:"column:eid;type:varchar(64)" json:"eid"`
+	StepType     string `gorm:"column:step_type" json:"type"`
+	Status       string `gorm:"column:status" json:"status"`
 }
 
 // BackupListModelData list all model data
@@ -87,4 +240,37 @@ type BackupListModelData struct {
 	RKEClusters            []RKECluster            `json:"rke_clusters"`
 	RainbondClusterConfigs []RainbondClusterConfig `json:"rainbond_cluster_configs"`
 	AppStores              []AppStore              `json:"app_stores"`
+	Blobs                  []Blob                  `json:"blobs"`
+	SSHKeys                []SSHKey                `json:"ssh_keys"`
+	SSHBastions            []SSHBastion            `json:"ssh_bastions"`
+}
+
+// TaskMessage status
+const (
+	TaskMessageStatusPending     = "pending"
+	TaskMessageStatusRunning     = "running"
+	TaskMessageStatusDone        = "done"
+	TaskMessageStatusInterrupted = "interrupted"
+	TaskMessageStatusCancelled   = "cancelled"
+)
+
+//TaskMessage task message persisted by the task queue
+type TaskMessage struct {
+	Model
+	Topic        string    `gorm:"column:topic;type:varchar(64)" json:"topic"`
+	TaskID       string    `gorm:"column:task_id;uniqueIndex;type:varchar(64)" json:"taskID"`
+	EnterpriseID string    `gorm:"column:eid" json:"eid"`
+	Body         string    `gorm:"column:body;type:text" json:"body"`
+	Status       string    `gorm:"column:status;index;type:varchar(32)" json:"status"`
+	Owner        string    `gorm:"column:owner" json:"owner"`
+	LeaseExpire  time.Time `gorm:"column:lease_expire" json:"leaseExpire"`
+	Attempts     int       `gorm:"column:attempts" json:"attempts"`
+	// CancelRequested is set when the user cancels a running task,
+	// the owner of the message cancels the task context.
+	CancelRequested bool `gorm:"column:cancel_requested" json:"cancelRequested"`
+	// Plan is the json encoded step types of the task, it is set when the task is enqueued.
+	Plan string `gorm:"column:plan;type:text" json:"plan"`
+	// Started is set by the owner before the task is handed to the handler, the message is requeued
+	// only if it has not started, so that a task is never run by two owners.
+	Started bool `gorm:"column:started" json:"started"`
 }
@@ -36,6 +36,9 @@ type RKECluster struct {
 	NodeList  string `gorm:"column:nodeList;type:text" json:"nodeList,omitempty"`
 	Stats     string `gorm:"column:stats" json:"stats,omitempty"`
 	RKEConfig string `gorm:"column:rkeConfig"`
+
+	// PreviousKubernetesVersion the version before the last upgrade, the cluster can be rolled back to it.
+	PreviousKubernetesVersion string `gorm:"column:previousKubernetesVersion" json:"previousKubernetesVersion,omitempty"`
 }
 
 //CustomCluster custom cluster
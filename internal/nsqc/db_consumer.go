@@ -0,0 +1,244 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package nsqc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/internal/task"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/internal/usecase"
+	"goodrain.com/cloud-adaptor/pkg/util/constants"
+	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
+)
+
+var (
+	pollInterval  = time.Second * 2
+	leaseTTL      = time.Minute
+	renewInterval = time.Second * 20
+	// a task that never started can be re-dispatched at most maxAttempts times
+	maxAttempts = 3
+	leaseLimit  = 10
+)
+
+// taskDBConsumer leases the task messages persisted by the db producer and
+// dispatches them to the task handlers.
+type taskDBConsumer struct {
+	ctx                         context.Context
+	owner                       string
+	cancels                     map[string]context.CancelFunc
+	taskMessageRepo             repo.TaskMessageRepository
+	clusterUsecase              *usecase.ClusterUsecase
+	createKubernetesTaskHandler task.CreateKubernetesTaskHandler
+	cloudInitTaskHandler        task.CloudInitTaskHandler
+	cloudUpdateTaskHandler      task.UpdateKubernetesTaskHandler
+	operationTaskHandler        task.OperationTaskHandler
+}
+
+// NewTaskDBConsumer creates a new db consumer.
+func NewTaskDBConsumer(
+	ctx context.Context,
+	taskMessageRepo repo.TaskMessageRepository,
+	clusterUsecase *usecase.ClusterUsecase,
+	createHandler task.CreateKubernetesTaskHandler,
+	initHandler task.CloudInitTaskHandler,
+	cloudUpdateTaskHandler task.UpdateKubernetesTaskHandler,
+	operationTaskHandler task.OperationTaskHandler,
+) TaskConsumer {
+	hostname, _ := os.Hostname()
+	return &taskDBConsumer{
+		ctx:                         ctx,
+		owner:                       fmt.Sprintf("%s-%s", hostname, uuidutil.NewUUID()),
+		cancels:                     make(map[string]context.CancelFunc),
+		taskMessageRepo:             taskMessageRepo,
+		clusterUsecase:              clusterUsecase,
+		createKubernetesTaskHandler: createHandler,
+		cloudInitTaskHandler:        initHandler,
+		cloudUpdateTaskHandler:      cloudUpdateTaskHandler,
+		operationTaskHandler:        operationTaskHandler,
+	}
+}
+
+// Start -
+func (c *taskDBConsumer) Start() error {
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+	renewTicker := time.NewTicker(renewInterval)
+	defer renewTicker.Stop()
+	c.poll()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-renewTicker.C:
+			if err := c.taskMessageRepo.RenewLease(c.owner, leaseTTL); err != nil {
+				logrus.Errorf("renew task message lease failure %s", err.Error())
+			}
+		case <-pollTicker.C:
+			c.poll()
+		}
+	}
+}
+
+func (c *taskDBConsumer) poll() {
+	c.reclaim()
+	msgs, err := c.taskMessageRepo.Lease(c.owner, leaseTTL, leaseLimit)
+	if err != nil {
+		logrus.Errorf("lease task messages failure %s", err.Error())
+	}
+	for _, msg := range msgs {
+		c.dispatch(msg)
+	}
+	c.cancelRequested()
+}
+
+// cancelRequested cancels the context of the running tasks which the user has cancelled,
+// and releases the contexts of the finished tasks.
+func (c *taskDBConsumer) cancelRequested() {
+	msgs, err := c.taskMessageRepo.ListRunning(c.owner)
+	if err != nil {
+		logrus.Errorf("list running task messages failure %s", err.Error())
+		return
+	}
+	running := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		running[msg.TaskID] = true
+		if !msg.CancelRequested {
+			continue
+		}
+		if cancel, ok := c.cancels[msg.TaskID]; ok {
+			logrus.Infof("cancel task %s", msg.TaskID)
+			cancel()
+		}
+	}
+	for taskID, cancel := range c.cancels {
+		if !running[taskID] {
+			cancel()
+			delete(c.cancels, taskID)
+		}
+	}
+}
+
+// reclaim handles the messages whose owner is gone, such as the tasks
+// that were running when the last cloud adaptor process exited.
+// The task that has not been handed to the handler by its owner is re-dispatched,
+// otherwise it is marked as interrupted so that it can be retried by the user.
+func (c *taskDBConsumer) reclaim() {
+	msgs, err := c.taskMessageRepo.ListExpired()
+	if err != nil {
+		logrus.Errorf("list expired task messages failure %s", err.Error())
+		return
+	}
+	for _, msg := range msgs {
+		if msg.CancelRequested {
+			ok, err := c.taskMessageRepo.Reclaim(msg, model.TaskMessageStatusCancelled)
+			if err != nil {
+				logrus.Errorf("cancel task %s failure %s", msg.TaskID, err.Error())
+				continue
+			}
+			if ok {
+				if err := c.clusterUsecase.MarkTaskCancelled(msg.EnterpriseID, msg.TaskID); err != nil {
+					logrus.Errorf("save task %s cancelled event failure %s", msg.TaskID, err.Error())
+				}
+			}
+			continue
+		}
+		if !msg.Started && msg.Attempts < maxAttempts {
+			ok, err := c.taskMessageRepo.Requeue(msg)
+			if err != nil {
+				logrus.Errorf("requeue task %s failure %s", msg.TaskID, err.Error())
+				continue
+			}
+			if ok {
+				logrus.Infof("task %s of %s has not started, requeue it", msg.TaskID, msg.Owner)
+				continue
+			}
+			// the owner started it at the same time, it is interrupted below.
+		}
+		ok, err := c.taskMessageRepo.Reclaim(msg, model.TaskMessageStatusInterrupted)
+		if err != nil {
+			logrus.Errorf("interrupt task %s failure %s", msg.TaskID, err.Error())
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := c.clusterUsecase.InterruptTask(msg.EnterpriseID, msg.TaskID); err != nil {
+			logrus.Errorf("save task %s interrupted event failure %s", msg.TaskID, err.Error())
+		}
+		logrus.Warningf("task %s of %s is interrupted", msg.TaskID, msg.Owner)
+	}
+}
+
+func (c *taskDBConsumer) dispatch(msg *model.TaskMessage) {
+	// the task is not handed to the handler if it has been reclaimed by others after its lease expired.
+	started, err := c.taskMessageRepo.Start(msg)
+	if err != nil {
+		logrus.Errorf("start task message %s failure %s", msg.TaskID, err.Error())
+		return
+	}
+	if !started {
+		logrus.Warningf("task message %s has been reclaimed, skip it", msg.TaskID)
+		return
+	}
+	// every task has its own context, so that it can be cancelled by the user.
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.cancels[msg.TaskID] = cancel
+	switch msg.Topic {
+	case constants.CloudCreate:
+		var createMsg types.KubernetesConfigMessage
+		if err = json.Unmarshal([]byte(msg.Body), &createMsg); err == nil {
+			err = c.createKubernetesTaskHandler.HandleMsg(ctx, createMsg)
+		}
+	case constants.CloudInit:
+		var initMsg types.InitRainbondConfigMessage
+		if err = json.Unmarshal([]byte(msg.Body), &initMsg); err == nil {
+			err = c.cloudInitTaskHandler.HandleMsg(ctx, initMsg)
+		}
+	case constants.CloudUpdate:
+		var updateMsg types.UpdateKubernetesConfigMessage
+		if err = json.Unmarshal([]byte(msg.Body), &updateMsg); err == nil {
+			err = c.cloudUpdateTaskHandler.HandleMsg(ctx, updateMsg)
+		}
+	case constants.CloudOperation:
+		var operationMsg types.OperationTaskMessage
+		if err = json.Unmarshal([]byte(msg.Body), &operationMsg); err == nil {
+			err = c.operationTaskHandler.HandleMsg(ctx, operationMsg)
+		}
+	default:
+		err = fmt.Errorf("unknown topic %s", msg.Topic)
+	}
+	if err != nil {
+		logrus.Errorf("handle task message %s failure %s", msg.TaskID, err.Error())
+		// the terminal event closes the event streams of the task and notifies the webhooks.
+		if err := c.clusterUsecase.FailTask(msg.EnterpriseID, msg.TaskID, err.Error()); err != nil {
+			logrus.Errorf("save task %s failure event failure %s", msg.TaskID, err.Error())
+		}
+		if err := c.taskMessageRepo.Finish(msg.TaskID); err != nil {
+			logrus.Errorf("finish task message %s failure %s", msg.TaskID, err.Error())
+		}
+	}
+}
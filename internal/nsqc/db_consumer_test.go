@@ -0,0 +1,310 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package nsqc
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/datastore"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/nsqc/producer"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/internal/task"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/internal/usecase"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type fakeCreateHandler struct {
+	handled []string
+	ctxs    map[string]context.Context
+}
+
+func (f *fakeCreateHandler) HandleMsg(ctx context.Context, createConfig types.KubernetesConfigMessage) error {
+	f.handled = append(f.handled, createConfig.TaskID)
+	if f.ctxs != nil {
+		f.ctxs[createConfig.TaskID] = ctx
+	}
+	return nil
+}
+
+func (f *fakeCreateHandler) HandleMessage(m *nsq.Message) error {
+	return nil
+}
+
+type fakeOperationHandler struct {
+	ctxs map[string]context.Context
+}
+
+func (f *fakeOperationHandler) HandleMsg(ctx context.Context, config types.OperationTaskMessage) error {
+	f.ctxs[config.TaskID] = ctx
+	return nil
+}
+
+func (f *fakeOperationHandler) HandleMessage(m *nsq.Message) error {
+	return nil
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(path.Join(t.TempDir(), "db.sqlite3")), &gorm.Config{
+		NamingStrategy: &schema.NamingStrategy{TablePrefix: "adaptor_"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestClusterUsecase(db *gorm.DB) *usecase.ClusterUsecase {
+	return usecase.NewClusterUsecase(usecase.ClusterUsecaseDeps{
+		DB:                       db,
+		CreateKubernetesTaskRepo: repo.NewCreateKubernetesTaskRepo(db),
+		InitRainbondTaskRepo:     repo.NewInitRainbondRegionTaskRepo(db),
+		UpdateKubernetesTaskRepo: repo.NewUpdateKubernetesTaskRepo(db),
+		TaskEventRepo:            repo.NewTaskEventRepo(db),
+		TaskResultRepo:           repo.NewTaskResultRepo(db),
+		TaskMessageRepo:          repo.NewTaskMessageRepo(db),
+		OperationTaskRepo:        repo.NewOperationTaskRepo(db),
+		CloudResourceRepo:        repo.NewCloudResourceRepo(db),
+		RKEStateStore:            repo.NewRKEStateStore(db),
+		SSHKeyRepo:               repo.NewSSHKeyRepo(db),
+		SSHBastionRepo:           repo.NewSSHBastionRepo(db),
+		InitNodeTokenRepo:        repo.NewInitNodeTokenRepo(db),
+		ClusterHealthRepo:        repo.NewClusterHealthRepo(db),
+		LeaseRepo:                repo.NewLeaseRepo(db),
+	})
+}
+
+func TestTaskDBConsumer(t *testing.T) {
+	db := newTestDB(t)
+	messageRepo := repo.NewTaskMessageRepo(db)
+	eventRepo := repo.NewTaskEventRepo(db)
+	clusterUsecase := newTestClusterUsecase(db)
+	createHandler := &fakeCreateHandler{}
+	consumer := NewTaskDBConsumer(context.Background(), messageRepo, clusterUsecase, createHandler, nil, nil, nil).(*taskDBConsumer)
+
+	taskProducer := producer.NewTaskDBProducer(messageRepo, task.NewTaskPlanner())
+	for _, taskID := range []string{"task1", "task2"} {
+		config := &v1alpha1.KubernetesClusterConfig{EnterpriseID: "eid", Provider: "rke"}
+		if err := taskProducer.SendCreateKuerbetesTask(types.KubernetesConfigMessage{EnterpriseID: "eid", TaskID: taskID, KubernetesConfig: config}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the plan is saved when the task is enqueued
+	plan, err := clusterUsecase.GetTaskPlan("eid", "task1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 4 || plan.Steps[3].StepType != v1.StepInstallKubernetes {
+		t.Errorf("want the plan of the rke create task, got %+v", plan.Steps)
+	}
+	consumer.poll()
+	if len(createHandler.handled) != 2 {
+		t.Fatalf("want 2 handled tasks, got %d", len(createHandler.handled))
+	}
+	// leased messages must not be dispatched twice
+	consumer.poll()
+	if len(createHandler.handled) != 2 {
+		t.Fatalf("want 2 handled tasks, got %d", len(createHandler.handled))
+	}
+
+	// simulate a restart: task1 has been handed to the handler, the process exited before handing task2 to it.
+	if _, err := clusterUsecase.CreateTaskEvent(newEvent("task1", "Init", "success")); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&model.TaskMessage{}).Where("task_id=?", "task2").Update("started", false)
+	db.Model(&model.TaskMessage{}).Where("1=1").Update("lease_expire", time.Now().Add(-time.Minute))
+	restarted := NewTaskDBConsumer(context.Background(), messageRepo, clusterUsecase, createHandler, nil, nil, nil).(*taskDBConsumer)
+	restarted.poll()
+
+	if len(createHandler.handled) != 3 || createHandler.handled[2] != "task2" {
+		t.Fatalf("want task2 to be re-dispatched, got %v", createHandler.handled)
+	}
+	var task1 model.TaskMessage
+	db.Where("task_id=?", "task1").Take(&task1)
+	if task1.Status != model.TaskMessageStatusInterrupted {
+		t.Errorf("want task1 to be interrupted, got %s", task1.Status)
+	}
+	events, _ := eventRepo.ListEvent("eid", "task1")
+	if len(events) != 2 || events[1].StepType != "TaskInterrupted" {
+		t.Errorf("want an interrupted event, got %+v", events)
+	}
+
+	// the terminal event finishes the message
+	if _, err := clusterUsecase.CreateTaskEvent(newEvent("task2", "CreateCluster", "success")); err != nil {
+		t.Fatal(err)
+	}
+	var task2 model.TaskMessage
+	db.Where("task_id=?", "task2").Take(&task2)
+	if task2.Status != model.TaskMessageStatusDone {
+		t.Errorf("want task2 to be done, got %s", task2.Status)
+	}
+}
+
+func TestTaskDBConsumerReclaimed(t *testing.T) {
+	db := newTestDB(t)
+	messageRepo := repo.NewTaskMessageRepo(db)
+	clusterUsecase := newTestClusterUsecase(db)
+	createHandler := &fakeCreateHandler{}
+	slow := NewTaskDBConsumer(context.Background(), messageRepo, clusterUsecase, createHandler, nil, nil, nil).(*taskDBConsumer)
+	other := NewTaskDBConsumer(context.Background(), messageRepo, clusterUsecase, createHandler, nil, nil, nil).(*taskDBConsumer)
+	taskProducer := producer.NewTaskDBProducer(messageRepo, nil)
+	if err := taskProducer.SendCreateKuerbetesTask(types.KubernetesConfigMessage{EnterpriseID: "eid", TaskID: "task"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the slow consumer leases the task but its lease expires before it hands the task to the handler
+	msgs, err := messageRepo.Lease(slow.owner, leaseTTL, leaseLimit)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("lease the task: %v %v", msgs, err)
+	}
+	db.Model(&model.TaskMessage{}).Where("1=1").Update("lease_expire", time.Now().Add(-time.Minute))
+	// the lease of the task that has not started is not renewed
+	if err := messageRepo.RenewLease(slow.owner, leaseTTL); err != nil {
+		t.Fatal(err)
+	}
+	other.poll()
+	if len(createHandler.handled) != 1 {
+		t.Fatalf("want the task to be requeued and dispatched by the other consumer, got %v", createHandler.handled)
+	}
+	slow.dispatch(msgs[0])
+	if len(createHandler.handled) != 1 {
+		t.Fatalf("the task reclaimed by others should not be dispatched again, got %v", createHandler.handled)
+	}
+
+	// the message can not be handled fails the task
+	if err := messageRepo.Create(&model.TaskMessage{Topic: "unknown", TaskID: "unknown", EnterpriseID: "eid"}); err != nil {
+		t.Fatal(err)
+	}
+	other.poll()
+	events, _ := repo.NewTaskEventRepo(db).ListEvent("eid", "unknown")
+	if len(events) != 1 || events[0].StepType != v1.StepCreateTask || events[0].Status != "failure" {
+		t.Errorf("want a failure event, got %+v", events)
+	}
+	var msg model.TaskMessage
+	db.Where("task_id=?", "unknown").Take(&msg)
+	if msg.Status != model.TaskMessageStatusDone {
+		t.Errorf("want the message to be done, got %s", msg.Status)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	db := newTestDB(t)
+	messageRepo := repo.NewTaskMessageRepo(db)
+	eventRepo := repo.NewTaskEventRepo(db)
+	clusterUsecase := newTestClusterUsecase(db)
+	createHandler := &fakeCreateHandler{ctxs: make(map[string]context.Context)}
+	consumer := NewTaskDBConsumer(context.Background(), messageRepo, clusterUsecase, createHandler, nil, nil, nil).(*taskDBConsumer)
+	taskProducer := producer.NewTaskDBProducer(messageRepo, nil)
+
+	// cancel a pending task
+	if err := taskProducer.SendCreateKuerbetesTask(types.KubernetesConfigMessage{EnterpriseID: "eid", TaskID: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterUsecase.CancelTask("eid", "pending"); err != nil {
+		t.Fatal(err)
+	}
+	consumer.poll()
+	if len(createHandler.handled) != 0 {
+		t.Fatalf("the cancelled task should not be dispatched, got %v", createHandler.handled)
+	}
+	events, _ := eventRepo.ListEvent("eid", "pending")
+	if len(events) != 1 || events[0].Status != "cancelled" {
+		t.Errorf("want a cancelled event, got %+v", events)
+	}
+
+	// cancel a running task
+	if err := taskProducer.SendCreateKuerbetesTask(types.KubernetesConfigMessage{EnterpriseID: "eid", TaskID: "running"}); err != nil {
+		t.Fatal(err)
+	}
+	consumer.poll()
+	ctx := createHandler.ctxs["running"]
+	if ctx == nil || ctx.Err() != nil {
+		t.Fatal("want a living task context")
+	}
+	if err := clusterUsecase.CancelTask("other", "running"); err == nil {
+		t.Error("want an error for the task of other enterprise")
+	}
+	if err := clusterUsecase.CancelTask("eid", "running"); err != nil {
+		t.Fatal(err)
+	}
+	consumer.poll()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("want the task context to be cancelled, got %v", ctx.Err())
+	}
+
+	// the finished task can not be cancelled
+	if _, err := clusterUsecase.CreateTaskEvent(newEvent("running", "Cancelled", "cancelled")); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterUsecase.CancelTask("eid", "running"); err == nil {
+		t.Error("want an error for the finished task")
+	}
+}
+
+func newEvent(taskID, stepType, status string) *v1.EventMessage {
+	return &v1.EventMessage{
+		EnterpriseID: "eid",
+		TaskID:       taskID,
+		Message:      &v1.Message{StepType: stepType, Status: status},
+	}
+}
+
+func TestOperationTaskDBConsumer(t *testing.T) {
+	db := newTestDB(t)
+	messageRepo := repo.NewTaskMessageRepo(db)
+	clusterUsecase := newTestClusterUsecase(db)
+	operationHandler := &fakeOperationHandler{ctxs: make(map[string]context.Context)}
+	consumer := NewTaskDBConsumer(context.Background(), messageRepo, clusterUsecase, nil, nil, nil, operationHandler).(*taskDBConsumer)
+
+	taskProducer := producer.NewTaskDBProducer(messageRepo, task.NewTaskPlanner())
+	if err := taskProducer.SendOperationTask(types.OperationTaskMessage{EnterpriseID: "eid", TaskID: "upgrade", Params: []byte(`{"version":"v1.24.4-rancher1-1"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	var msg model.TaskMessage
+	db.Where("task_id=?", "upgrade").Take(&msg)
+	if msg.Plan != "" {
+		t.Errorf("the operation task should not have a plan, got %s", msg.Plan)
+	}
+	consumer.poll()
+	ctx, ok := operationHandler.ctxs["upgrade"]
+	if !ok {
+		t.Fatalf("want the operation task to be dispatched")
+	}
+
+	// the running operation task is cancelled by the consumer who owns it
+	if err := clusterUsecase.CancelTask("eid", "upgrade"); err != nil {
+		t.Fatal(err)
+	}
+	consumer.poll()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("want the context of the operation task to be cancelled")
+	}
+}
@@ -24,4 +24,4 @@ import (
 )
 
 // ProviderSet is mq providers.
-var ProviderSet = wire.NewSet(producer.NewTaskChannelProducer)
+var ProviderSet = wire.NewSet(producer.NewTaskDBProducer)
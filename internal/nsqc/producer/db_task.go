@@ -0,0 +1,108 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package producer
+
+import (
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/pkg/util/constants"
+)
+
+//taskDBProducer persists tasks into the database, so that they survive a restart.
+type taskDBProducer struct {
+	taskMessageRepo repo.TaskMessageRepository
+	planner         TaskPlanner
+}
+
+//NewTaskDBProducer new task db producer
+func NewTaskDBProducer(taskMessageRepo repo.TaskMessageRepository, planner TaskPlanner) TaskProducer {
+	return &taskDBProducer{
+		taskMessageRepo: taskMessageRepo,
+		planner:         planner,
+	}
+}
+
+//Start start
+func (c *taskDBProducer) Start() error {
+	return nil
+}
+
+func (c *taskDBProducer) sendTask(topicName, eid, taskID string, taskConfig interface{}) error {
+	body, err := json.Marshal(taskConfig)
+	if err != nil {
+		return err
+	}
+	return c.taskMessageRepo.Create(&model.TaskMessage{
+		Topic:        topicName,
+		TaskID:       taskID,
+		EnterpriseID: eid,
+		Body:         string(body),
+		Plan:         c.plan(taskID, taskConfig),
+	})
+}
+
+//plan returns the json encoded step plan of the task, the task is enqueued without a plan if it can not be planned.
+func (c *taskDBProducer) plan(taskID string, taskConfig interface{}) string {
+	if c.planner == nil {
+		return ""
+	}
+	plan, err := c.planner.Plan(taskConfig)
+	if err != nil {
+		logrus.Errorf("plan task %s failure %s", taskID, err.Error())
+		return ""
+	}
+	if plan == nil {
+		return ""
+	}
+	body, err := json.Marshal(plan)
+	if err != nil {
+		logrus.Errorf("encode task %s plan failure %s", taskID, err.Error())
+		return ""
+	}
+	return string(body)
+}
+
+//SendCreateKuerbetesTask send create kubernetes task
+func (c *taskDBProducer) SendCreateKuerbetesTask(config types.KubernetesConfigMessage) error {
+	return c.sendTask(constants.CloudCreate, config.EnterpriseID, config.TaskID, config)
+}
+
+//SendInitRainbondRegionTask send init rainbond region task
+func (c *taskDBProducer) SendInitRainbondRegionTask(config types.InitRainbondConfigMessage) error {
+	return c.sendTask(constants.CloudInit, config.EnterpriseID, config.TaskID, config)
+}
+
+//SendUpdateKuerbetesTask send update kubernetes task
+func (c *taskDBProducer) SendUpdateKuerbetesTask(config types.UpdateKubernetesConfigMessage) error {
+	return c.sendTask(constants.CloudUpdate, config.EnterpriseID, config.TaskID, config)
+}
+
+//SendOperationTask send the operation task of the cluster
+func (c *taskDBProducer) SendOperationTask(config types.OperationTaskMessage) error {
+	return c.sendTask(constants.CloudOperation, config.EnterpriseID, config.TaskID, config)
+}
+
+//Stop stop
+func (c *taskDBProducer) Stop() {
+
+}
@@ -35,9 +35,15 @@ type TaskProducer interface {
 	SendCreateKuerbetesTask(config types.KubernetesConfigMessage) error
 	SendUpdateKuerbetesTask(config types.UpdateKubernetesConfigMessage) error
 	SendInitRainbondRegionTask(config types.InitRainbondConfigMessage) error
+	SendOperationTask(config types.OperationTaskMessage) error
 	Stop()
 }
 
+//TaskPlanner plans the step types of the task messages
+type TaskPlanner interface {
+	Plan(taskConfig interface{}) ([]string, error)
+}
+
 //TaskProducer task producer
 type taskProducer struct {
 	taskProducer *nsq.Producer
@@ -92,6 +98,11 @@ func (m *taskProducer) SendUpdateKuerbetesTask(config types.UpdateKubernetesConf
 	return m.sendTask(constants.CloudUpdate, config)
 }
 
+//SendOperationTask send the operation task of the cluster
+func (m *taskProducer) SendOperationTask(config types.OperationTaskMessage) error {
+	return m.sendTask(constants.CloudOperation, config)
+}
+
 //Stop stop
 func (m *taskProducer) Stop() {
 	m.taskProducer.Stop()
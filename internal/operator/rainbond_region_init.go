@@ -248,6 +248,19 @@ func (r *RainbondRegionInit) createRainbondCR(kubeClient *kubernetes.Clientset,
 				},
 			},
 		}
+	} else if initConfig.NFSServer != "" {
+		// the nfs server provided by the cloud file storage, such as tencent cfs
+		cluster.Spec.RainbondVolumeSpecRWX = &rainbondv1alpha1.RainbondVolumeSpec{
+			CSIPlugin: &rainbondv1alpha1.CSIPluginSource{
+				NFS: &rainbondv1alpha1.NFSCSIPluginSource{},
+			},
+			StorageClassParameters: &rainbondv1alpha1.StorageClassParameters{
+				Parameters: map[string]string{
+					"server": initConfig.NFSServer,
+					"share":  "/",
+				},
+			},
+		}
 	}
 	// handle volume spec
 	if cluster.Spec.RainbondVolumeSpecRWX != nil {
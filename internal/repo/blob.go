@@ -0,0 +1,111 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+	"gorm.io/gorm"
+)
+
+// BlobRepo the blob store saving the blobs in the database
+type BlobRepo struct {
+	DB *gorm.DB
+}
+
+// NewBlobRepo new blob repo
+func NewBlobRepo(db *gorm.DB) blobstore.Store {
+	return &BlobRepo{DB: db}
+}
+
+// Get returns blobstore.ErrNotFound if the blob does not exist
+func (b *BlobRepo) Get(key string) ([]byte, error) {
+	var blob model.Blob
+	if err := b.DB.Where("blob_key = ?", key).Take(&blob).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, blobstore.ErrNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return blob.Content, nil
+}
+
+// Stat returns the metadata of the blob, the content is not loaded.
+func (b *BlobRepo) Stat(key string) (*blobstore.Blob, error) {
+	var blob model.Blob
+	if err := b.DB.Select("blob_key", "size", "updated_at").Where("blob_key = ?", key).Take(&blob).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, blobstore.ErrNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &blobstore.Blob{Key: blob.Key, Size: blob.Size, ModTime: blob.UpdatedAt}, nil
+}
+
+// Put creates or overwrites the blob
+func (b *BlobRepo) Put(key string, data []byte) error {
+	var old model.Blob
+	err := b.DB.Select("id").Where("blob_key = ?", key).Take(&old).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.WithStack(b.DB.Create(&model.Blob{Key: key, Size: int64(len(data)), Content: data}).Error)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(b.DB.Model(&old).Updates(map[string]interface{}{"size": len(data), "content": data}).Error)
+}
+
+// List lists the blobs whose keys begin with the prefix, the contents are not loaded.
+func (b *BlobRepo) List(prefix string) ([]*blobstore.Blob, error) {
+	var list []*model.Blob
+	// the wildcards such as _ in the prefix match any char, the results are filtered by the prefix again
+	if err := b.DB.Select("blob_key", "size", "updated_at").Where("blob_key like ?", prefix+"%").Order("blob_key").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var blobs []*blobstore.Blob
+	for _, blob := range list {
+		if !strings.HasPrefix(blob.Key, prefix) {
+			continue
+		}
+		blobs = append(blobs, &blobstore.Blob{Key: blob.Key, Size: blob.Size, ModTime: blob.UpdatedAt})
+	}
+	return blobs, nil
+}
+
+// Delete deletes the blob
+func (b *BlobRepo) Delete(key string) error {
+	return errors.WithStack(b.DB.Where("blob_key = ?", key).Delete(&model.Blob{}).Error)
+}
+
+// DeleteAll deletes the blobs whose keys begin with the prefix
+func (b *BlobRepo) DeleteAll(prefix string) error {
+	blobs, err := b.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if err := b.Delete(blob.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"gorm.io/gorm"
+)
+
+// CloudResourceRepo the repository of the cloud resources created by cloud-adaptor
+type CloudResourceRepo struct {
+	DB *gorm.DB
+}
+
+// NewCloudResourceRepo new cloud resource repo
+func NewCloudResourceRepo(db *gorm.DB) CloudResourceRepository {
+	return &CloudResourceRepo{DB: db}
+}
+
+// Transaction -
+func (c *CloudResourceRepo) Transaction(tx *gorm.DB) CloudResourceRepository {
+	return &CloudResourceRepo{DB: tx}
+}
+
+// Save creates the resource, or updates the status of the resource recorded before.
+// The task created the resource is kept.
+func (c *CloudResourceRepo) Save(resource *model.CloudResource) error {
+	var old model.CloudResource
+	err := c.DB.Where("provider_name = ? and resource_type = ? and resource_id = ?", resource.Provider, resource.ResourceType, resource.ResourceID).Take(&old).Error
+	if err == gorm.ErrRecordNotFound {
+		return errors.WithStack(c.DB.Create(resource).Error)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	updates := map[string]interface{}{"status": resource.Status}
+	if old.ClusterID == "" && resource.ClusterID != "" {
+		updates["cluster_id"] = resource.ClusterID
+	}
+	if old.Name == "" && resource.Name != "" {
+		updates["name"] = resource.Name
+	}
+	return errors.WithStack(c.DB.Model(&old).Updates(updates).Error)
+}
+
+// SetClusterID sets the cluster of the resources created by the task before the cluster
+func (c *CloudResourceRepo) SetClusterID(eid, taskID, clusterID string) error {
+	return errors.WithStack(c.DB.Model(&model.CloudResource{}).Where("eid = ? and task_id = ? and cluster_id = ''", eid, taskID).
+		Update("cluster_id", clusterID).Error)
+}
+
+// ListByCluster list the resources of the cluster
+func (c *CloudResourceRepo) ListByCluster(eid, clusterID string) ([]*model.CloudResource, error) {
+	var resources []*model.CloudResource
+	if err := c.DB.Where("eid = ? and cluster_id = ?", eid, clusterID).Order("id").Find(&resources).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resources, nil
+}
@@ -41,10 +41,29 @@ func (t *TaskEventRepo) Transaction(tx *gorm.DB) TaskEventRepository {
 }
 
 func (t *TaskEventRepo) CreateEvent(te *model.TaskEvent) error {
-	if err := t.DB.Debug().Save(te).Error; err != nil {
-		return err
+	return t.saveWithSeq(te, func(seq int64) error {
+		te.Seq = seq
+		return t.DB.Debug().Save(te).Error
+	})
+}
+
+// taskEventSeqRetries the times to save the event again if the seq is taken by another event of the task saved at the same time
+const taskEventSeqRetries = 10
+
+// saveWithSeq calls save with the next seq of the task, and again with a new seq if the seq is taken.
+func (t *TaskEventRepo) saveWithSeq(te *model.TaskEvent, save func(seq int64) error) error {
+	var err error
+	for i := 0; i < taskEventSeqRetries; i++ {
+		var seq int64
+		if err = t.DB.Model(&model.TaskEvent{}).Where("eid = ? and task_id=?", te.EnterpriseID, te.TaskID).
+			Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error; err != nil {
+			return err
+		}
+		if err = save(seq + 1); err == nil || !isDuplicateEntry(err) {
+			return err
+		}
 	}
-	return nil
+	return errors.Wrap(err, "save the task event")
 }
 
 //Create create an event
@@ -52,6 +71,12 @@ func (t *TaskEventRepo) Create(te *model.TaskEvent) error {
 	if len(te.Message) > 512 {
 		te.Message = te.Message[:512]
 	}
+	return t.saveWithSeq(te, func(seq int64) error {
+		return t.create(te, seq)
+	})
+}
+
+func (t *TaskEventRepo) create(te *model.TaskEvent, seq int64) error {
 	var old model.TaskEvent
 	if err := t.DB.Where("eid = ? and task_id=? and step_type=?", te.EnterpriseID, te.TaskID, te.StepType).Take(&old).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -59,6 +84,7 @@ func (t *TaskEventRepo) Create(te *model.TaskEvent) error {
 			if te.EventID == "" {
 				te.EventID = uuidutil.NewUUID()
 			}
+			te.Seq = seq
 			if err := t.DB.Save(te).Error; err != nil {
 				return err
 			}
@@ -71,7 +97,11 @@ func (t *TaskEventRepo) Create(te *model.TaskEvent) error {
 		old.Message = te.Message
 		old.Status = te.Status
 		old.Reason = te.Reason
-		return t.DB.Save(&old).Error
+		old.Seq = seq
+		if err := t.DB.Save(&old).Error; err != nil {
+			return err
+		}
+		te.Seq = old.Seq
 	}
 	return nil
 }
@@ -85,6 +115,15 @@ func (t *TaskEventRepo) ListEvent(eid, taskID string) ([]*model.TaskEvent, error
 	return list, nil
 }
 
+//ListEventAfter list the task events saved after the seq in order
+func (t *TaskEventRepo) ListEventAfter(eid, taskID string, seq int64) ([]*model.TaskEvent, error) {
+	var list []*model.TaskEvent
+	if err := t.DB.Where("eid = ? and task_id=? and seq>?", eid, taskID, seq).Order("seq").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
 //DeleteEvent delete task events
 func (t *TaskEventRepo) DeleteEvent(eid, taskID string) error {
 	if err := t.DB.Where("eid = ? and task_id=?", eid, taskID).Delete(&model.TaskEvent{}).Error; err != nil {
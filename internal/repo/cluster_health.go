@@ -0,0 +1,76 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"gorm.io/gorm"
+)
+
+// ClusterHealthRepo the health records of the clusters
+type ClusterHealthRepo struct {
+	DB *gorm.DB
+}
+
+// NewClusterHealthRepo new cluster health repo
+func NewClusterHealthRepo(db *gorm.DB) ClusterHealthRepository {
+	return &ClusterHealthRepo{DB: db}
+}
+
+// Save creates or updates the health of the cluster, the last seen time is kept if the health has none.
+func (c *ClusterHealthRepo) Save(health *model.ClusterHealth) error {
+	var old model.ClusterHealth
+	err := c.DB.Select("id").Where("eid = ? and cluster_id = ?", health.EnterpriseID, health.ClusterID).Take(&old).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.WithStack(c.DB.Create(health).Error)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	health.ID = old.ID
+	values := map[string]interface{}{
+		"provider":      health.Provider,
+		"status":        health.Status,
+		"api_reachable": health.APIReachable,
+		"ready_nodes":   health.ReadyNodes,
+		"total_nodes":   health.TotalNodes,
+		"region_status": health.RegionStatus,
+		"reason":        health.Reason,
+		"checked_at":    health.CheckedAt,
+	}
+	if health.LastSeen != nil {
+		values["last_seen"] = health.LastSeen
+	}
+	return errors.WithStack(c.DB.Model(&old).Updates(values).Error)
+}
+
+// List lists the health of the clusters of the enterprise
+func (c *ClusterHealthRepo) List(eid string) ([]*model.ClusterHealth, error) {
+	var list []*model.ClusterHealth
+	if err := c.DB.Where("eid = ?", eid).Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+// Delete deletes the health of the cluster
+func (c *ClusterHealthRepo) Delete(eid, clusterID string) error {
+	return errors.WithStack(c.DB.Where("eid = ? and cluster_id = ?", eid, clusterID).Delete(&model.ClusterHealth{}).Error)
+}
@@ -86,6 +86,15 @@ func (t *CustomClusterRepo) ListCluster(eid string) ([]*model.CustomCluster, err
 	return list, nil
 }
 
+//ListAllClusters lists the clusters of all enterprises
+func (t *CustomClusterRepo) ListAllClusters() ([]*model.CustomCluster, error) {
+	var list []*model.CustomCluster
+	if err := t.DB.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
 //DeleteCluster delete cluster
 func (t *CustomClusterRepo) DeleteCluster(eid, name string) error {
 	var rc model.CustomCluster
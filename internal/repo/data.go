@@ -30,10 +30,22 @@ var ProviderSet = wire.NewSet(
 	NewInitRainbondRegionTaskRepo,
 	NewUpdateKubernetesTaskRepo,
 	NewTaskEventRepo,
+	NewTaskResultRepo,
+	NewTaskMessageRepo,
+	NewOperationTaskRepo,
+	NewCloudResourceRepo,
+	NewWebhookRepo,
+	NewWebhookDeliveryRepo,
 	NewRainbondClusterConfigRepo,
 	NewAppStoreRepo,
 	NewRKEClusterRepo,
 	NewCustomClusterRepository,
+	NewClusterHealthRepo,
+	NewLeaseRepo,
+	NewInitNodeTokenRepo,
+	NewSSHBastionRepo,
+	NewSSHKeyRepo,
+	NewRKEStateStore,
 	NewTemplateVersionRepo,
 	appstore.NewStorer,
 	appstore.NewAppTemplater,
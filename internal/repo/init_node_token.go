@@ -0,0 +1,63 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"gorm.io/gorm"
+)
+
+// InitNodeTokenRepo the one-time tokens of the node initialisation script
+type InitNodeTokenRepo struct {
+	DB *gorm.DB
+}
+
+// NewInitNodeTokenRepo new init node token repo
+func NewInitNodeTokenRepo(db *gorm.DB) InitNodeTokenRepository {
+	return &InitNodeTokenRepo{DB: db}
+}
+
+// Create creates the token, the expired tokens are deleted at the same time.
+func (i *InitNodeTokenRepo) Create(token *model.InitNodeToken) error {
+	if err := i.DB.Where("expired_at < ?", time.Now()).Delete(&model.InitNodeToken{}).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(i.DB.Create(token).Error)
+}
+
+// Consume marks the token used and returns it, ErrInitNodeTokenInvalid is returned if the token does not exist,
+// is used or expired. The token is marked in one statement so it can not be consumed twice.
+func (i *InitNodeTokenRepo) Consume(token string) (*model.InitNodeToken, error) {
+	res := i.DB.Model(&model.InitNodeToken{}).Where("token = ? and used = ? and expired_at > ?", token, false, time.Now()).Update("used", true)
+	if res.Error != nil {
+		return nil, errors.WithStack(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.WithStack(bcode.ErrInitNodeTokenInvalid)
+	}
+	var re model.InitNodeToken
+	if err := i.DB.Where("token = ?", token).Take(&re).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &re, nil
+}
@@ -0,0 +1,72 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"gorm.io/gorm"
+)
+
+// LeaseRepo the leases of the jobs running on one instance
+type LeaseRepo struct {
+	DB *gorm.DB
+}
+
+// NewLeaseRepo new lease repo
+func NewLeaseRepo(db *gorm.DB) LeaseRepository {
+	return &LeaseRepo{DB: db}
+}
+
+// Acquire acquires or renews the lease for ttl, returns false if the lease is held by another owner.
+func (l *LeaseRepo) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res := l.DB.Model(&model.Lease{}).Where("name = ? and (owner = ? or expire_at < ?)", name, owner, now).
+		Updates(map[string]interface{}{"owner": owner, "expire_at": now.Add(ttl)})
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+	var count int64
+	if err := l.DB.Model(&model.Lease{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, errors.WithStack(err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := l.DB.Create(&model.Lease{Name: name, Owner: owner, ExpireAt: now.Add(ttl)}).Error; err != nil {
+		// created by another owner at the same time
+		if err := l.DB.Model(&model.Lease{}).Where("name = ?", name).Count(&count).Error; err == nil && count > 0 {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// Release expires the lease if it is held by the owner, so another owner can acquire it at once.
+func (l *LeaseRepo) Release(name, owner string) error {
+	err := l.DB.Model(&model.Lease{}).Where("name = ? and owner = ?", name, owner).
+		Update("expire_at", time.Now().Add(-time.Second)).Error
+	return errors.WithStack(err)
+}
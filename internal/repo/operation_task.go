@@ -0,0 +1,77 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
+	"gorm.io/gorm"
+)
+
+// OperationTaskRepo the repository of the operation tasks
+type OperationTaskRepo struct {
+	DB *gorm.DB
+}
+
+// NewOperationTaskRepo new operation task repo
+func NewOperationTaskRepo(db *gorm.DB) OperationTaskRepository {
+	return &OperationTaskRepo{DB: db}
+}
+
+// Transaction -
+func (o *OperationTaskRepo) Transaction(tx *gorm.DB) OperationTaskRepository {
+	return &OperationTaskRepo{DB: tx}
+}
+
+// Create create a task
+func (o *OperationTaskRepo) Create(task *model.OperationTask) error {
+	if task.TaskID == "" {
+		task.TaskID = uuidutil.NewUUID()
+	}
+	return errors.WithStack(o.DB.Create(task).Error)
+}
+
+// GetTask get task
+func (o *OperationTaskRepo) GetTask(eid, taskID string) (*model.OperationTask, error) {
+	var task model.OperationTask
+	if err := o.DB.Where("eid = ? and task_id = ?", eid, taskID).Take(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetLastTask get the last task of the type of the cluster
+func (o *OperationTaskRepo) GetLastTask(eid, clusterID, taskType string) (*model.OperationTask, error) {
+	var task model.OperationTask
+	if err := o.DB.Where("eid = ? and cluster_id = ? and type = ?", eid, clusterID, taskType).Order("id desc").Take(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateStatus update status
+func (o *OperationTaskRepo) UpdateStatus(eid, taskID, status string) error {
+	return errors.WithStack(o.DB.Model(&model.OperationTask{}).Where("eid = ? and task_id = ?", eid, taskID).Update("status", status).Error)
+}
+
+// SaveResult saves the result of the task
+func (o *OperationTaskRepo) SaveResult(eid, taskID, result string) error {
+	return errors.WithStack(o.DB.Model(&model.OperationTask{}).Where("eid = ? and task_id = ?", eid, taskID).Update("result", result).Error)
+}
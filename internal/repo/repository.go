@@ -19,6 +19,8 @@
 package repo
 
 import (
+	"time"
+
 	"goodrain.com/cloud-adaptor/internal/model"
 	"gorm.io/gorm"
 )
@@ -67,10 +69,17 @@ type TaskEventRepository interface {
 	Create(ent *model.TaskEvent) error
 	CreateEvent(te *model.TaskEvent) error
 	ListEvent(eid, taskID string) ([]*model.TaskEvent, error)
+	ListEventAfter(eid, taskID string, seq int64) ([]*model.TaskEvent, error)
 	UpdateStatusInBatch(eventIDs []string, status string) error
 	DeleteEvent(eid, taskID string) error
 }
 
+//TaskResultRepository the terminal states of the tasks
+type TaskResultRepository interface {
+	Transaction(tx *gorm.DB) TaskResultRepository
+	Record(result *model.TaskResult) (bool, error)
+}
+
 //RainbondClusterConfigRepository -
 type RainbondClusterConfigRepository interface {
 	Create(ent *model.RainbondClusterConfig) error
@@ -83,6 +92,7 @@ type RKEClusterRepository interface {
 	Update(te *model.RKECluster) error
 	GetCluster(eid, name string) (*model.RKECluster, error)
 	ListCluster(eid string) ([]*model.RKECluster, error)
+	ListAllClusters() ([]*model.RKECluster, error)
 	DeleteCluster(eid, name string) error
 }
 
@@ -92,5 +102,94 @@ type CustomClusterRepository interface {
 	Update(cluster *model.CustomCluster) error
 	GetCluster(eid, name string) (*model.CustomCluster, error)
 	ListCluster(eid string) ([]*model.CustomCluster, error)
+	ListAllClusters() ([]*model.CustomCluster, error)
 	DeleteCluster(eid, name string) error
 }
+
+// TaskMessageRepository the persistent task queue
+type TaskMessageRepository interface {
+	Transaction(tx *gorm.DB) TaskMessageRepository
+	Create(msg *model.TaskMessage) error
+	Lease(owner string, ttl time.Duration, limit int) ([]*model.TaskMessage, error)
+	RenewLease(owner string, ttl time.Duration) error
+	ListExpired() ([]*model.TaskMessage, error)
+	Reclaim(msg *model.TaskMessage, status string) (bool, error)
+	Start(msg *model.TaskMessage) (bool, error)
+	Requeue(msg *model.TaskMessage) (bool, error)
+	Finish(taskID string) error
+	GetByTaskID(taskID string) (*model.TaskMessage, error)
+	ListRunning(owner string) ([]*model.TaskMessage, error)
+	RequestCancel(taskID string) error
+	CancelPending(taskID string) (bool, error)
+}
+
+// OperationTaskRepository -
+type OperationTaskRepository interface {
+	Transaction(tx *gorm.DB) OperationTaskRepository
+	Create(task *model.OperationTask) error
+	GetTask(eid, taskID string) (*model.OperationTask, error)
+	GetLastTask(eid, clusterID, taskType string) (*model.OperationTask, error)
+	UpdateStatus(eid, taskID, status string) error
+	SaveResult(eid, taskID, result string) error
+}
+
+// CloudResourceRepository -
+type CloudResourceRepository interface {
+	Transaction(tx *gorm.DB) CloudResourceRepository
+	Save(resource *model.CloudResource) error
+	SetClusterID(eid, taskID, clusterID string) error
+	ListByCluster(eid, clusterID string) ([]*model.CloudResource, error)
+}
+
+// SSHKeyRepository -
+type SSHKeyRepository interface {
+	Create(key *model.SSHKey, privateKey []byte) error
+	GetActive(eid string) (*model.SSHKey, error)
+	GetPrivateKey(key *model.SSHKey) ([]byte, error)
+	Activate(key *model.SSHKey) error
+	Delete(key *model.SSHKey) error
+}
+
+// SSHBastionRepository -
+type SSHBastionRepository interface {
+	Save(bastion *model.SSHBastion, privateKey []byte) error
+	Get(eid, clusterID string) (*model.SSHBastion, error)
+	GetPrivateKey(bastion *model.SSHBastion) ([]byte, error)
+	Delete(eid, clusterID string) error
+}
+
+// InitNodeTokenRepository -
+type InitNodeTokenRepository interface {
+	Create(token *model.InitNodeToken) error
+	Consume(token string) (*model.InitNodeToken, error)
+}
+
+// ClusterHealthRepository -
+type ClusterHealthRepository interface {
+	Save(health *model.ClusterHealth) error
+	List(eid string) ([]*model.ClusterHealth, error)
+	Delete(eid, clusterID string) error
+}
+
+// LeaseRepository the leases of the jobs running on one instance
+type LeaseRepository interface {
+	Acquire(name, owner string, ttl time.Duration) (bool, error)
+	Release(name, owner string) error
+}
+
+// WebhookRepository -
+type WebhookRepository interface {
+	Create(webhook *model.Webhook) error
+	ListByEnterprise(eid string) ([]*model.Webhook, error)
+	Get(eid, webhookID string) (*model.Webhook, error)
+	Delete(eid, webhookID string) error
+}
+
+// WebhookDeliveryRepository -
+type WebhookDeliveryRepository interface {
+	Create(deliveries []*model.WebhookDelivery) error
+	ListDue(now time.Time, limit int) ([]*model.WebhookDelivery, error)
+	Claim(delivery *model.WebhookDelivery, until time.Time) (bool, error)
+	Update(delivery *model.WebhookDelivery) error
+	ListByWebhook(eid, webhookID string, page, pageSize int) ([]*model.WebhookDelivery, int64, error)
+}
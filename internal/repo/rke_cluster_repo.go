@@ -83,6 +83,15 @@ func (t *RKEClusterRepo) ListCluster(eid string) ([]*model.RKECluster, error) {
 	return list, nil
 }
 
+//ListAllClusters lists the clusters of all enterprises
+func (t *RKEClusterRepo) ListAllClusters() ([]*model.RKECluster, error) {
+	var list []*model.RKECluster
+	if err := t.DB.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
 //DeleteCluster delete cluster
 func (t *RKEClusterRepo) DeleteCluster(eid, name string) error {
 	var rc model.RKECluster
@@ -0,0 +1,112 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+	"gorm.io/gorm"
+)
+
+// NewRKEStateStore returns the store of the state files of the rke clusters, such as cluster.yml, cluster.rkestate and create.log.
+// The files are saved in the database unless RKE_STATE_STORE is local, which saves them under CONFIG_DIR as before.
+func NewRKEStateStore(db *gorm.DB) blobstore.Store {
+	if os.Getenv("RKE_STATE_STORE") == "local" {
+		return blobstore.NewLocalStore(rkeConfigDir())
+	}
+	return NewBlobRepo(db)
+}
+
+func rkeConfigDir() string {
+	configDir := "/tmp"
+	if os.Getenv("CONFIG_DIR") != "" {
+		configDir = os.Getenv("CONFIG_DIR")
+	}
+	return configDir
+}
+
+// RKEStatePrefix returns the key prefix of the state files of the rke cluster, it is the same as the path of the local dir used before.
+func RKEStatePrefix(eid, clusterName string) string {
+	return fmt.Sprintf("enterprise/%s/rke/%s/", eid, clusterName)
+}
+
+// legacyRKEStateDirs the local dirs of the state files used before, the latter one is used before the enterprise dir is introduced.
+func legacyRKEStateDirs(eid, clusterName string) []string {
+	return []string{
+		path.Join(rkeConfigDir(), "enterprise", eid, "rke", clusterName),
+		path.Join(rkeConfigDir(), "rke", clusterName),
+	}
+}
+
+// ImportLegacyRKEState imports the state files in the local dirs used before to the store, the files existing in the store are kept.
+func ImportLegacyRKEState(store blobstore.Store, eid, clusterName string) error {
+	prefix := RKEStatePrefix(eid, clusterName)
+	blobs, err := store.List(prefix)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for _, blob := range blobs {
+		existing[strings.TrimPrefix(blob.Key, prefix)] = true
+	}
+	for _, dir := range legacyRKEStateDirs(eid, clusterName) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() || existing[file.Name()] {
+				continue
+			}
+			data, err := ioutil.ReadFile(path.Join(dir, file.Name()))
+			if err != nil {
+				return errors.Wrapf(err, "read legacy state file %s", file.Name())
+			}
+			if err := store.Put(prefix+file.Name(), data); err != nil {
+				return err
+			}
+			existing[file.Name()] = true
+		}
+	}
+	return nil
+}
+
+// GetRKEStateFile returns the state file of the rke cluster, the legacy local files are imported first.
+func GetRKEStateFile(store blobstore.Store, eid, clusterName, name string) ([]byte, error) {
+	if err := ImportLegacyRKEState(store, eid, clusterName); err != nil {
+		return nil, err
+	}
+	return store.Get(RKEStatePrefix(eid, clusterName) + name)
+}
+
+// DeleteRKEState deletes the state files of the rke cluster in the store and the legacy local dirs.
+func DeleteRKEState(store blobstore.Store, eid, clusterName string) error {
+	for _, dir := range legacyRKEStateDirs(eid, clusterName) {
+		if err := os.RemoveAll(dir); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return store.DeleteAll(RKEStatePrefix(eid, clusterName))
+}
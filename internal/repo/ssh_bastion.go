@@ -0,0 +1,80 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"gorm.io/gorm"
+)
+
+// SSHBastionRepo the ssh bastions of the clusters
+type SSHBastionRepo struct {
+	DB *gorm.DB
+}
+
+// NewSSHBastionRepo new ssh bastion repo
+func NewSSHBastionRepo(db *gorm.DB) SSHBastionRepository {
+	return &SSHBastionRepo{DB: db}
+}
+
+// Save encrypts the private key and creates or replaces the bastion of the cluster
+func (s *SSHBastionRepo) Save(bastion *model.SSHBastion, privateKey []byte) error {
+	bastion.PrivateKey = ""
+	if len(privateKey) > 0 {
+		var err error
+		if bastion.PrivateKey, err = EncryptSSHKey(privateKey); err != nil {
+			return err
+		}
+	}
+	var old model.SSHBastion
+	err := s.DB.Select("id").Where("eid = ? and cluster_id = ?", bastion.EnterpriseID, bastion.ClusterID).Take(&old).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.WithStack(s.DB.Create(bastion).Error)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	bastion.ID = old.ID
+	return errors.WithStack(s.DB.Model(&old).Updates(map[string]interface{}{
+		"address": bastion.Address, "port": bastion.Port, "user": bastion.User, "private_key": bastion.PrivateKey,
+	}).Error)
+}
+
+// Get returns the bastion of the cluster
+func (s *SSHBastionRepo) Get(eid, clusterID string) (*model.SSHBastion, error) {
+	var bastion model.SSHBastion
+	if err := s.DB.Where("eid = ? and cluster_id = ?", eid, clusterID).Take(&bastion).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &bastion, nil
+}
+
+// GetPrivateKey decrypts the private key, nil is returned if the bastion uses the key of the enterprise.
+func (s *SSHBastionRepo) GetPrivateKey(bastion *model.SSHBastion) ([]byte, error) {
+	if bastion.PrivateKey == "" {
+		return nil, nil
+	}
+	return DecryptSSHKey(bastion.PrivateKey)
+}
+
+// Delete deletes the bastion of the cluster
+func (s *SSHBastionRepo) Delete(eid, clusterID string) error {
+	return errors.WithStack(s.DB.Where("eid = ? and cluster_id = ?", eid, clusterID).Delete(&model.SSHBastion{}).Error)
+}
@@ -0,0 +1,122 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/cryptoutil"
+	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
+	"gorm.io/gorm"
+)
+
+// sshKeySecretEnv the env of the secret encrypting the private keys
+const sshKeySecretEnv = "SSH_KEY_SECRET"
+
+// CheckSSHKeySecret returns ErrSSHKeySecretNotSet if the secret encrypting the private keys is not set,
+// the private keys can not be encrypted or decrypted without it.
+func CheckSSHKeySecret() error {
+	_, err := loadSSHKeySecret()
+	return err
+}
+
+func loadSSHKeySecret() (string, error) {
+	secret, err := cryptoutil.LoadSecret(sshKeySecretEnv)
+	if err != nil {
+		logrus.Errorf("load the ssh key secret: %v", err)
+		return "", errors.WithStack(bcode.ErrSSHKeySecretNotSet)
+	}
+	return secret, nil
+}
+
+// EncryptSSHKey encrypts the private key with the ssh key secret
+func EncryptSSHKey(privateKey []byte) (string, error) {
+	secret, err := loadSSHKeySecret()
+	if err != nil {
+		return "", err
+	}
+	return cryptoutil.Encrypt(secret, privateKey)
+}
+
+// DecryptSSHKey decrypts the private key encrypted by EncryptSSHKey
+func DecryptSSHKey(privateKey string) ([]byte, error) {
+	secret, err := loadSSHKeySecret()
+	if err != nil {
+		return nil, err
+	}
+	return cryptoutil.Decrypt(secret, privateKey)
+}
+
+// SSHKeyRepo the ssh keys of the enterprises
+type SSHKeyRepo struct {
+	DB *gorm.DB
+}
+
+// NewSSHKeyRepo new ssh key repo
+func NewSSHKeyRepo(db *gorm.DB) SSHKeyRepository {
+	return &SSHKeyRepo{DB: db}
+}
+
+// Create encrypts the private key and creates the key
+func (s *SSHKeyRepo) Create(key *model.SSHKey, privateKey []byte) error {
+	var err error
+	if key.PrivateKey, err = EncryptSSHKey(privateKey); err != nil {
+		return err
+	}
+	if key.KeyID == "" {
+		key.KeyID = uuidutil.NewUUID()
+	}
+	return errors.WithStack(s.DB.Create(key).Error)
+}
+
+// GetActive returns the active key of the enterprise
+func (s *SSHKeyRepo) GetActive(eid string) (*model.SSHKey, error) {
+	var key model.SSHKey
+	if err := s.DB.Where("eid = ? and status = ?", eid, model.SSHKeyStatusActive).Order("id desc").Take(&key).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &key, nil
+}
+
+// GetPrivateKey decrypts the private key
+func (s *SSHKeyRepo) GetPrivateKey(key *model.SSHKey) ([]byte, error) {
+	if key.PrivateKey == "" {
+		return nil, errors.Errorf("the private key of %s is revoked", key.KeyID)
+	}
+	return DecryptSSHKey(key.PrivateKey)
+}
+
+// Activate activates the key, the other active keys of the enterprise are revoked and their private keys are removed.
+func (s *SSHKeyRepo) Activate(key *model.SSHKey) error {
+	return errors.WithStack(s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.SSHKey{}).Where("eid = ? and status = ? and key_id <> ?", key.EnterpriseID, model.SSHKeyStatusActive, key.KeyID).
+			Updates(map[string]interface{}{"status": model.SSHKeyStatusRevoked, "private_key": ""}).Error; err != nil {
+			return err
+		}
+		key.Status = model.SSHKeyStatusActive
+		return tx.Model(key).Update("status", key.Status).Error
+	}))
+}
+
+// Delete deletes the key
+func (s *SSHKeyRepo) Delete(key *model.SSHKey) error {
+	return errors.WithStack(s.DB.Where("key_id = ?", key.KeyID).Delete(&model.SSHKey{}).Error)
+}
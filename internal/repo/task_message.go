@@ -0,0 +1,185 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"gorm.io/gorm"
+)
+
+// TaskMessageRepo task message repo
+type TaskMessageRepo struct {
+	DB *gorm.DB `inject:""`
+}
+
+// NewTaskMessageRepo new task message repo
+func NewTaskMessageRepo(db *gorm.DB) TaskMessageRepository {
+	return &TaskMessageRepo{DB: db}
+}
+
+// Transaction -
+func (t *TaskMessageRepo) Transaction(tx *gorm.DB) TaskMessageRepository {
+	return &TaskMessageRepo{DB: tx}
+}
+
+//Create create a pending message
+func (t *TaskMessageRepo) Create(msg *model.TaskMessage) error {
+	if msg.Status == "" {
+		msg.Status = model.TaskMessageStatusPending
+	}
+	if err := t.DB.Create(msg).Error; err != nil {
+		if isDuplicateEntry(err) {
+			return errors.Errorf("task message %s already exists", msg.TaskID)
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+//Lease leases at most limit pending messages to the owner.
+//A message is leased by only one owner even if several workers share the database.
+func (t *TaskMessageRepo) Lease(owner string, ttl time.Duration, limit int) ([]*model.TaskMessage, error) {
+	var pending []*model.TaskMessage
+	if err := t.DB.Where("status=?", model.TaskMessageStatusPending).Order("id asc").Limit(limit).Find(&pending).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var leased []*model.TaskMessage
+	for _, msg := range pending {
+		expire := time.Now().Add(ttl)
+		res := t.DB.Model(&model.TaskMessage{}).Where("id=? and status=?", msg.ID, model.TaskMessageStatusPending).Updates(map[string]interface{}{
+			"status":       model.TaskMessageStatusRunning,
+			"owner":        owner,
+			"lease_expire": expire,
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+		if res.Error != nil {
+			return leased, errors.WithStack(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			// leased by another worker
+			continue
+		}
+		msg.Status = model.TaskMessageStatusRunning
+		msg.Owner = owner
+		msg.LeaseExpire = expire
+		msg.Attempts++
+		leased = append(leased, msg)
+	}
+	return leased, nil
+}
+
+//RenewLease extends the lease of all started messages of the owner, the message that fails to start is requeued after its lease expires.
+func (t *TaskMessageRepo) RenewLease(owner string, ttl time.Duration) error {
+	err := t.DB.Model(&model.TaskMessage{}).Where("owner=? and status=? and started=?", owner, model.TaskMessageStatusRunning, true).
+		Update("lease_expire", time.Now().Add(ttl)).Error
+	return errors.WithStack(err)
+}
+
+//ListExpired list running messages whose lease has expired
+func (t *TaskMessageRepo) ListExpired() ([]*model.TaskMessage, error) {
+	var list []*model.TaskMessage
+	if err := t.DB.Where("status=? and lease_expire<?", model.TaskMessageStatusRunning, time.Now()).Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+//Reclaim sets the status of an expired message, returns false if the message has been reclaimed by others.
+func (t *TaskMessageRepo) Reclaim(msg *model.TaskMessage, status string) (bool, error) {
+	res := t.DB.Model(&model.TaskMessage{}).Where("id=? and status=? and owner=? and lease_expire<?", msg.ID, model.TaskMessageStatusRunning, msg.Owner, time.Now()).
+		Updates(map[string]interface{}{"status": status, "owner": ""})
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+//Start marks the message leased by its owner as started, returns false if it has been reclaimed by others.
+func (t *TaskMessageRepo) Start(msg *model.TaskMessage) (bool, error) {
+	res := t.DB.Model(&model.TaskMessage{}).Where("id=? and status=? and owner=?", msg.ID, model.TaskMessageStatusRunning, msg.Owner).
+		Update("started", true)
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+//Requeue sets the expired message which has not started to pending, returns false if it has started or been reclaimed by others.
+func (t *TaskMessageRepo) Requeue(msg *model.TaskMessage) (bool, error) {
+	res := t.DB.Model(&model.TaskMessage{}).Where("id=? and status=? and owner=? and started=? and lease_expire<?", msg.ID, model.TaskMessageStatusRunning, msg.Owner, false, time.Now()).
+		Updates(map[string]interface{}{"status": model.TaskMessageStatusPending, "owner": ""})
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+//Finish marks the message as done unless it has been interrupted
+func (t *TaskMessageRepo) Finish(taskID string) error {
+	err := t.DB.Model(&model.TaskMessage{}).Where("task_id=? and status in ?", taskID, []string{model.TaskMessageStatusPending, model.TaskMessageStatusRunning}).
+		Updates(map[string]interface{}{"status": model.TaskMessageStatusDone, "owner": ""}).Error
+	return errors.WithStack(err)
+}
+
+//GetByTaskID get message by task id
+func (t *TaskMessageRepo) GetByTaskID(taskID string) (*model.TaskMessage, error) {
+	var msg model.TaskMessage
+	if err := t.DB.Where("task_id=?", taskID).Take(&msg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(bcode.ErrClusterTaskNotFound)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &msg, nil
+}
+
+//ListRunning list running messages of the owner
+func (t *TaskMessageRepo) ListRunning(owner string) ([]*model.TaskMessage, error) {
+	var list []*model.TaskMessage
+	if err := t.DB.Where("owner=? and status=?", owner, model.TaskMessageStatusRunning).Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+//RequestCancel marks the running message as cancel requested
+func (t *TaskMessageRepo) RequestCancel(taskID string) error {
+	res := t.DB.Model(&model.TaskMessage{}).Where("task_id=? and status=?", taskID, model.TaskMessageStatusRunning).Update("cancel_requested", true)
+	if res.Error != nil {
+		return errors.WithStack(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return errors.WithStack(bcode.ErrTaskNotRunning)
+	}
+	return nil
+}
+
+//CancelPending cancels the message that has not been leased, returns false if it has been leased.
+func (t *TaskMessageRepo) CancelPending(taskID string) (bool, error) {
+	res := t.DB.Model(&model.TaskMessage{}).Where("task_id=? and status=?", taskID, model.TaskMessageStatusPending).
+		Updates(map[string]interface{}{"status": model.TaskMessageStatusCancelled, "cancel_requested": true})
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
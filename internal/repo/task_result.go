@@ -0,0 +1,50 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TaskResultRepo the repository of the task results
+type TaskResultRepo struct {
+	DB *gorm.DB
+}
+
+// NewTaskResultRepo new task result repo
+func NewTaskResultRepo(db *gorm.DB) TaskResultRepository {
+	return &TaskResultRepo{DB: db}
+}
+
+// Transaction -
+func (t *TaskResultRepo) Transaction(tx *gorm.DB) TaskResultRepository {
+	return &TaskResultRepo{DB: tx}
+}
+
+// Record records the result of the task, returns false if the task has got a result.
+func (t *TaskResultRepo) Record(result *model.TaskResult) (bool, error) {
+	res := t.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(result)
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,127 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package repo
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"gorm.io/gorm"
+)
+
+// WebhookRepo enterprise webhook repo
+type WebhookRepo struct {
+	DB *gorm.DB `inject:""`
+}
+
+// NewWebhookRepo new webhook repo
+func NewWebhookRepo(db *gorm.DB) WebhookRepository {
+	return &WebhookRepo{DB: db}
+}
+
+//Create create a webhook
+func (w *WebhookRepo) Create(webhook *model.Webhook) error {
+	return errors.WithStack(w.DB.Create(webhook).Error)
+}
+
+//ListByEnterprise list the webhooks of the enterprise
+func (w *WebhookRepo) ListByEnterprise(eid string) ([]*model.Webhook, error) {
+	var list []*model.Webhook
+	if err := w.DB.Where("eid=?", eid).Order("id").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+//Get get the webhook of the enterprise
+func (w *WebhookRepo) Get(eid, webhookID string) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := w.DB.Where("eid=? and webhook_id=?", eid, webhookID).Take(&webhook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(bcode.ErrWebhookNotFound)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &webhook, nil
+}
+
+//Delete delete the webhook of the enterprise
+func (w *WebhookRepo) Delete(eid, webhookID string) error {
+	return errors.WithStack(w.DB.Where("eid=? and webhook_id=?", eid, webhookID).Delete(&model.Webhook{}).Error)
+}
+
+// WebhookDeliveryRepo webhook delivery repo
+type WebhookDeliveryRepo struct {
+	DB *gorm.DB `inject:""`
+}
+
+// NewWebhookDeliveryRepo new webhook delivery repo
+func NewWebhookDeliveryRepo(db *gorm.DB) WebhookDeliveryRepository {
+	return &WebhookDeliveryRepo{DB: db}
+}
+
+//Create create deliveries
+func (w *WebhookDeliveryRepo) Create(deliveries []*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return errors.WithStack(w.DB.Create(&deliveries).Error)
+}
+
+//ListDue list the pending deliveries of which the next attempt is due
+func (w *WebhookDeliveryRepo) ListDue(now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	var list []*model.WebhookDelivery
+	if err := w.DB.Where("status=? and next_attempt<=?", model.WebhookDeliveryStatusPending, now).
+		Order("next_attempt").Limit(limit).Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+//Claim postpones the next attempt of the delivery so that other instances skip it, returns false if it has been claimed.
+func (w *WebhookDeliveryRepo) Claim(delivery *model.WebhookDelivery, until time.Time) (bool, error) {
+	res := w.DB.Model(&model.WebhookDelivery{}).
+		Where("id=? and status=? and next_attempt=?", delivery.ID, model.WebhookDeliveryStatusPending, delivery.NextAttempt).
+		Update("next_attempt", until)
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+//Update update the result of the delivery
+func (w *WebhookDeliveryRepo) Update(delivery *model.WebhookDelivery) error {
+	return errors.WithStack(w.DB.Save(delivery).Error)
+}
+
+//ListByWebhook list the deliveries of the webhook, the latest first
+func (w *WebhookDeliveryRepo) ListByWebhook(eid, webhookID string, page, pageSize int) ([]*model.WebhookDelivery, int64, error) {
+	db := w.DB.Model(&model.WebhookDelivery{}).Where("eid=? and webhook_id=?", eid, webhookID)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	var list []*model.WebhookDelivery
+	if err := db.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list).Error; err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return list, total, nil
+}
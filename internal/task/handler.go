@@ -42,3 +42,9 @@ type UpdateKubernetesTaskHandler interface {
 	HandleMsg(ctx context.Context, createConfig types.UpdateKubernetesConfigMessage) error
 	HandleMessage(m *nsq.Message) error
 }
+
+//OperationTaskHandler runs the operation tasks of the clusters
+type OperationTaskHandler interface {
+	HandleMsg(ctx context.Context, config types.OperationTaskMessage) error
+	HandleMessage(m *nsq.Message) error
+}
@@ -30,7 +30,8 @@ import (
 	"github.com/rancher/rke/k8s"
 	"github.com/sirupsen/logrus"
 	apiv1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
-	"goodrain.com/cloud-adaptor/internal/adaptor/factory"
+	cloudadaptor "goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 	"goodrain.com/cloud-adaptor/internal/datastore"
 	"goodrain.com/cloud-adaptor/internal/operator"
 	"goodrain.com/cloud-adaptor/internal/repo"
@@ -57,136 +58,185 @@ func (c *InitRainbondCluster) rollback(step, message, status string) {
 	c.result <- apiv1.Message{StepType: step, Message: message, Status: status}
 }
 
+func (c *InitRainbondCluster) workflow() *Workflow {
+	var (
+		adaptor    cloudadaptor.RainbondClusterAdaptor
+		cluster    *v1alpha1.Cluster
+		kubeConfig *v1alpha1.KubeConfig
+		nodes      []v1.Node
+		initConfig *v1alpha1.RainbondInitConfig
+		rri        *operator.RainbondRegionInit
+	)
+	return NewWorkflow(c.rollback,
+		initAdaptorStep(c.config.Provider, c.config.AccessKey, c.config.SecretKey, &adaptor),
+		&Step{
+			Type:    apiv1.StepCheckCluster,
+			Timeout: time.Minute * 2,
+			Retry:   RetryPolicy{Attempts: 2, Interval: time.Second * 5},
+			Run: func(ctx context.Context) (string, error) {
+				var err error
+				cluster, kubeConfig, nodes, err = c.checkCluster(ctx, adaptor)
+				if err != nil {
+					return "", err
+				}
+				return c.config.ClusterID, nil
+			},
+		},
+		adaptorStep(c.config.Provider, InitRainbondClusterTask, func(ctx context.Context) (string, error) {
+			// select gateway and chaos node
+			gatewayNodes, chaosNodes := c.GetRainbondGatewayNodeAndChaosNodes(nodes)
+			recordResources(adaptor, c.result)
+			rollback := dropCancelledFailure(ctx, c.rollback)
+			if resumable, ok := adaptor.(cloudadaptor.ResumableInitConfigAdaptor); ok && len(c.config.Checkpoints) > 0 {
+				initConfig = resumable.ResumeRainbondInitConfig(c.config.EnterpriseID, cluster, gatewayNodes, chaosNodes, c.config.Checkpoints, rollback)
+			} else {
+				initConfig = adaptor.GetRainbondInitConfig(c.config.EnterpriseID, cluster, gatewayNodes, chaosNodes, rollback)
+			}
+			if initConfig == nil {
+				return "", fmt.Errorf("get rainbond init config failure")
+			}
+			initConfig.RainbondVersion = version.RainbondRegionVersion
+			rri = operator.NewRainbondRegionInit(*kubeConfig, repo.NewRainbondClusterConfigRepo(datastore.GetGDB()))
+			return "", nil
+		}),
+		&Step{
+			Type:       apiv1.StepInitRainbondRegionOperator,
+			Timeout:    time.Minute * 10,
+			Checkpoint: true,
+			Run: func(ctx context.Context) (string, error) {
+				if len(initConfig.EIPs) == 0 {
+					return "", Permanent(fmt.Errorf("can not select eip"))
+				}
+				if err := rri.InitRainbondRegion(initConfig); err != nil {
+					return "", err
+				}
+				return "", c.waitRegion(ctx, rri, func(status *v1alpha1.RainbondRegionStatus) bool {
+					return status.OperatorReady
+				})
+			},
+		},
+		&Step{
+			Type:       apiv1.StepInitRainbondRegionImageHub,
+			Timeout:    time.Minute * 10,
+			Checkpoint: true,
+			Run: func(ctx context.Context) (string, error) {
+				return "", c.waitRegion(ctx, rri, func(status *v1alpha1.RainbondRegionStatus) bool {
+					idx, condition := status.RainbondCluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionTypeImageRepository)
+					return idx != -1 && condition.Status == v1.ConditionTrue
+				})
+			},
+		},
+		&Step{
+			Type:       apiv1.StepInitRainbondRegionPackage,
+			Timeout:    time.Minute * 30,
+			Checkpoint: true,
+			Run: func(ctx context.Context) (string, error) {
+				return "", c.waitRegion(ctx, rri, func(status *v1alpha1.RainbondRegionStatus) bool {
+					for _, con := range status.RainbondPackage.Status.Conditions {
+						if con.Type == rainbondv1alpha1.Ready && con.Status == rainbondv1alpha1.Completed {
+							return true
+						}
+					}
+					return false
+				})
+			},
+		},
+		&Step{
+			Type:    apiv1.StepInitRainbondRegionRegionConfig,
+			Timeout: time.Minute * 10,
+			Run: func(ctx context.Context) (string, error) {
+				return "", c.waitRegion(ctx, rri, func(status *v1alpha1.RainbondRegionStatus) bool {
+					idx, condition := status.RainbondCluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionTypeRunning)
+					return idx != -1 && condition.Status == v1.ConditionTrue
+				})
+			},
+		},
+		&Step{
+			Type: apiv1.StepInitRainbondRegion,
+			Run: func(ctx context.Context) (string, error) {
+				return cluster.ClusterID, nil
+			},
+		},
+	).Resume(c.config.Checkpoints)
+}
+
 //Run run take time 214.10s
 func (c *InitRainbondCluster) Run(ctx context.Context) {
 	defer c.rollback("Close", "", "")
-	c.rollback("Init", "", "start")
-	// create adaptor
-	adaptor, err := factory.GetCloudFactory().GetRainbondClusterAdaptor(c.config.Provider, c.config.AccessKey, c.config.SecretKey)
-	if err != nil {
-		c.rollback("Init", fmt.Sprintf("create cloud adaptor failure %s", err.Error()), "failure")
-		return
-	}
+	c.workflow().Run(ctx)
+}
 
-	c.rollback("Init", "cloud adaptor create success", "success")
-	c.rollback("CheckCluster", "", "start")
+//Plan returns the step types of the task
+func (c *InitRainbondCluster) Plan() []string {
+	return c.workflow().Plan()
+}
+
+// checkCluster checks the cluster is running and can be connected
+func (c *InitRainbondCluster) checkCluster(ctx context.Context, adaptor cloudadaptor.RainbondClusterAdaptor) (*v1alpha1.Cluster, *v1alpha1.KubeConfig, []v1.Node, error) {
 	// get kubernetes cluster info
 	cluster, err := adaptor.DescribeCluster(c.config.EnterpriseID, c.config.ClusterID)
 	if err != nil {
-		cluster, err = adaptor.DescribeCluster(c.config.EnterpriseID, c.config.ClusterID)
-		if err != nil {
-			c.rollback("CheckCluster", err.Error(), "failure")
-			return
-		}
+		return nil, nil, nil, err
 	}
 	// check cluster status
 	if cluster.State != "running" {
-		c.rollback("CheckCluster", fmt.Sprintf("cluster status is %s,not support init rainbond", cluster.State), "failure")
-		return
+		return nil, nil, nil, Permanent(fmt.Errorf("cluster status is %s,not support init rainbond", cluster.State))
 	}
 	// check cluster version
 	if !versionutil.CheckVersion(cluster.KubernetesVersion) {
-		c.rollback("CheckCluster", fmt.Sprintf("current cluster version is %s, init rainbond support kubernetes version is 1.16.x-1.22.x", cluster.KubernetesVersion), "failure")
-		return
+		return nil, nil, nil, Permanent(fmt.Errorf("current cluster version is %s, init rainbond support kubernetes version is 1.16.x-1.22.x", cluster.KubernetesVersion))
 	}
 	// check cluster connection status
 	logrus.Infof("init kubernetes url %s", cluster.MasterURL)
 	if cluster.MasterURL.APIServerEndpoint == "" {
-		c.rollback("CheckCluster", "cluster api not open eip,not support init rainbond", "failure")
-		return
+		return nil, nil, nil, Permanent(fmt.Errorf("cluster api not open eip,not support init rainbond"))
 	}
 
 	kubeConfig, err := adaptor.GetKubeConfig(c.config.EnterpriseID, c.config.ClusterID)
 	if err != nil {
-		kubeConfig, err = adaptor.GetKubeConfig(c.config.EnterpriseID, c.config.ClusterID)
-		if err != nil {
-			c.rollback("CheckCluster", fmt.Sprintf("get kube config failure %s", err.Error()), "failure")
-			return
-		}
+		return nil, nil, nil, fmt.Errorf("get kube config failure %s", err.Error())
 	}
 
 	// check cluster not init rainbond
 	coreClient, _, err := kubeConfig.GetKubeClient()
 	if err != nil {
-		c.rollback("CheckCluster", fmt.Sprintf("get kube config failure %s", err.Error()), "failure")
-		return
+		return nil, nil, nil, Permanent(fmt.Errorf("get kube config failure %s", err.Error()))
 	}
 
 	// get cluster node lists
 	getctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
 	nodes, err := coreClient.CoreV1().Nodes().List(getctx, metav1.ListOptions{})
 	if err != nil {
-		nodes, err = coreClient.CoreV1().Nodes().List(getctx, metav1.ListOptions{})
-		cancel()
-		if err != nil {
-			logrus.Errorf("get kubernetes cluster node failure %s", err.Error())
-			c.rollback("CheckCluster", "cluster node list can not found, please check cluster public access and account authorization", "failure")
-			return
-		}
-	} else {
-		cancel()
+		logrus.Errorf("get kubernetes cluster node failure %s", err.Error())
+		return nil, nil, nil, fmt.Errorf("cluster node list can not found, please check cluster public access and account authorization")
 	}
 	if len(nodes.Items) == 0 {
-		c.rollback("CheckCluster", "node num is 0, can not init rainbond", "failure")
-		return
-	}
-	c.rollback("CheckCluster", c.config.ClusterID, "success")
-
-	// select gateway and chaos node
-	gatewayNodes, chaosNodes := c.GetRainbondGatewayNodeAndChaosNodes(nodes.Items)
-	initConfig := adaptor.GetRainbondInitConfig(c.config.EnterpriseID, cluster, gatewayNodes, chaosNodes, c.rollback)
-	initConfig.RainbondVersion = version.RainbondRegionVersion
-	// init rainbond
-	c.rollback("InitRainbondRegionOperator", "", "start")
-	if len(initConfig.EIPs) == 0 {
-		c.rollback("InitRainbondRegionOperator", "can not select eip", "failure")
-		return
+		return nil, nil, nil, Permanent(fmt.Errorf("node num is 0, can not init rainbond"))
 	}
+	return cluster, kubeConfig, nodes.Items, nil
+}
 
-	rri := operator.NewRainbondRegionInit(*kubeConfig, repo.NewRainbondClusterConfigRepo(datastore.GetGDB()))
-	if err := rri.InitRainbondRegion(initConfig); err != nil {
-		c.rollback("InitRainbondRegionOperator", err.Error(), "failure")
-		return
-	}
+// waitRegion waits until the rainbond region status is ready
+func (c *InitRainbondCluster) waitRegion(ctx context.Context, rri *operator.RainbondRegionInit, ready func(status *v1alpha1.RainbondRegionStatus) bool) error {
 	ticker := time.NewTicker(time.Second * 5)
-	timer := time.NewTimer(time.Minute * 60)
-	defer timer.Stop()
 	defer ticker.Stop()
-	var operatorMessage, imageHubMessage, packageMessage, apiReadyMessage bool
 	for {
 		select {
 		case <-ctx.Done():
-			c.rollback("InitRainbondRegion", "context cancel", "failure")
-			return
+			return ctx.Err()
 		case <-ticker.C:
-		case <-timer.C:
-			c.rollback("InitRainbondRegion", "waiting rainbond region ready timeout", "failure")
-			return
 		}
-		status, err := rri.GetRainbondRegionStatus(initConfig.ClusterID)
+		status, err := rri.GetRainbondRegionStatus(c.config.ClusterID)
 		if err != nil {
 			if k8sErrors.IsNotFound(err) {
-				c.rollback("InitRainbondRegion", err.Error(), "failure")
-				return
+				return Permanent(err)
 			}
 			logrus.Errorf("get rainbond region status failure %s", err.Error())
 		}
 		if status == nil {
 			continue
 		}
-		if status.OperatorReady && !operatorMessage {
-			c.rollback("InitRainbondRegionOperator", "", "success")
-			c.rollback("InitRainbondRegionImageHub", "", "start")
-			operatorMessage = true
-			continue
-		}
-
-		if idx, condition := status.RainbondCluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionTypeImageRepository); !imageHubMessage && idx != -1 && condition.Status == v1.ConditionTrue {
-			c.rollback("InitRainbondRegionImageHub", "", "success")
-			c.rollback("InitRainbondRegionPackage", "", "start")
-			imageHubMessage = true
-			continue
-		}
 		statusStr := fmt.Sprintf("Push Images:%d/%d\t", len(status.RainbondPackage.Status.ImagesPushed), status.RainbondPackage.Status.ImagesNumber)
 		for _, con := range status.RainbondCluster.Status.Conditions {
 			if con.Status == v1.ConditionTrue {
@@ -195,24 +245,11 @@ func (c *InitRainbondCluster) Run(ctx context.Context) {
 				statusStr += fmt.Sprintf("%s=>%s=>%s=>%s;\t", con.Type, con.Status, con.Reason, con.Message)
 			}
 		}
-		logrus.Infof("cluster %s states: %s", cluster.Name, statusStr)
-
-		for _, con := range status.RainbondPackage.Status.Conditions {
-			if con.Type == rainbondv1alpha1.Ready && con.Status == rainbondv1alpha1.Completed && !packageMessage {
-				c.rollback("InitRainbondRegionPackage", "", "success")
-				c.rollback("InitRainbondRegionRegionConfig", "", "start")
-				packageMessage = true
-			}
-			continue
-		}
-
-		idx, condition := status.RainbondCluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionTypeRunning)
-		if idx != -1 && condition.Status == v1.ConditionTrue && packageMessage && !apiReadyMessage {
-			apiReadyMessage = true
-			break
+		logrus.Infof("cluster %s states: %s", c.config.ClusterID, statusStr)
+		if ready(status) {
+			return nil
 		}
 	}
-	c.rollback("InitRainbondRegion", cluster.ClusterID, "success")
 }
 
 //GetRainbondGatewayNodeAndChaosNodes get gateway nodes
@@ -306,7 +343,7 @@ func (h *cloudInitTaskHandler) HandleMsg(ctx context.Context, initConfig types.I
 	if err != nil {
 		logrus.Errorf("create task failure %s", err.Error())
 		h.eventHandler.HandleEvent(initConfig.GetEvent(&apiv1.Message{
-			StepType: "CreateTask",
+			StepType: apiv1.StepCreateTask,
 			Message:  err.Error(),
 			Status:   "failure",
 		}))
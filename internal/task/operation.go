@@ -0,0 +1,66 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/internal/usecase"
+)
+
+type operationTaskHandler struct {
+	clusterUsecase *usecase.ClusterUsecase
+}
+
+// NewOperationTaskHandler -
+func NewOperationTaskHandler(clusterUsecase *usecase.ClusterUsecase) OperationTaskHandler {
+	return &operationTaskHandler{
+		clusterUsecase: clusterUsecase,
+	}
+}
+
+// HandleMsg -
+func (h *operationTaskHandler) HandleMsg(ctx context.Context, config types.OperationTaskMessage) error {
+	// Asynchronous execution to prevent message consumption from taking too long.
+	go h.clusterUsecase.RunOperationTask(ctx, config)
+	return nil
+}
+
+// HandleMessage implements the Handler interface.
+// Returning a non-nil error will automatically send a REQ command to NSQ to re-queue the message.
+func (h *operationTaskHandler) HandleMessage(m *nsq.Message) error {
+	if len(m.Body) == 0 {
+		// Returning nil will automatically send a FIN command to NSQ to mark the message as processed.
+		return nil
+	}
+	var config types.OperationTaskMessage
+	if err := json.Unmarshal(m.Body, &config); err != nil {
+		logrus.Errorf("unmarshal operation task message failure %s", err.Error())
+		return nil
+	}
+	if err := h.HandleMsg(context.Background(), config); err != nil {
+		logrus.Errorf("handle operation task message failure %s", err.Error())
+		return nil
+	}
+	return nil
+}
@@ -24,17 +24,21 @@ import (
 
 	"github.com/google/wire"
 	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	cloudadaptor "goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/factory"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/nsqc/producer"
 	"goodrain.com/cloud-adaptor/internal/types"
 )
 
 // ProviderSet is task providers.
-var ProviderSet = wire.NewSet(NewCreateKubernetesTaskHandler, NewCloudInitTaskHandler, NewCloudUpdateTaskHandler)
+var ProviderSet = wire.NewSet(NewCreateKubernetesTaskHandler, NewCloudInitTaskHandler, NewCloudUpdateTaskHandler, NewOperationTaskHandler, NewTaskPlanner)
 
 //Task Asynchronous tasks
 type Task interface {
 	Run(ctx context.Context)
 	GetChan() chan v1.Message
+	Plan() []string
 }
 
 //Type task type
@@ -54,22 +58,97 @@ func CreateTask(taskType Type, config interface{}) (Task, error) {
 	switch taskType {
 	case CreateKubernetesTask:
 		cconfig, ok := config.(*v1alpha1.KubernetesClusterConfig)
-		if !ok {
+		if !ok || cconfig == nil {
 			return nil, fmt.Errorf("config must be *v1alpha1.KubernetesClusterConfig")
 		}
 		return &CreateKubernetesCluster{result: make(chan v1.Message, 10), config: cconfig}, nil
 	case InitRainbondClusterTask:
 		cconfig, ok := config.(*types.InitRainbondConfig)
-		if !ok {
+		if !ok || cconfig == nil {
 			return nil, fmt.Errorf("config must be *InitRainbondConfig")
 		}
 		return &InitRainbondCluster{result: make(chan v1.Message, 10), config: cconfig}, nil
 	case UpdateKubernetesTask:
 		cconfig, ok := config.(*v1alpha1.ExpansionNode)
-		if !ok {
+		if !ok || cconfig == nil {
 			return nil, fmt.Errorf("config must be *v1alpha1.ExpansionNode")
 		}
 		return &UpdateKubernetesCluster{result: make(chan v1.Message, 10), config: cconfig}, nil
 	}
 	return nil, fmt.Errorf("task type not support")
 }
+
+//taskPlanner plans the steps of the task messages from their workflows
+type taskPlanner struct{}
+
+//NewTaskPlanner new task planner
+func NewTaskPlanner() producer.TaskPlanner {
+	return &taskPlanner{}
+}
+
+//Plan returns the step types of the task message
+func (t *taskPlanner) Plan(taskConfig interface{}) ([]string, error) {
+	var task Task
+	var err error
+	switch msg := taskConfig.(type) {
+	case types.KubernetesConfigMessage:
+		task, err = CreateTask(CreateKubernetesTask, msg.KubernetesConfig)
+	case types.InitRainbondConfigMessage:
+		task, err = CreateTask(InitRainbondClusterTask, msg.InitRainbondConfig)
+	case types.UpdateKubernetesConfigMessage:
+		task, err = CreateTask(UpdateKubernetesTask, msg.Config)
+	case types.OperationTaskMessage:
+		// the steps of the operation tasks are reported by the adaptors
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("task type not support")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return task.Plan(), nil
+}
+
+// initAdaptorStep creates the cloud adaptor of the provider
+func initAdaptorStep(provider, accessKey, secretKey string, adaptor *cloudadaptor.RainbondClusterAdaptor) *Step {
+	return &Step{
+		Type: v1.StepInit,
+		Run: func(ctx context.Context) (string, error) {
+			var err error
+			*adaptor, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(provider, accessKey, secretKey)
+			if err != nil {
+				return "", Permanent(fmt.Errorf("create cloud adaptor failure %s", err.Error()))
+			}
+			return "cloud adaptor create success", nil
+		},
+	}
+}
+
+// recordResources reports the cloud resources created or released by the adaptor through the messages of the task.
+func recordResources(adaptor cloudadaptor.RainbondClusterAdaptor, result chan v1.Message) {
+	if recordable, ok := adaptor.(cloudadaptor.ResourceRecordable); ok {
+		recordable.SetResourceRecorder(func(resource *v1alpha1.CloudResource) {
+			result <- v1.Message{StepType: v1.StepRecordResource, Status: resource.Status, Resource: resource}
+		})
+	}
+}
+
+// dropCancelledFailure drops the failure events reported by the cloud adaptor after the task is cancelled,
+// the cancelled event emitted by the workflow is the result of the task.
+func dropCancelledFailure(ctx context.Context, rollback func(step, message, status string)) func(step, message, status string) {
+	return func(step, message, status string) {
+		if status == "failure" && ctx.Err() == context.Canceled {
+			return
+		}
+		rollback(step, message, status)
+	}
+}
+
+// cancelled emits the cancelled event if the task has been cancelled.
+func cancelled(ctx context.Context, rollback func(step, message, status string)) bool {
+	if ctx.Err() != context.Canceled {
+		return false
+	}
+	rollback(v1.StepCancelled, "the task is cancelled", "cancelled")
+	return true
+}
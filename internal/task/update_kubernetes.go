@@ -27,7 +27,7 @@ import (
 	"github.com/nsqio/go-nsq"
 	"github.com/sirupsen/logrus"
 	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
-	"goodrain.com/cloud-adaptor/internal/adaptor/factory"
+	cloudadaptor "goodrain.com/cloud-adaptor/internal/adaptor"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 	"goodrain.com/cloud-adaptor/internal/usecase"
 	"goodrain.com/cloud-adaptor/internal/types"
@@ -47,19 +47,31 @@ func (c *UpdateKubernetesCluster) rollback(step, message, status string) {
 	c.result <- v1.Message{StepType: step, Message: message, Status: status}
 }
 
-//Run run
+//workflow builds the steps of the task, which initializes the adaptor of the provider and expands the nodes of the kubernetes cluster by the adaptor
+func (c *UpdateKubernetesCluster) workflow() *Workflow {
+	var adaptor cloudadaptor.RainbondClusterAdaptor
+	return NewWorkflow(c.rollback,
+		initAdaptorStep(c.config.Provider, c.config.AccessKey, c.config.SecretKey, &adaptor),
+		adaptorStep(c.config.Provider, UpdateKubernetesTask, func(ctx context.Context) (string, error) {
+			recordResources(adaptor, c.result)
+			cluster := adaptor.ExpansionNode(ctx, c.config.EnterpriseID, c.config, dropCancelledFailure(ctx, c.rollback))
+			if cluster == nil {
+				return "", fmt.Errorf("update kubernetes cluster failure")
+			}
+			return cluster.ClusterID, nil
+		}),
+	)
+}
+
+//Run run update kubernetes cluster
 func (c *UpdateKubernetesCluster) Run(ctx context.Context) {
 	defer c.rollback("Close", "", "")
-	c.rollback("Init", "", "start")
-	// create adaptor
-	adaptor, err := factory.GetCloudFactory().GetRainbondClusterAdaptor(c.config.Provider, c.config.AccessKey, c.config.SecretKey)
-	if err != nil {
-		c.rollback("Init", fmt.Sprintf("create cloud adaptor failure %s", err.Error()), "failure")
-		return
-	}
-	c.rollback("Init", "cloud adaptor create success", "success")
-	// update cluster
-	adaptor.ExpansionNode(ctx, c.config.EnterpriseID, c.config, c.rollback)
+	c.workflow().Run(ctx)
+}
+
+//Plan returns the step types of the task
+func (c *UpdateKubernetesCluster) Plan() []string {
+	return c.workflow().Plan()
 }
 
 //GetChan get message chan
@@ -90,7 +102,7 @@ func (h *cloudUpdateTaskHandler) HandleMsg(ctx context.Context, config types.Upd
 	if err != nil {
 		logrus.Errorf("create task failure %s", err.Error())
 		h.eventHandler.HandleEvent(config.GetEvent(&v1.Message{
-			StepType: "CreateTask",
+			StepType: v1.StepCreateTask,
 			Message:  err.Error(),
 			Status:   "failure",
 		}))
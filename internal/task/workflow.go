@@ -0,0 +1,222 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+// Step is a step of the task workflow
+type Step struct {
+	// Type is the step type of the events
+	Type string
+	// Reported means the events are reported by Run itself, such as the steps of the cloud adaptor.
+	// Steps are the reported step types for the plan.
+	Reported bool
+	Steps    []string
+	// Timeout of one attempt, no timeout if it is zero
+	Timeout time.Duration
+	Retry   RetryPolicy
+	// Checkpoint skips the step if it succeeded in the resumed task
+	Checkpoint bool
+	// Run returns the message of the success event
+	Run func(ctx context.Context) (string, error)
+	// Compensate undoes the step when a later step failed
+	Compensate func(ctx context.Context) error
+}
+
+// RetryPolicy retry policy of the step
+type RetryPolicy struct {
+	// Attempts is the max number of attempts, the step runs once if it is less than 2
+	Attempts int
+	Interval time.Duration
+}
+
+// permanentError is not retried
+type permanentError struct {
+	error
+}
+
+// Permanent marks the error should not be retried
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.error
+}
+
+// Workflow runs the steps in order, emits the start, success and failure events of the steps,
+// and compensates the succeeded steps in reverse order when a step failed.
+type Workflow struct {
+	steps       []*Step
+	checkpoints v1alpha1.Checkpoints
+	emit        func(step, message, status string)
+}
+
+// NewWorkflow new workflow
+func NewWorkflow(emit func(step, message, status string), steps ...*Step) *Workflow {
+	return &Workflow{steps: steps, emit: emit}
+}
+
+// Resume skips the checkpoint steps succeeded in the resumed task
+func (w *Workflow) Resume(checkpoints v1alpha1.Checkpoints) *Workflow {
+	w.checkpoints = checkpoints
+	return w
+}
+
+// Plan returns the step types in order
+func (w *Workflow) Plan() []string {
+	var plan []string
+	for _, step := range w.steps {
+		if step.Reported {
+			plan = append(plan, step.Steps...)
+			continue
+		}
+		plan = append(plan, step.Type)
+	}
+	return plan
+}
+
+// Run runs the workflow, returns the error of the failed step.
+func (w *Workflow) Run(ctx context.Context) error {
+	var done []*Step
+	for _, step := range w.steps {
+		if err := ctx.Err(); err != nil {
+			w.compensate(done)
+			cancelled(ctx, w.emit)
+			return err
+		}
+		if step.Checkpoint && w.checkpoints.Done(step.Type) {
+			w.emit(step.Type, w.checkpoints[step.Type], "success")
+			continue
+		}
+		if !step.Reported {
+			w.emit(step.Type, "", "start")
+		}
+		message, err := w.run(ctx, step)
+		if err != nil {
+			if !step.Reported && ctx.Err() != context.Canceled {
+				w.emit(step.Type, err.Error(), "failure")
+			}
+			w.compensate(done)
+			cancelled(ctx, w.emit)
+			return err
+		}
+		if !step.Reported {
+			w.emit(step.Type, message, "success")
+		}
+		done = append(done, step)
+	}
+	return nil
+}
+
+func (w *Workflow) run(ctx context.Context, step *Step) (string, error) {
+	attempts := step.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			logrus.Warningf("step %s failure %s, retry %d/%d", step.Type, err.Error(), i, attempts-1)
+			select {
+			case <-ctx.Done():
+				return "", err
+			case <-time.After(step.Retry.Interval):
+			}
+		}
+		var message string
+		message, err = w.attempt(ctx, step)
+		if err == nil {
+			return message, nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || ctx.Err() != nil {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+func (w *Workflow) attempt(ctx context.Context, step *Step) (string, error) {
+	if step.Timeout <= 0 {
+		return step.Run(ctx)
+	}
+	stepCtx, cancel := context.WithTimeout(ctx, step.Timeout)
+	defer cancel()
+	message, err := step.Run(stepCtx)
+	if err != nil && stepCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return "", fmt.Errorf("%s timeout after %s", step.Type, step.Timeout)
+	}
+	return message, err
+}
+
+func (w *Workflow) compensate(done []*Step) {
+	// the task context may be cancelled, compensation runs in a new context
+	ctx := context.Background()
+	for i := len(done) - 1; i >= 0; i-- {
+		step := done[i]
+		if step.Compensate == nil {
+			continue
+		}
+		if err := step.Compensate(ctx); err != nil {
+			logrus.Errorf("compensate step %s failure %s", step.Type, err.Error())
+			continue
+		}
+		logrus.Infof("step %s compensated", step.Type)
+	}
+}
+
+// adaptorSteps are the steps reported by the cloud adaptors
+var adaptorSteps = map[string]map[Type][]string{
+	"rke": {
+		CreateKubernetesTask: {v1.StepInitClusterConfig, v1.StepPreflightCheck, v1.StepInstallKubernetes},
+		UpdateKubernetesTask: {v1.StepInitClusterConfig, v1.StepUpdateKubernetes},
+	},
+	"ack": {
+		CreateKubernetesTask:    {v1.StepAllocateResource, v1.StepSelectZone, v1.StepCreateCluster},
+		UpdateKubernetesTask:    {v1.StepInitClusterConfig, v1.StepUpdateKubernetes},
+		InitRainbondClusterTask: {v1.StepCreateRDS, v1.StepCreateNAS, v1.StepCreateNASMount, v1.StepCreateLoadBalancer, v1.StepBoundLoadBalancer, v1.StepSetSecurityGroup},
+	},
+	"tke": {
+		CreateKubernetesTask:    {v1.StepAllocateResource, v1.StepSelectZone, v1.StepCreateCluster},
+		InitRainbondClusterTask: {v1.StepCreateRDS, v1.StepCreateNAS, v1.StepCreateNASMount, v1.StepCreateLoadBalancer, v1.StepBoundLoadBalancer},
+	},
+	"custom": {
+		CreateKubernetesTask: {v1.StepCreateCluster},
+	},
+}
+
+// adaptorStep is the step runs the cloud adaptor, its events are reported by the adaptor.
+func adaptorStep(provider string, taskType Type, run func(ctx context.Context) (string, error)) *Step {
+	return &Step{
+		Type:     string(taskType),
+		Reported: true,
+		Steps:    adaptorSteps[provider][taskType],
+		Run:      run,
+	}
+}
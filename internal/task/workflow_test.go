@@ -0,0 +1,133 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) emit(step, message, status string) {
+	r.events = append(r.events, step+":"+status)
+}
+
+func TestWorkflow(t *testing.T) {
+	var attempts int
+	var compensated []string
+	rec := &recorder{}
+	wf := NewWorkflow(rec.emit,
+		&Step{
+			Type:       "A",
+			Run:        func(ctx context.Context) (string, error) { return "", nil },
+			Compensate: func(ctx context.Context) error { compensated = append(compensated, "A"); return nil },
+		},
+		&Step{
+			Type:     "B",
+			Reported: true,
+			Steps:    []string{"B1", "B2"},
+			Run: func(ctx context.Context) (string, error) {
+				rec.emit("B1", "", "success")
+				return "", nil
+			},
+			Compensate: func(ctx context.Context) error { compensated = append(compensated, "B"); return nil },
+		},
+		&Step{
+			Type:  "C",
+			Retry: RetryPolicy{Attempts: 3},
+			Run: func(ctx context.Context) (string, error) {
+				attempts++
+				if attempts < 3 {
+					return "", fmt.Errorf("retry")
+				}
+				return "", Permanent(fmt.Errorf("stop"))
+			},
+		},
+		&Step{Type: "D", Run: func(ctx context.Context) (string, error) { return "", nil }},
+	)
+	if plan := wf.Plan(); !reflect.DeepEqual(plan, []string{"A", "B1", "B2", "C", "D"}) {
+		t.Fatalf("unexpected plan %v", plan)
+	}
+	if err := wf.Run(context.Background()); err == nil {
+		t.Fatal("expected the workflow failed")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+	if want := []string{"A:start", "A:success", "B1:success", "C:start", "C:failure"}; !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("expected events %v, got %v", want, rec.events)
+	}
+	if want := []string{"B", "A"}; !reflect.DeepEqual(compensated, want) {
+		t.Errorf("expected compensated %v, got %v", want, compensated)
+	}
+}
+
+func TestWorkflowTimeoutAndResume(t *testing.T) {
+	rec := &recorder{}
+	err := NewWorkflow(rec.emit,
+		&Step{Type: "A", Checkpoint: true, Run: func(ctx context.Context) (string, error) {
+			t.Error("the checkpoint step should be skipped")
+			return "", nil
+		}},
+		&Step{Type: "B", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}},
+	).Resume(v1alpha1.Checkpoints{"A": "a"}).Run(context.Background())
+	if err == nil || err.Error() != "B timeout after 10ms" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []string{"A:success", "B:start", "B:failure"}; !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("expected events %v, got %v", want, rec.events)
+	}
+
+	rec = &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	NewWorkflow(rec.emit,
+		&Step{Type: "A", Run: func(ctx context.Context) (string, error) { cancel(); return "", ctx.Err() }},
+	).Run(ctx)
+	if want := []string{"A:start", "Cancelled:cancelled"}; !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("expected events %v, got %v", want, rec.events)
+	}
+}
+
+func TestWorkflowCancelledAdaptorStep(t *testing.T) {
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	NewWorkflow(rec.emit,
+		adaptorStep("rke", CreateKubernetesTask, func(ctx context.Context) (string, error) {
+			rollback := dropCancelledFailure(ctx, rec.emit)
+			rollback("InstallKubernetes", "", "start")
+			cancel()
+			rollback("InstallKubernetes", "context canceled", "failure")
+			return "", ctx.Err()
+		}),
+	).Run(ctx)
+	if want := []string{"InstallKubernetes:start", "Cancelled:cancelled"}; !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("expected events %v, got %v", want, rec.events)
+	}
+}
@@ -19,6 +19,8 @@
 package types
 
 import (
+	"encoding/json"
+
 	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
 	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
 )
@@ -30,6 +32,8 @@ type InitRainbondConfig struct {
 	AccessKey    string `json:"access_key"`
 	SecretKey    string `json:"secret_key"`
 	Provider     string `json:"provider"`
+	// Checkpoints of the task which is resumed
+	Checkpoints v1alpha1.Checkpoints `json:"checkpoints,omitempty"`
 }
 
 //KubernetesConfigMessage nsq message
@@ -79,3 +83,19 @@ func (i UpdateKubernetesConfigMessage) GetEvent(m *v1.Message) v1.EventMessage {
 		Message:      m,
 	}
 }
+
+//OperationTaskMessage the message of the operation task, the params are decoded by the runner of the task type
+type OperationTaskMessage struct {
+	EnterpriseID string          `json:"enterprise_id,omitempty"`
+	TaskID       string          `json:"task_id,omitempty"`
+	Params       json.RawMessage `json:"params,omitempty"`
+}
+
+//GetEvent get event
+func (i OperationTaskMessage) GetEvent(m *v1.Message) v1.EventMessage {
+	return v1.EventMessage{
+		EnterpriseID: i.EnterpriseID,
+		TaskID:       i.TaskID,
+		Message:      m,
+	}
+}
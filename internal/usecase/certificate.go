@@ -0,0 +1,80 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+func (c *ClusterUsecase) getCertificateRotator(eid, providerName string) (adaptor.CertificateRotator, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilityRotateCertificates); err != nil {
+		return nil, err
+	}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	rotator, ok := ad.(adaptor.CertificateRotator)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return rotator, nil
+}
+
+// ListCertificates returns the certificates of the cluster with their expiry.
+func (c *ClusterUsecase) ListCertificates(eid, clusterID, providerName string) ([]*v1alpha1.Certificate, error) {
+	rotator, err := c.getCertificateRotator(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return rotator.ListCertificates(eid, clusterID)
+}
+
+// RotateCertificates rotates the certificates of the cluster in background.
+func (c *ClusterUsecase) RotateCertificates(eid, clusterID string, req v1.RotateCertificatesReq) (*model.OperationTask, error) {
+	if _, err := c.getCertificateRotator(eid, req.ProviderName); err != nil {
+		return nil, err
+	}
+	options := v1alpha1.RotateCertificatesOptions{RotateCA: req.RotateCA}
+	if req.Service != "" {
+		options.Services = []string{req.Service}
+	}
+	return c.runClusterStateTask(eid, clusterID, req.ProviderName, domain.ClusterTaskTypeRotateCertificates, options)
+}
+
+func (c *ClusterUsecase) runRotateCertificates(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var options v1alpha1.RotateCertificatesOptions
+	if err := decodeOperationParams(params, &options); err != nil {
+		return err
+	}
+	rotator, err := c.getCertificateRotator(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return rotator.RotateCertificates(ctx, task.EnterpriseID, task.ClusterID, options, rollback)
+}
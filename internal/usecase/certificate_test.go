@@ -0,0 +1,75 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// rotatorAdaptor records the options of the last rotation
+type rotatorAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	options v1alpha1.RotateCertificatesOptions
+}
+
+func (r *rotatorAdaptor) ListCertificates(eid, clusterID string) ([]*v1alpha1.Certificate, error) {
+	return nil, nil
+}
+
+func (r *rotatorAdaptor) RotateCertificates(ctx context.Context, eid, clusterID string, options v1alpha1.RotateCertificatesOptions, rollback func(step, message, status string)) error {
+	r.options = options
+	rollback(v1.StepInitClusterConfig, "", "start")
+	rollback(v1.StepInitClusterConfig, "", "success")
+	return nil
+}
+
+func TestRotateCertificates(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	rotator := &rotatorAdaptor{}
+	registerTestProvider(t, "test-rotator", adaptor.InterfaceCertificateRotator, adaptor.CapabilityRotateCertificates, rotator)
+
+	task, err := c.RotateCertificates("eid", "cluster", v1.RotateCertificatesReq{ProviderName: "test-rotator", Service: "kubelet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	last := events[len(events)-1]
+	if last.StepType != v1.StepRotateCertificates || last.Status != "success" {
+		t.Errorf("expect the task is success, the last event is %s %s", last.StepType, last.Status)
+	}
+	if len(rotator.options.Services) != 1 || rotator.options.Services[0] != "kubelet" || rotator.options.RotateCA {
+		t.Errorf("unexpected options %+v", rotator.options)
+	}
+
+	// the certificates can not be rotated while the cluster is upgrading
+	if _, err := c.createOperationTask("eid", "cluster", "test-rotator", domain.ClusterTaskTypeUpgradeKubernetes); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RotateCertificates("eid", "cluster", v1.RotateCertificatesReq{ProviderName: "test-rotator"}); errors.Cause(err) != bcode.ErrLastKubernetesTaskNotComplete {
+		t.Errorf("expected ErrLastKubernetesTaskNotComplete, got %v", err)
+	}
+}
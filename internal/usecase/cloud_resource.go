@@ -0,0 +1,57 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+)
+
+// recordResource saves the cloud resource reported by the adaptor in the task. The resources created
+// before the kubernetes cluster, such as vpc, belong to the cluster created by the same task.
+func (c *ClusterUsecase) recordResource(eid, taskID string, resource *v1alpha1.CloudResource) error {
+	tx := c.DB.Begin()
+	resourceRepo := c.cloudResourceRepo.Transaction(tx)
+	if err := resourceRepo.Save(&model.CloudResource{
+		Provider:     resource.Provider,
+		RegionID:     resource.RegionID,
+		ResourceType: resource.ResourceType,
+		ResourceID:   resource.ResourceID,
+		Name:         resource.Name,
+		ClusterID:    resource.ClusterID,
+		EnterpriseID: eid,
+		TaskID:       taskID,
+		Status:       resource.Status,
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if resource.ResourceType == v1alpha1.ResourceTypeKubernetes && resource.ClusterID != "" {
+		if err := resourceRepo.SetClusterID(eid, taskID, resource.ClusterID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// ListCloudResources list the cloud resources created by cloud-adaptor for the cluster
+func (c *ClusterUsecase) ListCloudResources(eid, clusterID string) ([]*model.CloudResource, error) {
+	return c.cloudResourceRepo.ListByCluster(eid, clusterID)
+}
@@ -0,0 +1,68 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"testing"
+
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+)
+
+func TestRecordCloudResource(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	record := func(taskID string, resource *v1alpha1.CloudResource) {
+		if _, err := c.CreateTaskEvent(&v1.EventMessage{EnterpriseID: "eid", TaskID: taskID, Message: &v1.Message{
+			StepType: v1.StepRecordResource, Status: resource.Status, Resource: resource,
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the vpc is created before the cluster
+	record("create", &v1alpha1.CloudResource{Provider: "ack", RegionID: "cn-hangzhou", ResourceType: v1alpha1.ResourceTypeVPC,
+		ResourceID: "vpc-1", Status: v1alpha1.ResourceStatusCreated})
+	record("create", &v1alpha1.CloudResource{Provider: "ack", RegionID: "cn-hangzhou", ResourceType: v1alpha1.ResourceTypeKubernetes,
+		ResourceID: "cluster", ClusterID: "cluster", Status: v1alpha1.ResourceStatusCreated})
+	record("init", &v1alpha1.CloudResource{Provider: "ack", RegionID: "cn-hangzhou", ResourceType: v1alpha1.ResourceTypeRDS,
+		ResourceID: "rm-1", ClusterID: "cluster", Status: v1alpha1.ResourceStatusCreated})
+	record("release", &v1alpha1.CloudResource{Provider: "ack", RegionID: "cn-hangzhou", ResourceType: v1alpha1.ResourceTypeRDS,
+		ResourceID: "rm-1", ClusterID: "cluster", Status: v1alpha1.ResourceStatusReleased})
+
+	resources, err := c.ListCloudResources("eid", "cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 3 {
+		t.Fatalf("expected 3 resources, got %d", len(resources))
+	}
+	if resources[0].ResourceID != "vpc-1" || resources[0].TaskID != "create" {
+		t.Errorf("expected the vpc belongs to the cluster, got %+v", resources[0])
+	}
+	if resources[2].Status != v1alpha1.ResourceStatusReleased || resources[2].TaskID != "init" {
+		t.Errorf("expected the rds created by init is released, got %+v", resources[2])
+	}
+	// the resources are not saved as events
+	events, err := c.TaskEventRepo.ListEvent("eid", "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected no event, got %d", len(events))
+	}
+}
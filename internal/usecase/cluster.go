@@ -23,7 +23,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"sort"
 	"strconv"
@@ -48,9 +47,9 @@ import (
 	"goodrain.com/cloud-adaptor/internal/repo"
 	"goodrain.com/cloud-adaptor/internal/types"
 	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
 	"goodrain.com/cloud-adaptor/pkg/util/constants"
 	"goodrain.com/cloud-adaptor/pkg/util/md5util"
-	"goodrain.com/cloud-adaptor/pkg/util/ssh"
 	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
 	"gopkg.in/yaml.v2"
 	"gorm.io/gorm"
@@ -71,55 +70,121 @@ type ClusterUsecase struct {
 	InitRainbondTaskRepo      repo.InitRainbondTaskRepository
 	UpdateKubernetesTaskRepo  repo.UpdateKubernetesTaskRepository
 	TaskEventRepo             repo.TaskEventRepository
+	taskResultRepo            repo.TaskResultRepository
+	TaskMessageRepo           repo.TaskMessageRepository
 	RainbondClusterConfigRepo repo.RainbondClusterConfigRepository
 	rkeClusterRepo            repo.RKEClusterRepository
 	customClusterRepo         repo.CustomClusterRepository
+	operationTaskRepo         repo.OperationTaskRepository
+	sshKeyRepo                repo.SSHKeyRepository
+	sshBastionRepo            repo.SSHBastionRepository
+	initNodeTokenRepo         repo.InitNodeTokenRepository
+	clusterHealthRepo         repo.ClusterHealthRepository
+	leaseRepo                 repo.LeaseRepository
+	cloudResourceRepo         repo.CloudResourceRepository
+	rkeStateStore             blobstore.Store
+	eventBroker               *taskEventBroker
+	webhook                   *WebhookUsecase
+}
+
+// ClusterUsecaseDeps the dependencies of the cluster usecase, they are injected by wire.
+type ClusterUsecaseDeps struct {
+	DB                        *gorm.DB
+	TaskProducer              producer.TaskProducer
+	CloudAccessKeyRepo        repo.CloudAccesskeyRepository
+	CreateKubernetesTaskRepo  repo.CreateKubernetesTaskRepository
+	InitRainbondTaskRepo      repo.InitRainbondTaskRepository
+	UpdateKubernetesTaskRepo  repo.UpdateKubernetesTaskRepository
+	TaskEventRepo             repo.TaskEventRepository
+	TaskResultRepo            repo.TaskResultRepository
+	TaskMessageRepo           repo.TaskMessageRepository
+	RainbondClusterConfigRepo repo.RainbondClusterConfigRepository
+	RKEClusterRepo            repo.RKEClusterRepository
+	CustomClusterRepo         repo.CustomClusterRepository
+	OperationTaskRepo         repo.OperationTaskRepository
+	CloudResourceRepo         repo.CloudResourceRepository
+	RKEStateStore             blobstore.Store
+	SSHKeyRepo                repo.SSHKeyRepository
+	SSHBastionRepo            repo.SSHBastionRepository
+	InitNodeTokenRepo         repo.InitNodeTokenRepository
+	ClusterHealthRepo         repo.ClusterHealthRepository
+	LeaseRepo                 repo.LeaseRepository
+	WebhookUsecase            *WebhookUsecase
 }
 
 // NewClusterUsecase new cluster usecase
-func NewClusterUsecase(db *gorm.DB,
-	taskProducer producer.TaskProducer,
-	cloudAccessKeyRepo repo.CloudAccesskeyRepository,
-	CreateKubernetesTaskRepo repo.CreateKubernetesTaskRepository,
-	InitRainbondTaskRepo repo.InitRainbondTaskRepository,
-	UpdateKubernetesTaskRepo repo.UpdateKubernetesTaskRepository,
-	TaskEventRepo repo.TaskEventRepository,
-	RainbondClusterConfigRepo repo.RainbondClusterConfigRepository,
-	rkeClusterRepo repo.RKEClusterRepository,
-	customClusterRepo repo.CustomClusterRepository,
-) *ClusterUsecase {
+func NewClusterUsecase(deps ClusterUsecaseDeps) *ClusterUsecase {
 	return &ClusterUsecase{
-		DB:                        db,
-		TaskProducer:              taskProducer,
-		CloudAccessKeyRepo:        cloudAccessKeyRepo,
-		CreateKubernetesTaskRepo:  CreateKubernetesTaskRepo,
-		InitRainbondTaskRepo:      InitRainbondTaskRepo,
-		UpdateKubernetesTaskRepo:  UpdateKubernetesTaskRepo,
-		TaskEventRepo:             TaskEventRepo,
-		RainbondClusterConfigRepo: RainbondClusterConfigRepo,
-		rkeClusterRepo:            rkeClusterRepo,
-		customClusterRepo:         customClusterRepo,
+		DB:                        deps.DB,
+		TaskProducer:              deps.TaskProducer,
+		CloudAccessKeyRepo:        deps.CloudAccessKeyRepo,
+		CreateKubernetesTaskRepo:  deps.CreateKubernetesTaskRepo,
+		InitRainbondTaskRepo:      deps.InitRainbondTaskRepo,
+		UpdateKubernetesTaskRepo:  deps.UpdateKubernetesTaskRepo,
+		TaskEventRepo:             deps.TaskEventRepo,
+		taskResultRepo:            deps.TaskResultRepo,
+		TaskMessageRepo:           deps.TaskMessageRepo,
+		RainbondClusterConfigRepo: deps.RainbondClusterConfigRepo,
+		rkeClusterRepo:            deps.RKEClusterRepo,
+		customClusterRepo:         deps.CustomClusterRepo,
+		operationTaskRepo:         deps.OperationTaskRepo,
+		sshKeyRepo:                deps.SSHKeyRepo,
+		sshBastionRepo:            deps.SSHBastionRepo,
+		initNodeTokenRepo:         deps.InitNodeTokenRepo,
+		clusterHealthRepo:         deps.ClusterHealthRepo,
+		leaseRepo:                 deps.LeaseRepo,
+		cloudResourceRepo:         deps.CloudResourceRepo,
+		rkeStateStore:             deps.RKEStateStore,
+		eventBroker:               newTaskEventBroker(),
+		webhook:                   deps.WebhookUsecase,
+	}
+}
+
+// getProviderAccessKey returns the access key of the enterprise, or nil if the provider does not need credentials.
+func (c *ClusterUsecase) getProviderAccessKey(eid, providerName string) (*model.CloudAccessKey, error) {
+	provider, ok := adaptor.GetProvider(providerName)
+	if !ok {
+		return nil, bcode.ErrorProviderNotSupport
+	}
+	if !provider.NeedCredentials {
+		return nil, nil
+	}
+	accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(providerName, eid)
+	if err != nil {
+		return nil, bcode.ErrorNotFoundAccessKey
+	}
+	return accessKey, nil
+}
+
+// getRainbondClusterAdaptor creates the adaptor of the provider with the access key of the enterprise if needed.
+func (c *ClusterUsecase) getRainbondClusterAdaptor(eid, providerName string) (adaptor.RainbondClusterAdaptor, error) {
+	accessKey, err := c.getProviderAccessKey(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	var accessKeyID, accessKeySecret string
+	if accessKey != nil {
+		accessKeyID, accessKeySecret = accessKey.AccessKey, accessKey.SecretKey
 	}
+	ad, err := factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, bcode.ErrorProviderNotSupport
+	}
+	return ad, nil
+}
+
+// providerNeedCredentials returns true if the provider is unknown or creates the cluster with the access key of the enterprise,
+// the clusters of such providers are not managed by cloud adaptor.
+func providerNeedCredentials(providerName string) bool {
+	provider, ok := adaptor.GetProvider(providerName)
+	return !ok || provider.NeedCredentials
 }
 
 // ListKubernetesCluster list kubernetes cluster
 func (c *ClusterUsecase) ListKubernetesCluster(eid string, re v1.ListKubernetesCluster) ([]*v1alpha1.Cluster, error) {
-	var ad adaptor.RainbondClusterAdaptor
-	var err error
-	if re.ProviderName != "rke" && re.ProviderName != "custom" {
-		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(re.ProviderName, eid)
-		if err != nil {
-			return nil, bcode.ErrorNotFoundAccessKey
-		}
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(re.ProviderName, accessKey.AccessKey, accessKey.SecretKey)
-		if err != nil {
-			return nil, bcode.ErrorProviderNotSupport
-		}
-	} else {
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(re.ProviderName, "", "")
-		if err != nil {
-			return nil, bcode.ErrorProviderNotSupport
-		}
+	ad, err := c.getRainbondClusterAdaptor(eid, re.ProviderName)
+	if err != nil {
+		return nil, err
 	}
 	clusters, err := ad.ClusterList(eid)
 	if err != nil {
@@ -135,6 +200,7 @@ func (c *ClusterUsecase) ListKubernetesCluster(eid string, re v1.ListKubernetesC
 		logrus.Errorf("list cluster list failure %s", err.Error())
 		return nil, bcode.ServerErr
 	}
+	c.attachClusterHealth(eid, clusters)
 	return clusters, nil
 }
 
@@ -143,6 +209,9 @@ func (c *ClusterUsecase) CreateKubernetesCluster(eid string, req v1.CreateKubern
 	if c.TaskProducer == nil {
 		return nil, errors.New("TaskProducer is nil")
 	}
+	if err := checkProviderCapability(req.Provider, adaptor.CapabilityCreateCluster); err != nil {
+		return nil, err
+	}
 	clusterID := uuidutil.NewUUID()
 	clusterStatus := v1alpha1.OfflineState
 	if req.Provider == "custom" {
@@ -175,11 +244,6 @@ func (c *ClusterUsecase) CreateKubernetesCluster(eid string, req v1.CreateKubern
 			EnterpriseID: eid,
 			ClusterID:    clusterID,
 		}
-		// Only the request to successfully create the rke cluster can send the task
-		if err := c.rkeClusterRepo.Create(rkeCluster); err != nil {
-			return nil, err
-		}
-
 		decRKEConfig, err := base64.StdEncoding.DecodeString(req.EncodedRKEConfig)
 		if err != nil {
 			return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "decode encoded rke config")
@@ -195,15 +259,26 @@ func (c *ClusterUsecase) CreateKubernetesCluster(eid string, req v1.CreateKubern
 		if err := nodeList.Validate(); err != nil {
 			return nil, err
 		}
+		// the bastion is validated even if the preflight is skipped, the nodes can not be installed without it
+		if req.Bastion != nil {
+			if err := c.validateSSHBastion(eid, req.Bastion); err != nil {
+				return nil, err
+			}
+		}
+		// Only the request to successfully create the rke cluster can send the task
+		if err := c.rkeClusterRepo.Create(rkeCluster); err != nil {
+			return nil, err
+		}
+		if req.Bastion != nil {
+			if _, err := c.saveSSHBastion(eid, clusterID, req.Bastion); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	var accessKey *model.CloudAccessKey
-	var err error
-	if req.Provider != "rke" && req.Provider != "custom" {
-		accessKey, err = c.CloudAccessKeyRepo.GetByProviderAndEnterprise(req.Provider, eid)
-		if err != nil {
-			return nil, bcode.ErrorNotFoundAccessKey
-		}
+	accessKey, err := c.getProviderAccessKey(eid, req.Provider)
+	if err != nil {
+		return nil, err
 	}
 	newTask := &model.CreateKubernetesTask{
 		Name:               req.Name,
@@ -230,6 +305,7 @@ func (c *ClusterUsecase) CreateKubernetesCluster(eid string, req v1.CreateKubern
 			Region:             newTask.Region,
 			RKEConfig:          &rkeConfig,
 			EnterpriseID:       eid,
+			SkipPreflight:      req.SkipPreflight,
 		}}
 	if accessKey != nil {
 		taskReq.KubernetesConfig.AccessKey = accessKey.AccessKey
@@ -308,27 +384,36 @@ func (c *ClusterUsecase) InitRainbondRegion(ctx context.Context, eid string, req
 	if err != nil && !errors.Is(err, bcode.ErrInitRainbondTaskNotFound) {
 		return nil, err
 	}
-	if oldTask != nil && !req.Retry {
+	if oldTask != nil && !req.Retry && !req.Resume {
 		return oldTask, bcode.ErrorLastTaskNotComplete
 	}
 
-	if err := c.isAlreadyInstalled(ctx, eid, req.ClusterID, req.Provider); err != nil {
-		return nil, err
-	}
-
-	var accessKey *model.CloudAccessKey
-	if req.Provider != "rke" && req.Provider != "custom" {
-		accessKey, err = c.CloudAccessKeyRepo.GetByProviderAndEnterprise(req.Provider, eid)
+	var checkpoints v1alpha1.Checkpoints
+	if req.Resume && oldTask != nil {
+		// the region may be installed partly by the last task
+		checkpoints, err = c.getTaskCheckpoints(oldTask)
 		if err != nil {
-			return nil, bcode.ErrorNotFoundAccessKey
+			return nil, err
+		}
+	} else {
+		if err := c.isAlreadyInstalled(ctx, eid, req.ClusterID, req.Provider); err != nil {
+			return nil, err
 		}
 	}
+
+	accessKey, err := c.getProviderAccessKey(eid, req.Provider)
+	if err != nil {
+		return nil, err
+	}
 	newTask := &model.InitRainbondTask{
 		TaskID:       uuidutil.NewUUID(),
 		Provider:     req.Provider,
 		EnterpriseID: eid,
 		ClusterID:    req.ClusterID,
 	}
+	if checkpoints != nil {
+		newTask.ResumeFrom = oldTask.TaskID
+	}
 
 	if err := c.InitRainbondTaskRepo.Create(newTask); err != nil {
 		logrus.Errorf("create init rainbond task failure %s", err.Error())
@@ -341,6 +426,7 @@ func (c *ClusterUsecase) InitRainbondRegion(ctx context.Context, eid string, req
 			EnterpriseID: eid,
 			ClusterID:    newTask.ClusterID,
 			Provider:     newTask.Provider,
+			Checkpoints:  checkpoints,
 		}}
 	if accessKey != nil {
 		initTask.InitRainbondConfig.AccessKey = accessKey.AccessKey
@@ -363,19 +449,45 @@ func (c *ClusterUsecase) UpdateKubernetesCluster(eid string, req v1.UpdateKubern
 		logrus.Errorf("TaskProducer is nil")
 		return nil, bcode.ServerErr
 	}
-	if req.Provider != "rke" {
-		return nil, bcode.ErrNotSupportUpdateKubernetes
+	if err := checkProviderCapability(req.Provider, adaptor.CapabilityExpandNodes); err != nil {
+		return nil, errors.WithStack(bcode.ErrNotSupportUpdateKubernetes)
 	}
-
-	decodedRkeConfig, err := base64.StdEncoding.DecodeString(req.EncodedRKEConfig)
-	if err != nil {
-		logrus.Errorf("decode encoded rke config: %v", err)
-		return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "decode encoded rke config")
+	expansionNode := &v1alpha1.ExpansionNode{
+		Provider:     req.Provider,
+		ClusterID:    req.ClusterID,
+		EnterpriseID: eid,
 	}
-	var rkeConfig v3.RancherKubernetesEngineConfig
-	if err := yaml.Unmarshal(decodedRkeConfig, &rkeConfig); err != nil {
-		logrus.Errorf("unmarshal rke config: %v", err)
-		return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "unmarshal rke config")
+	var nodeNumber int
+	if req.Provider == "rke" {
+		decodedRkeConfig, err := base64.StdEncoding.DecodeString(req.EncodedRKEConfig)
+		if err != nil {
+			logrus.Errorf("decode encoded rke config: %v", err)
+			return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "decode encoded rke config")
+		}
+		var rkeConfig v3.RancherKubernetesEngineConfig
+		if err := yaml.Unmarshal(decodedRkeConfig, &rkeConfig); err != nil {
+			logrus.Errorf("unmarshal rke config: %v", err)
+			return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "unmarshal rke config")
+		}
+		expansionNode.RKEConfig = &rkeConfig
+		nodeNumber = len(rkeConfig.Nodes)
+	} else {
+		if req.WorkerNodeNum < 1 {
+			return nil, errors.WithStack(bcode.ErrInvalidWorkerNodeNum)
+		}
+		accessKey, err := c.getProviderAccessKey(eid, req.Provider)
+		if err != nil {
+			return nil, err
+		}
+		if accessKey != nil {
+			expansionNode.AccessKey = accessKey.AccessKey
+			expansionNode.SecretKey = accessKey.SecretKey
+		}
+		expansionNode.WorkerNodeNum = req.WorkerNodeNum
+		expansionNode.InstanceType = req.InstanceType
+		expansionNode.NodePoolID = req.NodePoolID
+		expansionNode.WorkerDataDisk = req.WorkerDataDisk
+		nodeNumber = req.WorkerNodeNum
 	}
 
 	// check if the last task is complete
@@ -383,13 +495,18 @@ func (c *ClusterUsecase) UpdateKubernetesCluster(eid string, req v1.UpdateKubern
 	if err != nil {
 		return nil, err
 	}
+	for _, taskType := range clusterStateTaskTypes {
+		if err := c.checkOperationTaskComplete(eid, req.ClusterID, taskType); err != nil {
+			return nil, err
+		}
+	}
 
 	newTask := &model.UpdateKubernetesTask{
 		TaskID:       uuidutil.NewUUID(),
 		Provider:     req.Provider,
 		EnterpriseID: eid,
 		ClusterID:    req.ClusterID,
-		NodeNumber:   len(rkeConfig.Nodes),
+		NodeNumber:   nodeNumber,
 		Version:      version + 1, // optimistic lock
 	}
 	if err := c.UpdateKubernetesTaskRepo.Create(newTask); err != nil {
@@ -400,12 +517,8 @@ func (c *ClusterUsecase) UpdateKubernetesCluster(eid string, req v1.UpdateKubern
 	taskReq := types.UpdateKubernetesConfigMessage{
 		EnterpriseID: eid,
 		TaskID:       newTask.TaskID,
-		Config: &v1alpha1.ExpansionNode{
-			Provider:     req.Provider,
-			ClusterID:    req.ClusterID,
-			EnterpriseID: eid,
-			RKEConfig:    &rkeConfig,
-		}}
+		Config:       expansionNode,
+	}
 	if err := c.TaskProducer.SendUpdateKuerbetesTask(taskReq); err != nil {
 		logrus.Errorf("send create kubernetes task failure %s", err.Error())
 	} else {
@@ -423,6 +536,24 @@ func (c *ClusterUsecase) UpdateKubernetesCluster(eid string, req v1.UpdateKubern
 	}, nil
 }
 
+// getTaskCheckpoints returns the succeeded steps of the task which is not running.
+func (c *ClusterUsecase) getTaskCheckpoints(task *model.InitRainbondTask) (v1alpha1.Checkpoints, error) {
+	if task.Status != "complete" {
+		return nil, errors.WithStack(bcode.ErrorLastTaskNotComplete)
+	}
+	events, err := c.TaskEventRepo.ListEvent(task.EnterpriseID, task.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make(v1alpha1.Checkpoints)
+	for _, event := range events {
+		if event.Status == "success" {
+			checkpoints[event.StepType] = event.Message
+		}
+	}
+	return checkpoints, nil
+}
+
 func (c *ClusterUsecase) isLastTaskComplete(eid, clusterID string) (int, error) {
 	// check if update task complete
 	updateTask, err := c.UpdateKubernetesTaskRepo.GetTaskByClusterID(eid, clusterID)
@@ -536,25 +667,12 @@ func (c *ClusterUsecase) getUpdateKubernetesTask(eid, name, clusterID string) (i
 }
 
 func (c *ClusterUsecase) getRKEConfig(eid string, cluster *model.RKECluster) (*v3.RancherKubernetesEngineConfig, error) {
-	configDir := os.Getenv("CONFIG_DIR")
-	if configDir == "" {
-		configDir = "/tmp"
-	}
-	clusterYMLPath := fmt.Sprintf("%s/enterprise/%s/rke/%s/cluster.yml", configDir, cluster.EnterpriseID, cluster.Name)
-	oldClusterYMLPath := fmt.Sprintf("%s/rke/%s/cluster.yml", configDir, cluster.Name)
-
-	bytes, err := ioutil.ReadFile(clusterYMLPath)
+	bytes, err := repo.GetRKEStateFile(c.rkeStateStore, cluster.EnterpriseID, cluster.Name, "cluster.yml")
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, errors.Wrap(err, "read rke config file")
-		}
-		bytes, err = ioutil.ReadFile(oldClusterYMLPath)
-		if err != nil {
-			if !os.IsNotExist(err) {
-				return nil, errors.Wrap(bcode.ErrRKEConfigLost, err.Error())
-			}
+		if err == blobstore.ErrNotFound {
 			return nil, nil
 		}
+		return nil, errors.Wrap(bcode.ErrRKEConfigLost, err.Error())
 	}
 
 	var rkeConfig v3.RancherKubernetesEngineConfig
@@ -617,6 +735,9 @@ func (c *ClusterUsecase) CreateTaskEvent(em *v1.EventMessage) (*model.TaskEvent,
 	if em.Message == nil {
 		return nil, fmt.Errorf("message is nil")
 	}
+	if em.Message.Resource != nil {
+		return nil, c.recordResource(em.EnterpriseID, em.TaskID, em.Message.Resource)
+	}
 	ctx := c.DB.Begin()
 	ent := &model.TaskEvent{
 		TaskID:       em.TaskID,
@@ -633,7 +754,7 @@ func (c *ClusterUsecase) CreateTaskEvent(em *v1.EventMessage) (*model.TaskEvent,
 	}
 
 	createKubernetesTaskRepo := c.CreateKubernetesTaskRepo.Transaction(ctx)
-	if (em.Message.StepType == "CreateCluster" || em.Message.StepType == "InstallKubernetes") && em.Message.Status == "success" {
+	if (em.Message.StepType == v1.StepCreateCluster || em.Message.StepType == v1.StepInstallKubernetes) && em.Message.Status == "success" {
 		if ckErr := createKubernetesTaskRepo.UpdateStatus(em.EnterpriseID, em.TaskID, "complete"); ckErr != nil && ckErr != gorm.ErrRecordNotFound {
 			ctx.Rollback()
 			return nil, ckErr
@@ -641,21 +762,21 @@ func (c *ClusterUsecase) CreateTaskEvent(em *v1.EventMessage) (*model.TaskEvent,
 		logrus.Infof("set create kubernetes task %s status is complete", em.TaskID)
 	}
 	initRainbondTaskRepo := c.InitRainbondTaskRepo.Transaction(ctx)
-	if em.Message.StepType == "InitRainbondRegion" && em.Message.Status == "success" {
+	if em.Message.StepType == v1.StepInitRainbondRegion && em.Message.Status == "success" {
 		if err := initRainbondTaskRepo.UpdateStatus(em.EnterpriseID, em.TaskID, "inited"); err != nil && err != gorm.ErrRecordNotFound {
 			ctx.Rollback()
 			return nil, err
 		}
 		logrus.Infof("set init task %s status is inited", em.TaskID)
 	}
-	if em.Message.StepType == "UpdateKubernetes" && em.Message.Status == "success" {
+	if em.Message.StepType == v1.StepUpdateKubernetes && em.Message.Status == "success" {
 		if err := c.UpdateKubernetesTaskRepo.Transaction(ctx).UpdateStatus(em.EnterpriseID, em.TaskID, "complete"); err != nil && err != gorm.ErrRecordNotFound {
 			ctx.Rollback()
 			return nil, err
 		}
 		logrus.Infof("set init task %s status is inited", em.TaskID)
 	}
-	if em.Message.Status == "failure" {
+	if em.Message.Status == "failure" || em.Message.Status == "cancelled" {
 		if initErr := initRainbondTaskRepo.UpdateStatus(em.EnterpriseID, em.TaskID, "complete"); initErr != nil && initErr != gorm.ErrRecordNotFound {
 			ctx.Rollback()
 			return nil, initErr
@@ -665,6 +786,36 @@ func (c *ClusterUsecase) CreateTaskEvent(em *v1.EventMessage) (*model.TaskEvent,
 			ctx.Rollback()
 			return nil, ckErr
 		}
+
+		if ukErr := c.UpdateKubernetesTaskRepo.Transaction(ctx).UpdateStatus(em.EnterpriseID, em.TaskID, "complete"); ukErr != nil && ukErr != gorm.ErrRecordNotFound {
+			ctx.Rollback()
+			return nil, ukErr
+		}
+	}
+	// finished is true only for the first terminal event of the task
+	var finished bool
+	if IsTerminalEvent(em.Message.StepType, em.Message.Status) {
+		if err := c.operationTaskRepo.Transaction(ctx).UpdateStatus(em.EnterpriseID, em.TaskID, "complete"); err != nil {
+			ctx.Rollback()
+			return nil, err
+		}
+		recorded, err := c.taskResultRepo.Transaction(ctx).Record(&model.TaskResult{
+			TaskID:       em.TaskID,
+			EnterpriseID: em.EnterpriseID,
+			StepType:     em.Message.StepType,
+			Status:       em.Message.Status,
+		})
+		if err != nil {
+			ctx.Rollback()
+			return nil, err
+		}
+		finished = recorded
+	}
+	if c.TaskMessageRepo != nil && IsTerminalEvent(em.Message.StepType, em.Message.Status) {
+		if err := c.TaskMessageRepo.Transaction(ctx).Finish(em.TaskID); err != nil {
+			ctx.Rollback()
+			return nil, err
+		}
 	}
 
 	if err := ctx.Commit().Error; err != nil {
@@ -672,9 +823,165 @@ func (c *ClusterUsecase) CreateTaskEvent(em *v1.EventMessage) (*model.TaskEvent,
 		return nil, err
 	}
 	logrus.Infof("save task %s event %s status %s to db", em.TaskID, em.Message.StepType, em.Message.Status)
+	// the success event is not overwritten and has no new seq
+	if ent.Seq > 0 {
+		c.eventBroker.notify(em.TaskID)
+	}
+	if c.webhook != nil && finished {
+		c.notifyTaskWebhook(em)
+	}
 	return ent, nil
 }
 
+// IsTerminalEvent reports whether the event means the task is finished.
+// The failure of a node is not terminal, the step which the node belongs to reports the result of the task.
+func IsTerminalEvent(stepType, status string) bool {
+	if status == "failure" || status == "cancelled" {
+		return !v1alpha1.IsNodeStepType(stepType)
+	}
+	if status != "success" {
+		return false
+	}
+	switch stepType {
+	case v1.StepCreateCluster, v1.StepInstallKubernetes, v1.StepInitRainbondRegion, v1.StepUpdateKubernetes,
+		v1.StepReleaseResources, v1.StepUpgradeKubernetes, v1.StepRotateCertificates, v1.StepEnableSecretsEncryption, v1.StepRotateEncryptionKey,
+		v1.StepSaveEtcdSnapshot, v1.StepSetEtcdSnapshotPolicy, v1.StepRestoreEtcdSnapshot, v1.StepRemoveNodes, v1.StepRotateSSHKey, v1.StepPreflightCheck:
+		return true
+	}
+	return false
+}
+
+// notifyTaskWebhook notifies the webhooks of the first terminal event of the task.
+func (c *ClusterUsecase) notifyTaskWebhook(em *v1.EventMessage) {
+	payload := &v1.WebhookPayload{
+		EnterpriseID: em.EnterpriseID,
+		TaskID:       em.TaskID,
+		StepType:     em.Message.StepType,
+		Message:      em.Message.Message,
+	}
+	switch em.Message.Status {
+	case "success":
+		payload.Event = v1.WebhookEventTaskSuccess
+	case "cancelled":
+		payload.Event = v1.WebhookEventTaskCancelled
+	default:
+		payload.Event = v1.WebhookEventTaskFailure
+	}
+	task, err := c.getTask(em.EnterpriseID, em.TaskID)
+	if err != nil {
+		logrus.Warningf("get task %s for webhook failure %s", em.TaskID, err.Error())
+	} else {
+		payload.ClusterID = task.ClusterID
+		payload.ProviderName = task.ProviderName
+		payload.TaskType = string(task.TaskType)
+	}
+	c.webhook.Notify(payload)
+}
+
+// InterruptTask marks the task which was interrupted by a restart as failure.
+func (c *ClusterUsecase) InterruptTask(eid, taskID string) error {
+	_, err := c.CreateTaskEvent(&v1.EventMessage{
+		EnterpriseID: eid,
+		TaskID:       taskID,
+		Message: &v1.Message{
+			StepType: v1.StepTaskInterrupted,
+			Message:  "the task was interrupted because cloud adaptor restarted, please retry",
+			Status:   "failure",
+		},
+	})
+	return err
+}
+
+// CancelTask cancels the cluster task. The pending task is cancelled immediately,
+// the running task is cancelled by the worker who owns it.
+func (c *ClusterUsecase) CancelTask(eid, taskID string) error {
+	msg, err := c.TaskMessageRepo.GetByTaskID(taskID)
+	if err != nil {
+		return err
+	}
+	if msg.EnterpriseID != eid {
+		return errors.WithStack(bcode.ErrClusterTaskNotFound)
+	}
+	cancelled, err := c.TaskMessageRepo.CancelPending(taskID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return c.TaskMessageRepo.RequestCancel(taskID)
+	}
+	// the task has not started yet, there is nothing to roll back.
+	return c.MarkTaskCancelled(eid, taskID)
+}
+
+// GetTaskPlan returns the planned steps of the task with the status of the latest event of each step.
+// The steps reported by the task but not planned are appended in order.
+func (c *ClusterUsecase) GetTaskPlan(eid, taskID string) (*v1.TaskPlanRes, error) {
+	msg, err := c.TaskMessageRepo.GetByTaskID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.EnterpriseID != eid {
+		return nil, errors.WithStack(bcode.ErrClusterTaskNotFound)
+	}
+	var plan []string
+	if msg.Plan != "" {
+		if err := json.Unmarshal([]byte(msg.Plan), &plan); err != nil {
+			return nil, errors.Wrap(err, "decode task plan")
+		}
+	}
+	events, err := c.TaskEventRepo.ListEvent(eid, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &v1.TaskPlanRes{TaskID: taskID}
+	steps := make(map[string]*v1.TaskStep)
+	for _, stepType := range plan {
+		step := &v1.TaskStep{StepType: stepType, Status: "pending"}
+		steps[stepType] = step
+		res.Steps = append(res.Steps, step)
+	}
+	for _, event := range events {
+		step, ok := steps[event.StepType]
+		if !ok {
+			step = &v1.TaskStep{StepType: event.StepType}
+			steps[event.StepType] = step
+			res.Steps = append(res.Steps, step)
+		}
+		step.Status = event.Status
+		step.Message = event.Message
+	}
+	return res, nil
+}
+
+// MarkTaskCancelled saves the cancelled event of the task.
+func (c *ClusterUsecase) MarkTaskCancelled(eid, taskID string) error {
+	_, err := c.CreateTaskEvent(&v1.EventMessage{
+		EnterpriseID: eid,
+		TaskID:       taskID,
+		Message: &v1.Message{
+			StepType: v1.StepCancelled,
+			Message:  "the task is cancelled",
+			Status:   "cancelled",
+		},
+	})
+	return err
+}
+
+// FailTask saves the failure event of the task which can not be handled, such as its message is invalid.
+func (c *ClusterUsecase) FailTask(eid, taskID, message string) error {
+	_, err := c.CreateTaskEvent(&v1.EventMessage{
+		EnterpriseID: eid,
+		TaskID:       taskID,
+		Message: &v1.Message{
+			StepType: v1.StepCreateTask,
+			Message:  "handle the task failure: " + message,
+			Status:   "failure",
+		},
+	})
+	return err
+}
+
 func (c *ClusterUsecase) reasonFromMessage(message string) string {
 	if strings.Contains(message, fmt.Sprintf("namespace %s because it is being terminated", constants.Namespace)) {
 		return "NamespaceBeingTerminated"
@@ -700,25 +1007,25 @@ func (c *ClusterUsecase) ListTaskEvent(eid, taskID string) ([]*model.TaskEvent,
 	needSync := false
 	for i := range events {
 		event := events[i]
-		if (event.StepType == "CreateCluster" || event.StepType == "InstallKubernetes") && event.Status == "success" {
+		if (event.StepType == v1.StepCreateCluster || event.StepType == v1.StepInstallKubernetes) && event.Status == "success" {
 			if ckErr := c.CreateKubernetesTaskRepo.UpdateStatus(eid, event.TaskID, "complete"); ckErr != nil && ckErr != gorm.ErrRecordNotFound {
 				logrus.Errorf("set create kubernetes task %s status failure %s", event.TaskID, err.Error())
 			}
 			logrus.Infof("set create kubernetes task %s status is complete", event.TaskID)
 		}
-		if event.StepType == "InitRainbondRegion" && event.Status == "success" {
+		if event.StepType == v1.StepInitRainbondRegion && event.Status == "success" {
 			if err := c.InitRainbondTaskRepo.UpdateStatus(eid, event.TaskID, "inited"); err != nil && err != gorm.ErrRecordNotFound {
 				logrus.Errorf("set init rainbond task %s status failure %s", event.TaskID, err.Error())
 			}
 			logrus.Infof("set init task %s status is inited", event.TaskID)
 		}
-		if event.StepType == "UpdateKubernetes" && event.Status == "success" {
+		if event.StepType == v1.StepUpdateKubernetes && event.Status == "success" {
 			if err := c.UpdateKubernetesTaskRepo.UpdateStatus(eid, event.TaskID, "complete"); err != nil && err != gorm.ErrRecordNotFound {
 				logrus.Errorf("set init rainbond task %s status failure %s", event.TaskID, err.Error())
 			}
 			logrus.Infof("set init task %s status is inited", event.TaskID)
 		}
-		if event.Status == "failure" {
+		if event.Status == "failure" || event.Status == "cancelled" {
 			needSync = true
 			if initErr := c.InitRainbondTaskRepo.UpdateStatus(eid, event.TaskID, "complete"); initErr != nil && initErr != gorm.ErrRecordNotFound {
 				logrus.Errorf("set init rainbond task %s status failure %s", event.TaskID, err.Error())
@@ -778,6 +1085,16 @@ func (c *ClusterUsecase) getTask(eid, taskID string) (*domain.ClusterTask, error
 		taskType = domain.ClusterTaskTypeUpdateKubernetes
 	}
 
+	// operation task
+	operationTask, err := c.operationTaskRepo.GetTask(eid, taskID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if operationTask != nil {
+		source = operationTask
+		taskType = domain.ClusterTaskType(operationTask.Type)
+	}
+
 	if source == nil {
 		return nil, bcode.ErrClusterTaskNotFound
 	}
@@ -859,22 +1176,9 @@ func (c *ClusterUsecase) GetTaskRunningLists(eid string) ([]*model.InitRainbondT
 
 // GetKubeConfig get kube config file
 func (c *ClusterUsecase) GetKubeConfig(eid, clusterID, providerName string) (string, error) {
-	var ad adaptor.RainbondClusterAdaptor
-	var err error
-	if providerName != "rke" && providerName != "custom" {
-		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(providerName, eid)
-		if err != nil {
-			return "", bcode.ErrorNotFoundAccessKey
-		}
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, accessKey.AccessKey, accessKey.SecretKey)
-		if err != nil {
-			return "", bcode.ErrorProviderNotSupport
-		}
-	} else {
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, "", "")
-		if err != nil {
-			return "", bcode.ErrorProviderNotSupport
-		}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return "", err
 	}
 	kube, err := ad.GetKubeConfig(eid, clusterID)
 	if err != nil {
@@ -885,22 +1189,9 @@ func (c *ClusterUsecase) GetKubeConfig(eid, clusterID, providerName string) (str
 
 // GetRegionConfig get region config
 func (c *ClusterUsecase) GetRegionConfig(eid, clusterID, providerName string) (map[string]string, error) {
-	var ad adaptor.RainbondClusterAdaptor
-	var err error
-	if providerName != "rke" && providerName != "custom" {
-		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(providerName, eid)
-		if err != nil {
-			return nil, bcode.ErrorNotFoundAccessKey
-		}
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, accessKey.AccessKey, accessKey.SecretKey)
-		if err != nil {
-			return nil, bcode.ErrorProviderNotSupport
-		}
-	} else {
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, "", "")
-		if err != nil {
-			return nil, bcode.ErrorProviderNotSupport
-		}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
 	}
 	kubeConfig, err := ad.GetKubeConfig(eid, clusterID)
 	if err != nil {
@@ -946,46 +1237,41 @@ func (c *ClusterUsecase) UpdateInitRainbondTaskStatus(eid, taskID, status string
 	return task, nil
 }
 
-// DeleteKubernetesCluster delete provider
-func (c *ClusterUsecase) DeleteKubernetesCluster(eid, clusterID, providerName string) error {
-	var ad adaptor.RainbondClusterAdaptor
-	var err error
-	if providerName != "rke" && providerName != "custom" {
-		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(providerName, eid)
-		if err != nil {
-			return bcode.ErrorNotFoundAccessKey
-		}
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, accessKey.AccessKey, accessKey.SecretKey)
-		if err != nil {
-			return bcode.ErrorProviderNotSupport
-		}
-	} else {
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, "", "")
-		if err != nil {
-			return bcode.ErrorProviderNotSupport
-		}
+// DeleteKubernetesCluster delete provider. If the adaptor releases the cloud resources of the cluster,
+// the deletion runs as a task and the task is returned.
+func (c *ClusterUsecase) DeleteKubernetesCluster(eid, clusterID, providerName string) (*model.OperationTask, error) {
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ad.(adaptor.ResourceReleaser); ok {
+		return c.releaseResources(eid, clusterID, providerName, releaseResourcesParams{ClusterDeleted: true})
+	}
+	if err := ad.DeleteCluster(eid, clusterID); err != nil {
+		return nil, err
 	}
-	return ad.DeleteCluster(eid, clusterID)
+	if err := c.clusterHealthRepo.Delete(eid, clusterID); err != nil {
+		logrus.Warningf("delete health of cluster %s: %v", clusterID, err)
+	}
+	c.notifyClusterDeleted(eid, clusterID, providerName)
+	return nil, nil
+}
+
+// notifyClusterDeleted notifies the webhooks that the cluster is deleted.
+func (c *ClusterUsecase) notifyClusterDeleted(eid, clusterID, providerName string) {
+	c.webhook.Notify(&v1.WebhookPayload{
+		Event:        v1.WebhookEventClusterDeleted,
+		EnterpriseID: eid,
+		ClusterID:    clusterID,
+		ProviderName: providerName,
+	})
 }
 
 // GetCluster get cluster
 func (c *ClusterUsecase) GetCluster(providerName, eid, clusterID string) (*v1alpha1.Cluster, error) {
-	var ad adaptor.RainbondClusterAdaptor
-	var err error
-	if providerName != "rke" && providerName != "custom" {
-		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(providerName, eid)
-		if err != nil {
-			return nil, bcode.ErrorNotFoundAccessKey
-		}
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, accessKey.AccessKey, accessKey.SecretKey)
-		if err != nil {
-			return nil, bcode.ErrorProviderNotSupport
-		}
-	} else {
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(providerName, "", "")
-		if err != nil {
-			return nil, bcode.ErrorProviderNotSupport
-		}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
 	}
 	return ad.DescribeCluster(eid, clusterID)
 }
@@ -1088,22 +1374,9 @@ func (c *ClusterUsecase) UninstallRainbondRegion(eid, clusterID, provider string
 		logrus.Info("uninstall rainbond region is disable")
 		return nil
 	}
-	var ad adaptor.RainbondClusterAdaptor
-	var err error
-	if provider != "rke" && provider != "custom" {
-		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(provider, eid)
-		if err != nil {
-			return bcode.ErrorNotFoundAccessKey
-		}
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(provider, accessKey.AccessKey, accessKey.SecretKey)
-		if err != nil {
-			return bcode.ErrorProviderNotSupport
-		}
-	} else {
-		ad, err = factory.GetCloudFactory().GetRainbondClusterAdaptor(provider, "", "")
-		if err != nil {
-			return bcode.ErrorProviderNotSupport
-		}
+	ad, err := c.getRainbondClusterAdaptor(eid, provider)
+	if err != nil {
+		return err
 	}
 	kubeconfig, err := ad.GetKubeConfig(eid, clusterID)
 	if err != nil {
@@ -1113,7 +1386,7 @@ func (c *ClusterUsecase) UninstallRainbondRegion(eid, clusterID, provider string
 	go func() {
 		logrus.Infof("start uninstall cluster %s by provider %s", clusterID, provider)
 		if err := rri.UninstallRegion(clusterID); err != nil {
-			logrus.Errorf("uninstall region %s failure %s", err.Error())
+			logrus.Errorf("uninstall region %s failure %s", clusterID, err.Error())
 		}
 		if err := c.InitRainbondTaskRepo.DeleteTask(eid, provider, clusterID); err != nil {
 			logrus.Errorf("delete region init task failure %s", err.Error())
@@ -1191,11 +1464,12 @@ func (c *ClusterUsecase) nodeListToRKEConfigNodes(nodeList v1alpha1.NodeList) []
 	return nodes
 }
 
-// GetInitNodeCmd -
-func (c *ClusterUsecase) GetInitNodeCmd(ctx context.Context) (*v1.InitNodeCmdRes, error) {
-	pub, err := ssh.GetOrMakeSSHRSA()
+// GetInitNodeCmd returns the command initialising the node with the public key of the enterprise, or the legacy key if eid is empty.
+// The command downloads the init script from cloud adaptor at the advertise url with a one-time token.
+func (c *ClusterUsecase) GetInitNodeCmd(ctx context.Context, eid string, req *v1.InitNodeCmdReq) (*v1.InitNodeCmdRes, error) {
+	pub, err := c.getSSHPublicKey(eid)
 	if err != nil {
-		return nil, errors.Wrap(err, "get or create ssh rsa")
+		return nil, errors.Wrap(err, "get or create ssh key")
 	}
 
 	if config.C.IsOffline {
@@ -1204,8 +1478,17 @@ func (c *ClusterUsecase) GetInitNodeCmd(ctx context.Context) (*v1.InitNodeCmdRes
 			IsOffline: true,
 		}, nil
 	}
+	baseURL, err := advertiseURL()
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.createInitNodeToken(eid, req.RegistryMirror)
+	if err != nil {
+		return nil, err
+	}
 	return &v1.InitNodeCmdRes{
-		Cmd: fmt.Sprintf(`export SSH_RSA="%s" && curl -sfL https://get.rainbond.com/init_node | bash`, strings.Replace(pub, "\n", "", -1)),
+		Cmd:       initNodeCmd(initNodeScriptURL(baseURL, token.Token)),
+		ExpiredAt: &token.ExpiredAt,
 	}, nil
 }
 
@@ -1338,7 +1621,7 @@ func (c *ClusterUsecase) listPodEvents(ctx context.Context, kubeClient kubernete
 }
 
 func (c *ClusterUsecase) syncTaskEvents(task *domain.ClusterTask, events []*model.TaskEvent) error {
-	if task.TaskType != domain.ClusterTaskTypeInitRainbond || (task.ProviderName != "rke" && task.ProviderName != "custom") {
+	if task.TaskType != domain.ClusterTaskTypeInitRainbond || providerNeedCredentials(task.ProviderName) {
 		return nil
 	}
 
@@ -1356,14 +1639,14 @@ func (c *ClusterUsecase) syncTaskEvents(task *domain.ClusterTask, events []*mode
 	var updates []string
 	// update InitRainbondRegionOperator event
 	if status.OperatorReady {
-		event := c.getEvent("InitRainbondRegionOperator", events)
+		event := c.getEvent(v1.StepInitRainbondRegionOperator, events)
 		if event != nil {
 			updates = append(updates, event.EventID)
 		}
 	}
 	// update InitRainbondRegionImageHub event
 	if idx, condition := status.RainbondCluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionTypeImageRepository); idx != -1 && condition.Status == corev1.ConditionTrue {
-		event := c.getEvent("InitRainbondRegionImageHub", events)
+		event := c.getEvent(v1.StepInitRainbondRegionImageHub, events)
 		if event != nil {
 			updates = append(updates, event.EventID)
 		}
@@ -1371,7 +1654,7 @@ func (c *ClusterUsecase) syncTaskEvents(task *domain.ClusterTask, events []*mode
 	// update InitRainbondRegionPackage event
 	for _, con := range status.RainbondPackage.Status.Conditions {
 		if con.Type == rainbondv1alpha1.Ready && con.Status == rainbondv1alpha1.Completed {
-			event := c.getEvent("InitRainbondRegionPackage", events)
+			event := c.getEvent(v1.StepInitRainbondRegionPackage, events)
 			if event != nil {
 				updates = append(updates, event.EventID)
 			}
@@ -1380,9 +1663,10 @@ func (c *ClusterUsecase) syncTaskEvents(task *domain.ClusterTask, events []*mode
 	// update InitRainbondRegion event
 	idx, condition := status.RainbondCluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionTypeRunning)
 	if idx != -1 && condition.Status == corev1.ConditionTrue {
-		event := c.getEvent("InitRainbondRegion", events)
-		if event != nil {
-			updates = append(updates, event.EventID)
+		for _, stepType := range []string{v1.StepInitRainbondRegionRegionConfig, v1.StepInitRainbondRegion} {
+			if event := c.getEvent(stepType, events); event != nil {
+				updates = append(updates, event.EventID)
+			}
 		}
 	}
 
@@ -1399,7 +1683,7 @@ func (c *ClusterUsecase) getEvent(stepType string, events []*model.TaskEvent) *m
 }
 
 func (c *ClusterUsecase) getTaskClusterStatus(task *model.InitRainbondTask) (string, error) {
-	if task.Provider != "rke" && task.Provider != "custom" {
+	if providerNeedCredentials(task.Provider) {
 		return "", nil
 	}
 
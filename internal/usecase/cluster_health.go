@@ -0,0 +1,322 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/util/constants"
+	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// clusterHealthInterval how often the health of the clusters is checked
+	clusterHealthInterval = time.Minute
+	// clusterHealthTimeout the timeout of the requests to the kubernetes api of a cluster
+	clusterHealthTimeout = 10 * time.Second
+	// clusterHealthParallelism the number of clusters checked at the same time
+	clusterHealthParallelism = 10
+	// clusterHealthLeaseTTL the lease of the reconciler outlives the interval, so the holder keeps it
+	// as long as it is alive, and another instance takes over after the holder stopped.
+	clusterHealthLeaseTTL = 3 * clusterHealthInterval
+)
+
+const clusterHealthLease = "cluster-health"
+
+// healthClients the clients to check the health of a cluster
+type healthClients struct {
+	kube kubernetes.Interface
+	// runtime creates the client of the rainbond resources, it requests the api to discover the resources,
+	// so it is only created after the api is reachable.
+	runtime func() (client.Client, error)
+}
+
+// getHealthClients returns the clients of the cluster, it is replaced in tests.
+var getHealthClients = func(c *ClusterUsecase, eid, clusterID, providerName string) (*healthClients, error) {
+	config, err := c.GetKubeConfig(eid, clusterID, providerName)
+	if err != nil {
+		return nil, err
+	}
+	kc := &v1alpha1.KubeConfig{Config: config}
+	restConfig, err := kc.ToKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Timeout = clusterHealthTimeout
+	kube, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &healthClients{
+		kube: kube,
+		runtime: func() (client.Client, error) {
+			_, runtimeClient, err := kc.GetKubeClient()
+			return runtimeClient, err
+		},
+	}, nil
+}
+
+// healthTarget a stored cluster to check
+type healthTarget struct {
+	eid       string
+	clusterID string
+	provider  string
+}
+
+// StartHealthReconciler checks the health of all stored clusters periodically until the context is done.
+// Only the instance holding the lease checks the clusters when several instances share the database.
+func (c *ClusterUsecase) StartHealthReconciler(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%s", hostname, uuidutil.NewUUID())
+	ticker := time.NewTicker(clusterHealthInterval)
+	defer ticker.Stop()
+	for {
+		acquired, err := c.leaseRepo.Acquire(clusterHealthLease, owner, clusterHealthLeaseTTL)
+		if err != nil {
+			logrus.Errorf("acquire the lease of the health reconciler: %v", err)
+		}
+		if acquired {
+			c.reconcileClusterHealth(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileClusterHealth checks the health of the rke and custom clusters of all enterprises and saves it.
+func (c *ClusterUsecase) reconcileClusterHealth(ctx context.Context) {
+	var targets []healthTarget
+	rkeClusters, err := c.rkeClusterRepo.ListAllClusters()
+	if err != nil {
+		logrus.Errorf("list rke clusters for health check: %v", err)
+	}
+	for _, cluster := range rkeClusters {
+		targets = append(targets, healthTarget{eid: cluster.EnterpriseID, clusterID: cluster.ClusterID, provider: "rke"})
+	}
+	customClusters, err := c.customClusterRepo.ListAllClusters()
+	if err != nil {
+		logrus.Errorf("list custom clusters for health check: %v", err)
+	}
+	for _, cluster := range customClusters {
+		targets = append(targets, healthTarget{eid: cluster.EnterpriseID, clusterID: cluster.ClusterID, provider: "custom"})
+	}
+
+	sem := make(chan struct{}, clusterHealthParallelism)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(target healthTarget) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			c.saveClusterHealth(target, c.checkClusterHealth(ctx, target))
+		}(target)
+	}
+	wg.Wait()
+}
+
+// saveClusterHealth saves the health unless the cluster has been deleted during the check.
+// The cluster is checked again after the save, the health is deleted if the cluster was deleted in the meantime.
+func (c *ClusterUsecase) saveClusterHealth(target healthTarget, health *model.ClusterHealth) {
+	exists, err := c.healthTargetExists(target)
+	if err != nil {
+		logrus.Errorf("get cluster %s for health check: %v", target.clusterID, err)
+		return
+	}
+	if !exists {
+		return
+	}
+	if err := c.clusterHealthRepo.Save(health); err != nil {
+		logrus.Errorf("save health of cluster %s: %v", target.clusterID, err)
+		return
+	}
+	if exists, err := c.healthTargetExists(target); err != nil || exists {
+		return
+	}
+	if err := c.clusterHealthRepo.Delete(target.eid, target.clusterID); err != nil {
+		logrus.Errorf("delete health of cluster %s: %v", target.clusterID, err)
+	}
+}
+
+// healthTargetExists returns whether the cluster is still stored.
+func (c *ClusterUsecase) healthTargetExists(target healthTarget) (bool, error) {
+	var err error
+	if target.provider == "rke" {
+		_, err = c.rkeClusterRepo.GetCluster(target.eid, target.clusterID)
+	} else {
+		_, err = c.customClusterRepo.GetCluster(target.eid, target.clusterID)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkClusterHealth checks the kubernetes api, the nodes and the rainbond region of the cluster.
+func (c *ClusterUsecase) checkClusterHealth(ctx context.Context, target healthTarget) *model.ClusterHealth {
+	health := &model.ClusterHealth{
+		EnterpriseID: target.eid,
+		ClusterID:    target.clusterID,
+		Provider:     target.provider,
+		Status:       v1alpha1.HealthUnknown,
+		RegionStatus: v1alpha1.RegionUnknown,
+		CheckedAt:    time.Now(),
+	}
+	clients, err := getHealthClients(c, target.eid, target.clusterID, target.provider)
+	if err != nil {
+		health.Reason = fmt.Sprintf("load kubeconfig: %v", err)
+		return health
+	}
+	if _, err := clients.kube.Discovery().ServerVersion(); err != nil {
+		health.Status = v1alpha1.HealthUnreachable
+		health.Reason = fmt.Sprintf("kubernetes api is unreachable: %v", err)
+		return health
+	}
+	now := time.Now()
+	health.APIReachable = true
+	health.LastSeen = &now
+	health.Status = v1alpha1.HealthHealthy
+
+	var reasons []string
+	ctx, cancel := context.WithTimeout(ctx, clusterHealthTimeout)
+	defer cancel()
+	nodes, err := clients.kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		health.Status = v1alpha1.HealthDegraded
+		reasons = append(reasons, fmt.Sprintf("list nodes: %v", err))
+	} else {
+		var notReady []string
+		for i := range nodes.Items {
+			if nodeReady(&nodes.Items[i]) {
+				health.ReadyNodes++
+			} else {
+				notReady = append(notReady, nodes.Items[i].Name)
+			}
+		}
+		health.TotalNodes = len(nodes.Items)
+		if len(notReady) > 0 {
+			health.Status = v1alpha1.HealthDegraded
+			reasons = append(reasons, fmt.Sprintf("nodes not ready: %s", strings.Join(notReady, ", ")))
+		}
+	}
+
+	regionStatus, reason := checkRegionHealth(ctx, clients)
+	health.RegionStatus = regionStatus
+	if regionStatus == v1alpha1.RegionNotReady {
+		health.Status = v1alpha1.HealthDegraded
+	}
+	if reason != "" {
+		reasons = append(reasons, reason)
+	}
+	health.Reason = strings.Join(reasons, "; ")
+	return health
+}
+
+// checkRegionHealth returns the status of the rainbond region and the reason if it is not ready.
+func checkRegionHealth(ctx context.Context, clients *healthClients) (string, string) {
+	runtimeClient, err := clients.runtime()
+	if err != nil {
+		return v1alpha1.RegionUnknown, fmt.Sprintf("create kube client: %v", err)
+	}
+	components := &rainbondv1alpha1.RbdComponentList{}
+	if err := runtimeClient.List(ctx, components, &client.ListOptions{Namespace: constants.Namespace}); err != nil {
+		if meta.IsNoMatchError(err) || k8sErrors.IsNotFound(err) {
+			return v1alpha1.RegionNotInstalled, ""
+		}
+		return v1alpha1.RegionUnknown, fmt.Sprintf("list rainbond components: %v", err)
+	}
+	if len(components.Items) == 0 {
+		return v1alpha1.RegionNotInstalled, ""
+	}
+	var notReady []string
+	for _, cpt := range components.Items {
+		if cpt.Status.ReadyReplicas < cpt.Status.Replicas {
+			notReady = append(notReady, cpt.Name)
+		}
+	}
+	if len(notReady) > 0 {
+		return v1alpha1.RegionNotReady, fmt.Sprintf("rainbond components not ready: %s", strings.Join(notReady, ", "))
+	}
+	return v1alpha1.RegionReady, ""
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// attachClusterHealth sets the stored health to the clusters of the enterprise.
+func (c *ClusterUsecase) attachClusterHealth(eid string, clusters []*v1alpha1.Cluster) {
+	list, err := c.clusterHealthRepo.List(eid)
+	if err != nil {
+		logrus.Errorf("list health of the clusters of enterprise %s: %v", eid, err)
+		return
+	}
+	healths := make(map[string]*model.ClusterHealth, len(list))
+	for _, health := range list {
+		healths[health.ClusterID] = health
+	}
+	for _, cluster := range clusters {
+		health, ok := healths[cluster.ClusterID]
+		if !ok {
+			continue
+		}
+		cluster.Health = &v1alpha1.ClusterHealth{
+			Status:       health.Status,
+			APIReachable: health.APIReachable,
+			ReadyNodes:   health.ReadyNodes,
+			TotalNodes:   health.TotalNodes,
+			RegionStatus: health.RegionStatus,
+			Reason:       health.Reason,
+			LastSeen:     health.LastSeen,
+			CheckedAt:    health.CheckedAt,
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/util/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func healthTestNode(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: ready},
+		}},
+	}
+}
+
+func healthTestComponent(name string, replicas, ready int32) *rainbondv1alpha1.RbdComponent {
+	return &rainbondv1alpha1.RbdComponent{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: constants.Namespace},
+		Status:     rainbondv1alpha1.RbdComponentStatus{Replicas: replicas, ReadyReplicas: ready},
+	}
+}
+
+func TestReconcileClusterHealth(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "rke", ClusterID: "rke"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.customClusterRepo.Create(&model.CustomCluster{EnterpriseID: "eid", Name: "custom", ClusterID: "custom"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.customClusterRepo.Create(&model.CustomCluster{EnterpriseID: "eid", Name: "broken", ClusterID: "broken"}); err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	rainbondv1alpha1.AddToScheme(scheme)
+	clients := map[string]*healthClients{
+		"rke": {
+			kube: fake.NewSimpleClientset(healthTestNode("n1", corev1.ConditionTrue), healthTestNode("n2", corev1.ConditionTrue)),
+			runtime: func() (client.Client, error) {
+				return runtimefake.NewClientBuilder().WithScheme(scheme).WithObjects(healthTestComponent("rbd-api", 1, 1)).Build(), nil
+			},
+		},
+		"custom": {
+			kube: fake.NewSimpleClientset(healthTestNode("n1", corev1.ConditionTrue), healthTestNode("n2", corev1.ConditionFalse)),
+			runtime: func() (client.Client, error) {
+				return runtimefake.NewClientBuilder().WithScheme(scheme).WithObjects(healthTestComponent("rbd-api", 2, 1)).Build(), nil
+			},
+		},
+	}
+	old := getHealthClients
+	getHealthClients = func(c *ClusterUsecase, eid, clusterID, providerName string) (*healthClients, error) {
+		if hc, ok := clients[clusterID]; ok {
+			return hc, nil
+		}
+		return nil, errors.New("kubeconfig not found")
+	}
+	defer func() { getHealthClients = old }()
+
+	list := func() map[string]*model.ClusterHealth {
+		healths, err := c.clusterHealthRepo.List("eid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make(map[string]*model.ClusterHealth)
+		for _, health := range healths {
+			res[health.ClusterID] = health
+		}
+		return res
+	}
+
+	c.reconcileClusterHealth(context.Background())
+	healths := list()
+	if h := healths["rke"]; h == nil || h.Status != v1alpha1.HealthHealthy || h.RegionStatus != v1alpha1.RegionReady ||
+		h.ReadyNodes != 2 || h.TotalNodes != 2 || h.LastSeen == nil || h.Provider != "rke" {
+		t.Fatalf("unexpected health of rke cluster: %+v", h)
+	}
+	if h := healths["custom"]; h == nil || h.Status != v1alpha1.HealthDegraded || h.RegionStatus != v1alpha1.RegionNotReady ||
+		h.ReadyNodes != 1 || !strings.Contains(h.Reason, "n2") || !strings.Contains(h.Reason, "rbd-api") {
+		t.Fatalf("unexpected health of custom cluster: %+v", h)
+	}
+	if h := healths["broken"]; h == nil || h.Status != v1alpha1.HealthUnknown || h.LastSeen != nil || !strings.Contains(h.Reason, "kubeconfig") {
+		t.Fatalf("unexpected health of broken cluster: %+v", h)
+	}
+	lastSeen := *healths["rke"].LastSeen
+
+	// the api of the rke cluster goes away, the last seen time is kept.
+	unreachable, err := kubernetes.NewForConfig(&rest.Config{Host: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients["rke"] = &healthClients{kube: unreachable}
+	c.reconcileClusterHealth(context.Background())
+	healths = list()
+	if h := healths["rke"]; h.Status != v1alpha1.HealthUnreachable || h.APIReachable || h.LastSeen == nil ||
+		!h.LastSeen.Equal(lastSeen) || !h.CheckedAt.After(lastSeen) {
+		t.Fatalf("unexpected health of unreachable cluster: %+v", h)
+	}
+
+	clusters := []*v1alpha1.Cluster{{ClusterID: "rke"}, {ClusterID: "custom"}, {ClusterID: "other"}}
+	c.attachClusterHealth("eid", clusters)
+	if clusters[0].Health == nil || clusters[0].Health.Status != v1alpha1.HealthUnreachable ||
+		clusters[1].Health == nil || clusters[1].Health.Status != v1alpha1.HealthDegraded || clusters[2].Health != nil {
+		t.Fatalf("unexpected health of the listed clusters: %+v %+v %+v", clusters[0].Health, clusters[1].Health, clusters[2].Health)
+	}
+
+	if err := c.clusterHealthRepo.Delete("eid", "rke"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list()["rke"]; ok {
+		t.Fatal("the health of the deleted cluster is still stored")
+	}
+
+	// the cluster is deleted while its health is checked, the health is not stored again.
+	getHealthClients = func(c *ClusterUsecase, eid, clusterID, providerName string) (*healthClients, error) {
+		if clusterID == "custom" {
+			if err := c.customClusterRepo.DeleteCluster(eid, clusterID); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.clusterHealthRepo.Delete(eid, clusterID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nil, errors.New("kubeconfig not found")
+	}
+	c.reconcileClusterHealth(context.Background())
+	if _, ok := list()["custom"]; ok {
+		t.Fatal("the health of the cluster deleted during the check is stored")
+	}
+}
+
+func TestHealthReconcilerLease(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "rke", ClusterID: "rke"}); err != nil {
+		t.Fatal(err)
+	}
+	old := getHealthClients
+	getHealthClients = func(c *ClusterUsecase, eid, clusterID, providerName string) (*healthClients, error) {
+		return nil, errors.New("kubeconfig not found")
+	}
+	defer func() { getHealthClients = old }()
+
+	if ok, err := c.leaseRepo.Acquire(clusterHealthLease, "other", time.Minute); err != nil || !ok {
+		t.Fatalf("acquire lease: %v %v", ok, err)
+	}
+	if ok, err := c.leaseRepo.Acquire(clusterHealthLease, "another", time.Minute); err != nil || ok {
+		t.Fatalf("the lease held by other is acquired: %v %v", ok, err)
+	}
+	runOnce := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			c.StartHealthReconciler(ctx)
+			close(done)
+		}()
+		// the first round runs before the loop waits for the ticker
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+		<-done
+	}
+	runOnce()
+	if healths, _ := c.clusterHealthRepo.List("eid"); len(healths) != 0 {
+		t.Fatalf("the clusters are checked without the lease: %+v", healths)
+	}
+
+	// the holder stopped and the lease expired, the reconciler takes over.
+	if err := c.DB.Model(&model.Lease{}).Where("name = ?", clusterHealthLease).Update("expire_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	runOnce()
+	if healths, _ := c.clusterHealthRepo.List("eid"); len(healths) != 1 {
+		t.Fatalf("the clusters are not checked after the lease expired: %+v", healths)
+	}
+	if ok, err := c.leaseRepo.Acquire(clusterHealthLease, "other", time.Minute); err != nil || ok {
+		t.Fatalf("the lease taken over is acquired by the old holder: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,254 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+	"gorm.io/gorm"
+)
+
+const (
+	clusterLogName         = "create.log"
+	defaultClusterLogLimit = 64 * 1024
+	maxClusterLogLimit     = 1024 * 1024
+)
+
+// the interval to read the new content of the followed log
+var followClusterLogInterval = time.Second
+
+func (c *ClusterUsecase) getRKECluster(eid, clusterID string) (*model.RKECluster, error) {
+	cluster, err := c.rkeClusterRepo.GetCluster(eid, clusterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(bcode.ErrClusterNotFound)
+		}
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// clusterLogKey returns the key of the log in the rke state store, the name must be create.log or a rotated one.
+func clusterLogKey(cluster *model.RKECluster, name string) (string, error) {
+	if cluster.CreateLogPath == "" {
+		return "", errors.WithStack(bcode.ErrClusterLogNotFound)
+	}
+	if name != clusterLogName {
+		suffix := strings.TrimPrefix(name, clusterLogName+".")
+		if suffix == name {
+			return "", errors.WithStack(bcode.ErrClusterLogNotFound)
+		}
+		if _, err := time.Parse(time.RFC3339, suffix); err != nil {
+			return "", errors.WithStack(bcode.ErrClusterLogNotFound)
+		}
+	}
+	return repo.RKEStatePrefix(cluster.EnterpriseID, cluster.Name) + name, nil
+}
+
+// GetCreateLogContent returns the whole content of the current install log, it is empty if the log does not exist.
+func (c *ClusterUsecase) GetCreateLogContent(eid, clusterID string) (string, error) {
+	cluster, err := c.getRKECluster(eid, clusterID)
+	if err != nil {
+		return "", err
+	}
+	if cluster.CreateLogPath == "" {
+		return "", nil
+	}
+	content, err := repo.GetRKEStateFile(c.rkeStateStore, cluster.EnterpriseID, cluster.Name, clusterLogName)
+	if err != nil && err != blobstore.ErrNotFound {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ListClusterLogs lists the install log and the rotated logs of the expansions, the latest first.
+func (c *ClusterUsecase) ListClusterLogs(eid, clusterID string) ([]*v1.ClusterLog, error) {
+	cluster, err := c.getRKECluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.CreateLogPath == "" {
+		return nil, nil
+	}
+	if err := repo.ImportLegacyRKEState(c.rkeStateStore, cluster.EnterpriseID, cluster.Name); err != nil {
+		return nil, err
+	}
+	prefix := repo.RKEStatePrefix(cluster.EnterpriseID, cluster.Name)
+	blobs, err := c.rkeStateStore.List(prefix + clusterLogName)
+	if err != nil {
+		return nil, err
+	}
+	var logs []*v1.ClusterLog
+	for _, blob := range blobs {
+		name := strings.TrimPrefix(blob.Key, prefix)
+		if _, err := clusterLogKey(cluster, name); err != nil {
+			continue
+		}
+		logs = append(logs, &v1.ClusterLog{
+			Name:    name,
+			Size:    blob.Size,
+			ModTime: blob.ModTime,
+			Current: name == clusterLogName,
+		})
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].Current != logs[j].Current {
+			return logs[i].Current
+		}
+		return logs[i].Name > logs[j].Name
+	})
+	return logs, nil
+}
+
+// ReadClusterLog reads the log from the byte offset.
+func (c *ClusterUsecase) ReadClusterLog(eid, clusterID, name string, offset, limit int64) (*v1.ClusterLogContentRes, error) {
+	cluster, err := c.getRKECluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := clusterLogKey(cluster, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.ImportLegacyRKEState(c.rkeStateStore, cluster.EnterpriseID, cluster.Name); err != nil {
+		return nil, err
+	}
+	return c.readClusterLog(key, name, offset, limit)
+}
+
+func (c *ClusterUsecase) readClusterLog(key, name string, offset, limit int64) (*v1.ClusterLogContentRes, error) {
+	if limit <= 0 {
+		limit = defaultClusterLogLimit
+	}
+	if limit > maxClusterLogLimit {
+		limit = maxClusterLogLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := c.rkeStateStore.Get(key)
+	if err != nil {
+		if err == blobstore.ErrNotFound {
+			return nil, errors.WithStack(bcode.ErrClusterLogNotFound)
+		}
+		return nil, err
+	}
+	size := int64(len(data))
+	// the log is rewritten by a new install
+	if offset > size {
+		offset = 0
+	}
+	end := offset + limit
+	if end > size {
+		end = size
+	}
+	return &v1.ClusterLogContentRes{
+		Name:       name,
+		Offset:     offset,
+		NextOffset: end,
+		Size:       size,
+		Content:    string(data[offset:end]),
+		EOF:        end >= size,
+	}, nil
+}
+
+// FollowClusterLog returns the chunks of the log from the byte offset. The channel is closed
+// when the log is read to the end and the cluster is not installing, or the ctx is done.
+func (c *ClusterUsecase) FollowClusterLog(ctx context.Context, eid, clusterID, name string, offset int64) (<-chan *v1.ClusterLogContentRes, error) {
+	cluster, err := c.getRKECluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := clusterLogKey(cluster, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.ImportLegacyRKEState(c.rkeStateStore, cluster.EnterpriseID, cluster.Name); err != nil {
+		return nil, err
+	}
+	if _, err := c.rkeStateStore.Stat(key); err != nil {
+		if err == blobstore.ErrNotFound {
+			return nil, errors.WithStack(bcode.ErrClusterLogNotFound)
+		}
+		return nil, err
+	}
+
+	chunks := make(chan *v1.ClusterLogContentRes, 10)
+	go func() {
+		defer close(chunks)
+		ticker := time.NewTicker(followClusterLogInterval)
+		defer ticker.Stop()
+		// the metadata of the log when it was read last time
+		var read *blobstore.Blob
+		for {
+			// check the state before reading, the content written before the install finished will be read.
+			installing := name == clusterLogName && c.isRKEClusterInstalling(eid, clusterID)
+			blob, err := c.rkeStateStore.Stat(key)
+			if err != nil {
+				return
+			}
+			// the log is loaded only if it is not read to the end or it is changed
+			if read == nil || offset < blob.Size || blob.Size != read.Size || !blob.ModTime.Equal(read.ModTime) {
+				read = blob
+				chunk, err := c.readClusterLog(key, name, offset, maxClusterLogLimit)
+				if err != nil {
+					return
+				}
+				if chunk.NextOffset != offset || chunk.Offset != offset {
+					select {
+					case chunks <- chunk:
+					case <-ctx.Done():
+						return
+					}
+					offset = chunk.NextOffset
+				}
+				if !chunk.EOF {
+					continue
+				}
+			}
+			if !installing {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+func (c *ClusterUsecase) isRKEClusterInstalling(eid, clusterID string) bool {
+	cluster, err := c.rkeClusterRepo.GetCluster(eid, clusterID)
+	if err != nil {
+		return false
+	}
+	return cluster.Stats == v1alpha1.InstallingState || cluster.Stats == v1alpha1.InitState
+}
@@ -0,0 +1,179 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/blobstore"
+)
+
+// countingStore counts the blobs loaded with the contents
+type countingStore struct {
+	blobstore.Store
+	gets int32
+}
+
+func (s *countingStore) Get(key string) ([]byte, error) {
+	atomic.AddInt32(&s.gets, 1)
+	return s.Store.Get(key)
+}
+
+func TestReadClusterLog(t *testing.T) {
+	db := newTestDB(t)
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	c := &ClusterUsecase{rkeClusterRepo: repo.NewRKEClusterRepo(db), rkeStateStore: repo.NewBlobRepo(db)}
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1",
+		CreateLogPath: repo.RKEStatePrefix("eid", "c1") + "create.log"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"create.log":                           "0123456789",
+		"create.log.2021-08-01T10:00:00+08:00": "old",
+		"create.log.bak":                       "invalid",
+	} {
+		if err := c.rkeStateStore.Put(repo.RKEStatePrefix("eid", "c1")+name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logs, err := c.ListClusterLogs("eid", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || !logs[0].Current || logs[1].Name != "create.log.2021-08-01T10:00:00+08:00" {
+		t.Fatalf("unexpected logs %+v", logs)
+	}
+
+	chunk, err := c.ReadClusterLog("eid", "c1", "create.log", 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Content != "456" || chunk.NextOffset != 7 || chunk.EOF {
+		t.Fatalf("unexpected chunk %+v", chunk)
+	}
+	chunk, err = c.ReadClusterLog("eid", "c1", "create.log", chunk.NextOffset, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Content != "789" || !chunk.EOF {
+		t.Fatalf("unexpected chunk %+v", chunk)
+	}
+	if _, err := c.ReadClusterLog("eid", "c1", "../create.log", 0, 0); err == nil {
+		t.Fatal("expected the invalid log name is rejected")
+	}
+}
+
+func TestReadLegacyClusterLog(t *testing.T) {
+	db := newTestDB(t)
+	configDir := t.TempDir()
+	t.Setenv("CONFIG_DIR", configDir)
+	c := &ClusterUsecase{rkeClusterRepo: repo.NewRKEClusterRepo(db), rkeStateStore: repo.NewBlobRepo(db)}
+	dir := filepath.Join(configDir, "enterprise", "eid", "rke", "c1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "create.log"), []byte("legacy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1",
+		CreateLogPath: filepath.Join(dir, "create.log")}); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk, err := c.ReadClusterLog("eid", "c1", "create.log", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Content != "legacy" || !chunk.EOF {
+		t.Fatalf("unexpected chunk %+v", chunk)
+	}
+	if _, err := c.rkeStateStore.Get(repo.RKEStatePrefix("eid", "c1") + "create.log"); err != nil {
+		t.Fatalf("expected the legacy log is imported to the store: %v", err)
+	}
+}
+
+func TestFollowClusterLog(t *testing.T) {
+	interval := followClusterLogInterval
+	followClusterLogInterval = 10 * time.Millisecond
+	defer func() { followClusterLogInterval = interval }()
+	db := newTestDB(t)
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	store := &countingStore{Store: repo.NewBlobRepo(db)}
+	c := &ClusterUsecase{rkeClusterRepo: repo.NewRKEClusterRepo(db), rkeStateStore: store}
+	cluster := &model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1", Stats: v1alpha1.InstallingState,
+		CreateLogPath: repo.RKEStatePrefix("eid", "c1") + "create.log"}
+	if err := c.rkeClusterRepo.Create(cluster); err != nil {
+		t.Fatal(err)
+	}
+	key := repo.RKEStatePrefix("eid", "c1") + "create.log"
+	if err := store.Put(key, []byte("0123")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	chunks, err := c.FollowClusterLog(ctx, "eid", "c1", "create.log", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := func() string {
+		select {
+		case chunk := <-chunks:
+			if chunk == nil {
+				return ""
+			}
+			return chunk.Content
+		case <-ctx.Done():
+			t.Fatal("receive log timeout")
+		}
+		return ""
+	}
+	if content := receive(); content != "0123" {
+		t.Fatalf("unexpected content %s", content)
+	}
+	// the unchanged log is not loaded again
+	time.Sleep(10 * followClusterLogInterval)
+	if gets := atomic.LoadInt32(&store.gets); gets != 1 {
+		t.Fatalf("expected the log is loaded once, got %d", gets)
+	}
+
+	if err := store.Put(key, []byte("012345")); err != nil {
+		t.Fatal(err)
+	}
+	if content := receive(); content != "45" {
+		t.Fatalf("unexpected content %s", content)
+	}
+	cluster.Stats = v1alpha1.RunningState
+	if err := c.rkeClusterRepo.Update(cluster); err != nil {
+		t.Fatal(err)
+	}
+	if content := receive(); content != "" {
+		t.Fatalf("expected the channel closed after the install, got %s", content)
+	}
+}
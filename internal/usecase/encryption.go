@@ -0,0 +1,106 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+func (c *ClusterUsecase) getSecretsEncryptor(eid, providerName string) (adaptor.SecretsEncryptor, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilitySecretsEncryption); err != nil {
+		return nil, err
+	}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	encryptor, ok := ad.(adaptor.SecretsEncryptor)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return encryptor, nil
+}
+
+// GetSecretsEncryptionStatus returns the status of the secrets encryption of the cluster.
+func (c *ClusterUsecase) GetSecretsEncryptionStatus(eid, clusterID, providerName string) (*v1alpha1.SecretsEncryptionStatus, error) {
+	encryptor, err := c.getSecretsEncryptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return encryptor.GetSecretsEncryptionStatus(eid, clusterID)
+}
+
+// EnableSecretsEncryption enables the secrets encryption of the cluster in background.
+func (c *ClusterUsecase) EnableSecretsEncryption(eid, clusterID, providerName string) (*model.OperationTask, error) {
+	encryptor, err := c.getSecretsEncryptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	status, err := encryptor.GetSecretsEncryptionStatus(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if status.Enabled && status.Active {
+		return nil, errors.WithStack(bcode.ErrSecretsEncryptionEnabled)
+	}
+	return c.runClusterStateTask(eid, clusterID, providerName, domain.ClusterTaskTypeEnableSecretsEncryption, nil)
+}
+
+// RotateSecretsEncryptionKey rotates the key of the secrets encryption of the cluster in background.
+func (c *ClusterUsecase) RotateSecretsEncryptionKey(eid, clusterID, providerName string) (*model.OperationTask, error) {
+	encryptor, err := c.getSecretsEncryptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	status, err := encryptor.GetSecretsEncryptionStatus(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Active {
+		return nil, errors.WithStack(bcode.ErrSecretsEncryptionNotEnabled)
+	}
+	if status.CustomConfig {
+		return nil, errors.WithStack(bcode.ErrSecretsEncryptionCustomConfig)
+	}
+	return c.runClusterStateTask(eid, clusterID, providerName, domain.ClusterTaskTypeRotateEncryptionKey, nil)
+}
+
+func (c *ClusterUsecase) runEnableSecretsEncryption(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	encryptor, err := c.getSecretsEncryptor(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return encryptor.EnableSecretsEncryption(ctx, task.EnterpriseID, task.ClusterID, rollback)
+}
+
+func (c *ClusterUsecase) runRotateSecretsEncryptionKey(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	encryptor, err := c.getSecretsEncryptor(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return encryptor.RotateSecretsEncryptionKey(ctx, task.EnterpriseID, task.ClusterID, rollback)
+}
@@ -0,0 +1,101 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// encryptorAdaptor enables the encryption and rotates the key by the phases
+type encryptorAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	status v1alpha1.SecretsEncryptionStatus
+}
+
+func (e *encryptorAdaptor) GetSecretsEncryptionStatus(eid, clusterID string) (*v1alpha1.SecretsEncryptionStatus, error) {
+	status := e.status
+	return &status, nil
+}
+
+func (e *encryptorAdaptor) EnableSecretsEncryption(ctx context.Context, eid, clusterID string, rollback func(step, message, status string)) error {
+	e.status = v1alpha1.SecretsEncryptionStatus{Enabled: true, Active: true, ActiveKey: "key-1"}
+	return nil
+}
+
+func (e *encryptorAdaptor) RotateSecretsEncryptionKey(ctx context.Context, eid, clusterID string, rollback func(step, message, status string)) error {
+	for _, step := range []string{v1.StepDeployEncryptionKey, v1.StepRewriteSecrets, v1.StepRemoveEncryptionKey} {
+		rollback(step, "", "start")
+		rollback(step, "", "success")
+	}
+	e.status.ActiveKey = "key-2"
+	return nil
+}
+
+func TestSecretsEncryption(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	encryptor := &encryptorAdaptor{}
+	registerTestProvider(t, "test-encryptor", adaptor.InterfaceSecretsEncryptor, adaptor.CapabilitySecretsEncryption, encryptor)
+
+	if _, err := c.RotateSecretsEncryptionKey("eid", "cluster", "test-encryptor"); errors.Cause(err) != bcode.ErrSecretsEncryptionNotEnabled {
+		t.Errorf("expected ErrSecretsEncryptionNotEnabled, got %v", err)
+	}
+
+	task, err := c.EnableSecretsEncryption("eid", "cluster", "test-encryptor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitOperationTask(t, c, task)
+	if _, err := c.EnableSecretsEncryption("eid", "cluster", "test-encryptor"); errors.Cause(err) != bcode.ErrSecretsEncryptionEnabled {
+		t.Errorf("expected ErrSecretsEncryptionEnabled, got %v", err)
+	}
+
+	task, err = c.RotateSecretsEncryptionKey("eid", "cluster", "test-encryptor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	success := make(map[string]bool)
+	for _, event := range events {
+		success[event.StepType] = event.Status == "success"
+	}
+	for _, step := range []string{v1.StepDeployEncryptionKey, v1.StepRewriteSecrets, v1.StepRemoveEncryptionKey, v1.StepRotateEncryptionKey} {
+		if !success[step] {
+			t.Errorf("expect the event of %s is success", step)
+		}
+	}
+	status, err := c.GetSecretsEncryptionStatus("eid", "cluster", "test-encryptor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ActiveKey != "key-2" {
+		t.Errorf("expected the active key is key-2, got %s", status.ActiveKey)
+	}
+
+	encryptor.status.CustomConfig = true
+	if _, err := c.RotateSecretsEncryptionKey("eid", "cluster", "test-encryptor"); errors.Cause(err) != bcode.ErrSecretsEncryptionCustomConfig {
+		t.Errorf("expected ErrSecretsEncryptionCustomConfig, got %v", err)
+	}
+}
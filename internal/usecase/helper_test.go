@@ -0,0 +1,125 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/datastore"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/nsqc/producer"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(path.Join(t.TempDir(), "db.sqlite3")), &gorm.Config{
+		NamingStrategy: &schema.NamingStrategy{TablePrefix: "adaptor_"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// operationTaskProducer saves the task messages and runs the operation tasks at once, instead of the task consumer.
+type operationTaskProducer struct {
+	producer.TaskProducer
+	c *ClusterUsecase
+}
+
+func (p *operationTaskProducer) SendOperationTask(config types.OperationTaskMessage) error {
+	if err := p.TaskProducer.SendOperationTask(config); err != nil {
+		return err
+	}
+	go p.c.RunOperationTask(context.Background(), config)
+	return nil
+}
+
+// newTestClusterUsecase returns the cluster usecase with the repositories of a new test database.
+func newTestClusterUsecase(t *testing.T) *ClusterUsecase {
+	db := newTestDB(t)
+	c := NewClusterUsecase(ClusterUsecaseDeps{
+		DB:                        db,
+		CloudAccessKeyRepo:        repo.NewCloudAccessKeyRepo(db),
+		CreateKubernetesTaskRepo:  repo.NewCreateKubernetesTaskRepo(db),
+		InitRainbondTaskRepo:      repo.NewInitRainbondRegionTaskRepo(db),
+		UpdateKubernetesTaskRepo:  repo.NewUpdateKubernetesTaskRepo(db),
+		TaskEventRepo:             repo.NewTaskEventRepo(db),
+		TaskResultRepo:            repo.NewTaskResultRepo(db),
+		TaskMessageRepo:           repo.NewTaskMessageRepo(db),
+		RainbondClusterConfigRepo: repo.NewRainbondClusterConfigRepo(db),
+		RKEClusterRepo:            repo.NewRKEClusterRepo(db),
+		CustomClusterRepo:         repo.NewCustomClusterRepo(db),
+		OperationTaskRepo:         repo.NewOperationTaskRepo(db),
+		CloudResourceRepo:         repo.NewCloudResourceRepo(db),
+		RKEStateStore:             repo.NewRKEStateStore(db),
+		SSHKeyRepo:                repo.NewSSHKeyRepo(db),
+		SSHBastionRepo:            repo.NewSSHBastionRepo(db),
+		InitNodeTokenRepo:         repo.NewInitNodeTokenRepo(db),
+		ClusterHealthRepo:         repo.NewClusterHealthRepo(db),
+		LeaseRepo:                 repo.NewLeaseRepo(db),
+	})
+	c.TaskProducer = &operationTaskProducer{TaskProducer: producer.NewTaskDBProducer(c.TaskMessageRepo, nil), c: c}
+	return c
+}
+
+// registerTestProvider registers the fake adaptor with the interface and the capability until the test finished.
+func registerTestProvider(t *testing.T, name, iface, capability string, a adaptor.RainbondClusterAdaptor) {
+	adaptor.Register(&adaptor.Provider{
+		Name:         name,
+		Interfaces:   []string{iface},
+		Capabilities: []string{capability},
+		Create: func(accessKeyID, accessKeySecret string) (adaptor.RainbondClusterAdaptor, error) {
+			return a, nil
+		},
+	})
+	t.Cleanup(func() {
+		adaptor.Unregister(name)
+	})
+}
+
+func waitOperationTask(t *testing.T, c *ClusterUsecase, task *model.OperationTask) []*model.TaskEvent {
+	for i := 0; i < 100; i++ {
+		current, err := c.operationTaskRepo.GetTask(task.EnterpriseID, task.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Status == "complete" {
+			events, err := c.TaskEventRepo.ListEventAfter(task.EnterpriseID, task.TaskID, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return events
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("task %s is not complete", task.TaskID)
+	return nil
+}
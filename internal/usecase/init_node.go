@@ -0,0 +1,127 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/cmd/cloud-adaptor/config"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/constants"
+	"goodrain.com/cloud-adaptor/script"
+)
+
+// initNodeTokenTTL the time the link of the init node script is valid for
+const initNodeTokenTTL = 15 * time.Minute
+
+var (
+	// initNodeKernelModules the kernel modules required by docker, kube-proxy and the network plugins
+	initNodeKernelModules = []string{"br_netfilter", "overlay", "ip_tables", "iptable_filter", "iptable_nat",
+		"nf_conntrack", "nf_nat", "veth", "vxlan", "xt_conntrack"}
+	// initNodeSysctl the kernel parameters required by kubernetes
+	initNodeSysctl = []string{
+		"net.bridge.bridge-nf-call-iptables = 1",
+		"net.bridge.bridge-nf-call-ip6tables = 1",
+		"net.ipv4.ip_forward = 1",
+		"vm.max_map_count = 262144",
+	}
+	initNodeScriptTemplate = template.Must(template.New("init_node").Funcs(template.FuncMap{"quote": shellQuote}).Parse(script.InitNodeTemplate))
+)
+
+// shellQuote quotes the string as a single word of bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// advertiseURL returns the url the nodes reach cloud adaptor with. It is configured rather than built from the host of the request,
+// which is controlled by the client.
+func advertiseURL() (string, error) {
+	parsed, err := url.Parse(config.C.AdvertiseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", errors.WithStack(bcode.ErrAdvertiseURLInvalid)
+	}
+	return strings.TrimSuffix(config.C.AdvertiseURL, "/"), nil
+}
+
+// initNodeScriptURL returns the url of the init node script at the baseURL
+func initNodeScriptURL(baseURL, token string) string {
+	return baseURL + "/" + constants.Service + "/api/v1/init_node_script/" + token
+}
+
+// initNodeCmd returns the command downloading the script to a temp file before running it,
+// so the command fails if the script is not downloaded, such as the token is expired.
+func initNodeCmd(scriptURL string) string {
+	return fmt.Sprintf(`f=$(mktemp) && curl -sfL -o "$f" %s && bash "$f"`, shellQuote(scriptURL))
+}
+
+// createInitNodeToken creates the token of downloading the init node script once before it expires.
+func (c *ClusterUsecase) createInitNodeToken(eid, registryMirror string) (*model.InitNodeToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generate init node token")
+	}
+	token := &model.InitNodeToken{
+		Token:          hex.EncodeToString(b),
+		EnterpriseID:   eid,
+		RegistryMirror: registryMirror,
+		ExpiredAt:      time.Now().Add(initNodeTokenTTL),
+	}
+	if err := c.initNodeTokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// GetInitNodeScript consumes the token and returns the init node script, which authorizes the public key of the enterprise
+// of the token, loads the kernel modules and the sysctl settings, and installs docker with the bundled script.
+func (c *ClusterUsecase) GetInitNodeScript(token string) (string, error) {
+	t, err := c.initNodeTokenRepo.Consume(token)
+	if err != nil {
+		return "", err
+	}
+	pub, err := c.getSSHPublicKey(t.EnterpriseID)
+	if err != nil {
+		return "", errors.Wrap(err, "get or create ssh key")
+	}
+	return renderInitNodeScript(strings.TrimSpace(pub), t.RegistryMirror)
+}
+
+// renderInitNodeScript renders the init node script, all values are quoted in the script.
+func renderInitNodeScript(publicKey, registryMirror string) (string, error) {
+	var buf bytes.Buffer
+	if err := initNodeScriptTemplate.Execute(&buf, map[string]interface{}{
+		"PublicKey":      publicKey,
+		"RegistryMirror": registryMirror,
+		"KernelModules":  initNodeKernelModules,
+		"Sysctl":         initNodeSysctl,
+		"InstallDocker":  script.InstallDocker,
+	}); err != nil {
+		return "", errors.Wrap(err, "render init node script")
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,145 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/cmd/cloud-adaptor/config"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// initNodeCmdToken returns the token in the url of the init node command
+func initNodeCmdToken(t *testing.T, cmd string) string {
+	prefix := `f=$(mktemp) && curl -sfL -o "$f" '` + config.C.AdvertiseURL + "/enterprise-server/api/v1/init_node_script/"
+	suffix := `' && bash "$f"`
+	if !strings.HasPrefix(cmd, prefix) || !strings.HasSuffix(cmd, suffix) {
+		t.Fatalf("expected the command downloads the script from cloud adaptor, got %s", cmd)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(cmd, prefix), suffix)
+}
+
+// getInitNodeScript gets the init node command of the enterprise and downloads the script with the token in it
+func getInitNodeScript(t *testing.T, c *ClusterUsecase, eid string, req *v1.InitNodeCmdReq) string {
+	res, err := c.GetInitNodeCmd(context.Background(), eid, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := c.GetInitNodeScript(initNodeCmdToken(t, res.Cmd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestGetInitNodeScript(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	old := config.C
+	config.C = &config.Config{AdvertiseURL: "http://127.0.0.1:8080"}
+	defer func() { config.C = old }()
+
+	script := getInitNodeScript(t, c, "eid", &v1.InitNodeCmdReq{RegistryMirror: "https://mirror.example.com/it's"})
+	key, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"export SSH_RSA='" + strings.TrimSpace(key.PublicKey) + "'",
+		`export REGISTRY_MIRROR='https://mirror.example.com/it'"'"'s'`,
+		"br_netfilter",
+		"net.ipv4.ip_forward = 1",
+		"function install_docker()",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script contains %s", want)
+		}
+	}
+	if bash, err := exec.LookPath("bash"); err == nil {
+		cmd := exec.Command(bash, "-n")
+		cmd.Stdin = strings.NewReader(script)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("the script is invalid: %s", out)
+		}
+	}
+	// the builtin mirrors of the bundled script are used without the registry mirror
+	if script := getInitNodeScript(t, c, "eid", &v1.InitNodeCmdReq{}); strings.Contains(script, "export REGISTRY_MIRROR='") {
+		t.Error("expected no registry mirror")
+	}
+
+	// the token can be used once
+	res, err := c.GetInitNodeCmd(context.Background(), "eid", &v1.InitNodeCmdReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := initNodeCmdToken(t, res.Cmd)
+	if _, err := c.GetInitNodeScript(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetInitNodeScript(token); errors.Cause(err) != bcode.ErrInitNodeTokenInvalid {
+		t.Fatalf("expected ErrInitNodeTokenInvalid for the used token, got %v", err)
+	}
+	if _, err := c.GetInitNodeScript("unknown"); errors.Cause(err) != bcode.ErrInitNodeTokenInvalid {
+		t.Fatalf("expected ErrInitNodeTokenInvalid for the unknown token, got %v", err)
+	}
+	expired := &model.InitNodeToken{Token: "expired", EnterpriseID: "eid", ExpiredAt: time.Now().Add(-time.Second)}
+	if err := c.DB.Create(expired).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetInitNodeScript("expired"); errors.Cause(err) != bcode.ErrInitNodeTokenInvalid {
+		t.Fatalf("expected ErrInitNodeTokenInvalid for the expired token, got %v", err)
+	}
+
+	if res.ExpiredAt == nil || res.ExpiredAt.Before(time.Now()) {
+		t.Fatalf("expected the expiry of the token, got %v", res.ExpiredAt)
+	}
+
+	// the command is not built without the advertise url
+	for _, advertiseURL := range []string{"", "adaptor.example.com", "ftp://adaptor.example.com"} {
+		config.C.AdvertiseURL = advertiseURL
+		if _, err := c.GetInitNodeCmd(context.Background(), "eid", &v1.InitNodeCmdReq{}); errors.Cause(err) != bcode.ErrAdvertiseURLInvalid {
+			t.Errorf("expected ErrAdvertiseURLInvalid for the advertise url %q, got %v", advertiseURL, err)
+		}
+	}
+}
+
+func TestInitNodeCmdFailsWithoutScript(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	// curl -f exits with 22 if the token is expired
+	bin := t.TempDir()
+	if err := os.WriteFile(path.Join(bin, "curl"), []byte("#!/bin/sh\nexit 22\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(bash, "-c", initNodeCmd("http://127.0.0.1:8080/enterprise-server/api/v1/init_node_script/expired"))
+	cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("expected the command fails if the script is not downloaded, got %s", out)
+	}
+}
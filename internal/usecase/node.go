@@ -0,0 +1,114 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rke/types"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+func (c *ClusterUsecase) getNodeRemover(eid, providerName string) (adaptor.NodeRemover, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilityRemoveNodes); err != nil {
+		return nil, err
+	}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	remover, ok := ad.(adaptor.NodeRemover)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return remover, nil
+}
+
+// RemoveKubernetesNodes drains the nodes and removes them from the cluster in background.
+// The remaining nodes must keep all roles and an odd number of etcd nodes.
+func (c *ClusterUsecase) RemoveKubernetesNodes(eid, clusterID string, req *v1.RemoveKubernetesNodesReq) (*model.OperationTask, error) {
+	if len(req.Nodes) == 0 {
+		return nil, errors.WithStack(bcode.ErrRemoveNodesEmpty)
+	}
+	if _, err := c.getNodeRemover(eid, req.ProviderName); err != nil {
+		return nil, err
+	}
+	cluster, err := c.getRKECluster(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	rkeConfig, err := c.getRKEConfig(eid, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if rkeConfig == nil {
+		return nil, errors.WithStack(bcode.ErrRKEConfigLost)
+	}
+	if err := c.validateRemainingNodes(rkeConfig, req.Nodes); err != nil {
+		return nil, err
+	}
+	return c.runClusterStateTask(eid, clusterID, req.ProviderName, domain.ClusterTaskTypeRemoveNodes, removeNodesParams{Nodes: req.Nodes})
+}
+
+// removeNodesParams the params of the task removing the nodes
+type removeNodesParams struct {
+	Nodes []string `json:"nodes"`
+}
+
+func (c *ClusterUsecase) runRemoveNodes(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p removeNodesParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	remover, err := c.getNodeRemover(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return remover.RemoveNodes(ctx, task.EnterpriseID, task.ClusterID, p.Nodes, rollback)
+}
+
+// validateRemainingNodes validates the nodes of the cluster after the nodes are removed.
+func (c *ClusterUsecase) validateRemainingNodes(rkeConfig *v3.RancherKubernetesEngineConfig, addresses []string) error {
+	toRemove := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		toRemove[address] = true
+	}
+	remaining := &v3.RancherKubernetesEngineConfig{}
+	for _, node := range rkeConfig.Nodes {
+		if toRemove[node.Address] {
+			delete(toRemove, node.Address)
+			continue
+		}
+		remaining.Nodes = append(remaining.Nodes, node)
+	}
+	for address := range toRemove {
+		return errors.Wrapf(bcode.ErrClusterNodeNotFound, "node %s", address)
+	}
+	nodeList, err := c.rkeConfigToNodeList(remaining)
+	if err != nil {
+		return err
+	}
+	return nodeList.Validate()
+}
@@ -0,0 +1,110 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// nodeRemoverAdaptor reports the events of the nodes
+type nodeRemoverAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	removed []string
+}
+
+func (n *nodeRemoverAdaptor) RemoveNodes(ctx context.Context, eid, clusterID string, nodes []string, rollback func(step, message, status string)) error {
+	for _, node := range nodes {
+		rollback(v1alpha1.NodeStepType(v1.StepDrainNode, node), "", "success")
+		rollback(v1alpha1.NodeStepType(v1.StepRemoveNode, node), "", "success")
+	}
+	n.removed = nodes
+	return nil
+}
+
+const testRemoveNodesConfig = `nodes:
+- address: 192.168.1.1
+  port: "22"
+  role: [controlplane, etcd, worker]
+- address: 192.168.1.2
+  port: "22"
+  role: [etcd, worker]
+- address: 192.168.1.3
+  port: "22"
+  role: [etcd, worker]
+- address: 192.168.1.4
+  port: "22"
+  role: [worker]
+`
+
+func TestRemoveKubernetesNodes(t *testing.T) {
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	c := newTestClusterUsecase(t)
+	remover := &nodeRemoverAdaptor{}
+	registerTestProvider(t, "test-node-remover", adaptor.InterfaceNodeRemover, adaptor.CapabilityRemoveNodes, remover)
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.rkeStateStore.Put(repo.RKEStatePrefix("eid", "c1")+"cluster.yml", []byte(testRemoveNodesConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		nodes []string
+		err   error
+	}{
+		{nodes: nil, err: bcode.ErrRemoveNodesEmpty},
+		{nodes: []string{"192.168.1.5"}, err: bcode.ErrClusterNodeNotFound},
+		{nodes: []string{"192.168.1.2"}, err: bcode.ErrETCDNodeNotOddNumer},
+		{nodes: []string{"192.168.1.1"}, err: bcode.ErrClusterNodeRoleMiss},
+	} {
+		if _, err := c.RemoveKubernetesNodes("eid", "c1", &v1.RemoveKubernetesNodesReq{ProviderName: "test-node-remover", Nodes: tc.nodes}); errors.Cause(err) != tc.err {
+			t.Errorf("remove %v: expected %v, got %v", tc.nodes, tc.err, err)
+		}
+	}
+
+	task, err := c.RemoveKubernetesNodes("eid", "c1", &v1.RemoveKubernetesNodesReq{ProviderName: "test-node-remover", Nodes: []string{"192.168.1.2", "192.168.1.3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	if last := events[len(events)-1]; last.StepType != v1.StepRemoveNodes || last.Status != "success" {
+		t.Errorf("expected the removal succeeds, got %s %s", last.StepType, last.Status)
+	}
+	var nodeEvents int
+	for _, event := range events {
+		if v1alpha1.IsNodeStepType(event.StepType) {
+			nodeEvents++
+		}
+	}
+	if nodeEvents != 4 {
+		t.Errorf("expected 4 events of the nodes, got %d", nodeEvents)
+	}
+	if len(remover.removed) != 2 {
+		t.Errorf("unexpected removed nodes %v", remover.removed)
+	}
+}
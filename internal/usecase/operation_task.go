@@ -0,0 +1,288 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"gorm.io/gorm"
+)
+
+// checkOperationTaskComplete returns ErrLastKubernetesTaskNotComplete if the last task of the type is running.
+// The task interrupted by a restart is complete after the task consumer reclaims it.
+func (c *ClusterUsecase) checkOperationTaskComplete(eid, clusterID string, taskType domain.ClusterTaskType) error {
+	last, err := c.operationTaskRepo.GetLastTask(eid, clusterID, string(taskType))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if last != nil && last.Status != "complete" {
+		return errors.WithStack(bcode.ErrLastKubernetesTaskNotComplete)
+	}
+	return nil
+}
+
+// clusterStateTaskTypes the tasks change the kubernetes cluster, only one of them runs at a time.
+var clusterStateTaskTypes = []domain.ClusterTaskType{
+	domain.ClusterTaskTypeUpgradeKubernetes,
+	domain.ClusterTaskTypeRotateCertificates,
+	domain.ClusterTaskTypeEnableSecretsEncryption,
+	domain.ClusterTaskTypeRotateEncryptionKey,
+	domain.ClusterTaskTypeSaveEtcdSnapshot,
+	domain.ClusterTaskTypeSetEtcdSnapshotPolicy,
+	domain.ClusterTaskTypeRestoreEtcdSnapshot,
+	domain.ClusterTaskTypeRemoveNodes,
+}
+
+// checkClusterTasksComplete returns ErrLastKubernetesTaskNotComplete if any task changing the kubernetes cluster is running,
+// or the ssh key of the enterprise is being rotated.
+func (c *ClusterUsecase) checkClusterTasksComplete(eid, clusterID string) error {
+	if _, err := c.isLastTaskComplete(eid, clusterID); err != nil {
+		return err
+	}
+	if err := c.checkOperationTaskComplete(eid, "", domain.ClusterTaskTypeRotateSSHKey); err != nil {
+		return err
+	}
+	for _, taskType := range clusterStateTaskTypes {
+		if err := c.checkOperationTaskComplete(eid, clusterID, taskType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runClusterStateTask starts the task changing the kubernetes cluster after the other ones complete.
+func (c *ClusterUsecase) runClusterStateTask(eid, clusterID, providerName string, taskType domain.ClusterTaskType, params interface{}) (*model.OperationTask, error) {
+	if err := c.checkClusterTasksComplete(eid, clusterID); err != nil {
+		return nil, err
+	}
+	task, err := c.createOperationTask(eid, clusterID, providerName, taskType)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.startOperationTask(task, params); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// createOperationTask creates the operation task of the cluster, only one task of the type runs at a time.
+func (c *ClusterUsecase) createOperationTask(eid, clusterID, providerName string, taskType domain.ClusterTaskType) (*model.OperationTask, error) {
+	if err := c.checkOperationTaskComplete(eid, clusterID, taskType); err != nil {
+		return nil, err
+	}
+	task := &model.OperationTask{
+		EnterpriseID: eid,
+		ClusterID:    clusterID,
+		Provider:     providerName,
+		Type:         string(taskType),
+		Status:       "running",
+	}
+	if err := c.operationTaskRepo.Create(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// operationRunner runs the operation task of a type with the params saved in the task message.
+type operationRunner struct {
+	terminalStep string
+	run          func(c *ClusterUsecase, ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error
+}
+
+// operationRunners the runners of the operation task types
+var operationRunners = map[domain.ClusterTaskType]operationRunner{
+	domain.ClusterTaskTypeReleaseResources:        {v1.StepReleaseResources, (*ClusterUsecase).runReleaseResources},
+	domain.ClusterTaskTypeUpgradeKubernetes:       {v1.StepUpgradeKubernetes, (*ClusterUsecase).runUpgradeKubernetes},
+	domain.ClusterTaskTypeRotateCertificates:      {v1.StepRotateCertificates, (*ClusterUsecase).runRotateCertificates},
+	domain.ClusterTaskTypeEnableSecretsEncryption: {v1.StepEnableSecretsEncryption, (*ClusterUsecase).runEnableSecretsEncryption},
+	domain.ClusterTaskTypeRotateEncryptionKey:     {v1.StepRotateEncryptionKey, (*ClusterUsecase).runRotateSecretsEncryptionKey},
+	domain.ClusterTaskTypeSaveEtcdSnapshot:        {v1.StepSaveEtcdSnapshot, (*ClusterUsecase).runSaveEtcdSnapshot},
+	domain.ClusterTaskTypeSetEtcdSnapshotPolicy:   {v1.StepSetEtcdSnapshotPolicy, (*ClusterUsecase).runSetEtcdSnapshotPolicy},
+	domain.ClusterTaskTypeRestoreEtcdSnapshot:     {v1.StepRestoreEtcdSnapshot, (*ClusterUsecase).runRestoreEtcdSnapshot},
+	domain.ClusterTaskTypeRemoveNodes:             {v1.StepRemoveNodes, (*ClusterUsecase).runRemoveNodes},
+	domain.ClusterTaskTypeRotateSSHKey:            {v1.StepRotateSSHKey, (*ClusterUsecase).runRotateSSHKey},
+	domain.ClusterTaskTypePreflightCheck:          {v1.StepPreflightCheck, (*ClusterUsecase).runPreflightCheck},
+}
+
+// decodeOperationParams decodes the params of the operation task saved in the task message.
+func decodeOperationParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return errors.Wrap(err, "decode the params of the operation task")
+	}
+	return nil
+}
+
+// operationTaskRollback saves the events reported by the operation task.
+func (c *ClusterUsecase) operationTaskRollback(eid, taskID string) func(step, message, status string) {
+	return func(step, message, status string) {
+		if _, err := c.CreateTaskEvent(&v1.EventMessage{
+			EnterpriseID: eid,
+			TaskID:       taskID,
+			Message:      &v1.Message{StepType: step, Message: message, Status: status},
+		}); err != nil {
+			logrus.Errorf("save event %s of task %s failure %s", step, taskID, err.Error())
+		}
+	}
+}
+
+// startOperationTask sends the operation task to the task consumer with the params of its runner.
+// The task fails at once if it can not be sent, so that it does not block the next one.
+func (c *ClusterUsecase) startOperationTask(task *model.OperationTask, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err == nil {
+		err = c.TaskProducer.SendOperationTask(types.OperationTaskMessage{
+			EnterpriseID: task.EnterpriseID,
+			TaskID:       task.TaskID,
+			Params:       body,
+		})
+	}
+	if err != nil {
+		logrus.Errorf("send operation task %s failure %s", task.TaskID, err.Error())
+		c.operationTaskRollback(task.EnterpriseID, task.TaskID)(v1.StepCreateTask, err.Error(), "failure")
+		return errors.Wrap(err, "send operation task")
+	}
+	return nil
+}
+
+// RunOperationTask runs the operation task of the message until it finishes or ctx is cancelled. The events reported
+// by the runner are saved as the events of the task, the task is complete when the terminal step succeeds, any step fails
+// or the task is cancelled.
+func (c *ClusterUsecase) RunOperationTask(ctx context.Context, msg types.OperationTaskMessage) {
+	rollback := c.operationTaskRollback(msg.EnterpriseID, msg.TaskID)
+	task, err := c.operationTaskRepo.GetTask(msg.EnterpriseID, msg.TaskID)
+	if err != nil {
+		logrus.Errorf("get operation task %s failure %s", msg.TaskID, err.Error())
+		rollback(v1.StepCreateTask, err.Error(), "failure")
+		return
+	}
+	runner, ok := operationRunners[domain.ClusterTaskType(task.Type)]
+	if !ok {
+		rollback(v1.StepCreateTask, fmt.Sprintf("operation task type %s not support", task.Type), "failure")
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			debug.PrintStack()
+			rollback(runner.terminalStep, fmt.Sprintf("%v", err), "failure")
+		}
+	}()
+	rollback(runner.terminalStep, "", "start")
+	err = runner.run(c, ctx, task, msg.Params, dropCancelledFailure(ctx, rollback))
+	if ctx.Err() == context.Canceled {
+		rollback(v1.StepCancelled, "the task is cancelled", "cancelled")
+		return
+	}
+	if err != nil {
+		logrus.Errorf("run task %s failure %s", task.TaskID, err.Error())
+		rollback(runner.terminalStep, err.Error(), "failure")
+		return
+	}
+	rollback(runner.terminalStep, "", "success")
+}
+
+// dropCancelledFailure drops the failure events reported by the operation after the task is cancelled,
+// the cancelled event is the result of the task.
+func dropCancelledFailure(ctx context.Context, rollback func(step, message, status string)) func(step, message, status string) {
+	return func(step, message, status string) {
+		if status == "failure" && ctx.Err() == context.Canceled {
+			return
+		}
+		rollback(step, message, status)
+	}
+}
+
+// ReleaseRainbondResources deletes the cloud resources created for the rainbond region of the cluster.
+func (c *ClusterUsecase) ReleaseRainbondResources(eid, clusterID, providerName string) (*model.OperationTask, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilityReleaseResources); err != nil {
+		return nil, err
+	}
+	if _, err := c.getResourceReleaser(eid, providerName); err != nil {
+		return nil, err
+	}
+	return c.releaseResources(eid, clusterID, providerName, releaseResourcesParams{})
+}
+
+func (c *ClusterUsecase) getResourceReleaser(eid, providerName string) (adaptor.ResourceReleaser, error) {
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	releaser, ok := ad.(adaptor.ResourceReleaser)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return releaser, nil
+}
+
+// releaseResourcesParams the params of the task releasing the resources
+type releaseResourcesParams struct {
+	// ClusterDeleted is true if the cluster is deleted after the resources are released
+	ClusterDeleted bool `json:"clusterDeleted,omitempty"`
+}
+
+func (c *ClusterUsecase) releaseResources(eid, clusterID, providerName string, params releaseResourcesParams) (*model.OperationTask, error) {
+	task, err := c.createOperationTask(eid, clusterID, providerName, domain.ClusterTaskTypeReleaseResources)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.startOperationTask(task, params); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (c *ClusterUsecase) runReleaseResources(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p releaseResourcesParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	releaser, err := c.getResourceReleaser(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	if recordable, ok := releaser.(adaptor.ResourceRecordable); ok {
+		recordable.SetResourceRecorder(func(resource *v1alpha1.CloudResource) {
+			if err := c.recordResource(task.EnterpriseID, task.TaskID, resource); err != nil {
+				logrus.Errorf("record the resource %s %s failure %s", resource.ResourceType, resource.ResourceID, err.Error())
+			}
+		})
+	}
+	if err := releaser.ReleaseRainbondResources(task.EnterpriseID, task.ClusterID, rollback); err != nil {
+		return err
+	}
+	if p.ClusterDeleted {
+		if err := c.clusterHealthRepo.Delete(task.EnterpriseID, task.ClusterID); err != nil {
+			logrus.Warningf("delete health of cluster %s: %v", task.ClusterID, err)
+		}
+		c.notifyClusterDeleted(task.EnterpriseID, task.ClusterID, task.Provider)
+	}
+	return nil
+}
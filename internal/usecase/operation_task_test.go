@@ -0,0 +1,162 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/types"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// releaserAdaptor releases the resources by the steps, the step failed is not released.
+type releaserAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	steps  []string
+	failed string
+}
+
+func (r *releaserAdaptor) ReleaseRainbondResources(eid, clusterID string, rollback func(step, message, status string)) error {
+	for _, step := range r.steps {
+		rollback(step, "", "start")
+		if step == r.failed {
+			rollback(step, "not created by cloud-adaptor", "failure")
+			return fmt.Errorf("refuse to delete %s", step)
+		}
+		rollback(step, clusterID, "success")
+	}
+	return nil
+}
+
+func TestReleaseRainbondResources(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	releaser := &releaserAdaptor{steps: []string{v1.StepDeleteLoadBalancer, v1.StepDeleteNAS, v1.StepDeleteRDS}}
+	registerTestProvider(t, "test-releaser", adaptor.InterfaceResourceReleaser, adaptor.CapabilityReleaseResources, releaser)
+
+	task, err := c.ReleaseRainbondResources("eid", "cluster", "test-releaser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	success := make(map[string]bool)
+	for _, event := range events {
+		success[event.StepType] = event.Status == "success"
+	}
+	for _, step := range append(releaser.steps, v1.StepReleaseResources) {
+		if !success[step] {
+			t.Errorf("expect the event of %s is success", step)
+		}
+	}
+	clusterTask, err := c.getTask("eid", task.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clusterTask.ClusterID != "cluster" || clusterTask.TaskType != "release-resources" {
+		t.Errorf("unexpected task %+v", clusterTask)
+	}
+
+	// the failure of the deletion fails the task
+	releaser.failed = v1.StepDeleteNAS
+	task, err = c.ReleaseRainbondResources("eid", "cluster", "test-releaser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = waitOperationTask(t, c, task)
+	last := events[len(events)-1]
+	if last.StepType != v1.StepReleaseResources || last.Status != "failure" {
+		t.Errorf("expect the task is failure, the last event is %s %s", last.StepType, last.Status)
+	}
+	for _, event := range events {
+		if event.StepType == v1.StepDeleteRDS {
+			t.Errorf("the resources after the failure should not be deleted")
+		}
+	}
+
+	if _, err := c.ReleaseRainbondResources("eid", "cluster", "rke"); errors.Cause(err) != bcode.ErrProviderNotSupportAction {
+		t.Errorf("expected ErrProviderNotSupportAction, got %v", err)
+	}
+}
+
+func TestCreateOperationTask(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	task, err := c.createOperationTask("eid", "cluster", "ack", "release-resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.createOperationTask("eid", "cluster", "ack", "release-resources"); errors.Cause(err) != bcode.ErrLastKubernetesTaskNotComplete {
+		t.Errorf("expected ErrLastKubernetesTaskNotComplete, got %v", err)
+	}
+	// the task interrupted by a restart is complete
+	if err := c.InterruptTask("eid", task.TaskID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.createOperationTask("eid", "cluster", "ack", "release-resources"); err != nil {
+		t.Errorf("create task after the last one is interrupted: %v", err)
+	}
+}
+
+// blockingUpgraderAdaptor upgrades the cluster until the task is cancelled.
+type blockingUpgraderAdaptor struct {
+	upgraderAdaptor
+	started chan struct{}
+}
+
+func (u *blockingUpgraderAdaptor) UpgradeKubernetes(ctx context.Context, eid, clusterID, version string, rollback func(step, message, status string)) error {
+	close(u.started)
+	<-ctx.Done()
+	rollback(v1.StepUpgradeKubernetes, ctx.Err().Error(), "failure")
+	return ctx.Err()
+}
+
+func TestRunOperationTaskCancelled(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	upgrader := &blockingUpgraderAdaptor{started: make(chan struct{})}
+	registerTestProvider(t, "test-upgrader", adaptor.InterfaceKubernetesUpgrader, adaptor.CapabilityUpgradeKubernetes, upgrader)
+
+	task, err := c.createOperationTask("eid", "cluster", "test-upgrader", domain.ClusterTaskTypeUpgradeKubernetes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.RunOperationTask(ctx, types.OperationTaskMessage{EnterpriseID: "eid", TaskID: task.TaskID, Params: []byte(`{"version":"v1.24.4-rancher1-1"}`)})
+	}()
+	<-upgrader.started
+	cancel()
+	<-done
+
+	events := waitOperationTask(t, c, task)
+	last := events[len(events)-1]
+	if last.StepType != v1.StepCancelled || last.Status != "cancelled" {
+		t.Errorf("expect the task is cancelled, the last event is %s %s", last.StepType, last.Status)
+	}
+	for _, event := range events {
+		if event.Status == "failure" {
+			t.Errorf("the failure %s after the task is cancelled should be dropped", event.StepType)
+		}
+	}
+}
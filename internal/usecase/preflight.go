@@ -0,0 +1,184 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rke/types"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"gopkg.in/yaml.v2"
+	"gorm.io/gorm"
+)
+
+func (c *ClusterUsecase) getPreflightChecker(eid, providerName string) (adaptor.PreflightChecker, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilityPreflightCheck); err != nil {
+		return nil, err
+	}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	checker, ok := ad.(adaptor.PreflightChecker)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return checker, nil
+}
+
+// PreflightCheck checks the nodes of the rke config in background, the report of every node is saved as the result of the task.
+func (c *ClusterUsecase) PreflightCheck(eid string, req *v1.PreflightCheckReq) (*model.OperationTask, error) {
+	if _, err := c.getPreflightChecker(eid, req.ProviderName); err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(req.EncodedRKEConfig)
+	if err != nil {
+		return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "decode encoded rke config")
+	}
+	var rkeConfig v3.RancherKubernetesEngineConfig
+	if err := yaml.Unmarshal(decoded, &rkeConfig); err != nil {
+		return nil, errors.Wrap(bcode.ErrIncorrectRKEConfig, "unmarshal rke config")
+	}
+	nodeList, err := c.rkeConfigToNodeList(&rkeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := nodeList.Validate(); err != nil {
+		return nil, err
+	}
+	params := preflightCheckParams{EncodedRKEConfig: req.EncodedRKEConfig}
+	if req.Bastion != nil {
+		bastion := *req.Bastion
+		// the key of the bastion is saved in the task message, it is encrypted the same as the saved bastions
+		if bastion.PrivateKey != "" {
+			if bastion.PrivateKey, err = repo.EncryptSSHKey([]byte(bastion.PrivateKey)); err != nil {
+				return nil, err
+			}
+		}
+		params.Bastion = &bastion
+	}
+	task, err := c.createOperationTask(eid, "", req.ProviderName, domain.ClusterTaskTypePreflightCheck)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.startOperationTask(task, params); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// preflightCheckParams the params of the preflight task
+type preflightCheckParams struct {
+	EncodedRKEConfig string `json:"encodedRKEConfig"`
+	// Bastion the private key of the bastion is encrypted
+	Bastion *v1alpha1.SSHBastion `json:"bastion,omitempty"`
+}
+
+func (c *ClusterUsecase) runPreflightCheck(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p preflightCheckParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(p.EncodedRKEConfig)
+	if err != nil {
+		return errors.Wrap(err, "decode encoded rke config")
+	}
+	var rkeConfig v3.RancherKubernetesEngineConfig
+	if err := yaml.Unmarshal(decoded, &rkeConfig); err != nil {
+		return errors.Wrap(err, "unmarshal rke config")
+	}
+	if p.Bastion != nil && p.Bastion.PrivateKey != "" {
+		key, err := repo.DecryptSSHKey(p.Bastion.PrivateKey)
+		if err != nil {
+			return errors.WithMessage(err, "decrypt the key of the bastion")
+		}
+		p.Bastion.PrivateKey = string(key)
+	}
+	checker, err := c.getPreflightChecker(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	report, err := checker.PreflightCheck(&v1alpha1.KubernetesClusterConfig{
+		EnterpriseID: task.EnterpriseID,
+		Provider:     task.Provider,
+		RKEConfig:    &rkeConfig,
+		Bastion:      p.Bastion,
+	})
+	if err != nil {
+		return err
+	}
+	for _, node := range report.Nodes {
+		var messages []string
+		for _, check := range node.Checks {
+			if check.Status != v1alpha1.PreflightPass {
+				messages = append(messages, fmt.Sprintf("%s: %s: %s", check.Name, check.Status, check.Message))
+			}
+		}
+		status := "success"
+		if node.Status == v1alpha1.PreflightFail {
+			status = "failure"
+		}
+		rollback(v1alpha1.NodeStepType(v1.StepPreflightNode, node.Address), strings.Join(messages, "; "), status)
+	}
+	result, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "encode preflight report")
+	}
+	if err := c.operationTaskRepo.SaveResult(task.EnterpriseID, task.TaskID, string(result)); err != nil {
+		return err
+	}
+	if report.Status == v1alpha1.PreflightFail {
+		return errors.Wrap(bcode.ErrPreflightCheckFailed, strings.Join(report.Failures(), "; "))
+	}
+	return nil
+}
+
+// GetPreflightReport returns the report of the preflight task, ErrPreflightReportNotFound is returned
+// if the nodes are being checked or the task failed before checking them.
+func (c *ClusterUsecase) GetPreflightReport(eid, taskID string) (*v1alpha1.PreflightReport, error) {
+	task, err := c.operationTaskRepo.GetTask(eid, taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(bcode.ErrClusterTaskNotFound)
+		}
+		return nil, err
+	}
+	if task.Type != string(domain.ClusterTaskTypePreflightCheck) {
+		return nil, errors.WithStack(bcode.ErrClusterTaskNotFound)
+	}
+	if task.Result == "" {
+		return nil, errors.WithStack(bcode.ErrPreflightReportNotFound)
+	}
+	var report v1alpha1.PreflightReport
+	if err := json.Unmarshal([]byte(task.Result), &report); err != nil {
+		return nil, errors.Wrap(err, "decode preflight report")
+	}
+	return &report, nil
+}
@@ -0,0 +1,125 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// preflightCheckerAdaptor fails the nodes in failed
+type preflightCheckerAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	failed  map[string]bool
+	bastion *v1alpha1.SSHBastion
+}
+
+func (p *preflightCheckerAdaptor) PreflightCheck(config *v1alpha1.KubernetesClusterConfig) (*v1alpha1.PreflightReport, error) {
+	p.bastion = config.Bastion
+	var nodes []*v1alpha1.NodePreflightReport
+	for _, node := range config.RKEConfig.Nodes {
+		report := &v1alpha1.NodePreflightReport{Address: node.Address}
+		if p.failed[node.Address] {
+			report.AddCheck("ports", v1alpha1.PreflightFail, "the ports 6443 are in use")
+		} else {
+			report.AddCheck("ports", v1alpha1.PreflightPass, "")
+		}
+		nodes = append(nodes, report)
+	}
+	return v1alpha1.NewPreflightReport(nodes), nil
+}
+
+func TestPreflightCheck(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	checker := &preflightCheckerAdaptor{}
+	registerTestProvider(t, "test-preflight-checker", adaptor.InterfacePreflightChecker, adaptor.CapabilityPreflightCheck, checker)
+	encoded := base64.StdEncoding.EncodeToString([]byte(testRemoveNodesConfig))
+
+	checker.failed = map[string]bool{"192.168.1.1": true}
+	bastion := &v1alpha1.SSHBastion{Address: "10.0.0.1", User: "docker", PrivateKey: "bastion key"}
+	task, err := c.PreflightCheck("eid", &v1.PreflightCheckReq{ProviderName: "test-preflight-checker", EncodedRKEConfig: encoded, Bastion: bastion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	last := events[len(events)-1]
+	if last.StepType != v1.StepPreflightCheck || last.Status != "failure" {
+		t.Errorf("expect the task is failure, the last event is %s %s", last.StepType, last.Status)
+	}
+	status := make(map[string]string)
+	for _, event := range events {
+		status[event.StepType] = event.Status
+	}
+	if status["PreflightNode:192.168.1.1"] != "failure" || status["PreflightNode:192.168.1.2"] != "success" {
+		t.Errorf("unexpected node events %v", status)
+	}
+	if checker.bastion == nil || checker.bastion.PrivateKey != "bastion key" {
+		t.Errorf("expect the nodes are checked through the bastion with its key, got %+v", checker.bastion)
+	}
+	var msg model.TaskMessage
+	if err := c.DB.Where("task_id=?", task.TaskID).Take(&msg).Error; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.Body, "bastion key") {
+		t.Errorf("the key of the bastion should be encrypted in the task message")
+	}
+	report, err := c.GetPreflightReport("eid", task.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != v1alpha1.PreflightFail || len(report.Nodes) != 4 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	checker.failed = nil
+	task, err = c.PreflightCheck("eid", &v1.PreflightCheckReq{ProviderName: "test-preflight-checker", EncodedRKEConfig: encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = waitOperationTask(t, c, task)
+	if last := events[len(events)-1]; last.StepType != v1.StepPreflightCheck || last.Status != "success" {
+		t.Errorf("expect the preflight passes, the last event is %s %s", last.StepType, last.Status)
+	}
+	if report, err := c.GetPreflightReport("eid", task.TaskID); err != nil || report.Status != v1alpha1.PreflightPass {
+		t.Errorf("expect the report passes, got %+v %v", report, err)
+	}
+
+	if _, err := c.PreflightCheck("eid", &v1.PreflightCheckReq{ProviderName: "test-preflight-checker", EncodedRKEConfig: "invalid"}); errors.Cause(err) != bcode.ErrIncorrectRKEConfig {
+		t.Errorf("expected ErrIncorrectRKEConfig, got %v", err)
+	}
+	if _, err := c.PreflightCheck("eid", &v1.PreflightCheckReq{ProviderName: "rke-not-exist", EncodedRKEConfig: encoded}); err == nil {
+		t.Errorf("expected the provider not supported")
+	}
+	notReady, err := c.createOperationTask("eid", "", "test-preflight-checker", domain.ClusterTaskTypePreflightCheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPreflightReport("eid", notReady.TaskID); errors.Cause(err) != bcode.ErrPreflightReportNotFound {
+		t.Errorf("expected ErrPreflightReportNotFound, got %v", err)
+	}
+}
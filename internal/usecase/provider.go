@@ -0,0 +1,76 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"gorm.io/gorm"
+)
+
+// ListProviders lists the registered cloud adaptors.
+func (c *ClusterUsecase) ListProviders() []*v1.Provider {
+	providers := []*v1.Provider{}
+	for _, p := range adaptor.ListProviders() {
+		interfaces := []string{}
+		interfaces = append(interfaces, p.Interfaces...)
+		providers = append(providers, &v1.Provider{
+			Name:            p.Name,
+			NeedCredentials: p.NeedCredentials,
+			Interfaces:      interfaces,
+			Capabilities:    append([]string{}, p.Capabilities...),
+		})
+	}
+	return providers
+}
+
+// GetProviderCapabilities returns the capabilities of the provider for the enterprise.
+func (c *ClusterUsecase) GetProviderCapabilities(eid, providerName string) (*v1.ProviderCapabilitiesRes, error) {
+	provider, ok := adaptor.GetProvider(providerName)
+	if !ok {
+		return nil, bcode.ErrorProviderNotSupport
+	}
+	res := &v1.ProviderCapabilitiesRes{
+		Name:            provider.Name,
+		Capabilities:    append([]string{}, provider.Capabilities...),
+		NeedCredentials: provider.NeedCredentials,
+	}
+	if provider.NeedCredentials {
+		accessKey, err := c.CloudAccessKeyRepo.GetByProviderAndEnterprise(providerName, eid)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		res.AccessKeyConfigured = accessKey != nil && accessKey.AccessKey != ""
+	}
+	return res, nil
+}
+
+// checkProviderCapability returns an error if the provider does not support the capability.
+func checkProviderCapability(providerName, capability string) error {
+	provider, ok := adaptor.GetProvider(providerName)
+	if !ok {
+		return bcode.ErrorProviderNotSupport
+	}
+	if !provider.Supports(capability) {
+		return errors.Wrapf(bcode.ErrProviderNotSupportAction, "provider %s does not support %s", providerName, capability)
+	}
+	return nil
+}
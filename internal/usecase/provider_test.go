@@ -0,0 +1,70 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+func TestGetProviderCapabilities(t *testing.T) {
+	c := newTestClusterUsecase(t)
+
+	res, err := c.GetProviderCapabilities("eid", "ack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.NeedCredentials || res.AccessKeyConfigured {
+		t.Errorf("unexpected capabilities %+v", res)
+	}
+	if err := c.CloudAccessKeyRepo.Create(&model.CloudAccessKey{EnterpriseID: "eid", ProviderName: "ack", AccessKey: "ak", SecretKey: "sk"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = c.GetProviderCapabilities("eid", "ack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.AccessKeyConfigured {
+		t.Errorf("expected the access key is configured")
+	}
+
+	res, err = c.GetProviderCapabilities("eid", "rke")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NeedCredentials || len(res.Capabilities) == 0 {
+		t.Errorf("unexpected capabilities %+v", res)
+	}
+
+	if _, err := c.GetProviderCapabilities("eid", "unknown"); err != bcode.ErrorProviderNotSupport {
+		t.Errorf("expected ErrorProviderNotSupport, got %v", err)
+	}
+}
+
+func TestCheckProviderCapability(t *testing.T) {
+	if err := checkProviderCapability("rke", "expandNodes"); err != nil {
+		t.Errorf("rke supports expandNodes: %v", err)
+	}
+	if err := checkProviderCapability("tke", "expandNodes"); errors.Cause(err) != bcode.ErrProviderNotSupportAction {
+		t.Errorf("expected ErrProviderNotSupportAction, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// etcdSnapshotNameRegexp the snapshot name is used as the file name on the etcd nodes
+var etcdSnapshotNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+func (c *ClusterUsecase) getEtcdSnapshotter(eid, providerName string) (adaptor.EtcdSnapshotter, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilityEtcdSnapshot); err != nil {
+		return nil, err
+	}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	snapshotter, ok := ad.(adaptor.EtcdSnapshotter)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return snapshotter, nil
+}
+
+// ListEtcdSnapshots lists the etcd snapshots of the cluster.
+func (c *ClusterUsecase) ListEtcdSnapshots(eid, clusterID, providerName string) ([]*v1alpha1.EtcdSnapshot, error) {
+	snapshotter, err := c.getEtcdSnapshotter(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotter.ListEtcdSnapshots(eid, clusterID)
+}
+
+// SaveEtcdSnapshot saves an etcd snapshot of the cluster in background, the name is generated with the current time if it is empty.
+func (c *ClusterUsecase) SaveEtcdSnapshot(eid, clusterID string, req *v1.SaveEtcdSnapshotReq) (*model.OperationTask, error) {
+	name := req.Name
+	if name == "" {
+		name = "etcd-snapshot-" + time.Now().Format("20060102150405")
+	}
+	if !etcdSnapshotNameRegexp.MatchString(name) {
+		return nil, errors.WithStack(bcode.ErrEtcdSnapshotNameInvalid)
+	}
+	if _, err := c.getEtcdSnapshotter(eid, req.ProviderName); err != nil {
+		return nil, err
+	}
+	return c.runClusterStateTask(eid, clusterID, req.ProviderName, domain.ClusterTaskTypeSaveEtcdSnapshot, etcdSnapshotParams{Name: name})
+}
+
+// etcdSnapshotParams the params of the task saving or restoring the etcd snapshot
+type etcdSnapshotParams struct {
+	Name string `json:"name"`
+}
+
+func (c *ClusterUsecase) runSaveEtcdSnapshot(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p etcdSnapshotParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	snapshotter, err := c.getEtcdSnapshotter(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return snapshotter.SaveEtcdSnapshot(ctx, task.EnterpriseID, task.ClusterID, p.Name, rollback)
+}
+
+// GetEtcdSnapshotPolicy returns the policy of the recurring etcd snapshots of the cluster.
+func (c *ClusterUsecase) GetEtcdSnapshotPolicy(eid, clusterID, providerName string) (*v1alpha1.EtcdSnapshotPolicy, error) {
+	snapshotter, err := c.getEtcdSnapshotter(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotter.GetEtcdSnapshotPolicy(eid, clusterID)
+}
+
+// SetEtcdSnapshotPolicy configures the recurring etcd snapshots of the cluster in background.
+func (c *ClusterUsecase) SetEtcdSnapshotPolicy(eid, clusterID string, req *v1.SetEtcdSnapshotPolicyReq) (*model.OperationTask, error) {
+	if _, err := c.getEtcdSnapshotter(eid, req.ProviderName); err != nil {
+		return nil, err
+	}
+	policy := v1alpha1.EtcdSnapshotPolicy{
+		Enabled:       req.Enabled,
+		IntervalHours: req.IntervalHours,
+		Retention:     req.Retention,
+	}
+	return c.runClusterStateTask(eid, clusterID, req.ProviderName, domain.ClusterTaskTypeSetEtcdSnapshotPolicy, policy)
+}
+
+func (c *ClusterUsecase) runSetEtcdSnapshotPolicy(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var policy v1alpha1.EtcdSnapshotPolicy
+	if err := decodeOperationParams(params, &policy); err != nil {
+		return err
+	}
+	snapshotter, err := c.getEtcdSnapshotter(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return snapshotter.SetEtcdSnapshotPolicy(ctx, task.EnterpriseID, task.ClusterID, policy, rollback)
+}
+
+// RestoreEtcdSnapshot restores the cluster from the etcd snapshot in background.
+func (c *ClusterUsecase) RestoreEtcdSnapshot(eid, clusterID string, req *v1.RestoreEtcdSnapshotReq) (*model.OperationTask, error) {
+	snapshotter, err := c.getEtcdSnapshotter(eid, req.ProviderName)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := snapshotter.ListEtcdSnapshots(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	var found bool
+	for _, snapshot := range snapshots {
+		if snapshot.Name == req.Name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.WithStack(bcode.ErrEtcdSnapshotNotFound)
+	}
+	return c.runClusterStateTask(eid, clusterID, req.ProviderName, domain.ClusterTaskTypeRestoreEtcdSnapshot, etcdSnapshotParams{Name: req.Name})
+}
+
+func (c *ClusterUsecase) runRestoreEtcdSnapshot(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p etcdSnapshotParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	snapshotter, err := c.getEtcdSnapshotter(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return snapshotter.RestoreEtcdSnapshot(ctx, task.EnterpriseID, task.ClusterID, p.Name, rollback)
+}
@@ -0,0 +1,97 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// snapshotterAdaptor saves the snapshots in memory
+type snapshotterAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	snapshots []*v1alpha1.EtcdSnapshot
+	restored  string
+}
+
+func (s *snapshotterAdaptor) ListEtcdSnapshots(eid, clusterID string) ([]*v1alpha1.EtcdSnapshot, error) {
+	return s.snapshots, nil
+}
+
+func (s *snapshotterAdaptor) SaveEtcdSnapshot(ctx context.Context, eid, clusterID, name string, rollback func(step, message, status string)) error {
+	s.snapshots = append(s.snapshots, &v1alpha1.EtcdSnapshot{Name: name, Size: 1024, CreatedAt: time.Now()})
+	return nil
+}
+
+func (s *snapshotterAdaptor) GetEtcdSnapshotPolicy(eid, clusterID string) (*v1alpha1.EtcdSnapshotPolicy, error) {
+	return &v1alpha1.EtcdSnapshotPolicy{}, nil
+}
+
+func (s *snapshotterAdaptor) SetEtcdSnapshotPolicy(ctx context.Context, eid, clusterID string, policy v1alpha1.EtcdSnapshotPolicy, rollback func(step, message, status string)) error {
+	return nil
+}
+
+func (s *snapshotterAdaptor) RestoreEtcdSnapshot(ctx context.Context, eid, clusterID, name string, rollback func(step, message, status string)) error {
+	s.restored = name
+	return nil
+}
+
+func TestEtcdSnapshot(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	snapshotter := &snapshotterAdaptor{}
+	registerTestProvider(t, "test-snapshotter", adaptor.InterfaceEtcdSnapshotter, adaptor.CapabilityEtcdSnapshot, snapshotter)
+
+	if _, err := c.SaveEtcdSnapshot("eid", "cluster", &v1.SaveEtcdSnapshotReq{ProviderName: "test-snapshotter", Name: "../snapshot"}); errors.Cause(err) != bcode.ErrEtcdSnapshotNameInvalid {
+		t.Errorf("expected ErrEtcdSnapshotNameInvalid, got %v", err)
+	}
+	task, err := c.SaveEtcdSnapshot("eid", "cluster", &v1.SaveEtcdSnapshotReq{ProviderName: "test-snapshotter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitOperationTask(t, c, task)
+	snapshots, err := c.ListEtcdSnapshots("eid", "cluster", "test-snapshotter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name == "" {
+		t.Fatalf("expected the generated snapshot, got %v", snapshots)
+	}
+
+	if _, err := c.RestoreEtcdSnapshot("eid", "cluster", &v1.RestoreEtcdSnapshotReq{ProviderName: "test-snapshotter", Name: "not-exist"}); errors.Cause(err) != bcode.ErrEtcdSnapshotNotFound {
+		t.Errorf("expected ErrEtcdSnapshotNotFound, got %v", err)
+	}
+	task, err = c.RestoreEtcdSnapshot("eid", "cluster", &v1.RestoreEtcdSnapshotReq{ProviderName: "test-snapshotter", Name: snapshots[0].Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	if last := events[len(events)-1]; last.StepType != v1.StepRestoreEtcdSnapshot || last.Status != "success" {
+		t.Errorf("expected the restore succeeds, got %s %s", last.StepType, last.Status)
+	}
+	if snapshotter.restored != snapshots[0].Name {
+		t.Errorf("expected restoring %s, got %s", snapshots[0].Name, snapshotter.restored)
+	}
+}
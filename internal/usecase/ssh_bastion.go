@@ -0,0 +1,119 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"github.com/pkg/errors"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
+	"gorm.io/gorm"
+)
+
+// checkSSHBastion checks the bastion can be logged in and forwards tcp, it is replaced in the tests.
+var checkSSHBastion = ssh.CheckBastion
+
+// validateSSHBastion returns ErrBastionUnreachable if the bastion can not be logged in with its own key or the key of the enterprise.
+func (c *ClusterUsecase) validateSSHBastion(eid string, bastion *v1alpha1.SSHBastion) error {
+	if bastion.PrivateKey != "" {
+		if _, _, _, err := ssh.ParseKeyPair([]byte(bastion.PrivateKey)); err != nil {
+			return errors.Wrap(bcode.ErrBastionUnreachable, err.Error())
+		}
+	}
+	key, err := c.getSSHPrivateKey(eid)
+	if err != nil {
+		return err
+	}
+	if err := checkSSHBastion(bastion.Dialer(key), sshCheckTimeout); err != nil {
+		return errors.Wrap(bcode.ErrBastionUnreachable, err.Error())
+	}
+	return nil
+}
+
+// saveSSHBastion creates or replaces the bastion of the cluster
+func (c *ClusterUsecase) saveSSHBastion(eid, clusterID string, bastion *v1alpha1.SSHBastion) (*model.SSHBastion, error) {
+	port := bastion.Port
+	if port == 0 {
+		port = 22
+	}
+	m := &model.SSHBastion{
+		EnterpriseID: eid,
+		ClusterID:    clusterID,
+		Address:      bastion.Address,
+		Port:         port,
+		User:         bastion.User,
+	}
+	if err := c.sshBastionRepo.Save(m, []byte(bastion.PrivateKey)); err != nil {
+		return nil, err
+	}
+	m.PrivateKey = ""
+	return m, nil
+}
+
+// getClusterSSHBastion returns the bastion of the cluster logged in with its own key or the key, or nil if the cluster has no bastion.
+func (c *ClusterUsecase) getClusterSSHBastion(eid, clusterID string, key []byte) (*ssh.Bastion, error) {
+	bastion, err := c.sshBastionRepo.Get(eid, clusterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	private, err := c.sshBastionRepo.GetPrivateKey(bastion)
+	if err != nil {
+		return nil, err
+	}
+	if private == nil {
+		private = key
+	}
+	return &ssh.Bastion{Host: bastion.Address, Port: bastion.Port, User: bastion.User, PrivateKey: private}, nil
+}
+
+// SetClusterBastion validates the bastion and sets it as the bastion the nodes of the cluster are reached through.
+func (c *ClusterUsecase) SetClusterBastion(eid, clusterID string, req *v1alpha1.SSHBastion) (*model.SSHBastion, error) {
+	if _, err := c.getRKECluster(eid, clusterID); err != nil {
+		return nil, err
+	}
+	if err := c.validateSSHBastion(eid, req); err != nil {
+		return nil, err
+	}
+	return c.saveSSHBastion(eid, clusterID, req)
+}
+
+// GetClusterBastion returns the bastion of the cluster without the private key.
+func (c *ClusterUsecase) GetClusterBastion(eid, clusterID string) (*model.SSHBastion, error) {
+	bastion, err := c.sshBastionRepo.Get(eid, clusterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(bcode.ErrBastionNotFound)
+		}
+		return nil, err
+	}
+	bastion.PrivateKey = ""
+	return bastion, nil
+}
+
+// DeleteClusterBastion deletes the bastion of the cluster, the nodes are dialed directly afterwards.
+func (c *ClusterUsecase) DeleteClusterBastion(eid, clusterID string) error {
+	if _, err := c.GetClusterBastion(eid, clusterID); err != nil {
+		return err
+	}
+	return c.sshBastionRepo.Delete(eid, clusterID)
+}
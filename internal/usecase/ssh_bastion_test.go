@@ -0,0 +1,155 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
+)
+
+func TestClusterBastion(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	var checked *ssh.Bastion
+	defer func(check func(*ssh.Bastion, time.Duration) error) { checkSSHBastion = check }(checkSSHBastion)
+	checkSSHBastion = func(bastion *ssh.Bastion, timeout time.Duration) error {
+		checked = bastion
+		if bastion.Host == "10.0.0.9" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	if _, err := c.SetClusterBastion("eid", "c2", &v1alpha1.SSHBastion{Address: "10.0.0.1", User: "jump"}); errors.Cause(err) != bcode.ErrClusterNotFound {
+		t.Fatalf("expected ErrClusterNotFound, got %v", err)
+	}
+	if _, err := c.SetClusterBastion("eid", "c1", &v1alpha1.SSHBastion{Address: "10.0.0.9", User: "jump"}); errors.Cause(err) != bcode.ErrBastionUnreachable {
+		t.Fatalf("expected ErrBastionUnreachable, got %v", err)
+	}
+
+	// the bastion without a key of its own is logged in with the enterprise key
+	if _, err := c.SetClusterBastion("eid", "c1", &v1alpha1.SSHBastion{Address: "10.0.0.1", User: "jump"}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, pub, _, _ := ssh.ParseKeyPair(checked.PrivateKey); pub != key.PublicKey || checked.Port != 22 {
+		t.Fatalf("expected the bastion is checked with the enterprise key on port 22, got %s %d", pub, checked.Port)
+	}
+	bastion, err := c.GetClusterBastion("eid", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bastion.Address != "10.0.0.1" || bastion.Port != 22 || bastion.User != "jump" || bastion.PrivateKey != "" {
+		t.Fatalf("unexpected bastion %+v", bastion)
+	}
+
+	own, _, err := ssh.MakeKeyPair(ssh.KeyTypeED25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetClusterBastion("eid", "c1", &v1alpha1.SSHBastion{Address: "10.0.0.2", Port: 2222, User: "jump", PrivateKey: own}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := c.sshBastionRepo.Get("eid", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored.PrivateKey, "PRIVATE KEY") {
+		t.Fatal("the private key should be encrypted")
+	}
+	dialer, err := c.getClusterSSHBastion("eid", "c1", []byte("enterprise key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialer.Host != "10.0.0.2" || dialer.Port != 2222 || string(dialer.PrivateKey) != own {
+		t.Fatalf("expected the bastion is logged in with its own key, got %s:%d", dialer.Host, dialer.Port)
+	}
+
+	if err := c.DeleteClusterBastion("eid", "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetClusterBastion("eid", "c1"); errors.Cause(err) != bcode.ErrBastionNotFound {
+		t.Fatalf("expected ErrBastionNotFound, got %v", err)
+	}
+	if dialer, err := c.getClusterSSHBastion("eid", "c1", nil); err != nil || dialer != nil {
+		t.Fatalf("expected no bastion, got %v %v", dialer, err)
+	}
+}
+
+func TestRotateSSHKeyThroughBastion(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.rkeStateStore.Put(repo.RKEStatePrefix("eid", "c1")+"cluster.yml", []byte(testSSHKeyNodesConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.saveSSHBastion("eid", "c1", &v1alpha1.SSHBastion{Address: "10.0.0.1", User: "jump"}); err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := &fakeNodes{authorized: map[string][]string{
+		"10.0.0.1":    {strings.TrimSpace(oldKey.PublicKey)},
+		"192.168.1.1": {strings.TrimSpace(oldKey.PublicKey)},
+		"192.168.1.2": {strings.TrimSpace(oldKey.PublicKey)},
+	}}
+	defer func(run func(*ssh.Bastion, string, uint, string, []byte, string) (string, error)) {
+		runSSHCommand = run
+	}(runSSHCommand)
+	runSSHCommand = nodes.run
+
+	task, err := c.RotateSSHKey("eid", &v1.RotateSSHKeyReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	if last := events[len(events)-1]; last.StepType != v1.StepRotateSSHKey || last.Status != "success" {
+		t.Fatalf("expected the rotation succeeds, got %s %s %s", last.StepType, last.Status, last.Message)
+	}
+	newKey, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the bastion logged in with the enterprise key is rotated as well
+	for _, host := range []string{"10.0.0.1", "192.168.1.1", "192.168.1.2"} {
+		if keys := nodes.authorized[host]; len(keys) != 1 || keys[0] != strings.TrimSpace(newKey.PublicKey) {
+			t.Errorf("expected host %s authorizes the new key only, got %v", host, keys)
+		}
+	}
+	if nodes.via["192.168.1.1"] != "10.0.0.1" || nodes.via["10.0.0.1"] != "" {
+		t.Errorf("expected the nodes are reached through the bastion, got %v", nodes.via)
+	}
+}
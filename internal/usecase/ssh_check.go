@@ -0,0 +1,101 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"path"
+	"time"
+
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
+	"k8s.io/client-go/util/homedir"
+)
+
+// checkSSHConnect logs in to the host, it is replaced in tests
+var checkSSHConnect = ssh.CheckSSHConnect
+
+var (
+	// sshCheckParallelism the max number of the nodes checked at the same time
+	sshCheckParallelism = 20
+	// sshCheckTimeout the max time of checking a node
+	sshCheckTimeout = 10 * time.Second
+)
+
+// CheckSSHBatch logs in to the nodes with their users and the key of the enterprise concurrently,
+// and checks the users can run docker. The legacy key of cloud adaptor is used if eid is empty.
+// The nodes are dialed through the bastion of the request, or the bastion of the cluster ClusterID if it has one.
+func (c *ClusterUsecase) CheckSSHBatch(eid string, req *v1.CheckSSHBatchReq) (*v1.CheckSSHBatchRes, error) {
+	key, err := c.getSSHPrivateKey(eid)
+	if err != nil {
+		return nil, err
+	}
+	bastion := req.Bastion.Dialer(key)
+	if bastion == nil && req.ClusterID != "" {
+		if bastion, err = c.getClusterSSHBastion(eid, req.ClusterID, key); err != nil {
+			return nil, err
+		}
+	}
+	var targets []ssh.CheckTarget
+	for _, node := range req.Nodes {
+		target := ssh.CheckTarget{Host: node.IP, Port: uint(node.SSHPort), User: node.SSHUser}
+		if target.Port == 0 {
+			target.Port = 22
+		}
+		if target.User == "" {
+			target.User = "docker"
+		}
+		targets = append(targets, target)
+	}
+	checker := &ssh.Checker{
+		PrivateKey:     key,
+		Bastion:        bastion,
+		KnownHostsFile: path.Join(homedir.HomeDir(), ".ssh", "known_hosts"),
+		Parallelism:    sshCheckParallelism,
+		Timeout:        sshCheckTimeout,
+	}
+	results, err := checker.CheckHosts(targets)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.CheckSSHBatchRes{Nodes: results}, nil
+}
+
+// CheckSSH logs in to the host with the user and the key of the enterprise. The legacy key of cloud adaptor is used if eid is empty.
+// The host is dialed through the bastion of the request, or the bastion of the cluster ClusterID if it has one.
+func (c *ClusterUsecase) CheckSSH(eid string, req *v1.CheckSSHReq) (bool, error) {
+	key, err := c.getSSHPrivateKey(eid)
+	if err != nil {
+		return false, err
+	}
+	port := req.Port
+	if port == 0 {
+		port = 22
+	}
+	user := req.User
+	if user == "" {
+		user = "docker"
+	}
+	bastion := req.Bastion.Dialer(key)
+	if bastion == nil && req.ClusterID != "" {
+		if bastion, err = c.getClusterSSHBastion(eid, req.ClusterID, key); err != nil {
+			return false, err
+		}
+	}
+	return checkSSHConnect(req.Host, port, user, key, bastion)
+}
@@ -0,0 +1,88 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"testing"
+
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
+)
+
+// fakeSSHConnect records the arguments of the last check
+type fakeSSHConnect struct {
+	host    string
+	port    uint
+	user    string
+	key     []byte
+	bastion *ssh.Bastion
+}
+
+func (f *fakeSSHConnect) check(host string, port uint, user string, key []byte, bastion *ssh.Bastion) (bool, error) {
+	f.host, f.port, f.user, f.key, f.bastion = host, port, user, key, bastion
+	return true, nil
+}
+
+func TestCheckSSH(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	checked := &fakeSSHConnect{}
+	defer func(check func(string, uint, string, []byte, *ssh.Bastion) (bool, error)) { checkSSHConnect = check }(checkSSHConnect)
+	checkSSHConnect = checked.check
+
+	ok, err := c.CheckSSH("eid", &v1.CheckSSHReq{Host: "192.168.1.1", Bastion: &v1alpha1.SSHBastion{Address: "10.0.0.1", User: "jump"}})
+	if err != nil || !ok {
+		t.Fatalf("expected the check passes, got %v %v", ok, err)
+	}
+	key, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, pub, _, _ := ssh.ParseKeyPair(checked.key); pub != key.PublicKey {
+		t.Errorf("expected the host is checked with the enterprise key, got %s", pub)
+	}
+	if checked.port != 22 || checked.user != "docker" {
+		t.Errorf("expected the default port and user, got %d %s", checked.port, checked.user)
+	}
+	if checked.bastion == nil || checked.bastion.Host != "10.0.0.1" || string(checked.bastion.PrivateKey) != string(checked.key) {
+		t.Errorf("expected the host is checked through the bastion with the enterprise key, got %+v", checked.bastion)
+	}
+
+	if _, err := c.CheckSSH("eid", &v1.CheckSSHReq{Host: "192.168.1.1", Port: 2222, User: "root"}); err != nil {
+		t.Fatal(err)
+	}
+	if checked.port != 2222 || checked.user != "root" || checked.bastion != nil {
+		t.Errorf("unexpected check %d %s %+v", checked.port, checked.user, checked.bastion)
+	}
+
+	// the host is dialed through the bastion of the cluster
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.saveSSHBastion("eid", "c1", &v1alpha1.SSHBastion{Address: "10.0.0.2", Port: 2222, User: "jump"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CheckSSH("eid", &v1.CheckSSHReq{Host: "192.168.1.1", ClusterID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if checked.bastion == nil || checked.bastion.Host != "10.0.0.2" || checked.bastion.Port != 2222 || string(checked.bastion.PrivateKey) != string(checked.key) {
+		t.Errorf("expected the host is checked through the bastion of the cluster, got %+v", checked.bastion)
+	}
+}
@@ -0,0 +1,391 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rke/types"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
+	"gorm.io/gorm"
+)
+
+// legacySSHKeyPath the key file shared by the enterprises before they have their own keys
+const legacySSHKeyPath = "~/.ssh/id_rsa"
+
+// defaultSSHKeyType the type of the keys made for the enterprises
+const defaultSSHKeyType = ssh.KeyTypeED25519
+
+// runSSHCommand runs the command on the node, it is replaced in the tests.
+var runSSHCommand = ssh.RunCommand
+
+// sshKeyLock makes sure only one key is created for the enterprise without one.
+var sshKeyLock sync.Mutex
+
+// getOrCreateSSHKey returns the active key of the enterprise. The enterprise without one imports the legacy key file,
+// so the nodes initialised with it can still be logged in, or makes a new key if the file does not exist.
+func (c *ClusterUsecase) getOrCreateSSHKey(eid string) (*model.SSHKey, error) {
+	if err := repo.CheckSSHKeySecret(); err != nil {
+		return nil, err
+	}
+	sshKeyLock.Lock()
+	defer sshKeyLock.Unlock()
+	key, err := c.sshKeyRepo.GetActive(eid)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, err
+	}
+	private, err := ssh.ReadPrivateKey(legacySSHKeyPath)
+	if err != nil {
+		logrus.Infof("make the ssh key of enterprise %s: %s", eid, err.Error())
+		made, _, err := ssh.MakeKeyPair(defaultSSHKeyType)
+		if err != nil {
+			return nil, errors.Wrap(err, "make ssh key")
+		}
+		private = []byte(made)
+	}
+	return c.createSSHKey(eid, private, model.SSHKeyStatusActive)
+}
+
+func (c *ClusterUsecase) createSSHKey(eid string, private []byte, status string) (*model.SSHKey, error) {
+	keyType, pub, fingerprint, err := ssh.ParseKeyPair(private)
+	if err != nil {
+		return nil, err
+	}
+	key := &model.SSHKey{
+		EnterpriseID: eid,
+		Type:         keyType,
+		PublicKey:    pub,
+		Fingerprint:  fingerprint,
+		Status:       status,
+	}
+	if err := c.sshKeyRepo.Create(key, private); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// getSSHPrivateKey returns the private key of the enterprise, or the legacy key if eid is empty.
+func (c *ClusterUsecase) getSSHPrivateKey(eid string) ([]byte, error) {
+	if eid == "" {
+		if _, err := ssh.GetOrMakeSSHRSA(); err != nil {
+			return nil, err
+		}
+		return ssh.ReadPrivateKey(legacySSHKeyPath)
+	}
+	key, err := c.getOrCreateSSHKey(eid)
+	if err != nil {
+		return nil, err
+	}
+	return c.sshKeyRepo.GetPrivateKey(key)
+}
+
+// getSSHPublicKey returns the public key of the enterprise, or the legacy key if eid is empty.
+func (c *ClusterUsecase) getSSHPublicKey(eid string) (string, error) {
+	if eid == "" {
+		return ssh.GetOrMakeSSHRSA()
+	}
+	key, err := c.getOrCreateSSHKey(eid)
+	if err != nil {
+		return "", err
+	}
+	return key.PublicKey, nil
+}
+
+// GetSSHKey returns the active key of the enterprise without the private key.
+func (c *ClusterUsecase) GetSSHKey(eid string) (*model.SSHKey, error) {
+	key, err := c.getOrCreateSSHKey(eid)
+	if err != nil {
+		return nil, err
+	}
+	key.PrivateKey = ""
+	return key, nil
+}
+
+// sshKeyNode the node logged in with the key of the enterprise
+type sshKeyNode struct {
+	Address string
+	Port    uint
+	User    string
+	// Bastion the node is reached through the bastion if its address is not empty
+	Bastion sshKeyBastion
+}
+
+// sshKeyBastion the bastion which the nodes are reached through, it is logged in with the key of the enterprise if PrivateKey is empty.
+type sshKeyBastion struct {
+	Address    string
+	Port       uint
+	User       string
+	PrivateKey string
+}
+
+// run runs the command on the node with the key, the bastion without a key of its own is logged in with the bastionKey.
+func (n sshKeyNode) run(bastionKey, key []byte, command string) (string, error) {
+	var bastion *ssh.Bastion
+	if n.Bastion.Address != "" {
+		bastion = &ssh.Bastion{Host: n.Bastion.Address, Port: n.Bastion.Port, User: n.Bastion.User, PrivateKey: []byte(n.Bastion.PrivateKey)}
+		if len(bastion.PrivateKey) == 0 {
+			bastion.PrivateKey = bastionKey
+		}
+	}
+	return runSSHCommand(bastion, n.Address, n.Port, n.User, key, command)
+}
+
+// getSSHKeyBastion returns the bastion the nodes of the cluster are reached through, the one in the rke config takes precedence.
+// The address of the bastion is empty if the cluster has none.
+func (c *ClusterUsecase) getSSHKeyBastion(eid, clusterID string, rkeConfig *v3.RancherKubernetesEngineConfig) (sshKeyBastion, error) {
+	if host := rkeConfig.BastionHost; host.Address != "" {
+		port, _ := strconv.Atoi(host.Port)
+		if port == 0 {
+			port = 22
+		}
+		bastion := sshKeyBastion{Address: host.Address, Port: uint(port), User: host.User, PrivateKey: host.SSHKey}
+		if bastion.PrivateKey == "" && host.SSHKeyPath != "" && host.SSHKeyPath != legacySSHKeyPath {
+			key, err := ssh.ReadPrivateKey(host.SSHKeyPath)
+			if err != nil {
+				return bastion, err
+			}
+			bastion.PrivateKey = string(key)
+		}
+		return bastion, nil
+	}
+	bastion, err := c.sshBastionRepo.Get(eid, clusterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return sshKeyBastion{}, nil
+		}
+		return sshKeyBastion{}, err
+	}
+	key, err := c.sshBastionRepo.GetPrivateKey(bastion)
+	if err != nil {
+		return sshKeyBastion{}, err
+	}
+	return sshKeyBastion{Address: bastion.Address, Port: bastion.Port, User: bastion.User, PrivateKey: string(key)}, nil
+}
+
+// listSSHKeyNodes lists the nodes of the rke clusters of the enterprise which log in with the enterprise key rather than their own keys,
+// and the bastions logging in with the enterprise key. The clusters not created yet have no nodes.
+func (c *ClusterUsecase) listSSHKeyNodes(eid string) ([]sshKeyNode, error) {
+	clusters, err := c.rkeClusterRepo.ListCluster(eid)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []sshKeyNode
+	exists := make(map[sshKeyNode]bool)
+	add := func(n sshKeyNode) {
+		if !exists[n] {
+			exists[n] = true
+			nodes = append(nodes, n)
+		}
+	}
+	for _, cluster := range clusters {
+		rkeConfig, err := c.getRKEConfig(eid, cluster)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get the rke config of cluster %s", cluster.ClusterID)
+		}
+		if rkeConfig == nil {
+			continue
+		}
+		bastion, err := c.getSSHKeyBastion(eid, cluster.ClusterID, rkeConfig)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get the bastion of cluster %s", cluster.ClusterID)
+		}
+		if bastion.Address != "" && bastion.PrivateKey == "" {
+			add(sshKeyNode{Address: bastion.Address, Port: bastion.Port, User: bastion.User})
+		}
+		for _, node := range rkeConfig.Nodes {
+			keyPath := node.SSHKeyPath
+			if keyPath == "" {
+				keyPath = rkeConfig.SSHKeyPath
+			}
+			if node.SSHKey != "" || node.SSHAgentAuth || (keyPath != "" && keyPath != legacySSHKeyPath) {
+				continue
+			}
+			port, _ := strconv.Atoi(node.Port)
+			if port == 0 {
+				port = 22
+			}
+			user := node.User
+			if user == "" {
+				user = "docker"
+			}
+			add(sshKeyNode{Address: node.Address, Port: uint(port), User: user, Bastion: bastion})
+		}
+	}
+	return nodes, nil
+}
+
+// forEachSSHKeyNode runs fn on the nodes concurrently and reports the result of every node as an event of the step,
+// returns the nodes fn succeeds on.
+func forEachSSHKeyNode(ctx context.Context, nodes []sshKeyNode, step string, rollback func(step, message, status string),
+	fn func(node sshKeyNode) error) []sshKeyNode {
+	succeeded := make([]bool, len(nodes))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, sshCheckParallelism)
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			nodeStep := v1alpha1.NodeStepType(step, nodes[i].Address)
+			if err := ctx.Err(); err != nil {
+				rollback(nodeStep, err.Error(), "failure")
+				return
+			}
+			rollback(nodeStep, "", "start")
+			if err := fn(nodes[i]); err != nil {
+				rollback(nodeStep, err.Error(), "failure")
+				return
+			}
+			succeeded[i] = true
+			rollback(nodeStep, "", "success")
+		}(i)
+	}
+	wg.Wait()
+	var re []sshKeyNode
+	for i := range nodes {
+		if succeeded[i] {
+			re = append(re, nodes[i])
+		}
+	}
+	return re
+}
+
+// RotateSSHKey makes a new key for the enterprise in background. The new public key is pushed to all nodes of the rke clusters
+// with the old key and verified by logging in with the new key, then the new key is activated and the old public key is removed
+// from the nodes. The old key stays active if the new key can not be pushed to any node.
+func (c *ClusterUsecase) RotateSSHKey(eid string, req *v1.RotateSSHKeyReq) (*model.OperationTask, error) {
+	keyType := req.Type
+	if keyType == "" {
+		keyType = defaultSSHKeyType
+	}
+	if _, err := c.getOrCreateSSHKey(eid); err != nil {
+		return nil, err
+	}
+	if _, err := c.listSSHKeyNodes(eid); err != nil {
+		return nil, err
+	}
+	clusters, err := c.rkeClusterRepo.ListCluster(eid)
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		if err := c.checkClusterTasksComplete(eid, cluster.ClusterID); err != nil {
+			return nil, err
+		}
+	}
+	task, err := c.createOperationTask(eid, "", "rke", domain.ClusterTaskTypeRotateSSHKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.startOperationTask(task, rotateSSHKeyParams{Type: keyType}); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// rotateSSHKeyParams the params of the task rotating the ssh key
+type rotateSSHKeyParams struct {
+	Type string `json:"type"`
+}
+
+func (c *ClusterUsecase) runRotateSSHKey(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p rotateSSHKeyParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	eid := task.EnterpriseID
+	oldKey, err := c.getOrCreateSSHKey(eid)
+	if err != nil {
+		return err
+	}
+	nodes, err := c.listSSHKeyNodes(eid)
+	if err != nil {
+		return err
+	}
+	oldPrivate, err := c.sshKeyRepo.GetPrivateKey(oldKey)
+	if err != nil {
+		return err
+	}
+	newPrivate, _, err := ssh.MakeKeyPair(p.Type)
+	if err != nil {
+		return errors.Wrap(err, "make ssh key")
+	}
+	newKey, err := c.createSSHKey(eid, []byte(newPrivate), model.SSHKeyStatusPending)
+	if err != nil {
+		return err
+	}
+	authorize, err := ssh.AuthorizeKeyCommand(newKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	pushed := forEachSSHKeyNode(ctx, nodes, v1.StepPushSSHKey, rollback, func(node sshKeyNode) error {
+		if _, err := node.run(oldPrivate, oldPrivate, authorize); err != nil {
+			return err
+		}
+		// the bastion is still logged in with the old key, it may not have the new key yet
+		_, err := node.run(oldPrivate, []byte(newPrivate), "true")
+		return errors.WithMessage(err, "login with the new key")
+	})
+	if len(pushed) < len(nodes) {
+		c.withdrawSSHKey(pushed, oldPrivate, newKey)
+		return fmt.Errorf("push the new key to %d of %d nodes failure, the old key is still active", len(nodes)-len(pushed), len(nodes))
+	}
+	if err := c.sshKeyRepo.Activate(newKey); err != nil {
+		return err
+	}
+	revoke, err := ssh.RevokeKeyCommand(oldKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	revoked := forEachSSHKeyNode(ctx, nodes, v1.StepRevokeSSHKey, rollback, func(node sshKeyNode) error {
+		_, err := node.run([]byte(newPrivate), []byte(newPrivate), revoke)
+		return err
+	})
+	if len(revoked) < len(nodes) {
+		logrus.Warningf("the old ssh key %s of enterprise %s is not removed from %d nodes", oldKey.Fingerprint, eid, len(nodes)-len(revoked))
+	}
+	return nil
+}
+
+// withdrawSSHKey removes the new key from the nodes it is pushed to and deletes it, after it fails to be pushed to the other nodes.
+func (c *ClusterUsecase) withdrawSSHKey(nodes []sshKeyNode, oldPrivate []byte, newKey *model.SSHKey) {
+	revoke, err := ssh.RevokeKeyCommand(newKey.PublicKey)
+	if err == nil {
+		for _, node := range nodes {
+			if _, err := node.run(oldPrivate, oldPrivate, revoke); err != nil {
+				logrus.Warningf("remove the new ssh key from node %s failure %s", node.Address, err.Error())
+			}
+		}
+	}
+	if err := c.sshKeyRepo.Delete(newKey); err != nil {
+		logrus.Errorf("delete the ssh key %s failure %s", newKey.KeyID, err.Error())
+	}
+}
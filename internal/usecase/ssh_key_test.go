@@ -0,0 +1,245 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/cmd/cloud-adaptor/config"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/ssh"
+)
+
+// fakeNodes the authorized_keys of the nodes
+type fakeNodes struct {
+	lock        sync.Mutex
+	authorized  map[string][]string
+	unreachable string
+	// via the bastion the node is reached through last time
+	via map[string]string
+}
+
+// run logs in to the node through the bastion with the private key and runs the commands of authorizing and revoking the keys
+func (f *fakeNodes) run(bastion *ssh.Bastion, host string, port uint, user string, privateKey []byte, command string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if bastion != nil {
+		if err := f.login(bastion.Host, bastion.PrivateKey); err != nil {
+			return "", err
+		}
+		if f.via == nil {
+			f.via = make(map[string]string)
+		}
+		f.via[host] = bastion.Host
+	}
+	if err := f.login(host, privateKey); err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(command, "mkdir"):
+		line := command[strings.Index(command, "echo '")+len("echo '") : strings.LastIndex(command, "' >>")]
+		f.authorized[host] = append(f.authorized[host], line)
+	case strings.HasPrefix(command, "if"):
+		blob := strings.SplitN(command[strings.Index(command, "grep -vF '")+len("grep -vF '"):], "'", 2)[0]
+		var keys []string
+		for _, key := range f.authorized[host] {
+			if !strings.Contains(key, blob) {
+				keys = append(keys, key)
+			}
+		}
+		f.authorized[host] = keys
+	}
+	return "", nil
+}
+
+func (f *fakeNodes) login(host string, privateKey []byte) error {
+	if host == f.unreachable {
+		return errors.New("connection refused")
+	}
+	_, pub, _, err := ssh.ParseKeyPair(privateKey)
+	if err != nil {
+		return err
+	}
+	if !f.isAuthorized(host, pub) {
+		return errors.New("unable to authenticate")
+	}
+	return nil
+}
+
+func (f *fakeNodes) isAuthorized(host, pub string) bool {
+	for _, key := range f.authorized[host] {
+		if key == strings.TrimSpace(pub) {
+			return true
+		}
+	}
+	return false
+}
+
+const testSSHKeyNodesConfig = `
+nodes:
+- address: 192.168.1.1
+  role: [controlplane, etcd, worker]
+- address: 192.168.1.2
+  user: root
+  role: [worker]
+- address: 192.168.1.3
+  ssh_key_path: /root/.ssh/custom
+  role: [worker]
+`
+
+func newSSHKeyTestUsecase(t *testing.T) *ClusterUsecase {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_KEY_SECRET", "secret")
+	return newTestClusterUsecase(t)
+}
+
+func TestGetInitNodeCmd(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	old := config.C
+	config.C = &config.Config{AdvertiseURL: "http://127.0.0.1:8080"}
+	defer func() { config.C = old }()
+
+	legacy, legacyPub, err := ssh.MakeKeyPair(ssh.KeyTypeRSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(path.Join(os.Getenv("HOME"), ".ssh"), 0700)
+	if err := os.WriteFile(path.Join(os.Getenv("HOME"), ".ssh", "id_rsa"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	script := getInitNodeScript(t, c, "eid1", &v1.InitNodeCmdReq{})
+	if !strings.Contains(script, strings.TrimSpace(legacyPub)) {
+		t.Fatalf("expected the enterprise imports the legacy key, got %s", script)
+	}
+
+	os.Remove(path.Join(os.Getenv("HOME"), ".ssh", "id_rsa"))
+	key, err := c.GetSSHKey("eid2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Type != ssh.KeyTypeED25519 || key.PrivateKey != "" {
+		t.Fatalf("expected the ed25519 key without the private key, got %s %s", key.Type, key.PrivateKey)
+	}
+	script = getInitNodeScript(t, c, "eid2", &v1.InitNodeCmdReq{})
+	if !strings.Contains(script, strings.TrimSpace(key.PublicKey)) || strings.Contains(script, strings.TrimSpace(legacyPub)) {
+		t.Fatalf("expected the key of eid2, got %s", script)
+	}
+
+	stored, err := c.sshKeyRepo.GetActive("eid2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored.PrivateKey, "PRIVATE KEY") {
+		t.Fatal("the private key should be encrypted")
+	}
+	private, err := c.sshKeyRepo.GetPrivateKey(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, pub, _, _ := ssh.ParseKeyPair(private); pub != key.PublicKey {
+		t.Fatalf("expected the private key of %s, got %s", key.PublicKey, pub)
+	}
+}
+
+func TestGetSSHKeyWithoutSecret(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	t.Setenv("SSH_KEY_SECRET", "")
+	if _, err := c.GetSSHKey("eid"); !errors.Is(err, bcode.ErrSSHKeySecretNotSet) {
+		t.Fatalf("expected ErrSSHKeySecretNotSet, got %v", err)
+	}
+}
+
+func TestRotateSSHKey(t *testing.T) {
+	c := newSSHKeyTestUsecase(t)
+	if err := c.rkeClusterRepo.Create(&model.RKECluster{EnterpriseID: "eid", Name: "c1", ClusterID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.rkeStateStore.Put(repo.RKEStatePrefix("eid", "c1")+"cluster.yml", []byte(testSSHKeyNodesConfig)); err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := &fakeNodes{authorized: map[string][]string{
+		"192.168.1.1": {strings.TrimSpace(oldKey.PublicKey)},
+		"192.168.1.2": {strings.TrimSpace(oldKey.PublicKey)},
+	}}
+	defer func(run func(*ssh.Bastion, string, uint, string, []byte, string) (string, error)) {
+		runSSHCommand = run
+	}(runSSHCommand)
+	runSSHCommand = nodes.run
+
+	// the old key stays active if the new key can not be pushed to any node
+	nodes.unreachable = "192.168.1.2"
+	task, err := c.RotateSSHKey("eid", &v1.RotateSSHKeyReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	if last := events[len(events)-1]; last.StepType != v1.StepRotateSSHKey || last.Status != "failure" {
+		t.Fatalf("expected the rotation fails, got %s %s", last.StepType, last.Status)
+	}
+	if active, _ := c.GetSSHKey("eid"); active.KeyID != oldKey.KeyID {
+		t.Fatalf("expected the old key %s is active, got %s", oldKey.KeyID, active.KeyID)
+	}
+	if keys := nodes.authorized["192.168.1.1"]; len(keys) != 1 || keys[0] != strings.TrimSpace(oldKey.PublicKey) {
+		t.Fatalf("expected the new key is removed from the node, got %v", keys)
+	}
+
+	nodes.unreachable = ""
+	task, err = c.RotateSSHKey("eid", &v1.RotateSSHKeyReq{Type: ssh.KeyTypeRSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = waitOperationTask(t, c, task)
+	if last := events[len(events)-1]; last.StepType != v1.StepRotateSSHKey || last.Status != "success" {
+		t.Fatalf("expected the rotation succeeds, got %s %s %s", last.StepType, last.Status, last.Message)
+	}
+	newKey, err := c.GetSSHKey("eid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKey.KeyID == oldKey.KeyID || newKey.Type != ssh.KeyTypeRSA {
+		t.Fatalf("expected the new rsa key is active, got %s %s", newKey.KeyID, newKey.Type)
+	}
+	for _, host := range []string{"192.168.1.1", "192.168.1.2"} {
+		if keys := nodes.authorized[host]; len(keys) != 1 || keys[0] != strings.TrimSpace(newKey.PublicKey) {
+			t.Errorf("expected node %s authorizes the new key only, got %v", host, keys)
+		}
+	}
+	if _, ok := nodes.authorized["192.168.1.3"]; ok {
+		t.Error("the node with its own key should not be changed")
+	}
+	var revoked model.SSHKey
+	if err := c.DB.Where("key_id = ?", oldKey.KeyID).Take(&revoked).Error; err != nil {
+		t.Fatal(err)
+	}
+	if revoked.Status != model.SSHKeyStatusRevoked || revoked.PrivateKey != "" {
+		t.Errorf("expected the old key is revoked, got %s", revoked.Status)
+	}
+}
@@ -0,0 +1,131 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"goodrain.com/cloud-adaptor/internal/model"
+)
+
+// the interval to reload the events which may be saved by other instances
+var watchTaskEventInterval = time.Second * 5
+
+// taskEventBroker notifies the watchers of the task when an event of the task is saved.
+type taskEventBroker struct {
+	lock     sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func newTaskEventBroker() *taskEventBroker {
+	return &taskEventBroker{watchers: make(map[string]map[chan struct{}]struct{})}
+}
+
+func (b *taskEventBroker) watch(taskID string) (chan struct{}, func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	ch := make(chan struct{}, 1)
+	if b.watchers[taskID] == nil {
+		b.watchers[taskID] = make(map[chan struct{}]struct{})
+	}
+	b.watchers[taskID][ch] = struct{}{}
+	return ch, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.watchers[taskID], ch)
+		if len(b.watchers[taskID]) == 0 {
+			delete(b.watchers, taskID)
+		}
+	}
+}
+
+func (b *taskEventBroker) notify(taskID string) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for ch := range b.watchers[taskID] {
+		// the watcher reloads all new events, one pending notification is enough
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// WatchTaskEvents returns the events of the task saved after the seq in order,
+// a negative seq means from the beginning. The channel is closed after the terminal
+// event of the task is sent or the ctx is done.
+func (c *ClusterUsecase) WatchTaskEvents(ctx context.Context, eid, taskID string, seq int64) (<-chan *model.TaskEvent, error) {
+	if _, err := c.getTask(eid, taskID); err != nil {
+		return nil, err
+	}
+	notify, cancel := c.eventBroker.watch(taskID)
+	// the terminal event may have been sent before
+	all, err := c.TaskEventRepo.ListEvent(eid, taskID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	var terminated bool
+	for _, event := range all {
+		if event.Seq <= seq && IsTerminalEvent(event.StepType, event.Status) {
+			terminated = true
+		}
+	}
+
+	events := make(chan *model.TaskEvent, 10)
+	go func() {
+		defer cancel()
+		defer close(events)
+		ticker := time.NewTicker(watchTaskEventInterval)
+		defer ticker.Stop()
+		for {
+			list, err := c.TaskEventRepo.ListEventAfter(eid, taskID, seq)
+			if err != nil {
+				logrus.Errorf("list events of task %s failure %s", taskID, err.Error())
+			}
+			for _, event := range list {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				seq = event.Seq
+				if IsTerminalEvent(event.StepType, event.Status) {
+					terminated = true
+				}
+			}
+			if terminated {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events, nil
+}
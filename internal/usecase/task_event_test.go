@@ -0,0 +1,122 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/model"
+)
+
+func TestWatchTaskEvents(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	if err := c.CreateKubernetesTaskRepo.Create(&model.CreateKubernetesTask{EnterpriseID: "eid", TaskID: "task"}); err != nil {
+		t.Fatal(err)
+	}
+	send := func(step, status string) {
+		if _, err := c.CreateTaskEvent(&v1.EventMessage{EnterpriseID: "eid", TaskID: "task",
+			Message: &v1.Message{StepType: step, Status: status}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(v1.StepCreateCluster, "start")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	events, err := c.WatchTaskEvents(ctx, "eid", "task", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := func() *model.TaskEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-ctx.Done():
+			t.Fatal("receive event timeout")
+		}
+		return nil
+	}
+	if event := receive(); event.Status != "start" || event.Seq != 1 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	send(v1.StepCreateCluster, "success")
+	if event := receive(); event.Status != "success" || event.Seq != 2 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event := receive(); event != nil {
+		t.Fatalf("expected the channel closed after the terminal event, got %+v", event)
+	}
+
+	// resume after the terminal event
+	events, err = c.WatchTaskEvents(ctx, "eid", "task", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := receive(); event != nil {
+		t.Fatalf("expected no event, got %+v", event)
+	}
+}
+
+func TestCreateTaskEventSeq(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	// the event saved before seq was added
+	if err := c.DB.Exec("insert into adaptor_task_events (task_id, eid, step_type, status) values (?, ?, ?, ?)",
+		"task", "eid", v1.StepInit, "success").Error; err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.TaskEventRepo.Create(&model.TaskEvent{EnterpriseID: "eid", TaskID: "task",
+				StepType: fmt.Sprintf("step-%d", i), Status: "start"}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	events, err := c.TaskEventRepo.ListEventAfter("eid", "task", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 10 {
+		t.Fatalf("expected 10 events with seq, got %d", len(events))
+	}
+	for i, event := range events {
+		if event.Seq != int64(i+1) {
+			t.Fatalf("expected the seq of the events from 1 to 10, got %d at %d", event.Seq, i)
+		}
+	}
+
+	// the events without step type are not merged
+	for _, eid := range []string{"eid", "eid", "other"} {
+		if err := c.TaskEventRepo.CreateEvent(&model.TaskEvent{EnterpriseID: eid, TaskID: "helm_install_region"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events, err := c.TaskEventRepo.ListEvent("eid", "helm_install_region"); err != nil || len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d %v", len(events), err)
+	}
+}
@@ -0,0 +1,99 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/internal/domain"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+func (c *ClusterUsecase) getKubernetesUpgrader(eid, providerName string) (adaptor.KubernetesUpgrader, error) {
+	if err := checkProviderCapability(providerName, adaptor.CapabilityUpgradeKubernetes); err != nil {
+		return nil, err
+	}
+	ad, err := c.getRainbondClusterAdaptor(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	upgrader, ok := ad.(adaptor.KubernetesUpgrader)
+	if !ok {
+		return nil, errors.WithStack(bcode.ErrProviderNotSupportAction)
+	}
+	return upgrader, nil
+}
+
+// ListKubernetesVersions returns the kubernetes versions which the cluster can be upgraded to.
+func (c *ClusterUsecase) ListKubernetesVersions(eid, clusterID, providerName string) ([]*v1alpha1.KubernetesVersion, error) {
+	upgrader, err := c.getKubernetesUpgrader(eid, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return upgrader.ListKubernetesVersions(eid, clusterID)
+}
+
+// UpgradeKubernetes upgrades the kubernetes version of the cluster in background.
+func (c *ClusterUsecase) UpgradeKubernetes(eid, clusterID string, req v1.UpgradeKubernetesReq) (*model.OperationTask, error) {
+	upgrader, err := c.getKubernetesUpgrader(eid, req.ProviderName)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := upgrader.ListKubernetesVersions(eid, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	var target *v1alpha1.KubernetesVersion
+	for _, version := range versions {
+		if version.Version == req.Version {
+			target = version
+			break
+		}
+	}
+	if target == nil {
+		return nil, errors.Wrapf(bcode.ErrKubernetesVersionNotUpgradable, "version %s not found", req.Version)
+	}
+	if !target.Upgradable {
+		return nil, errors.Wrap(bcode.ErrKubernetesVersionNotUpgradable, target.Reason)
+	}
+	return c.runClusterStateTask(eid, clusterID, req.ProviderName, domain.ClusterTaskTypeUpgradeKubernetes, upgradeKubernetesParams{Version: req.Version})
+}
+
+// upgradeKubernetesParams the params of the task upgrading the kubernetes
+type upgradeKubernetesParams struct {
+	Version string `json:"version"`
+}
+
+func (c *ClusterUsecase) runUpgradeKubernetes(ctx context.Context, task *model.OperationTask, params json.RawMessage, rollback func(step, message, status string)) error {
+	var p upgradeKubernetesParams
+	if err := decodeOperationParams(params, &p); err != nil {
+		return err
+	}
+	upgrader, err := c.getKubernetesUpgrader(task.EnterpriseID, task.Provider)
+	if err != nil {
+		return err
+	}
+	return upgrader.UpgradeKubernetes(ctx, task.EnterpriseID, task.ClusterID, p.Version, rollback)
+}
@@ -0,0 +1,121 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/adaptor"
+	"goodrain.com/cloud-adaptor/internal/adaptor/v1alpha1"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+)
+
+// upgraderAdaptor upgrades the nodes, the node failed is not upgraded.
+type upgraderAdaptor struct {
+	adaptor.RainbondClusterAdaptor
+	nodes  []string
+	failed string
+}
+
+func (u *upgraderAdaptor) ListKubernetesVersions(eid, clusterID string) ([]*v1alpha1.KubernetesVersion, error) {
+	return []*v1alpha1.KubernetesVersion{
+		{Version: "v1.23.10-rancher1-1", Current: true, Reason: "the cluster is running version v1.23.10-rancher1-1"},
+		{Version: "v1.24.4-rancher1-1", Upgradable: true},
+		{Version: "v1.25.1-rancher1-1", Reason: "only one minor version can be upgraded at a time"},
+	}, nil
+}
+
+func (u *upgraderAdaptor) UpgradeKubernetes(ctx context.Context, eid, clusterID, version string, rollback func(step, message, status string)) error {
+	for _, node := range u.nodes {
+		rollback(v1alpha1.NodeStepType(v1.StepUpgradeNode, node), "", "start")
+	}
+	var failed []string
+	for _, node := range u.nodes {
+		if node == u.failed {
+			rollback(v1alpha1.NodeStepType(v1.StepUpgradeNode, node), "the node is not upgraded", "failure")
+			failed = append(failed, node)
+			continue
+		}
+		rollback(v1alpha1.NodeStepType(v1.StepUpgradeNode, node), version, "success")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("nodes %v are not upgraded", failed)
+	}
+	return nil
+}
+
+func TestUpgradeKubernetes(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	upgrader := &upgraderAdaptor{nodes: []string{"node1", "node2"}}
+	registerTestProvider(t, "test-upgrader", adaptor.InterfaceKubernetesUpgrader, adaptor.CapabilityUpgradeKubernetes, upgrader)
+
+	for _, version := range []string{"v1.23.10-rancher1-1", "v1.25.1-rancher1-1", "v1.99.0"} {
+		_, err := c.UpgradeKubernetes("eid", "cluster", v1.UpgradeKubernetesReq{ProviderName: "test-upgrader", Version: version})
+		if errors.Cause(err) != bcode.ErrKubernetesVersionNotUpgradable {
+			t.Errorf("version %s: expected ErrKubernetesVersionNotUpgradable, got %v", version, err)
+		}
+	}
+
+	task, err := c.UpgradeKubernetes("eid", "cluster", v1.UpgradeKubernetesReq{ProviderName: "test-upgrader", Version: "v1.24.4-rancher1-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := waitOperationTask(t, c, task)
+	success := make(map[string]bool)
+	for _, event := range events {
+		success[event.StepType] = event.Status == "success"
+	}
+	for _, step := range []string{"UpgradeNode:node1", "UpgradeNode:node2", v1.StepUpgradeKubernetes} {
+		if !success[step] {
+			t.Errorf("expect the event of %s is success", step)
+		}
+	}
+
+	// the failure of a node fails the task after all nodes are reported
+	upgrader.failed = "node1"
+	task, err = c.UpgradeKubernetes("eid", "cluster", v1.UpgradeKubernetesReq{ProviderName: "test-upgrader", Version: "v1.24.4-rancher1-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = waitOperationTask(t, c, task)
+	last := events[len(events)-1]
+	if last.StepType != v1.StepUpgradeKubernetes || last.Status != "failure" {
+		t.Errorf("expect the task is failure, the last event is %s %s", last.StepType, last.Status)
+	}
+	success = make(map[string]bool)
+	for _, event := range events {
+		success[event.StepType] = event.Status == "success"
+	}
+	if success["UpgradeNode:node1"] || !success["UpgradeNode:node2"] {
+		t.Errorf("unexpected node events %v", success)
+	}
+}
+
+func TestIsTerminalEvent(t *testing.T) {
+	if IsTerminalEvent(v1alpha1.NodeStepType(v1.StepUpgradeNode, "node1"), "failure") {
+		t.Errorf("the failure of a node should not be terminal")
+	}
+	if !IsTerminalEvent(v1.StepUpgradeKubernetes, "success") || !IsTerminalEvent(v1.StepUpgradeKubernetes, "failure") {
+		t.Errorf("the result of the upgrade should be terminal")
+	}
+}
@@ -23,6 +23,8 @@ import "github.com/google/wire"
 // ProviderSet is biz providers.
 var ProviderSet = wire.NewSet(
 	NewClusterUsecase,
+	wire.Struct(new(ClusterUsecaseDeps), "*"),
+	NewWebhookUsecase,
 	NewAppStoreUsecase,
 	NewAppTemplate,
 )
@@ -0,0 +1,263 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+	"goodrain.com/cloud-adaptor/pkg/bcode"
+	"goodrain.com/cloud-adaptor/pkg/util/uuidutil"
+)
+
+var (
+	webhookPollInterval    = time.Second * 2
+	webhookTimeout         = time.Second * 10
+	webhookRetryInterval   = time.Second * 10
+	webhookMaxRetryBackoff = time.Hour
+	webhookMaxAttempts     = 8
+	webhookDeliverLimit    = 20
+)
+
+// WebhookSignatureHeader the header of the payload signature
+const WebhookSignatureHeader = "X-Cloud-Adaptor-Signature"
+
+// WebhookUsecase manages the webhooks and delivers the cluster lifecycle events.
+type WebhookUsecase struct {
+	WebhookRepo  repo.WebhookRepository
+	DeliveryRepo repo.WebhookDeliveryRepository
+	client       *http.Client
+}
+
+// NewWebhookUsecase new webhook usecase
+func NewWebhookUsecase(webhookRepo repo.WebhookRepository, deliveryRepo repo.WebhookDeliveryRepository) *WebhookUsecase {
+	return &WebhookUsecase{
+		WebhookRepo:  webhookRepo,
+		DeliveryRepo: deliveryRepo,
+		client:       &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// CreateWebhook registers a webhook, the secret is generated if it is not set.
+func (w *WebhookUsecase) CreateWebhook(eid string, req *v1.CreateWebhookReq) (*v1.WebhookRes, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.WithStack(bcode.ErrWebhookURLInvalid)
+	}
+	secret := req.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		secret = hex.EncodeToString(b)
+	}
+	webhook := &model.Webhook{
+		WebhookID:    uuidutil.NewUUID(),
+		EnterpriseID: eid,
+		URL:          req.URL,
+		Secret:       secret,
+	}
+	if err := w.WebhookRepo.Create(webhook); err != nil {
+		return nil, err
+	}
+	return &v1.WebhookRes{Webhook: *webhook, Secret: secret}, nil
+}
+
+// ListWebhooks lists the webhooks of the enterprise.
+func (w *WebhookUsecase) ListWebhooks(eid string) ([]*model.Webhook, error) {
+	return w.WebhookRepo.ListByEnterprise(eid)
+}
+
+// DeleteWebhook deletes the webhook, the pending deliveries fail.
+func (w *WebhookUsecase) DeleteWebhook(eid, webhookID string) error {
+	if _, err := w.WebhookRepo.Get(eid, webhookID); err != nil {
+		return err
+	}
+	return w.WebhookRepo.Delete(eid, webhookID)
+}
+
+// ListDeliveries lists the delivery history of the webhook.
+func (w *WebhookUsecase) ListDeliveries(eid, webhookID string, page, pageSize int) (*v1.WebhookDeliveryListRes, error) {
+	if _, err := w.WebhookRepo.Get(eid, webhookID); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	deliveries, total, err := w.DeliveryRepo.ListByWebhook(eid, webhookID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.WebhookDeliveryListRes{Deliveries: deliveries, Total: total}, nil
+}
+
+// Notify queues a delivery of the payload for each webhook of the enterprise.
+func (w *WebhookUsecase) Notify(payload *v1.WebhookPayload) {
+	if w == nil {
+		return
+	}
+	webhooks, err := w.WebhookRepo.ListByEnterprise(payload.EnterpriseID)
+	if err != nil {
+		logrus.Errorf("list webhooks of enterprise %s failure %s", payload.EnterpriseID, err.Error())
+		return
+	}
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now()
+	}
+	var deliveries []*model.WebhookDelivery
+	for _, webhook := range webhooks {
+		p := *payload
+		p.DeliveryID = uuidutil.NewUUID()
+		body, _ := json.Marshal(p)
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			DeliveryID:   p.DeliveryID,
+			WebhookID:    webhook.WebhookID,
+			EnterpriseID: webhook.EnterpriseID,
+			Event:        p.Event,
+			Payload:      string(body),
+			Status:       model.WebhookDeliveryStatusPending,
+			NextAttempt:  p.Timestamp,
+		})
+	}
+	if err := w.DeliveryRepo.Create(deliveries); err != nil {
+		logrus.Errorf("create webhook deliveries of event %s failure %s", payload.Event, err.Error())
+	}
+}
+
+// Start delivers the due deliveries until the ctx is done.
+func (w *WebhookUsecase) Start(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.deliverDue(ctx)
+	}
+}
+
+func (w *WebhookUsecase) deliverDue(ctx context.Context) {
+	deliveries, err := w.DeliveryRepo.ListDue(time.Now(), webhookDeliverLimit)
+	if err != nil {
+		logrus.Errorf("list due webhook deliveries failure %s", err.Error())
+		return
+	}
+	for _, delivery := range deliveries {
+		// other instances skip the delivery until the attempt times out
+		claimed, err := w.DeliveryRepo.Claim(delivery, time.Now().Add(webhookTimeout*2))
+		if err != nil {
+			logrus.Errorf("claim webhook delivery %s failure %s", delivery.DeliveryID, err.Error())
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		w.deliver(ctx, delivery)
+		if err := w.DeliveryRepo.Update(delivery); err != nil {
+			logrus.Errorf("update webhook delivery %s failure %s", delivery.DeliveryID, err.Error())
+		}
+	}
+}
+
+// deliver posts the payload and sets the result of the attempt.
+func (w *WebhookUsecase) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+	webhook, err := w.WebhookRepo.Get(delivery.EnterpriseID, delivery.WebhookID)
+	if err != nil {
+		delivery.Status = model.WebhookDeliveryStatusFailure
+		delivery.Error = err.Error()
+		return
+	}
+	code, err := w.post(ctx, webhook, delivery)
+	delivery.ResponseCode = code
+	if err == nil {
+		delivery.Status = model.WebhookDeliveryStatusSuccess
+		delivery.Error = ""
+		return
+	}
+	delivery.Error = err.Error()
+	if len(delivery.Error) > 512 {
+		delivery.Error = delivery.Error[:512]
+	}
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = model.WebhookDeliveryStatusFailure
+		logrus.Warningf("webhook delivery %s failure after %d attempts: %s", delivery.DeliveryID, delivery.Attempts, delivery.Error)
+		return
+	}
+	delivery.NextAttempt = time.Now().Add(webhookBackoff(delivery.Attempts))
+}
+
+func (w *WebhookUsecase) post(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Cloud-Adaptor-Event", delivery.Event)
+	req.Header.Set("X-Cloud-Adaptor-Delivery", delivery.DeliveryID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, []byte(delivery.Payload)))
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// webhookBackoff returns the interval before the next attempt, it doubles after every attempt.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookRetryInterval
+	for i := 1; i < attempts && backoff < webhookMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxRetryBackoff {
+		backoff = webhookMaxRetryBackoff
+	}
+	return backoff
+}
+
+// SignWebhookPayload returns the signature of the payload: sha256=hex(HMAC-SHA256(secret, body)).
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,115 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2020 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package usecase
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "goodrain.com/cloud-adaptor/api/cloud-adaptor/v1"
+	"goodrain.com/cloud-adaptor/internal/model"
+	"goodrain.com/cloud-adaptor/internal/repo"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	db := newTestDB(t)
+	w := NewWebhookUsecase(repo.NewWebhookRepo(db), repo.NewWebhookDeliveryRepo(db))
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload("secret", body) {
+			t.Errorf("unexpected signature %s", r.Header.Get(WebhookSignatureHeader))
+		}
+		// the first attempt fails
+		if requests == 1 {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	webhook, err := w.CreateWebhook("eid", &v1.CreateWebhookReq{URL: server.URL, Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Notify(&v1.WebhookPayload{Event: v1.WebhookEventClusterDeleted, EnterpriseID: "eid", ClusterID: "c1"})
+
+	w.deliverDue(context.Background())
+	res, err := w.ListDeliveries("eid", webhook.WebhookID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery := res.Deliveries[0]
+	if res.Total != 1 || delivery.Status != model.WebhookDeliveryStatusPending || delivery.ResponseCode != 500 || delivery.Attempts != 1 {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+	if delivery.NextAttempt.Before(time.Now().Add(webhookRetryInterval / 2)) {
+		t.Fatalf("expected the delivery is retried after backoff, next attempt %s", delivery.NextAttempt)
+	}
+
+	// retry now
+	delivery.NextAttempt = time.Now()
+	if err := w.DeliveryRepo.Update(delivery); err != nil {
+		t.Fatal(err)
+	}
+	w.deliverDue(context.Background())
+	res, _ = w.ListDeliveries("eid", webhook.WebhookID, 1, 10)
+	if delivery = res.Deliveries[0]; delivery.Status != model.WebhookDeliveryStatusSuccess || delivery.Attempts != 2 {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: webhookRetryInterval, 3: webhookRetryInterval * 4, 20: webhookMaxRetryBackoff} {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("attempts %d: expected %s, got %s", attempts, want, got)
+		}
+	}
+}
+
+func TestTaskWebhookOnce(t *testing.T) {
+	c := newTestClusterUsecase(t)
+	c.webhook = NewWebhookUsecase(repo.NewWebhookRepo(c.DB), repo.NewWebhookDeliveryRepo(c.DB))
+	webhook, err := c.webhook.CreateWebhook("eid", &v1.CreateWebhookReq{URL: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the adaptor and the workflow both report the failure, and the task is cancelled later
+	for _, msg := range []*v1.Message{
+		{StepType: v1.StepCreateVPC, Status: "failure"},
+		{StepType: v1.StepCreateCluster, Status: "failure"},
+		{StepType: v1.StepCancelled, Status: "cancelled"},
+	} {
+		if _, err := c.CreateTaskEvent(&v1.EventMessage{EnterpriseID: "eid", TaskID: "task", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := c.webhook.ListDeliveries("eid", webhook.WebhookID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Deliveries[0].Event != v1.WebhookEventTaskFailure {
+		t.Fatalf("expected one failure delivery, got %+v", res.Deliveries)
+	}
+}
@@ -73,6 +73,31 @@ var (
 
 	ErrRainbondClusterInstalled = newByMessage(409, 7028, "rainbond cluster is already installed")
 	ErrClusterTaskNotFound      = newByMessage(404, 7029, "cluster task not found")
+	ErrTaskNotRunning           = newByMessage(409, 7030, "the task is not running")
+	ErrClusterLogNotFound       = newByMessage(404, 7031, "cluster log not found")
+	ErrWebhookNotFound          = newByMessage(404, 7032, "webhook not found")
+	ErrWebhookURLInvalid        = newByMessage(400, 7033, "webhook url must be http or https")
+	ErrProviderNotSupportAction = newByMessage(400, 7034, "the action is not supported by the provider")
+	ErrInvalidWorkerNodeNum     = newByMessage(400, 7035, "the number of worker nodes must be greater than 0")
+
+	ErrKubernetesVersionNotUpgradable = newByMessage(400, 7036, "the cluster can not be upgraded to the kubernetes version")
+	ErrSecretsEncryptionNotEnabled    = newByMessage(400, 7037, "the secrets encryption is not enabled")
+	ErrSecretsEncryptionEnabled       = newByMessage(409, 7038, "the secrets encryption is already enabled")
+	ErrSecretsEncryptionCustomConfig  = newByMessage(400, 7039, "the key of the custom encryption config can not be rotated")
+
+	ErrEtcdSnapshotNotFound    = newByMessage(404, 7040, "the etcd snapshot not found")
+	ErrEtcdSnapshotNameInvalid = newByMessage(400, 7041, "the name of the etcd snapshot is invalid")
+
+	ErrClusterNodeNotFound     = newByMessage(404, 7042, "the node not found in the cluster")
+	ErrPreflightCheckFailed    = newByMessage(400, 7043, "the preflight checks of the nodes failed")
+	ErrBastionUnreachable      = newByMessage(400, 7044, "the bastion host is unreachable")
+	ErrBastionNotFound         = newByMessage(404, 7045, "the cluster has no bastion host")
+	ErrInitNodeTokenInvalid    = newByMessage(403, 7046, "the token of the init node script is invalid or expired")
+	ErrRemoveNodesEmpty        = newByMessage(400, 7047, "the nodes to remove can not be empty")
+	ErrAdvertiseURLInvalid     = newByMessage(400, 7048, "the advertise url of cloud adaptor is not configured or invalid")
+	ErrPreflightReportNotFound = newByMessage(404, 7049, "the preflight report is not found")
+	ErrSSHKeySecretNotSet      = newByMessage(500, 7050, "the env SSH_KEY_SECRET encrypting the ssh keys is not set")
+	ErrClusterOperationRunning = newByMessage(409, 7051, "another operation of the cluster is running")
 
 	//check ssh error
 	ErrSSHFileNotFond = newByMessage(200, 9000, "file /root/.ssh/id_rsa not found")
@@ -0,0 +1,50 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package blobstore
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound the blob of the key does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// Blob the metadata of a blob
+type Blob struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store saves the blobs by the keys, the keys are slash-separated paths such as enterprise/<eid>/rke/<name>/cluster.yml.
+type Store interface {
+	// Get returns ErrNotFound if the blob does not exist.
+	Get(key string) ([]byte, error)
+	// Stat returns the metadata of the blob without the content, ErrNotFound if the blob does not exist.
+	Stat(key string) (*Blob, error)
+	// Put creates or overwrites the blob.
+	Put(key string, data []byte) error
+	// List lists the blobs whose keys begin with the prefix.
+	List(prefix string) ([]*Blob, error)
+	// Delete deletes the blob, it is not an error if the blob does not exist.
+	Delete(key string) error
+	// DeleteAll deletes the blobs whose keys begin with the prefix.
+	DeleteAll(prefix string) error
+}
@@ -0,0 +1,128 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package blobstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// localStore saves the blobs as the files under the dir
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore creates the store saving the blobs as the files under the dir, the key is the relative path of the file.
+func NewLocalStore(dir string) Store {
+	return &localStore{dir: dir}
+}
+
+func (l *localStore) filePath(key string) string {
+	// the cleaned key can not point to a file outside the dir
+	return filepath.Join(l.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (l *localStore) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(l.filePath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (l *localStore) Stat(key string) (*Blob, error) {
+	info, err := os.Stat(l.filePath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &Blob{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *localStore) Put(key string, data []byte) error {
+	filePath := l.filePath(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	// write to a temp file and rename it, the readers never see a partial file
+	tmp := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, filePath))
+}
+
+func (l *localStore) List(prefix string) ([]*Blob, error) {
+	dir := filepath.Dir(l.filePath(prefix + "_"))
+	var blobs []*Blob
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(filePath, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, strings.TrimPrefix(prefix, "/")) {
+			blobs = append(blobs, &Blob{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return blobs, nil
+}
+
+func (l *localStore) Delete(key string) error {
+	if err := os.Remove(l.filePath(key)); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (l *localStore) DeleteAll(prefix string) error {
+	blobs, err := l.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if err := l.Delete(blob.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
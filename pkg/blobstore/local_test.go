@@ -0,0 +1,73 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package blobstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(filepath.Join(dir, "store"))
+	for _, key := range []string{"a/b/cluster.yml", "a/b/create.log", "a/c/cluster.yml"} {
+		if err := store.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := store.Get("a/b/cluster.yml")
+	if err != nil || string(data) != "a/b/cluster.yml" {
+		t.Fatalf("unexpected blob %s %v", data, err)
+	}
+	if _, err := store.Get("a/b/none"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if blob, err := store.Stat("a/b/create.log"); err != nil || blob.Size != int64(len("a/b/create.log")) {
+		t.Fatalf("unexpected blob %+v %v", blob, err)
+	}
+	if _, err := store.Stat("a/b/none"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	// the key can not escape the dir
+	if err := store.Put("../../outside", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); !os.IsNotExist(err) {
+		t.Fatal("expected the blob is saved under the dir")
+	}
+
+	blobs, err := store.List("a/b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 {
+		t.Fatalf("unexpected blobs %+v", blobs)
+	}
+	if err := store.DeleteAll("a/b/"); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err = store.List("a/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].Key != "a/c/cluster.yml" {
+		t.Fatalf("unexpected blobs %+v", blobs)
+	}
+}
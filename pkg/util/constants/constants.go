@@ -27,6 +27,8 @@ const (
 	CloudCreate = "cloud-create"
 	// CloudUpdate -
 	CloudUpdate = "cloud-update"
+	// CloudOperation the operation tasks of the clusters, such as upgrading kubernetes
+	CloudOperation = "cloud-operation"
 	// Namespace is the namespace for rainbond-operator and rainbond components
 	Namespace = "rbd-system"
 )
@@ -0,0 +1,57 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cryptoutil
+
+import (
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	ciphertext, err := Encrypt("secret", []byte("private key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := Decrypt("secret", ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "private key" {
+		t.Fatalf("want private key, got %s", plaintext)
+	}
+	if again, _ := Encrypt("secret", []byte("private key")); again == ciphertext {
+		t.Fatal("the nonce should be random")
+	}
+	if _, err := Decrypt("other", ciphertext); err == nil {
+		t.Fatal("decrypt with the other secret should fail")
+	}
+	if _, err := Encrypt("", []byte("private key")); err == nil {
+		t.Fatal("encrypt with the empty secret should fail")
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	t.Setenv("TEST_SECRET", "")
+	if _, err := LoadSecret("TEST_SECRET"); err == nil {
+		t.Fatal("want the error if the env is not set")
+	}
+	t.Setenv("TEST_SECRET", "env")
+	if secret, _ := LoadSecret("TEST_SECRET"); secret != "env" {
+		t.Fatalf("want the secret in the env, got %s", secret)
+	}
+}
@@ -0,0 +1,130 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// Bastion the jump host which the hosts in the private network are dialed through
+type Bastion struct {
+	Host       string
+	Port       uint
+	User       string
+	PrivateKey []byte
+}
+
+// bastionConn the connection forwarded by the bastion, closing it closes the connection to the bastion.
+type bastionConn struct {
+	net.Conn
+	raw    net.Conn
+	client *ssh.Client
+}
+
+func (b *bastionConn) Close() error {
+	b.Conn.Close()
+	return b.client.Close()
+}
+
+// SetDeadline sets the deadline of the connection to the bastion, the forwarded connection does not support deadlines.
+func (b *bastionConn) SetDeadline(t time.Time) error {
+	return b.raw.SetDeadline(t)
+}
+
+// dialTCP connects to the address directly, or through the bastion if it is not nil.
+// The handshake with the bastion must complete within the timeout.
+func dialTCP(bastion *Bastion, address string, timeout time.Duration) (net.Conn, error) {
+	if bastion == nil {
+		return net.DialTimeout("tcp", address, timeout)
+	}
+	signer, err := ssh.ParsePrivateKey(bastion.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key of the bastion")
+	}
+	bastionAddress := net.JoinHostPort(bastion.Host, fmt.Sprintf("%d", bastion.Port))
+	raw, err := net.DialTimeout("tcp", bastionAddress, timeout)
+	if err != nil {
+		return nil, &BastionError{Address: bastionAddress, Err: err}
+	}
+	raw.SetDeadline(time.Now().Add(timeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(raw, bastionAddress, &ssh.ClientConfig{
+		User:            bastion.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		raw.Close()
+		return nil, &BastionError{Address: bastionAddress, Err: err}
+	}
+	raw.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+	conn, err := client.Dial("tcp", address)
+	if err != nil {
+		client.Close()
+		return nil, errors.Wrapf(err, "connect to %s through the bastion %s", address, bastionAddress)
+	}
+	return &bastionConn{Conn: conn, raw: raw, client: client}, nil
+}
+
+// BastionError the bastion can not be connected or logged in
+type BastionError struct {
+	Address string
+	Err     error
+}
+
+func (b *BastionError) Error() string {
+	return fmt.Sprintf("connect to the bastion %s: %s", b.Address, b.Err.Error())
+}
+
+// CheckBastion logs in to the bastion and checks it allows the tcp forwarding.
+func CheckBastion(bastion *Bastion, timeout time.Duration) error {
+	signer, err := ssh.ParsePrivateKey(bastion.PrivateKey)
+	if err != nil {
+		return errors.Wrap(err, "parse private key of the bastion")
+	}
+	address := net.JoinHostPort(bastion.Host, fmt.Sprintf("%d", bastion.Port))
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            bastion.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		Timeout:         timeout,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return &BastionError{Address: address, Err: err}
+	}
+	defer client.Close()
+	// the sshd of the bastion itself may not listen on the port, the forwarding is allowed if the bastion fails to connect it.
+	conn, err := client.Dial("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprintf("%d", bastion.Port)))
+	if err != nil {
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) && openErr.Reason == ssh.ConnectionFailed {
+			return nil
+		}
+		if errors.As(err, &openErr) && openErr.Reason == ssh.Prohibited {
+			return &BastionError{Address: address, Err: errors.Wrap(err, "tcp forwarding is not allowed")}
+		}
+		return &BastionError{Address: address, Err: errors.Wrap(err, "check tcp forwarding")}
+	}
+	return conn.Close()
+}
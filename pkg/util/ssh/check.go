@@ -0,0 +1,208 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// The categories of the failed checks.
+const (
+	CheckErrUnreachable    = "unreachable"
+	CheckErrAuthFailed     = "authFailed"
+	CheckErrNoDockerGroup  = "noDockerGroup"
+	CheckErrHostKeyChanged = "hostKeyChanged"
+	CheckErrProbeFailed    = "probeFailed"
+	CheckErrBastionFailed  = "bastionFailed"
+)
+
+// checkProbeCommand proves the user can run docker without changing anything
+const checkProbeCommand = "docker ps -q > /dev/null"
+
+// CheckTarget the host to check
+type CheckTarget struct {
+	Host string
+	Port uint
+	User string
+}
+
+// CheckResult the result of the host, the category is empty if the check passes.
+type CheckResult struct {
+	Host     string `json:"host"`
+	Port     uint   `json:"port"`
+	User     string `json:"user"`
+	OK       bool   `json:"ok"`
+	Category string `json:"category,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Checker checks the hosts concurrently. The host key seen at the first time is trusted and saved
+// in the known hosts file, the check fails if the host presents another key later.
+type Checker struct {
+	PrivateKey     []byte
+	KnownHostsFile string
+	// Bastion the hosts are dialed through the bastion if it is not nil
+	Bastion *Bastion
+	// Parallelism the max number of the hosts checked at the same time
+	Parallelism int
+	// Timeout the max time of checking a host, including connecting, handshake and the probe command
+	Timeout time.Duration
+
+	lock sync.Mutex
+}
+
+// CheckHosts checks the hosts and returns the results in the same order.
+func (c *Checker) CheckHosts(targets []CheckTarget) ([]*CheckResult, error) {
+	signer, err := ssh.ParsePrivateKey(c.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+	if err := os.MkdirAll(path.Dir(c.KnownHostsFile), 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file, err := os.OpenFile(c.KnownHostsFile, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file.Close()
+	knownHosts, err := knownhosts.New(c.KnownHostsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read known hosts")
+	}
+
+	parallelism := c.Parallelism
+	if parallelism <= 0 {
+		parallelism = 10
+	}
+	results := make([]*CheckResult, len(targets))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = c.checkHost(targets[i], signer, knownHosts)
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (c *Checker) checkHost(target CheckTarget, signer ssh.Signer, knownHosts ssh.HostKeyCallback) *CheckResult {
+	result := &CheckResult{Host: target.Host, Port: target.Port, User: target.User}
+	fail := func(category, message string) *CheckResult {
+		result.Category = category
+		result.Message = message
+		return result
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	address := net.JoinHostPort(target.Host, fmt.Sprintf("%d", target.Port))
+	conn, err := dialTCP(c.Bastion, address, timeout)
+	if err != nil {
+		var bastionErr *BastionError
+		if errors.As(err, &bastionErr) {
+			return fail(CheckErrBastionFailed, err.Error())
+		}
+		return fail(CheckErrUnreachable, err.Error())
+	}
+	defer conn.Close()
+	// the deadline covers the handshake and the probe command
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var hostKeyChanged bool
+	config := &ssh.ClientConfig{
+		User: target.User,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := knownHosts(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+				// the host is unknown, trust its key at the first time
+				return c.addKnownHost(hostname, key)
+			}
+			if errors.As(err, &keyErr) {
+				hostKeyChanged = true
+			}
+			return err
+		},
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		switch {
+		case hostKeyChanged:
+			return fail(CheckErrHostKeyChanged, "the host key does not match the one in "+c.KnownHostsFile)
+		case strings.Contains(err.Error(), "unable to authenticate"):
+			return fail(CheckErrAuthFailed, err.Error())
+		default:
+			return fail(CheckErrUnreachable, err.Error())
+		}
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fail(CheckErrProbeFailed, err.Error())
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if err := session.Run(checkProbeCommand); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		if strings.Contains(message, "permission denied") {
+			return fail(CheckErrNoDockerGroup, fmt.Sprintf("the user %s can not access the docker daemon: %s", target.User, message))
+		}
+		return fail(CheckErrProbeFailed, message)
+	}
+	result.OK = true
+	return result
+}
+
+func (c *Checker) addKnownHost(hostname string, key ssh.PublicKey) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	file, err := os.OpenFile(c.KnownHostsFile, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return errors.WithStack(err)
+}
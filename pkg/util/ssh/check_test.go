@@ -0,0 +1,278 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ssh
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	signer, err := ssh.NewSignerFromKey(mustGenerateKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// startTestServer starts the ssh server accepting the authorized key, the user nodocker can not run docker.
+func startTestServer(t *testing.T, authorized ssh.PublicKey) (string, uint) {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.AddHostKey(newTestSigner(t))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(conn, config)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, uint(p)
+}
+
+func serveTestConn(conn net.Conn, config *ssh.ServerConfig) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go forwardTestChannel(sshConn, newChannel)
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				var status uint32
+				if sshConn.User() == "nodocker" {
+					channel.Stderr().Write([]byte("Got permission denied while trying to connect to the Docker daemon socket"))
+					status = 1
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				channel.Close()
+			}
+		}()
+	}
+}
+
+// forwardTestChannel forwards the connection as a bastion, the user noforward is not allowed to forward.
+func forwardTestChannel(sshConn *ssh.ServerConn, newChannel ssh.NewChannel) {
+	if sshConn.User() == "noforward" {
+		newChannel.Reject(ssh.Prohibited, "administratively prohibited")
+		return
+	}
+	if sshConn.User() == "shortage" {
+		newChannel.Reject(ssh.ResourceShortage, "resource shortage")
+		return
+	}
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(channel, conn)
+		channel.Close()
+	}()
+	io.Copy(conn, channel)
+	conn.Close()
+}
+
+func TestCheckHosts(t *testing.T) {
+	key := mustGenerateKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port := startTestServer(t, signer.PublicKey())
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := uint(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	checker := &Checker{
+		PrivateKey:     EncodePrivateKey(key),
+		KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts"),
+		Parallelism:    2,
+		Timeout:        5 * time.Second,
+	}
+	results, err := checker.CheckHosts([]CheckTarget{
+		{Host: host, Port: port, User: "docker"},
+		{Host: host, Port: port, User: "nodocker"},
+		{Host: host, Port: closedPort, User: "docker"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].OK {
+		t.Errorf("expected the check passes, got %+v", results[0])
+	}
+	if results[1].Category != CheckErrNoDockerGroup {
+		t.Errorf("expected %s, got %+v", CheckErrNoDockerGroup, results[1])
+	}
+	if results[2].Category != CheckErrUnreachable {
+		t.Errorf("expected %s, got %+v", CheckErrUnreachable, results[2])
+	}
+
+	checker.PrivateKey = EncodePrivateKey(mustGenerateKey(t))
+	if results, _ = checker.CheckHosts([]CheckTarget{{Host: host, Port: port, User: "docker"}}); results[0].Category != CheckErrAuthFailed {
+		t.Errorf("expected %s, got %+v", CheckErrAuthFailed, results[0])
+	}
+
+	// another key of the host is saved
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, strconv.Itoa(int(port))))}, newTestSigner(t).PublicKey())
+	if err := os.WriteFile(checker.KnownHostsFile, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	checker.PrivateKey = EncodePrivateKey(key)
+	if results, _ = checker.CheckHosts([]CheckTarget{{Host: host, Port: port, User: "docker"}}); results[0].Category != CheckErrHostKeyChanged {
+		t.Errorf("expected %s, got %+v", CheckErrHostKeyChanged, results[0])
+	}
+}
+
+func mustGenerateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestBastion(t *testing.T) {
+	key := mustGenerateKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastionKey := mustGenerateKey(t)
+	bastionSigner, err := ssh.NewSignerFromKey(bastionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port := startTestServer(t, signer.PublicKey())
+	bastionHost, bastionPort := startTestServer(t, bastionSigner.PublicKey())
+	bastion := &Bastion{Host: bastionHost, Port: bastionPort, User: "jump", PrivateKey: EncodePrivateKey(bastionKey)}
+
+	if err := CheckBastion(bastion, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunCommand(bastion, host, port, "docker", EncodePrivateKey(key), "docker ps"); err != nil {
+		t.Fatal(err)
+	}
+	checker := &Checker{
+		PrivateKey:     EncodePrivateKey(key),
+		KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts"),
+		Bastion:        bastion,
+		Timeout:        5 * time.Second,
+	}
+	if results, _ := checker.CheckHosts([]CheckTarget{{Host: host, Port: port, User: "docker"}}); !results[0].OK {
+		t.Errorf("expected the check through the bastion passes, got %+v", results[0])
+	}
+
+	noForward := &Bastion{Host: bastionHost, Port: bastionPort, User: "noforward", PrivateKey: EncodePrivateKey(bastionKey)}
+	if err := CheckBastion(noForward, 5*time.Second); err == nil {
+		t.Error("expected the bastion not allowing forwarding fails")
+	}
+	shortage := &Bastion{Host: bastionHost, Port: bastionPort, User: "shortage", PrivateKey: EncodePrivateKey(bastionKey)}
+	if err := CheckBastion(shortage, 5*time.Second); err == nil {
+		t.Error("expected the bastion failing to forward fails")
+	}
+
+	// the target not speaking ssh does not hang the dial through the bastion
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	old := dialTimeout
+	dialTimeout = 500 * time.Millisecond
+	defer func() { dialTimeout = old }()
+	silentHost, silentPort, _ := net.SplitHostPort(silent.Addr().String())
+	p, _ := strconv.Atoi(silentPort)
+	if _, err := Dial(bastion, silentHost, uint(p), "docker", EncodePrivateKey(key)); err == nil {
+		t.Error("expected the dial to the target not speaking ssh fails")
+	}
+
+	checker.Bastion = &Bastion{Host: bastionHost, Port: bastionPort, User: "jump", PrivateKey: EncodePrivateKey(key)}
+	if results, _ := checker.CheckHosts([]CheckTarget{{Host: host, Port: port, User: "docker"}}); results[0].Category != CheckErrBastionFailed {
+		t.Errorf("expected %s, got %+v", CheckErrBastionFailed, results[0])
+	}
+}
@@ -0,0 +1,115 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/client-go/util/homedir"
+)
+
+// ReadPrivateKey reads the private key file, the path beginning with ~ is relative to the home dir.
+func ReadPrivateKey(keyPath string) ([]byte, error) {
+	if strings.HasPrefix(keyPath, "~/") {
+		keyPath = path.Join(homedir.HomeDir(), keyPath[2:])
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read private key %s", keyPath)
+	}
+	return key, nil
+}
+
+// dialTimeout the timeout of connecting to the host and the ssh handshake
+var dialTimeout = 5 * time.Second
+
+// Client runs the commands on the remote host through one connection
+type Client struct {
+	host string
+	conn *ssh.Client
+}
+
+// Dial connects to the host with the private key, through the bastion if it is not nil.
+func Dial(bastion *Bastion, host string, port uint, user string, privateKey []byte) (*Client, error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		Timeout:         dialTimeout,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	address := fmt.Sprintf("%s:%d", host, port)
+	tcpConn, err := dialTCP(bastion, address, dialTimeout)
+	if err != nil {
+		if bastion == nil {
+			return nil, errors.Wrapf(err, "connect to %s", address)
+		}
+		return nil, err
+	}
+	// the host which does not speak ssh must not hang the handshake
+	tcpConn.SetDeadline(time.Now().Add(dialTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(tcpConn, address, config)
+	if err != nil {
+		tcpConn.Close()
+		return nil, errors.Wrapf(err, "connect to %s", address)
+	}
+	tcpConn.SetDeadline(time.Time{})
+	return &Client{host: host, conn: ssh.NewClient(sshConn, chans, reqs)}, nil
+}
+
+// Run runs the command and returns its stdout.
+func (c *Client) Run(command string) (string, error) {
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return "", errors.Wrap(err, "create ssh session")
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		return "", errors.Wrapf(err, "run command on %s: %s", c.host, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// RunCommand runs the command on the remote host through the bastion if it is not nil, and returns its stdout.
+func RunCommand(bastion *Bastion, host string, port uint, user string, privateKey []byte, command string) (string, error) {
+	client, err := Dial(bastion, host, port, user, privateKey)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	return client.Run(command)
+}
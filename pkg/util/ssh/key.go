@@ -19,6 +19,7 @@
 package ssh
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -27,7 +28,9 @@ import (
 	"goodrain.com/cloud-adaptor/pkg/bcode"
 	"os"
 	"path"
-	"time"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
@@ -89,6 +92,84 @@ func MakeSSHKeyPair() (string, string, error) {
 	return string(EncodePrivateKey(pkey)), string(pub), nil
 }
 
+const (
+	// KeyTypeRSA the rsa key of 2048 bits
+	KeyTypeRSA = "rsa"
+	// KeyTypeED25519 the ed25519 key
+	KeyTypeED25519 = "ed25519"
+)
+
+// MakeKeyPair makes the key pair of the type, returns the private key in PEM and the public key in the authorized_keys format.
+func MakeKeyPair(keyType string) (string, string, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		return MakeSSHKeyPair()
+	case KeyTypeED25519:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		privateBytes, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return "", "", err
+		}
+		publicKey, err := ssh.NewPublicKey(public)
+		if err != nil {
+			return "", "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Bytes: privateBytes, Type: "PRIVATE KEY"})), string(ssh.MarshalAuthorizedKey(publicKey)), nil
+	}
+	return "", "", fmt.Errorf("unsupported key type %s", keyType)
+}
+
+// ParseKeyPair parses the private key in PEM, returns the key type, the public key in the authorized_keys format and its fingerprint.
+func ParseKeyPair(privateKey []byte) (keyType, publicKey, fingerprint string, err error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "parse private key")
+	}
+	switch signer.PublicKey().Type() {
+	case ssh.KeyAlgoRSA:
+		keyType = KeyTypeRSA
+	case ssh.KeyAlgoED25519:
+		keyType = KeyTypeED25519
+	default:
+		return "", "", "", fmt.Errorf("unsupported key type %s", signer.PublicKey().Type())
+	}
+	return keyType, string(ssh.MarshalAuthorizedKey(signer.PublicKey())), ssh.FingerprintSHA256(signer.PublicKey()), nil
+}
+
+// parseAuthorizedKey returns the public key in the authorized_keys format without the comment,
+// and its base64 part which identifies the key in authorized_keys.
+func parseAuthorizedKey(publicKey string) (line, blob string, err error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return "", "", errors.Wrap(err, "parse public key")
+	}
+	line = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	return line, strings.TrimSpace(strings.TrimPrefix(line, key.Type())), nil
+}
+
+// AuthorizeKeyCommand returns the command adding the public key to the authorized_keys of the user if it does not exist.
+func AuthorizeKeyCommand(publicKey string) (string, error) {
+	line, blob, err := parseAuthorizedKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("mkdir -p ~/.ssh && chmod 700 ~/.ssh && touch ~/.ssh/authorized_keys && chmod 600 ~/.ssh/authorized_keys && "+
+		"(grep -qF '%s' ~/.ssh/authorized_keys || echo '%s' >> ~/.ssh/authorized_keys)", blob, line), nil
+}
+
+// RevokeKeyCommand returns the command removing the public key from the authorized_keys of the user.
+func RevokeKeyCommand(publicKey string) (string, error) {
+	_, blob, err := parseAuthorizedKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("if [ -f ~/.ssh/authorized_keys ]; then grep -vF '%s' ~/.ssh/authorized_keys > ~/.ssh/authorized_keys.tmp; "+
+		"cat ~/.ssh/authorized_keys.tmp > ~/.ssh/authorized_keys; rm -f ~/.ssh/authorized_keys.tmp; fi", blob), nil
+}
+
 // GetOrMakeSSHRSA get or make ssh rsa
 func GetOrMakeSSHRSA() (string, error) {
 	home := homedir.HomeDir()
@@ -124,36 +205,24 @@ func GetOrMakeSSHRSA() (string, error) {
 	return string(pub), nil
 }
 
-// check ssh connection
-func CheckSSHConnect(host string, port uint) (bool, error) {
-	// 读取私钥文件
-	key, err := os.ReadFile("/root/.ssh/id_rsa")
-	if err != nil {
-		return false, bcode.ErrSSHFileNotFond
-	}
-
+// CheckSSHConnect check ssh connection as the user with the private key, through the bastion if it is not nil.
+// The bastion is logged in with the same key if it has no key.
+func CheckSSHConnect(host string, port uint, user string, key []byte, bastion *Bastion) (bool, error) {
 	// 使用私钥创建一个Signer
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
+	if _, err := ssh.ParsePrivateKey(key); err != nil {
 		return false, bcode.ErrParseSSH
 	}
-
-	// 配置SSH客户端参数
-	config := &ssh.ClientConfig{
-		User: "docker",
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		Timeout:         5 * time.Second,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	if bastion != nil && len(bastion.PrivateKey) == 0 {
+		withKey := *bastion
+		withKey.PrivateKey = key
+		bastion = &withKey
 	}
 
 	// 尝试连接目标主机
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), config)
-
+	client, err := Dial(bastion, host, port, user, key)
 	if err != nil {
 		return false, bcode.ErrConnect
 	}
-	defer conn.Close()
+	defer client.Close()
 	return true, nil
 }
@@ -18,7 +18,10 @@
 
 package ssh
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestGetOrMakeSSHRSA(t *testing.T) {
 	pub, err := GetOrMakeSSHRSA()
@@ -27,3 +30,47 @@ func TestGetOrMakeSSHRSA(t *testing.T) {
 	}
 	t.Log(pub)
 }
+
+func TestMakeKeyPair(t *testing.T) {
+	for _, keyType := range []string{KeyTypeRSA, KeyTypeED25519} {
+		private, pub, err := MakeKeyPair(keyType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsedType, parsedPub, fingerprint, err := ParseKeyPair([]byte(private))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsedType != keyType || parsedPub != pub || !strings.HasPrefix(fingerprint, "SHA256:") {
+			t.Fatalf("want %s key %s, got %s key %s %s", keyType, pub, parsedType, parsedPub, fingerprint)
+		}
+	}
+	if _, _, err := MakeKeyPair("dsa"); err == nil {
+		t.Fatal("make dsa key should fail")
+	}
+}
+
+func TestAuthorizeKeyCommand(t *testing.T) {
+	_, pub, err := MakeKeyPair(KeyTypeED25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.Fields(pub)[1]
+	authorize, err := AuthorizeKeyCommand(pub + " comment\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(authorize, "grep -qF '"+blob+"'") || !strings.Contains(authorize, "echo '"+strings.TrimSpace(pub)+"' >>") {
+		t.Fatalf("unexpected command %s", authorize)
+	}
+	revoke, err := RevokeKeyCommand(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(revoke, "grep -vF '"+blob+"'") {
+		t.Fatalf("unexpected command %s", revoke)
+	}
+	if _, err := AuthorizeKeyCommand("invalid"); err == nil {
+		t.Fatal("authorize the invalid key should fail")
+	}
+}
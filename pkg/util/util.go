@@ -19,6 +19,8 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"net"
 	"net/url"
@@ -42,6 +44,39 @@ func RandString(len int) string {
 	return string(bytes)
 }
 
+//passwordChars the character classes of the password, a password contains at least one character of each class
+var passwordChars = []string{"ABCDEFGHJKLMNPQRSTUVWXYZ", "abcdefghijkmnpqrstuvwxyz", "23456789", "!@#$%^&*"}
+
+//RandPassword create a random password with upper and lower case letters, digits and special characters,
+//which meets the password policies of the cloud providers.
+func RandPassword(length int) string {
+	if length < len(passwordChars) {
+		length = len(passwordChars)
+	}
+	var all string
+	password := make([]byte, length)
+	for i, chars := range passwordChars {
+		password[i] = chars[randInt(len(chars))]
+		all += chars
+	}
+	for i := len(passwordChars); i < length; i++ {
+		password[i] = all[randInt(len(all))]
+	}
+	for i := length - 1; i > 0; i-- {
+		j := randInt(i + 1)
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password)
+}
+
+func randInt(max int) int {
+	n, err := crand.Int(crand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		panic(err)
+	}
+	return int(n.Int64())
+}
+
 //GetIPByURL get ip by url
 func GetIPByURL(u string) string {
 	url, _ := url.Parse(u)
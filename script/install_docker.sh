@@ -5,8 +5,9 @@ set -o nounset
 #set -o xtrace
 
 # default version, can be overridden by cmd line options
-export DOCKER_VER=19.03.5
-export REGISTRY_MIRROR=CN
+export DOCKER_VER=${DOCKER_VER:-19.03.5}
+# CN uses the builtin mirrors, NONE uses no mirror, or the url of the registry mirror
+export REGISTRY_MIRROR=${REGISTRY_MIRROR:-CN}
 export CONSOLE=${CONSOLE:-false}
 export os_type=`cat /etc/os-release | grep "^ID=" | awk -F= '{print $2}' | tr -d [:punct:]`
 
@@ -86,9 +87,27 @@ EOF'
   "data-root": "/var/lib/docker"
 }
 EOF'
+    elif [[ "$REGISTRY_MIRROR" != NONE ]]; then
+        echo "[INFO] prepare register mirror $REGISTRY_MIRROR"
+        cat <<EOF | sudo tee /etc/docker/daemon.json >/dev/null
+{
+  "registry-mirrors": [
+    "$REGISTRY_MIRROR"
+  ],
+  "max-concurrent-downloads": 10,
+  "max-concurrent-uploads": 10,
+  "log-driver": "json-file",
+  "log-level": "warn",
+  "log-opts": {
+    "max-size": "10m",
+    "max-file": "3"
+    },
+  "data-root": "/var/lib/docker"
+}
+EOF
     else
         echo "[INFO] standard config without registry mirrors"
-        sudo 'cat >/etc/docker/daemon.json <<EOF
+        sudo bash -c 'cat >/etc/docker/daemon.json <<EOF
 {
   "max-concurrent-downloads": 10,
   "log-driver": "json-file",
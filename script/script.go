@@ -0,0 +1,35 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2020-2021 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package script bundles the scripts run on the nodes into the binary.
+package script
+
+import (
+	// embed the scripts
+	_ "embed"
+)
+
+// InstallDocker the script creating the docker user with the authorized key in SSH_RSA and installing docker
+//
+//go:embed install_docker.sh
+var InstallDocker string
+
+// InitNodeTemplate the text/template of the node initialisation script, it runs InstallDocker at last.
+//
+//go:embed init_node.sh.tmpl
+var InitNodeTemplate string